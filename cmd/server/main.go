@@ -2,32 +2,99 @@ package main
 
 import (
     "log"
+    "os"
     "order-matching-system/internal/api"
     "order-matching-system/internal/config"
     "order-matching-system/internal/database"
+    "order-matching-system/internal/journal"
+    "order-matching-system/internal/repository"
     "order-matching-system/internal/service"
+    "order-matching-system/internal/snapshot"
 )
 
 func main() {
     
     cfg := config.Load()   // Load configuration
-    db, err := database.Connect(cfg.DatabaseURL)
-    if err != nil {
-        log.Fatalf("Failed to connect to database: %v", err)
+    if err := validateStorage(cfg); err != nil {
+        log.Fatal(err)
+    }
+    
+    if len(os.Args) > 1 && os.Args[1] == "replay" {
+        if err := runReplay(cfg, os.Args[2:]); err != nil {
+            log.Fatalf("Replay failed: %v", err)
+        }
+        return
     }
-    defer db.Close()
     
-    // Run migrations
-    if err := database.RunMigrations(db); err != nil {
-        log.Fatalf("Failed to run migrations: %v", err)
+    if len(os.Args) > 1 && os.Args[1] != "migrate" {
+        log.Fatalf("Unknown command %q", os.Args[1])
     }
     
+    var store *repository.Store
+    var writeBehind *repository.WriteBehind
+    if cfg.Storage == config.StorageMemory {
+        if len(os.Args) > 1 {
+            log.Fatal("migrate needs STORAGE=database")
+        }
+        
+        memoryStore, err := newMemoryStore()
+        if err != nil {
+            log.Fatalf("Failed to initialize storage: %v", err)
+        }
+        store = memoryStore
+    } else {
+        db, err := database.Connect(cfg.Driver, cfg.DatabaseURL)
+        if err != nil {
+            log.Fatalf("Failed to connect to database: %v", err)
+        }
+        defer db.Close()
+        
+        if len(os.Args) > 1 {
+            if err := runMigrate(db, cfg.Driver, os.Args[2:]); err != nil {
+                log.Fatalf("Migration failed: %v", err)
+            }
+            return
+        }
+        
+        // Apply pending migrations, refusing a dirty or newer schema
+        if err := database.RunMigrations(db, cfg.Driver); err != nil {
+            log.Fatalf("Failed to run migrations: %v", err)
+        }
+        store = newDatabaseStore(db, cfg.Driver)
+        
+        if cfg.Persistence == config.PersistenceWriteBehind {
+            writeBehind, err = newWriteBehind(cfg, store)
+            if err != nil {
+                log.Fatalf("Failed to start write-behind persistence: %v", err)
+            }
+            defer writeBehind.Close()
+            store = writeBehind.Store()
+        }
+    }
+    
+    commandJournal, err := journal.Open(cfg.JournalPath)
+    if err != nil {
+        log.Fatalf("Failed to open journal: %v", err)
+    }
+    defer commandJournal.Close()
+    
     // Initialize matching engine
-    matchingEngine := service.NewMatchingEngine(db)
+    matchingEngine := service.NewMatchingEngine(store, commandJournal)
+    
+    snapshots, err := snapshot.NewStore(cfg.SnapshotDir, cfg.SnapshotRetain)
+    if err != nil {
+        log.Fatalf("Failed to open snapshot directory: %v", err)
+    }
+    matchingEngine.EnableSnapshots(snapshots, cfg.SnapshotInterval)
+    
+    // Restore instruments and resting orders from a snapshot or storage, then replay journaled commands, before accepting requests
+    if err := matchingEngine.Recover(); err != nil {
+        log.Fatalf("Failed to recover matching engine: %v", err)
+    }
     
     // Start matching engine
-    go matchingEngine.Start()
-    server := api.NewServer(matchingEngine, db)
+    matchingEngine.Start()
+    server := api.NewServer(matchingEngine, store, writeBehind, cfg.AdminToken, cfg.APIKeySecret)
     log.Printf("Server starting on port %s", cfg.Port)
     if err := server.Start(cfg.Port); err != nil {
         log.Fatalf("Failed to start server: %v", err)
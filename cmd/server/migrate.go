@@ -0,0 +1,72 @@
+package main
+
+import (
+    "database/sql"
+    "fmt"
+    "order-matching-system/internal/database"
+    "os"
+    "strconv"
+    "text/tabwriter"
+)
+
+const migrateUsage = "usage: server migrate up | down [steps] | status"
+
+// runMigrate implements the `migrate` subcommand.
+func runMigrate(db *sql.DB, driver string, args []string) error {
+    if len(args) == 0 {
+        return fmt.Errorf(migrateUsage)
+    }
+    
+    migrator, err := database.NewMigrator(db, driver)
+    if err != nil {
+        return err
+    }
+    
+    switch args[0] {
+    case "up":
+        applied, err := migrator.Up()
+        for _, migration := range applied {
+            fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+        }
+        if err == nil && len(applied) == 0 {
+            fmt.Println("no pending migrations")
+        }
+        return err
+        
+    case "down":
+        steps := 1
+        if len(args) > 1 {
+            if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+                return fmt.Errorf("steps must be a positive integer")
+            }
+        }
+        reverted, err := migrator.Down(steps)
+        for _, migration := range reverted {
+            fmt.Printf("reverted %d_%s\n", migration.Version, migration.Name)
+        }
+        return err
+        
+    case "status":
+        states, err := migrator.Status()
+        w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+        for _, state := range states {
+            status, appliedAt := "pending", ""
+            if state.Applied {
+                status = "applied"
+            }
+            if state.Dirty {
+                status = "dirty"
+            }
+            if state.AppliedAt != nil {
+                appliedAt = state.AppliedAt.Format("2006-01-02 15:04:05")
+            }
+            fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", state.Version, state.Name, status, appliedAt)
+        }
+        w.Flush()
+        return err
+        
+    default:
+        return fmt.Errorf(migrateUsage)
+    }
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "order-matching-system/internal/config"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/service"
+    "os"
+)
+
+const replayUsage = "usage: server replay [journal]"
+
+// runReplay implements the `replay` subcommand. It applies a journal to a fresh in-memory engine
+// and writes every trade to stdout as one JSON object per line; the same journal always produces
+// the same output.
+func runReplay(cfg *config.Config, args []string) error {
+    if len(args) > 1 {
+        return fmt.Errorf(replayUsage)
+    }
+    
+    path := cfg.JournalPath
+    if len(args) == 1 {
+        path = args[0]
+    }
+    
+    store, err := newMemoryStore()
+    if err != nil {
+        return err
+    }
+    
+    engine := service.NewMatchingEngine(store, nil)
+    if err := engine.Recover(); err != nil {
+        return err
+    }
+    
+    out := bufio.NewWriter(os.Stdout)
+    defer out.Flush()
+    
+    return engine.Replay(path, func(trade *models.Trade) error {
+        line, err := json.Marshal(trade)
+        if err != nil {
+            return err
+        }
+        
+        if _, err := out.Write(append(line, '\n')); err != nil {
+            return err
+        }
+        return nil
+    })
+}
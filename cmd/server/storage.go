@@ -0,0 +1,105 @@
+package main
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "order-matching-system/internal/config"
+    "order-matching-system/internal/database"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+// newMemoryStore returns an empty in-memory store listed with the same instruments the
+// MySQL migrations seed, so STORAGE=memory behaves like a fresh database.
+func newMemoryStore() (*repository.Store, error) {
+    store := repository.NewMemoryStore()
+    now := time.Now()
+    
+    for _, instrument := range []models.Instrument{
+        seedInstrument("BTCUSD", "BTC", "USD", "0.01", "0.00001", "1000", now),
+        seedInstrument("ETHUSD", "ETH", "USD", "0.01", "0.0001", "10000", now),
+    } {
+        if err := store.Instruments.Create(&instrument); err != nil {
+            return nil, fmt.Errorf("failed to seed %s: %w", instrument.Symbol, err)
+        }
+    }
+    
+    log.Println("Using in-memory storage, state is rebuilt from the journal on startup")
+    return store, nil
+}
+
+func seedInstrument(symbol, base, quote, tickSize, lotSize, maxQuantity string, now time.Time) models.Instrument {
+    maxQty := decimal.RequireFromString(maxQuantity)
+    return models.Instrument{
+        Symbol:            symbol,
+        BaseAsset:         base,
+        QuoteAsset:        quote,
+        Status:            models.ACTIVE,
+        PricePrecision:    2,
+        QuantityPrecision: 8,
+        TickSize:          decimal.RequireFromString(tickSize),
+        LotSize:           decimal.RequireFromString(lotSize),
+        MinQuantity:       decimal.RequireFromString(lotSize),
+        MaxQuantity:       &maxQty,
+        MinNotional:       decimal.NewFromInt(10),
+        CreatedAt:         now,
+        UpdatedAt:         now,
+    }
+}
+
+func validateStorage(cfg *config.Config) error {
+    switch cfg.Storage {
+    case config.StorageDatabase, config.StorageMemory:
+    default:
+        return fmt.Errorf("unknown STORAGE %q, expected %q or %q", cfg.Storage, config.StorageDatabase, config.StorageMemory)
+    }
+    
+    switch cfg.Persistence {
+    case config.PersistenceSync:
+    case config.PersistenceWriteBehind:
+        if cfg.Storage != config.StorageDatabase {
+            return fmt.Errorf("PERSISTENCE=%s needs STORAGE=%s", cfg.Persistence, config.StorageDatabase)
+        }
+    default:
+        return fmt.Errorf("unknown PERSISTENCE %q, expected %q or %q", cfg.Persistence, config.PersistenceSync, config.PersistenceWriteBehind)
+    }
+    
+    switch cfg.Driver {
+    case database.DriverMySQL, database.DriverSQLite, database.DriverPostgres:
+        return nil
+    default:
+        return fmt.Errorf("unknown DB_DRIVER %q, expected %q, %q or %q", cfg.Driver, database.DriverMySQL, database.DriverSQLite, database.DriverPostgres)
+    }
+}
+
+// newDatabaseStore returns the SQL-backed store for the configured driver.
+func newDatabaseStore(db *sql.DB, driver string) *repository.Store {
+    switch driver {
+    case database.DriverSQLite:
+        return repository.NewSQLiteStore(db)
+    case database.DriverPostgres:
+        return repository.NewPostgresStore(db)
+    default:
+        return repository.NewMySQLStore(db)
+    }
+}
+
+// newWriteBehind puts a write-behind store in front of the database, so the engine commits to
+// memory and a worker writes the commits in batches.
+func newWriteBehind(cfg *config.Config, store *repository.Store) (*repository.WriteBehind, error) {
+    writeBehind, err := repository.NewWriteBehind(store, repository.WriteBehindConfig{
+        FlushSize:     cfg.FlushSize,
+        FlushInterval: cfg.FlushInterval,
+        MaxPending:    cfg.MaxPendingCommits,
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    log.Printf("Writing to storage behind the engine, up to %d commits every %s", cfg.FlushSize, cfg.FlushInterval)
+    return writeBehind, nil
+}
@@ -6,20 +6,34 @@ import (
     "order-matching-system/internal/service"
     "order-matching-system/internal/utils"
     "strconv"
+    "strings"
+    "time"
     
     "github.com/gin-gonic/gin"
+    "github.com/shopspring/decimal"
 )
 
 type Handlers struct {
-    orderService *service.OrderService
+    orderService       *service.OrderService
+    instrumentService  *service.InstrumentService
+    snapshotService    *service.SnapshotService
+    persistenceService *service.PersistenceService
+    accountService     *service.AccountService
+    apiKeySecret       string
 }
 
-func NewHandlers(orderService *service.OrderService) *Handlers {
+func NewHandlers(orderService *service.OrderService, instrumentService *service.InstrumentService, snapshotService *service.SnapshotService, persistenceService *service.PersistenceService, accountService *service.AccountService, apiKeySecret string) *Handlers {
     return &Handlers{
-        orderService: orderService,
+        orderService:       orderService,
+        instrumentService:  instrumentService,
+        snapshotService:    snapshotService,
+        persistenceService: persistenceService,
+        accountService:     accountService,
+        apiKeySecret:       apiKeySecret,
     }
 }
 
+// PlaceOrder places an order for the caller's account. A body naming another account is refused.
 func (h *Handlers) PlaceOrder(c *gin.Context) {
     var req models.PlaceOrderRequest
     if err := c.ShouldBindJSON(&req); err != nil {
@@ -27,6 +41,11 @@ func (h *Handlers) PlaceOrder(c *gin.Context) {
         return
     }
     
+    if req.AccountID != "" && !h.ownAccount(c, req.AccountID) {
+        return
+    }
+    req.AccountID = authenticatedAccount(c)
+    
     order, err := h.orderService.PlaceOrder(&req)
     if err != nil {
         utils.Error(c, err)
@@ -43,23 +62,145 @@ func (h *Handlers) CancelOrder(c *gin.Context) {
         return
     }
     
-    err := h.orderService.CancelOrder(orderID)
+    if _, ok := h.ownOrder(c, orderID); !ok {
+        return
+    }
+    
+    result, err := h.orderService.CancelOrder(orderID)
     if err != nil {
         utils.Error(c, err)
         return
     }
     
-    utils.Success(c, gin.H{"message": "Order canceled successfully"})
+    utils.Success(c, result)
 }
 
-func (h *Handlers) GetOrder(c *gin.Context) {
+// ListOrders serves GET /orders, for the caller's account. Status takes a comma-separated list,
+// and open=true keeps only orders that can still trade.
+func (h *Handlers) ListOrders(c *gin.Context) {
+    if accountID := c.Query("account_id"); accountID != "" && !h.ownAccount(c, accountID) {
+        return
+    }
+    
+    query := models.OrderQuery{
+        AccountID: authenticatedAccount(c),
+        Symbol:    c.Query("symbol"),
+        Side:      models.OrderSide(c.Query("side")),
+        Type:      models.OrderType(c.Query("type")),
+    }
+    
+    for _, statuses := range c.QueryArray("status") {
+        for _, status := range strings.Split(statuses, ",") {
+            query.Statuses = append(query.Statuses, models.OrderStatus(strings.TrimSpace(status)))
+        }
+    }
+    
+    if openStr := c.Query("open"); openStr != "" {
+        open, err := strconv.ParseBool(openStr)
+        if err != nil {
+            utils.BadRequest(c, "Open must be true or false")
+            return
+        }
+        query.OpenOnly = open
+    }
+    
+    var err error
+    if query.CreatedFrom, err = queryTime(c, "created_from"); err != nil {
+        utils.BadRequest(c, "created_from must be an RFC 3339 time")
+        return
+    }
+    if query.CreatedTo, err = queryTime(c, "created_to"); err != nil {
+        utils.BadRequest(c, "created_to must be an RFC 3339 time")
+        return
+    }
+    
+    if cursor := c.Query("cursor"); cursor != "" {
+        after, err := models.ParseOrderCursor(cursor)
+        if err != nil {
+            utils.Error(c, err)
+            return
+        }
+        query.After = after
+    }
+    
+    if limitStr := c.Query("limit"); limitStr != "" {
+        limit, err := strconv.Atoi(limitStr)
+        if err != nil || limit <= 0 {
+            utils.Error(c, models.ErrInvalidPageSize)
+            return
+        }
+        query.Limit = limit
+    }
+    
+    page, err := h.orderService.ListOrders(&query)
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, page)
+}
+
+// queryTime parses an optional RFC 3339 query parameter.
+func queryTime(c *gin.Context, param string) (*time.Time, error) {
+    value := c.Query(param)
+    if value == "" {
+        return nil, nil
+    }
+    
+    parsed, err := time.Parse(time.RFC3339Nano, value)
+    if err != nil {
+        return nil, err
+    }
+    return &parsed, nil
+}
+
+func (h *Handlers) GetOrderByClientID(c *gin.Context) {
+    if !h.ownAccount(c, c.Param("accountId")) {
+        return
+    }
+    
+    order, err := h.orderService.GetOrderByClientID(c.Param("accountId"), c.Param("clientOrderId"))
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, order)
+}
+
+func (h *Handlers) CancelOrderByClientID(c *gin.Context) {
+    if !h.ownAccount(c, c.Param("accountId")) {
+        return
+    }
+    
+    result, err := h.orderService.CancelOrderByClientID(c.Param("accountId"), c.Param("clientOrderId"))
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, result)
+}
+
+func (h *Handlers) AmendOrder(c *gin.Context) {
     orderID := c.Param("orderId")
     if orderID == "" {
         utils.BadRequest(c, "Order ID is required")
         return
     }
     
-    order, err := h.orderService.GetOrder(orderID)
+    var req models.AmendOrderRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        utils.BadRequest(c, "Invalid request body")
+        return
+    }
+    
+    if _, ok := h.ownOrder(c, orderID); !ok {
+        return
+    }
+    
+    order, err := h.orderService.AmendOrder(orderID, &req)
     if err != nil {
         utils.Error(c, err)
         return
@@ -68,6 +209,41 @@ func (h *Handlers) GetOrder(c *gin.Context) {
     utils.Success(c, order)
 }
 
+func (h *Handlers) GetOrder(c *gin.Context) {
+    orderID := c.Param("orderId")
+    if orderID == "" {
+        utils.BadRequest(c, "Order ID is required")
+        return
+    }
+    
+    order, ok := h.ownOrder(c, orderID)
+    if !ok {
+        return
+    }
+    
+    utils.Success(c, order)
+}
+
+func (h *Handlers) GetOrderEvents(c *gin.Context) {
+    orderID := c.Param("orderId")
+    if orderID == "" {
+        utils.BadRequest(c, "Order ID is required")
+        return
+    }
+    
+    if _, ok := h.ownOrder(c, orderID); !ok {
+        return
+    }
+    
+    events, err := h.orderService.GetOrderEvents(orderID)
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, events)
+}
+
 func (h *Handlers) GetOrderBook(c *gin.Context) {
     symbol := c.Query("symbol")
     if symbol == "" {
@@ -75,7 +251,27 @@ func (h *Handlers) GetOrderBook(c *gin.Context) {
         return
     }
     
-    orderBook := h.orderService.GetOrderBook(symbol)
+    depth := 0
+    if depthStr := c.Query("depth"); depthStr != "" {
+        parsedDepth, err := strconv.Atoi(depthStr)
+        if err != nil || parsedDepth < 0 {
+            utils.BadRequest(c, "Depth must be a non-negative integer")
+            return
+        }
+        depth = parsedDepth
+    }
+    
+    increment := decimal.Zero
+    if incrementStr := c.Query("increment"); incrementStr != "" {
+        parsedIncrement, err := decimal.NewFromString(incrementStr)
+        if err != nil || !parsedIncrement.IsPositive() {
+            utils.BadRequest(c, "Increment must be a positive number")
+            return
+        }
+        increment = parsedIncrement
+    }
+    
+    orderBook := h.orderService.GetOrderBook(symbol, depth, increment)
     utils.Success(c, orderBook)
 }
 
@@ -102,6 +298,220 @@ func (h *Handlers) GetTrades(c *gin.Context) {
     utils.Success(c, trades)
 }
 
+// newAccount is the response to CreateAccount, the only one that carries the account's API key.
+type newAccount struct {
+    *models.Account
+    APIKey string `json:"api_key"`
+}
+
+// CreateAccount opens an account and returns it with the API key that authenticates its requests.
+func (h *Handlers) CreateAccount(c *gin.Context) {
+    if h.apiKeySecret == "" {
+        utils.Error(c, models.ErrUnauthorized)
+        return
+    }
+    
+    account, err := h.accountService.CreateAccount()
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, newAccount{Account: account, APIKey: signAccountKey(h.apiKeySecret, account.ID)})
+}
+
+func (h *Handlers) GetAccount(c *gin.Context) {
+    accountID := c.Param("accountId")
+    if accountID == "" {
+        utils.BadRequest(c, "Account ID is required")
+        return
+    }
+    
+    if !h.ownAccount(c, accountID) {
+        return
+    }
+    
+    account, err := h.accountService.GetAccount(accountID)
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, account)
+}
+
+// ownAccount reports whether accountID is the caller's, and answers FORBIDDEN if it is not.
+func (h *Handlers) ownAccount(c *gin.Context, accountID string) bool {
+    if accountID != authenticatedAccount(c) {
+        utils.Error(c, models.ErrForbidden)
+        return false
+    }
+    return true
+}
+
+// ownOrder returns an order if it belongs to the caller. Other accounts' orders are reported as
+// not found, so their IDs cannot be probed.
+func (h *Handlers) ownOrder(c *gin.Context, orderID string) (*models.Order, bool) {
+    order, err := h.orderService.GetOrder(orderID)
+    if err != nil {
+        utils.Error(c, err)
+        return nil, false
+    }
+    
+    if order.AccountID != authenticatedAccount(c) {
+        utils.Error(c, models.ErrOrderNotFound)
+        return nil, false
+    }
+    return order, true
+}
+
+func (h *Handlers) Deposit(c *gin.Context) {
+    h.transfer(c, h.accountService.Deposit)
+}
+
+func (h *Handlers) Withdraw(c *gin.Context) {
+    h.transfer(c, h.accountService.Withdraw)
+}
+
+// transfer binds a deposit or withdrawal and returns the account's balances after it.
+func (h *Handlers) transfer(c *gin.Context, move func(string, *models.TransferRequest) (*models.Account, error)) {
+    accountID := c.Param("accountId")
+    if accountID == "" {
+        utils.BadRequest(c, "Account ID is required")
+        return
+    }
+    
+    var req models.TransferRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        utils.BadRequest(c, "Invalid request body")
+        return
+    }
+    
+    account, err := move(accountID, &req)
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, account)
+}
+
+func (h *Handlers) SetAccountFees(c *gin.Context) {
+    accountID := c.Param("accountId")
+    if accountID == "" {
+        utils.BadRequest(c, "Account ID is required")
+        return
+    }
+    
+    var fees models.FeeSchedule
+    if err := c.ShouldBindJSON(&fees); err != nil {
+        utils.BadRequest(c, "Invalid request body")
+        return
+    }
+    
+    account, err := h.accountService.SetFees(accountID, &fees)
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, account)
+}
+
+func (h *Handlers) ClearAccountFees(c *gin.Context) {
+    accountID := c.Param("accountId")
+    if accountID == "" {
+        utils.BadRequest(c, "Account ID is required")
+        return
+    }
+    
+    account, err := h.accountService.ClearFees(accountID)
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, account)
+}
+
+func (h *Handlers) CreateInstrument(c *gin.Context) {
+    var req models.CreateInstrumentRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        utils.BadRequest(c, "Invalid request body")
+        return
+    }
+    
+    instrument, err := h.instrumentService.CreateInstrument(&req)
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, instrument)
+}
+
+func (h *Handlers) UpdateInstrument(c *gin.Context) {
+    symbol := c.Param("symbol")
+    if symbol == "" {
+        utils.BadRequest(c, "Symbol is required")
+        return
+    }
+    
+    var req models.UpdateInstrumentRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        utils.BadRequest(c, "Invalid request body")
+        return
+    }
+    
+    instrument, err := h.instrumentService.UpdateInstrument(symbol, &req)
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, instrument)
+}
+
+func (h *Handlers) GetInstrument(c *gin.Context) {
+    symbol := c.Param("symbol")
+    if symbol == "" {
+        utils.BadRequest(c, "Symbol is required")
+        return
+    }
+    
+    instrument, err := h.instrumentService.GetInstrument(symbol)
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, instrument)
+}
+
+func (h *Handlers) ListInstruments(c *gin.Context) {
+    utils.Success(c, h.instrumentService.ListInstruments())
+}
+
+func (h *Handlers) CreateSnapshot(c *gin.Context) {
+    info, err := h.snapshotService.CreateSnapshot()
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, info)
+}
+
+func (h *Handlers) GetPersistenceStats(c *gin.Context) {
+    stats, err := h.persistenceService.GetStats()
+    if err != nil {
+        utils.Error(c, err)
+        return
+    }
+    
+    utils.Success(c, stats)
+}
+
 func (h *Handlers) Health(c *gin.Context) {
     c.JSON(http.StatusOK, gin.H{
         "status": "healthy",
@@ -1,12 +1,22 @@
 package api
 
 import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
     "log"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/utils"
+    "strings"
     "time"
     "fmt"
     "github.com/gin-gonic/gin"
 )
 
+// accountKey is the context key under which AccountAuthMiddleware stores the caller's account ID.
+const accountKey = "account_id"
+
 func LoggerMiddleware() gin.HandlerFunc {
     return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
         return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
@@ -28,7 +38,7 @@ func CORSMiddleware() gin.HandlerFunc {
         c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
         c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
         c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-        c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+        c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
         
         if c.Request.Method == "OPTIONS" {
             c.AbortWithStatus(204)
@@ -57,4 +67,59 @@ func ErrorHandlerMiddleware() gin.HandlerFunc {
         }()
         c.Next()
     }
-}
\ No newline at end of file
+}
+
+// AdminAuthMiddleware admits requests that carry the admin token as a bearer token. Without a
+// configured token it admits nothing.
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        presented, ok := bearerToken(c)
+        if !ok || token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+            utils.Error(c, models.ErrUnauthorized)
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
+
+// AccountAuthMiddleware admits requests that carry an account's API key as a bearer token, and
+// records the account for the handlers. Without a configured secret it admits nothing.
+func AccountAuthMiddleware(secret string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        key, ok := bearerToken(c)
+        if !ok || secret == "" {
+            utils.Error(c, models.ErrUnauthorized)
+            c.Abort()
+            return
+        }
+        
+        accountID, _, found := strings.Cut(key, ".")
+        if !found || !hmac.Equal([]byte(key), []byte(signAccountKey(secret, accountID))) {
+            utils.Error(c, models.ErrUnauthorized)
+            c.Abort()
+            return
+        }
+        
+        c.Set(accountKey, accountID)
+        c.Next()
+    }
+}
+
+// signAccountKey returns an account's API key: its ID and an HMAC-SHA256 of the ID under the
+// server's secret. Keys are not stored; changing the secret revokes all of them.
+func signAccountKey(secret, accountID string) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(accountID))
+    return accountID + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// authenticatedAccount returns the account AccountAuthMiddleware admitted the request for.
+func authenticatedAccount(c *gin.Context) string {
+    return c.GetString(accountKey)
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+    token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+    return token, found && token != ""
+}
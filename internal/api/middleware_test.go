@@ -0,0 +1,170 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "order-matching-system/internal/service"
+    "strings"
+    "testing"
+    "time"
+    
+    "github.com/gin-gonic/gin"
+    "github.com/shopspring/decimal"
+)
+
+const (
+    testAdminToken = "admin-token"
+    testKeySecret  = "key-secret"
+)
+
+func newTestServer(t *testing.T, adminToken, apiKeySecret string) *Server {
+    gin.SetMode(gin.TestMode)
+    
+    store := repository.NewMemoryStore()
+    now := time.Now()
+    err := store.Instruments.Create(&models.Instrument{
+        Symbol:            "BTCUSD",
+        BaseAsset:         "BTC",
+        QuoteAsset:        "USD",
+        Status:            models.ACTIVE,
+        PricePrecision:    2,
+        QuantityPrecision: 4,
+        TickSize:          decimal.RequireFromString("0.01"),
+        LotSize:           decimal.RequireFromString("0.0001"),
+        MinQuantity:       decimal.RequireFromString("0.0001"),
+        CreatedAt:         now,
+        UpdatedAt:         now,
+    })
+    if err != nil {
+        t.Fatalf("create instrument: %v", err)
+    }
+    
+    engine := service.NewMatchingEngine(store, nil)
+    if err := engine.Recover(); err != nil {
+        t.Fatalf("recover: %v", err)
+    }
+    engine.Start()
+    
+    return NewServer(engine, store, nil, adminToken, apiKeySecret)
+}
+
+// call sends a request with an optional bearer token and returns the status and response data.
+func call(t *testing.T, server *Server, method, path, token, body string) (int, json.RawMessage) {
+    request := httptest.NewRequest(method, path, strings.NewReader(body))
+    request.Header.Set("Content-Type", "application/json")
+    if token != "" {
+        request.Header.Set("Authorization", "Bearer "+token)
+    }
+    
+    recorder := httptest.NewRecorder()
+    server.router.ServeHTTP(recorder, request)
+    
+    var response struct {
+        Data json.RawMessage `json:"data"`
+    }
+    if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+        t.Fatalf("%s %s: undecodable response %q", method, path, recorder.Body.String())
+    }
+    return recorder.Code, response.Data
+}
+
+func createAccount(t *testing.T, server *Server) (string, string) {
+    status, data := call(t, server, http.MethodPost, "/api/v1/accounts", "", "")
+    if status != http.StatusOK {
+        t.Fatalf("create account: status %d", status)
+    }
+    
+    var account struct {
+        ID     string `json:"id"`
+        APIKey string `json:"api_key"`
+    }
+    if err := json.Unmarshal(data, &account); err != nil || account.APIKey == "" {
+        t.Fatalf("create account: no API key in %s", data)
+    }
+    return account.ID, account.APIKey
+}
+
+func TestAdminRoutesNeedToken(t *testing.T) {
+    server := newTestServer(t, testAdminToken, testKeySecret)
+    alice, _ := createAccount(t, server)
+    deposit := "/api/v1/admin/accounts/" + alice + "/deposits"
+    body := `{"asset": "USD", "amount": "1000"}`
+    
+    for _, token := range []string{"", "wrong", alice} {
+        if status, _ := call(t, server, http.MethodPost, deposit, token, body); status != http.StatusUnauthorized {
+            t.Errorf("deposit with token %q: status %d, want 401", token, status)
+        }
+    }
+    if status, _ := call(t, server, http.MethodPost, deposit, testAdminToken, body); status != http.StatusOK {
+        t.Fatalf("deposit with admin token: status %d", status)
+    }
+    
+    // Without a configured token no request gets in
+    closed := newTestServer(t, "", testKeySecret)
+    if status, _ := call(t, closed, http.MethodGet, "/api/v1/admin/persistence", "", ""); status != http.StatusUnauthorized {
+        t.Errorf("admin without a configured token: status %d, want 401", status)
+    }
+}
+
+func TestOrdersBoundToAuthenticatedAccount(t *testing.T) {
+    server := newTestServer(t, testAdminToken, testKeySecret)
+    alice, aliceKey := createAccount(t, server)
+    bob, bobKey := createAccount(t, server)
+    
+    deposit := `{"asset": "USD", "amount": "1000"}`
+    if status, _ := call(t, server, http.MethodPost, "/api/v1/admin/accounts/"+alice+"/deposits", testAdminToken, deposit); status != http.StatusOK {
+        t.Fatalf("deposit: status %d", status)
+    }
+    
+    order := `{"symbol": "BTCUSD", "side": "buy", "type": "limit", "price": "100", "quantity": "1"}`
+    forged := alice + strings.TrimPrefix(bobKey, bob)
+    for _, token := range []string{"", aliceKey + "0", forged, testAdminToken} {
+        if status, _ := call(t, server, http.MethodPost, "/api/v1/orders", token, order); status != http.StatusUnauthorized {
+            t.Errorf("place with key %q: status %d, want 401", token, status)
+        }
+    }
+    
+    // Bob cannot place an order on Alice's account by naming it
+    onAlice := `{"account_id": "` + alice + `", "symbol": "BTCUSD", "side": "buy", "type": "limit", "price": "100", "quantity": "1"}`
+    if status, _ := call(t, server, http.MethodPost, "/api/v1/orders", bobKey, onAlice); status != http.StatusForbidden {
+        t.Errorf("place on another account: status %d, want 403", status)
+    }
+    
+    status, data := call(t, server, http.MethodPost, "/api/v1/orders", aliceKey, order)
+    if status != http.StatusOK {
+        t.Fatalf("place: status %d", status)
+    }
+    var placed models.Order
+    if err := json.Unmarshal(data, &placed); err != nil {
+        t.Fatalf("decode order: %v", err)
+    }
+    if placed.AccountID != alice {
+        t.Errorf("order account %q, want %q", placed.AccountID, alice)
+    }
+    
+    // Bob sees none of Alice's orders or balances
+    path := "/api/v1/orders/" + placed.ID
+    for _, method := range []string{http.MethodGet, http.MethodDelete} {
+        if status, _ := call(t, server, method, path, bobKey, ""); status != http.StatusNotFound {
+            t.Errorf("%s another account's order: status %d, want 404", method, status)
+        }
+    }
+    if status, _ := call(t, server, http.MethodGet, "/api/v1/accounts/"+alice, bobKey, ""); status != http.StatusForbidden {
+        t.Errorf("get another account: status %d, want 403", status)
+    }
+    if status, _ := call(t, server, http.MethodGet, "/api/v1/orders?account_id="+alice, bobKey, ""); status != http.StatusForbidden {
+        t.Errorf("list another account's orders: status %d, want 403", status)
+    }
+    
+    status, data = call(t, server, http.MethodGet, "/api/v1/orders", bobKey, "")
+    if status != http.StatusOK || strings.Contains(string(data), placed.ID) {
+        t.Errorf("bob's order list: status %d, %s", status, data)
+    }
+    
+    if status, _ := call(t, server, http.MethodDelete, path, aliceKey, ""); status != http.StatusOK {
+        t.Errorf("cancel own order: status %d", status)
+    }
+}
@@ -1,7 +1,7 @@
 package api
 
 import (
-    "database/sql"
+    "log"
     "order-matching-system/internal/repository"
     "order-matching-system/internal/service"
     
@@ -12,13 +12,19 @@ type Server struct {
     router       *gin.Engine
     handlers     *Handlers
     orderService *service.OrderService
+    adminToken   string
+    apiKeySecret string
 }
 
-func NewServer(matchingEngine *service.MatchingEngine, db *sql.DB) *Server {
-    orderRepo := repository.NewOrderRepository(db)
-    tradeRepo := repository.NewTradeRepository(db)
-    orderService := service.NewOrderService(orderRepo, tradeRepo, matchingEngine)
-    handlers := NewHandlers(orderService)
+// NewServer builds the HTTP API. The admin routes take adminToken as a bearer token, and the
+// account routes take an API key signed with apiKeySecret; either left empty turns its routes off.
+func NewServer(matchingEngine *service.MatchingEngine, store *repository.Store, writeBehind *repository.WriteBehind, adminToken, apiKeySecret string) *Server {
+    orderService := service.NewOrderService(store.Orders, store.Trades, store.OrderEvents, matchingEngine)
+    instrumentService := service.NewInstrumentService(matchingEngine)
+    snapshotService := service.NewSnapshotService(matchingEngine)
+    persistenceService := service.NewPersistenceService(writeBehind)
+    accountService := service.NewAccountService(store.Accounts, store.Balances, matchingEngine)
+    handlers := NewHandlers(orderService, instrumentService, snapshotService, persistenceService, accountService, apiKeySecret)
     
     router := gin.New()
     router.Use(LoggerMiddleware())
@@ -29,6 +35,15 @@ func NewServer(matchingEngine *service.MatchingEngine, db *sql.DB) *Server {
         router:       router,
         handlers:     handlers,
         orderService: orderService,
+        adminToken:   adminToken,
+        apiKeySecret: apiKeySecret,
+    }
+    
+    if adminToken == "" {
+        log.Printf("ADMIN_TOKEN is not set; the /admin routes will refuse every request")
+    }
+    if apiKeySecret == "" {
+        log.Printf("API_KEY_SECRET is not set; accounts cannot be created or used")
     }
     
     server.setupRoutes()
@@ -41,14 +56,39 @@ func (s *Server) setupRoutes() {
     // Health check
     api.GET("/health", s.handlers.Health)
     
-    // Order operations
-    api.POST("/orders", s.handlers.PlaceOrder)
-    api.DELETE("/orders/:orderId", s.handlers.CancelOrder)
-    api.GET("/orders/:orderId", s.handlers.GetOrder)
+    // Accounts; creating one issues its API key
+    api.POST("/accounts", s.handlers.CreateAccount)
+    
+    // Order and account operations, for the account whose API key the request carries
+    account := api.Group("", AccountAuthMiddleware(s.apiKeySecret))
+    account.POST("/orders", s.handlers.PlaceOrder)
+    account.GET("/orders", s.handlers.ListOrders)
+    account.PATCH("/orders/:orderId", s.handlers.AmendOrder)
+    account.DELETE("/orders/:orderId", s.handlers.CancelOrder)
+    account.GET("/orders/:orderId", s.handlers.GetOrder)
+    account.GET("/orders/:orderId/events", s.handlers.GetOrderEvents)
+    account.GET("/accounts/:accountId", s.handlers.GetAccount)
+    account.GET("/accounts/:accountId/client-orders/:clientOrderId", s.handlers.GetOrderByClientID)
+    account.DELETE("/accounts/:accountId/client-orders/:clientOrderId", s.handlers.CancelOrderByClientID)
     
     // Market data
     api.GET("/orderbook", s.handlers.GetOrderBook)
     api.GET("/trades", s.handlers.GetTrades)
+    
+    // Reference data
+    api.GET("/instruments", s.handlers.ListInstruments)
+    api.GET("/instruments/:symbol", s.handlers.GetInstrument)
+    
+    // Administration
+    admin := api.Group("/admin", AdminAuthMiddleware(s.adminToken))
+    admin.POST("/instruments", s.handlers.CreateInstrument)
+    admin.PATCH("/instruments/:symbol", s.handlers.UpdateInstrument)
+    admin.POST("/accounts/:accountId/deposits", s.handlers.Deposit)
+    admin.POST("/accounts/:accountId/withdrawals", s.handlers.Withdraw)
+    admin.PUT("/accounts/:accountId/fees", s.handlers.SetAccountFees)
+    admin.DELETE("/accounts/:accountId/fees", s.handlers.ClearAccountFees)
+    admin.POST("/snapshots", s.handlers.CreateSnapshot)
+    admin.GET("/persistence", s.handlers.GetPersistenceStats)
 }
 
 func (s *Server) Start(port string) error {
@@ -2,32 +2,99 @@ package config
 
 import (
     "fmt"
+    "log"
+    "net/url"
+    "order-matching-system/internal/database"
     "os"
+    "strconv"
+    "time"
 
     "github.com/joho/godotenv"
 )
 
+const (
+    StorageDatabase = "database"
+    StorageMemory   = "memory"
+)
+
+const (
+    PersistenceSync        = "sync"
+    PersistenceWriteBehind = "write-behind"
+)
+
 type Config struct {
     Port        string
+    Storage     string // "database" (default) or "memory"
+    Driver      string // "mysql" (default), "sqlite" or "postgres", used when Storage is "database"
     DatabaseURL string
+    JournalPath string // Write-ahead log of engine commands
+
+    AdminToken   string // Bearer token for the /admin routes; unset turns them off
+    APIKeySecret string // Signs account API keys; unset turns off the account routes
+
+    SnapshotDir      string
+    SnapshotInterval time.Duration // 0 disables periodic snapshots
+    SnapshotRetain   int           // Number of snapshot files kept
+
+    Persistence       string        // "sync" (default) commits each command, "write-behind" batches commits
+    FlushSize         int           // Most commits in one write-behind batch
+    FlushInterval     time.Duration // How often a write-behind batch short of FlushSize is written
+    MaxPendingCommits int           // Unwritten commits before the engine waits for storage
 }
 
 func Load() *Config {
     _ = godotenv.Load()
 
+    driver := getEnv("DB_DRIVER", database.DriverMySQL)
+
+    return &Config{
+        Port:        getEnv("PORT", "8080"),
+        Storage:     getEnv("STORAGE", StorageDatabase),
+        Driver:      driver,
+        DatabaseURL: databaseURL(driver),
+        JournalPath: getEnv("JOURNAL_PATH", "data/journal.log"),
+
+        AdminToken:   os.Getenv("ADMIN_TOKEN"),
+        APIKeySecret: os.Getenv("API_KEY_SECRET"),
+
+        SnapshotDir:      getEnv("SNAPSHOT_DIR", "data/snapshots"),
+        SnapshotInterval: getEnvDuration("SNAPSHOT_INTERVAL", 5*time.Minute),
+        SnapshotRetain:   getEnvInt("SNAPSHOT_RETAIN", 3),
+
+        Persistence:       getEnv("PERSISTENCE", PersistenceSync),
+        FlushSize:         getEnvInt("FLUSH_SIZE", 500),
+        FlushInterval:     getEnvDuration("FLUSH_INTERVAL", 50*time.Millisecond),
+        MaxPendingCommits: getEnvInt("MAX_PENDING_COMMITS", 20000),
+    }
+}
+
+// databaseURL builds the DSN in the form the given driver expects.
+func databaseURL(driver string) string {
+    switch driver {
+    case database.DriverSQLite:
+        // Times are written in SQLite's own text format so they sort and parse back as time.Time
+        dbPath := getEnv("DB_PATH", "ordermatching.db")
+        return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite&_txlock=immediate", dbPath)
+
+    case database.DriverPostgres:
+        dsn := url.URL{
+            Scheme:   "postgres",
+            User:     url.UserPassword(getEnv("DB_USER", "postgres"), getEnv("DB_PASS", "postgres")),
+            Host:     getEnv("DB_HOST", "localhost") + ":" + getEnv("DB_PORT", "5432"),
+            Path:     getEnv("DB_NAME", "ordermatching"),
+            RawQuery: "sslmode=" + url.QueryEscape(getEnv("DB_SSLMODE", "disable")),
+        }
+        return dsn.String()
+    }
+
     dbUser := getEnv("DB_USER", "root")
     dbPass := getEnv("DB_PASS", "root")
     dbHost := getEnv("DB_HOST", "localhost")
     dbPort := getEnv("DB_PORT", "3306")
     dbName := getEnv("DB_NAME", "ordermatching")
 
-    dbURL := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
+    return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
         dbUser, dbPass, dbHost, dbPort, dbName)
-
-    return &Config{
-        Port:        getEnv("PORT", "8080"),
-        DatabaseURL: dbURL,
-    }
 }
 
 func getEnv(key, defaultValue string) string {
@@ -36,3 +103,31 @@ func getEnv(key, defaultValue string) string {
     }
     return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+
+    duration, err := time.ParseDuration(value)
+    if err != nil {
+        log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+        return defaultValue
+    }
+    return duration
+}
+
+func getEnvInt(key string, defaultValue int) int {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+
+    number, err := strconv.Atoi(value)
+    if err != nil {
+        log.Printf("Invalid %s %q, using %d", key, value, defaultValue)
+        return defaultValue
+    }
+    return number
+}
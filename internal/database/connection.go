@@ -5,10 +5,13 @@ import (
     "fmt"
     
     _ "github.com/go-sql-driver/mysql"
+    _ "github.com/jackc/pgx/v5/stdlib"
+    _ "modernc.org/sqlite"
 )
 
-func Connect(databaseURL string) (*sql.DB, error) {
-    db, err := sql.Open("mysql", databaseURL)
+// Connect opens a pool for the given driver: "mysql", "sqlite" or "postgres".
+func Connect(driver, databaseURL string) (*sql.DB, error) {
+    db, err := sql.Open(sqlDriverName(driver), databaseURL)
     if err != nil {
         return nil, fmt.Errorf("failed to open database: %w", err)
     }
@@ -0,0 +1,42 @@
+package database
+
+import (
+    "strconv"
+    "strings"
+)
+
+// Driver names used in configuration, each with its own migration set.
+const (
+    DriverMySQL    = "mysql"
+    DriverSQLite   = "sqlite"
+    DriverPostgres = "postgres"
+)
+
+// sqlDriverName maps a configured driver to the name its database/sql driver registers.
+func sqlDriverName(driver string) string {
+    if driver == DriverPostgres {
+        return "pgx"
+    }
+    return driver
+}
+
+// Rebind rewrites the ? placeholders the queries are written with into the driver's own
+// style. Only Postgres differs ($1, $2, ...). Queries must not contain a literal ?.
+func Rebind(driver, query string) string {
+    if driver != DriverPostgres || !strings.Contains(query, "?") {
+        return query
+    }
+    
+    var rebound strings.Builder
+    n := 0
+    for _, r := range query {
+        if r == '?' {
+            n++
+            rebound.WriteString("$")
+            rebound.WriteString(strconv.Itoa(n))
+            continue
+        }
+        rebound.WriteRune(r)
+    }
+    return rebound.String()
+}
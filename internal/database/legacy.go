@@ -0,0 +1,201 @@
+package database
+
+import (
+    "errors"
+    "fmt"
+    "log"
+)
+
+var ErrLegacySchema = errors.New("database has tables from before versioned migrations that do not match them")
+
+// legacyTable is a table the startup DDL created before versioned migrations. The DDL grew over
+// time, so a table may hold anything from its first shape to the migration's. It is adopted as
+// the migration that creates the same table: once the first shape's columns check out, the
+// missing columns and indexes are added and upgrade brings the rest, such as enum values, up to
+// the migration.
+type legacyTable struct {
+    version int64
+    table   string
+    columns string // Every column the first startup DDL created
+    added   []legacyObject
+    indexes []legacyObject
+    upgrade []string // Run after added and indexes; must be safe on a table already at the migration
+}
+
+// legacyObject is a column or index that is created, by statement, only when the table lacks name.
+type legacyObject struct {
+    name      string
+    statement string
+}
+
+// legacySchemas lists, per driver, the tables the old startup DDL created, in migration order.
+// Only MySQL was supported then.
+var legacySchemas = map[string][]legacyTable{
+    DriverMySQL: {
+        {
+            version: 1,
+            table:   "symbols",
+            columns: `symbol, base_asset, quote_asset, status, price_precision, quantity_precision, created_at, updated_at`,
+            added: []legacyObject{
+                {"tick_size", `ALTER TABLE symbols ADD COLUMN tick_size DECIMAL(15,8) NOT NULL DEFAULT 0.00000001`},
+                {"lot_size", `ALTER TABLE symbols ADD COLUMN lot_size DECIMAL(15,8) NOT NULL DEFAULT 0.00000001`},
+                {"min_quantity", `ALTER TABLE symbols ADD COLUMN min_quantity DECIMAL(15,8) NOT NULL DEFAULT 0.00000001`},
+                {"max_quantity", `ALTER TABLE symbols ADD COLUMN max_quantity DECIMAL(15,8) NULL`},
+                {"min_notional", `ALTER TABLE symbols ADD COLUMN min_notional DECIMAL(20,8) NOT NULL DEFAULT 0`},
+            },
+            indexes: []legacyObject{
+                {"idx_status", `CREATE INDEX idx_status ON symbols (status)`},
+            },
+        },
+        {
+            version: 2,
+            table:   "orders",
+            columns: `id, symbol, side, type, price, initial_quantity, remaining_quantity, status, created_at, updated_at`,
+            added: []legacyObject{
+                {"stop_price", `ALTER TABLE orders ADD COLUMN stop_price DECIMAL(15,8) NULL`},
+                {"display_quantity", `ALTER TABLE orders ADD COLUMN display_quantity DECIMAL(15,8) NULL`},
+                {"visible_quantity", `ALTER TABLE orders ADD COLUMN visible_quantity DECIMAL(15,8) NULL`},
+                {"time_in_force", `ALTER TABLE orders ADD COLUMN time_in_force ENUM('gtc', 'ioc', 'fok', 'gtd', 'day') NOT NULL DEFAULT 'gtc'`},
+                {"expires_at", `ALTER TABLE orders ADD COLUMN expires_at TIMESTAMP NULL`},
+                {"post_only", `ALTER TABLE orders ADD COLUMN post_only BOOLEAN NOT NULL DEFAULT FALSE`},
+                {"post_only_action", `ALTER TABLE orders ADD COLUMN post_only_action ENUM('reject', 'reprice') NULL`},
+                {"reject_reason", `ALTER TABLE orders ADD COLUMN reject_reason VARCHAR(255) NULL`},
+                {"triggered_at", `ALTER TABLE orders ADD COLUMN triggered_at TIMESTAMP NULL`},
+                {"priority_at", `ALTER TABLE orders ADD COLUMN priority_at TIMESTAMP(6) NULL`},
+            },
+            indexes: []legacyObject{
+                {"idx_symbol_side_status", `CREATE INDEX idx_symbol_side_status ON orders (symbol, side, status)`},
+                {"idx_price_created", `CREATE INDEX idx_price_created ON orders (price, created_at)`},
+                {"idx_status_expires", `CREATE INDEX idx_status_expires ON orders (status, expires_at)`},
+                {"idx_status", `CREATE INDEX idx_status ON orders (status)`},
+                {"idx_created_at", `CREATE INDEX idx_created_at ON orders (created_at)`},
+            },
+            // The foreign key to symbols is left out: old orders may name symbols that were never listed
+            upgrade: []string{
+                `ALTER TABLE orders MODIFY COLUMN type ENUM('limit', 'market', 'stop_market', 'stop_limit') NOT NULL`,
+                `ALTER TABLE orders MODIFY COLUMN status ENUM('open', 'filled', 'canceled', 'partial', 'untriggered', 'expired', 'rejected') NOT NULL DEFAULT 'open'`,
+                // Orders from before priority was tracked keep the time priority of their creation
+                `UPDATE orders SET priority_at = created_at WHERE priority_at IS NULL`,
+                `ALTER TABLE orders MODIFY COLUMN priority_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)`,
+            },
+        },
+        {
+            version: 3,
+            table:   "trades",
+            columns: `id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at`,
+            indexes: []legacyObject{
+                {"idx_symbol_executed", `CREATE INDEX idx_symbol_executed ON trades (symbol, executed_at)`},
+                {"idx_buy_order", `CREATE INDEX idx_buy_order ON trades (buy_order_id)`},
+                {"idx_sell_order", `CREATE INDEX idx_sell_order ON trades (sell_order_id)`},
+            },
+        },
+    },
+}
+
+// adoptLegacySchema records the migrations whose tables the old startup DDL already created as
+// applied, after bringing each table up to its migration, instead of letting their CREATE TABLE
+// fail on a database that has no migration history. Tables the old DDL had not created yet, such
+// as symbols before it was introduced, are left to their migrations, and every later migration
+// runs as usual. It returns the adopted versions.
+func (m *Migrator) adoptLegacySchema() ([]int64, error) {
+    var adopted []int64
+    for _, legacy := range legacySchemas[m.driver] {
+        exists, err := m.tableExists(legacy.table)
+        if err != nil {
+            return adopted, err
+        }
+        if !exists {
+            continue
+        }
+        
+        // Fails on any column the old table lacks
+        rows, err := m.db.Query(`SELECT ` + legacy.columns + ` FROM ` + legacy.table + ` WHERE 1 = 0`)
+        if err != nil {
+            return adopted, fmt.Errorf("%w: %s: %v", ErrLegacySchema, legacy.table, err)
+        }
+        rows.Close()
+        
+        migration, found := m.migration(legacy.version)
+        if !found {
+            return adopted, fmt.Errorf("no migration %d to adopt %s as", legacy.version, legacy.table)
+        }
+        upgrade, err := m.legacyUpgrade(legacy)
+        if err != nil {
+            return adopted, err
+        }
+        log.Printf("Adopting existing table %s as migration %d_%s", legacy.table, migration.Version, migration.Name)
+        if err := m.run(migration, upgrade); err != nil {
+            return adopted, err
+        }
+        adopted = append(adopted, legacy.version)
+    }
+    return adopted, nil
+}
+
+// legacyUpgrade returns the statements that take the table from whichever shape the old DDL
+// left it in to its migration.
+func (m *Migrator) legacyUpgrade(legacy legacyTable) ([]string, error) {
+    var statements []string
+    for _, column := range legacy.added {
+        exists, err := m.columnExists(legacy.table, column.name)
+        if err != nil {
+            return nil, err
+        }
+        if !exists {
+            statements = append(statements, column.statement)
+        }
+    }
+    for _, index := range legacy.indexes {
+        exists, err := m.indexExists(legacy.table, index.name)
+        if err != nil {
+            return nil, err
+        }
+        if !exists {
+            statements = append(statements, index.statement)
+        }
+    }
+    return append(statements, legacy.upgrade...), nil
+}
+
+func (m *Migrator) migration(version int64) (Migration, bool) {
+    for _, migration := range m.migrations {
+        if migration.Version == version {
+            return migration, true
+        }
+    }
+    return Migration{}, false
+}
+
+// The lookups below are for MySQL, the only driver with legacy tables, apart from tableExists,
+// which every driver uses.
+
+func (m *Migrator) tableExists(table string) (bool, error) {
+    var query string
+    switch m.driver {
+    case DriverSQLite:
+        query = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+    case DriverPostgres:
+        query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`
+    default:
+        query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
+    }
+    return m.found(query, "table "+table, table)
+}
+
+func (m *Migrator) columnExists(table, column string) (bool, error) {
+    return m.found(`SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`,
+        "column "+table+"."+column, table, column)
+}
+
+func (m *Migrator) indexExists(table, index string) (bool, error) {
+    return m.found(`SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`,
+        "index "+index+" on "+table, table, index)
+}
+
+func (m *Migrator) found(query, what string, args ...interface{}) (bool, error) {
+    var count int
+    if err := m.db.QueryRow(Rebind(m.driver, query), args...).Scan(&count); err != nil {
+        return false, fmt.Errorf("failed to look for %s: %w", what, err)
+    }
+    return count > 0, nil
+}
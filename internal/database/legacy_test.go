@@ -0,0 +1,252 @@
+package database_test
+
+import (
+    "database/sql"
+    "fmt"
+    "order-matching-system/internal/database"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "os"
+    "testing"
+    "time"
+    
+    "github.com/go-sql-driver/mysql"
+    "github.com/shopspring/decimal"
+)
+
+// legacyDDL holds the tables the old startup code created, from its first version to its last,
+// and a few rows in them. Each creates orders and trades; the last also has symbols.
+// scripts/schema.sql had the first version's tables with a few more indexes.
+var legacyDDL = map[string][]string{
+    "baseline": {
+        `CREATE TABLE IF NOT EXISTS orders (
+            id VARCHAR(36) PRIMARY KEY,
+            symbol VARCHAR(10) NOT NULL,
+            side ENUM('buy', 'sell') NOT NULL,
+            type ENUM('limit', 'market') NOT NULL,
+            price DECIMAL(15,8) NULL,
+            initial_quantity DECIMAL(15,8) NOT NULL,
+            remaining_quantity DECIMAL(15,8) NOT NULL,
+            status ENUM('open', 'filled', 'canceled', 'partial') NOT NULL DEFAULT 'open',
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+            INDEX idx_symbol_side_status (symbol, side, status),
+            INDEX idx_price_created (price, created_at),
+            INDEX idx_status (status)
+        )`,
+        legacyTrades(""),
+        legacyOrder("bid", "buy", 2, 1, "partial", "2024-03-01 12:00:00", false),
+        legacyOrder("ask", "sell", 1, 0, "filled", "2024-03-01 12:00:05", false),
+        legacyTrade,
+    },
+    "schema.sql": {
+        `CREATE TABLE IF NOT EXISTS orders (
+            id VARCHAR(36) PRIMARY KEY,
+            symbol VARCHAR(10) NOT NULL,
+            side ENUM('buy', 'sell') NOT NULL,
+            type ENUM('limit', 'market') NOT NULL,
+            price DECIMAL(15,8) NULL,
+            initial_quantity DECIMAL(15,8) NOT NULL,
+            remaining_quantity DECIMAL(15,8) NOT NULL,
+            status ENUM('open', 'filled', 'canceled', 'partial') NOT NULL DEFAULT 'open',
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+            INDEX idx_symbol_side_status (symbol, side, status),
+            INDEX idx_price_created (price, created_at),
+            INDEX idx_status (status),
+            INDEX idx_created_at (created_at)
+        )`,
+        legacyTrades(`,
+            INDEX idx_buy_order (buy_order_id),
+            INDEX idx_sell_order (sell_order_id)`),
+        legacyOrder("bid", "buy", 2, 1, "partial", "2024-03-01 12:00:00", false),
+        legacyOrder("ask", "sell", 1, 0, "filled", "2024-03-01 12:00:05", false),
+        legacyTrade,
+    },
+    "trading rules": {
+        `CREATE TABLE IF NOT EXISTS symbols (
+            symbol VARCHAR(10) PRIMARY KEY,
+            base_asset VARCHAR(10) NOT NULL,
+            quote_asset VARCHAR(10) NOT NULL,
+            status ENUM('active', 'halted', 'delisted') NOT NULL DEFAULT 'active',
+            price_precision TINYINT UNSIGNED NOT NULL DEFAULT 8,
+            quantity_precision TINYINT UNSIGNED NOT NULL DEFAULT 8,
+            tick_size DECIMAL(15,8) NOT NULL DEFAULT 0.00000001,
+            lot_size DECIMAL(15,8) NOT NULL DEFAULT 0.00000001,
+            min_quantity DECIMAL(15,8) NOT NULL DEFAULT 0.00000001,
+            max_quantity DECIMAL(15,8) NULL,
+            min_notional DECIMAL(20,8) NOT NULL DEFAULT 0,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+            INDEX idx_status (status)
+        )`,
+        `INSERT IGNORE INTO symbols (symbol, base_asset, quote_asset, price_precision, quantity_precision, tick_size, lot_size, min_quantity, max_quantity, min_notional) VALUES
+            ('BTCUSD', 'BTC', 'USD', 2, 8, 0.01, 0.00001, 0.00001, 1000, 10),
+            ('ETHUSD', 'ETH', 'USD', 2, 8, 0.01, 0.0001, 0.0001, 10000, 10)`,
+        `CREATE TABLE IF NOT EXISTS orders (
+            id VARCHAR(36) PRIMARY KEY,
+            symbol VARCHAR(10) NOT NULL,
+            side ENUM('buy', 'sell') NOT NULL,
+            type ENUM('limit', 'market', 'stop_market', 'stop_limit') NOT NULL,
+            price DECIMAL(15,8) NULL,
+            stop_price DECIMAL(15,8) NULL,
+            initial_quantity DECIMAL(15,8) NOT NULL,
+            remaining_quantity DECIMAL(15,8) NOT NULL,
+            display_quantity DECIMAL(15,8) NULL,
+            visible_quantity DECIMAL(15,8) NULL,
+            status ENUM('open', 'filled', 'canceled', 'partial', 'untriggered', 'expired', 'rejected') NOT NULL DEFAULT 'open',
+            time_in_force ENUM('gtc', 'ioc', 'fok', 'gtd', 'day') NOT NULL DEFAULT 'gtc',
+            expires_at TIMESTAMP NULL,
+            post_only BOOLEAN NOT NULL DEFAULT FALSE,
+            post_only_action ENUM('reject', 'reprice') NULL,
+            reject_reason VARCHAR(255) NULL,
+            triggered_at TIMESTAMP NULL,
+            priority_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+            INDEX idx_symbol_side_status (symbol, side, status),
+            INDEX idx_price_created (price, created_at),
+            INDEX idx_status_expires (status, expires_at),
+            INDEX idx_status (status),
+            FOREIGN KEY (symbol) REFERENCES symbols(symbol)
+        )`,
+        legacyTrades(""),
+        legacyOrder("bid", "buy", 2, 1, "partial", "2024-03-01 12:00:00", true),
+        legacyOrder("ask", "sell", 1, 0, "filled", "2024-03-01 12:00:05", true),
+        legacyTrade,
+    },
+}
+
+func legacyTrades(indexes string) string {
+    return `CREATE TABLE IF NOT EXISTS trades (
+            id VARCHAR(36) PRIMARY KEY,
+            symbol VARCHAR(10) NOT NULL,
+            buy_order_id VARCHAR(36) NOT NULL,
+            sell_order_id VARCHAR(36) NOT NULL,
+            price DECIMAL(15,8) NOT NULL,
+            quantity DECIMAL(15,8) NOT NULL,
+            executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (buy_order_id) REFERENCES orders(id),
+            FOREIGN KEY (sell_order_id) REFERENCES orders(id),
+            INDEX idx_symbol_executed (symbol, executed_at)` + indexes + `
+        )`
+}
+
+// legacyOrder inserts a BTCUSD limit order at 100 the way the old code did, which set priority_at
+// once the table had it.
+func legacyOrder(id, side string, initial, remaining int, status, createdAt string, priority bool) string {
+    columns, values := "", ""
+    if priority {
+        columns, values = ", priority_at", fmt.Sprintf(", '%s'", createdAt)
+    }
+    return fmt.Sprintf(`INSERT INTO orders (id, symbol, side, type, price, initial_quantity, remaining_quantity, status, created_at, updated_at%s)
+        VALUES ('%s', 'BTCUSD', '%s', 'limit', 100, %d, %d, '%s', '%s', '2024-03-01 12:00:05'%s)`,
+        columns, id, side, initial, remaining, status, createdAt, values)
+}
+
+const legacyTrade = `INSERT INTO trades (id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at)
+    VALUES ('trade', 'BTCUSD', 'bid', 'ask', 100, 1, '2024-03-01 12:00:05')`
+
+// TestAdoptLegacySchema creates each shape of the old startup tables, migrates it, and checks that every migration is recorded and that the old rows and new kinds
+// of order read and write through the store. It runs when TEST_MYSQL_DSN points at a scratch
+// database, and works in a database of its own beside it.
+func TestAdoptLegacySchema(t *testing.T) {
+    dsn := os.Getenv("TEST_MYSQL_DSN")
+    if dsn == "" {
+        t.Skip("TEST_MYSQL_DSN not set")
+    }
+    
+    for name, ddl := range legacyDDL {
+        ddl := ddl
+        t.Run(name, func(t *testing.T) {
+            db := scratchDatabase(t, dsn)
+            for _, statement := range ddl {
+                if _, err := db.Exec(statement); err != nil {
+                    t.Fatalf("old tables: %v", err)
+                }
+            }
+            if err := database.RunMigrations(db, database.DriverMySQL); err != nil {
+                t.Fatalf("migrate: %v", err)
+            }
+            migrator, err := database.NewMigrator(db, database.DriverMySQL)
+            if err != nil {
+                t.Fatalf("migrator: %v", err)
+            }
+            states, err := migrator.Status()
+            if err != nil {
+                t.Fatalf("status: %v", err)
+            }
+            for _, state := range states {
+                if !state.Applied || state.Dirty {
+                    t.Errorf("migration %d_%s: applied %v, dirty %v", state.Version, state.Name, state.Applied, state.Dirty)
+                }
+            }
+            
+            store := repository.NewMySQLStore(db)
+            bid, err := store.Orders.GetByID("bid")
+            if err != nil {
+                t.Fatalf("old order: %v", err)
+            }
+            createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+            if bid.Status != models.PARTIAL || bid.TimeInForce != models.GTC || !bid.RemainingQuantity.Equal(decimal.NewFromInt(1)) ||
+                !bid.CreatedAt.Equal(createdAt) || !bid.PriorityAt.Equal(createdAt) {
+                t.Errorf("old order reads back as %+v", bid)
+            }
+            trades, err := store.Trades.GetBySymbol("BTCUSD", 10)
+            if err != nil || len(trades) != 1 || trades[0].BuyOrderID != "bid" {
+                t.Errorf("old trades = %+v, %v", trades, err)
+            }
+            
+            stopPrice := decimal.NewFromInt(90)
+            stop := &models.Order{
+                ID:                "stop",
+                Symbol:            "BTCUSD",
+                Side:              models.SELL,
+                Type:              models.STOP_MARKET,
+                StopPrice:         &stopPrice,
+                InitialQuantity:   decimal.NewFromInt(1),
+                RemainingQuantity: decimal.NewFromInt(1),
+                Status:            models.UNTRIGGERED,
+                TimeInForce:       models.GTC,
+                PriorityAt:        createdAt,
+                CreatedAt:         createdAt,
+                UpdatedAt:         createdAt,
+            }
+            if err := store.Orders.Create(stop); err != nil {
+                t.Fatalf("create a stop order: %v", err)
+            }
+            if got, err := store.Orders.GetByID("stop"); err != nil || got.Type != models.STOP_MARKET || got.Status != models.UNTRIGGERED {
+                t.Errorf("stop order reads back as %+v, %v", got, err)
+            }
+        })
+    }
+}
+
+// scratchDatabase creates an empty database named after the one dsn points at and connects to it.
+func scratchDatabase(t *testing.T, dsn string) *sql.DB {
+    config, err := mysql.ParseDSN(dsn)
+    if err != nil {
+        t.Fatalf("parse TEST_MYSQL_DSN: %v", err)
+    }
+    admin, err := database.Connect(database.DriverMySQL, dsn)
+    if err != nil {
+        t.Fatalf("connect: %v", err)
+    }
+    t.Cleanup(func() { admin.Close() })
+    
+    config.DBName += "_legacy"
+    name := "`" + config.DBName + "`"
+    for _, statement := range []string{"DROP DATABASE IF EXISTS " + name, "CREATE DATABASE " + name} {
+        if _, err := admin.Exec(statement); err != nil {
+            t.Fatalf("%s: %v", statement, err)
+        }
+    }
+    t.Cleanup(func() { admin.Exec("DROP DATABASE IF EXISTS " + name) })
+    
+    db, err := database.Connect(database.DriverMySQL, config.FormatDSN())
+    if err != nil {
+        t.Fatalf("connect to %s: %v", config.DBName, err)
+    }
+    t.Cleanup(func() { db.Close() })
+    return db
+}
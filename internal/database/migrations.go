@@ -2,47 +2,357 @@ package database
 
 import (
     "database/sql"
+    "embed"
+    "errors"
     "fmt"
+    "io/fs"
+    "log"
+    "path"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
 )
 
-func RunMigrations(db *sql.DB) error {
-    queries := []string{
-		`DROP TABLE IF EXISTS trades`,      // drop trades first due to FK
-    `DROP TABLE IF EXISTS orders`,      // then drop orders
-        `CREATE TABLE IF NOT EXISTS orders (
-            id VARCHAR(36) PRIMARY KEY,
-            symbol VARCHAR(10) NOT NULL,
-            side ENUM('buy', 'sell') NOT NULL,
-            type ENUM('limit', 'market') NOT NULL,
-            price DECIMAL(15,8) NULL,
-            initial_quantity DECIMAL(15,8) NOT NULL,
-            remaining_quantity DECIMAL(15,8) NOT NULL,
-            status ENUM('open', 'filled', 'canceled', 'partial') NOT NULL DEFAULT 'open',
-            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-            INDEX idx_symbol_side_status (symbol, side, status),
-            INDEX idx_price_created (price, created_at),
-            INDEX idx_status (status)
-        )`,
-        `CREATE TABLE IF NOT EXISTS trades (
-            id VARCHAR(36) PRIMARY KEY,
-            symbol VARCHAR(10) NOT NULL,
-            buy_order_id VARCHAR(36) NOT NULL,
-            sell_order_id VARCHAR(36) NOT NULL,
-            price DECIMAL(15,8) NOT NULL,
-            quantity DECIMAL(15,8) NOT NULL,
-            executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-            FOREIGN KEY (buy_order_id) REFERENCES orders(id),
-            FOREIGN KEY (sell_order_id) REFERENCES orders(id),
-            INDEX idx_symbol_executed (symbol, executed_at)
-        )`,
-    }
-
-    for _, query := range queries {
-        if _, err := db.Exec(query); err != nil {
-            return fmt.Errorf("failed to execute migration: %w", err)
+// Each driver has its own migration set under migrations/<driver>, kept at the same versions.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var (
+    ErrDirtySchema = errors.New("database schema is dirty: a migration failed part way, repair it by hand and clear the row in schema_migrations")
+    ErrSchemaNewer = errors.New("database schema is newer than this binary")
+)
+
+// migrationName matches files such as 0002_create_orders.up.sql.
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+    Version int64
+    Name    string
+    Up      string
+    Down    string
+}
+
+// MigrationState is one line of `migrate status`.
+type MigrationState struct {
+    Version   int64
+    Name      string
+    Applied   bool
+    Dirty     bool
+    AppliedAt *time.Time
+}
+
+type appliedMigration struct {
+    dirty     bool
+    appliedAt sql.NullTime
+}
+
+// Migrator applies the ordered, embedded migrations and records them in schema_migrations.
+// A version is marked dirty before its statements run and clean once they all succeed, so a
+// failure part way through (MySQL DDL cannot be rolled back) is detected on the next run.
+type Migrator struct {
+    db         *sql.DB
+    driver     string
+    migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, driver string) (*Migrator, error) {
+    migrations, err := loadMigrations(migrationFiles, path.Join("migrations", driver))
+    if err != nil {
+        return nil, err
+    }
+    
+    return &Migrator{db: db, driver: driver, migrations: migrations}, nil
+}
+
+// RunMigrations applies every pending migration and refuses to continue on a dirty or newer schema.
+func RunMigrations(db *sql.DB, driver string) error {
+    migrator, err := NewMigrator(db, driver)
+    if err != nil {
+        return err
+    }
+    
+    applied, err := migrator.Up()
+    if err != nil {
+        return err
+    }
+    
+    if len(applied) == 0 {
+        log.Println("Database schema is up to date")
+    }
+    return nil
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+    entries, err := fs.ReadDir(fsys, dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read migrations: %w", err)
+    }
+    
+    byVersion := make(map[int64]*Migration)
+    for _, entry := range entries {
+        match := migrationName.FindStringSubmatch(entry.Name())
+        if match == nil {
+            continue
+        }
+        
+        version, err := strconv.ParseInt(match[1], 10, 64)
+        if err != nil {
+            return nil, fmt.Errorf("invalid migration version %q: %w", entry.Name(), err)
+        }
+        
+        content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+        if err != nil {
+            return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+        }
+        
+        migration, exists := byVersion[version]
+        if !exists {
+            migration = &Migration{Version: version, Name: match[2]}
+            byVersion[version] = migration
+        } else if migration.Name != match[2] {
+            return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+        }
+        
+        if match[3] == "up" {
+            migration.Up = string(content)
+        } else {
+            migration.Down = string(content)
+        }
+    }
+    
+    migrations := make([]Migration, 0, len(byVersion))
+    for _, migration := range byVersion {
+        if migration.Up == "" || migration.Down == "" {
+            return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+        }
+        migrations = append(migrations, *migration)
+    }
+    
+    sort.Slice(migrations, func(i, j int) bool {
+        return migrations[i].Version < migrations[j].Version
+    })
+    return migrations, nil
+}
+
+func (m *Migrator) ensureTable() error {
+    _, err := m.db.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version BIGINT PRIMARY KEY,
+            name VARCHAR(255) NOT NULL,
+            dirty BOOLEAN NOT NULL DEFAULT FALSE,
+            applied_at TIMESTAMP NULL
+        )
+    `)
+    if err != nil {
+        return fmt.Errorf("failed to create schema_migrations: %w", err)
+    }
+    return nil
+}
+
+func (m *Migrator) applied() (map[int64]appliedMigration, error) {
+    if err := m.ensureTable(); err != nil {
+        return nil, err
+    }
+    
+    rows, err := m.db.Query(`SELECT version, dirty, applied_at FROM schema_migrations`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    applied := make(map[int64]appliedMigration)
+    for rows.Next() {
+        var version int64
+        var state appliedMigration
+        if err := rows.Scan(&version, &state.dirty, &state.appliedAt); err != nil {
+            return nil, err
+        }
+        applied[version] = state
+    }
+    
+    return applied, rows.Err()
+}
+
+// check refuses to touch a schema that is dirty or was migrated by a newer binary.
+func (m *Migrator) check(applied map[int64]appliedMigration) error {
+    latest := int64(0)
+    if len(m.migrations) > 0 {
+        latest = m.migrations[len(m.migrations)-1].Version
+    }
+    
+    for version, state := range applied {
+        if state.dirty {
+            return fmt.Errorf("%w (version %d)", ErrDirtySchema, version)
+        }
+        if version > latest {
+            return fmt.Errorf("%w (database at version %d, binary knows up to %d)", ErrSchemaNewer, version, latest)
+        }
+    }
+    return nil
+}
+
+// Up applies every pending migration in version order and returns the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+    applied, err := m.applied()
+    if err != nil {
+        return nil, err
+    }
+    
+    if err := m.check(applied); err != nil {
+        return nil, err
+    }
+    
+    if len(applied) == 0 {
+        adopted, err := m.adoptLegacySchema()
+        if err != nil {
+            return nil, err
+        }
+        for _, version := range adopted {
+            applied[version] = appliedMigration{}
         }
     }
     
+    var done []Migration
+    for _, migration := range m.migrations {
+        if _, exists := applied[migration.Version]; exists {
+            continue
+        }
+        
+        if err := m.apply(migration); err != nil {
+            return done, err
+        }
+        done = append(done, migration)
+    }
+    
+    return done, nil
+}
+
+// Down reverts the most recently applied migrations, newest first.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+    applied, err := m.applied()
+    if err != nil {
+        return nil, err
+    }
+    
+    if err := m.check(applied); err != nil {
+        return nil, err
+    }
+    
+    var done []Migration
+    for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+        migration := m.migrations[i]
+        if _, exists := applied[migration.Version]; !exists {
+            continue
+        }
+        
+        if err := m.revert(migration); err != nil {
+            return done, err
+        }
+        done = append(done, migration)
+    }
+    
+    return done, nil
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status() ([]MigrationState, error) {
+    applied, err := m.applied()
+    if err != nil {
+        return nil, err
+    }
+    
+    states := make([]MigrationState, 0, len(m.migrations))
+    for _, migration := range m.migrations {
+        state := MigrationState{Version: migration.Version, Name: migration.Name}
+        if record, exists := applied[migration.Version]; exists {
+            state.Applied = true
+            state.Dirty = record.dirty
+            if record.appliedAt.Valid {
+                state.AppliedAt = &record.appliedAt.Time
+            }
+        }
+        states = append(states, state)
+    }
+    
+    return states, m.check(applied)
+}
+
+func (m *Migrator) apply(migration Migration) error {
+    log.Printf("Applying migration %d_%s", migration.Version, migration.Name)
+    return m.run(migration, splitStatements(migration.Up))
+}
+
+// run records the migration as dirty, executes the statements and records it as applied.
+func (m *Migrator) run(migration Migration, statements []string) error {
+    if _, err := m.exec(`INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, TRUE)`, migration.Version, migration.Name); err != nil {
+        return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+    }
+    
+    for _, statement := range statements {
+        if _, err := m.db.Exec(statement); err != nil {
+            return fmt.Errorf("failed to execute migration %d_%s: %w", migration.Version, migration.Name, err)
+        }
+    }
+    
+    if _, err := m.exec(`UPDATE schema_migrations SET dirty = FALSE, applied_at = ? WHERE version = ?`, time.Now(), migration.Version); err != nil {
+        return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+    }
+    return nil
+}
+
+func (m *Migrator) revert(migration Migration) error {
+    log.Printf("Reverting migration %d_%s", migration.Version, migration.Name)
+    
+    if _, err := m.exec(`UPDATE schema_migrations SET dirty = TRUE WHERE version = ?`, migration.Version); err != nil {
+        return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+    }
+    
+    for _, statement := range splitStatements(migration.Down) {
+        if _, err := m.db.Exec(statement); err != nil {
+            return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+        }
+    }
+    
+    if _, err := m.exec(`DELETE FROM schema_migrations WHERE version = ?`, migration.Version); err != nil {
+        return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+    }
     return nil
-}
\ No newline at end of file
+}
+
+func (m *Migrator) exec(query string, args ...interface{}) (sql.Result, error) {
+    return m.db.Exec(Rebind(m.driver, query), args...)
+}
+
+// splitStatements breaks a migration file into statements on semicolons that end a line,
+// dropping comment-only lines. Migration files must not put a statement-ending semicolon
+// inside a string literal.
+func splitStatements(script string) []string {
+    var statements []string
+    var current strings.Builder
+    
+    for _, line := range strings.Split(script, "\n") {
+        trimmed := strings.TrimSpace(line)
+        if strings.HasPrefix(trimmed, "--") {
+            continue
+        }
+        
+        current.WriteString(line)
+        current.WriteString("\n")
+        
+        if strings.HasSuffix(trimmed, ";") {
+            statement := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+            if statement != "" {
+                statements = append(statements, statement)
+            }
+            current.Reset()
+        }
+    }
+    
+    if statement := strings.TrimSpace(current.String()); statement != "" {
+        statements = append(statements, statement)
+    }
+    return statements
+}
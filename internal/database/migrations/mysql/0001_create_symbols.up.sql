@@ -0,0 +1,22 @@
+-- Instrument registry and trading rules
+CREATE TABLE symbols (
+    symbol VARCHAR(10) PRIMARY KEY,
+    base_asset VARCHAR(10) NOT NULL,
+    quote_asset VARCHAR(10) NOT NULL,
+    status ENUM('active', 'halted', 'delisted') NOT NULL DEFAULT 'active',
+    price_precision TINYINT UNSIGNED NOT NULL DEFAULT 8,
+    quantity_precision TINYINT UNSIGNED NOT NULL DEFAULT 8,
+    tick_size DECIMAL(15,8) NOT NULL DEFAULT 0.00000001,
+    lot_size DECIMAL(15,8) NOT NULL DEFAULT 0.00000001,
+    min_quantity DECIMAL(15,8) NOT NULL DEFAULT 0.00000001,
+    max_quantity DECIMAL(15,8) NULL,
+    min_notional DECIMAL(20,8) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    
+    INDEX idx_status (status)
+);
+
+INSERT INTO symbols (symbol, base_asset, quote_asset, price_precision, quantity_precision, tick_size, lot_size, min_quantity, max_quantity, min_notional) VALUES
+    ('BTCUSD', 'BTC', 'USD', 2, 8, 0.01, 0.00001, 0.00001, 1000, 10),
+    ('ETHUSD', 'ETH', 'USD', 2, 8, 0.01, 0.0001, 0.0001, 10000, 10);
@@ -0,0 +1,29 @@
+CREATE TABLE orders (
+    id VARCHAR(36) PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    side ENUM('buy', 'sell') NOT NULL,
+    type ENUM('limit', 'market', 'stop_market', 'stop_limit') NOT NULL,
+    price DECIMAL(15,8) NULL,
+    stop_price DECIMAL(15,8) NULL,
+    initial_quantity DECIMAL(15,8) NOT NULL,
+    remaining_quantity DECIMAL(15,8) NOT NULL,
+    display_quantity DECIMAL(15,8) NULL,
+    visible_quantity DECIMAL(15,8) NULL,
+    status ENUM('open', 'filled', 'canceled', 'partial', 'untriggered', 'expired', 'rejected') NOT NULL DEFAULT 'open',
+    time_in_force ENUM('gtc', 'ioc', 'fok', 'gtd', 'day') NOT NULL DEFAULT 'gtc',
+    expires_at TIMESTAMP NULL,
+    post_only BOOLEAN NOT NULL DEFAULT FALSE,
+    post_only_action ENUM('reject', 'reprice') NULL,
+    reject_reason VARCHAR(255) NULL,
+    triggered_at TIMESTAMP NULL,
+    priority_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    
+    INDEX idx_symbol_side_status (symbol, side, status),
+    INDEX idx_price_created (price, created_at),
+    INDEX idx_status_expires (status, expires_at),
+    INDEX idx_status (status),
+    INDEX idx_created_at (created_at),
+    FOREIGN KEY (symbol) REFERENCES symbols(symbol)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE trades (
+    id VARCHAR(36) PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    buy_order_id VARCHAR(36) NOT NULL,
+    sell_order_id VARCHAR(36) NOT NULL,
+    price DECIMAL(15,8) NOT NULL,
+    quantity DECIMAL(15,8) NOT NULL,
+    executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    
+    FOREIGN KEY (buy_order_id) REFERENCES orders(id),
+    FOREIGN KEY (sell_order_id) REFERENCES orders(id),
+    INDEX idx_symbol_executed (symbol, executed_at),
+    INDEX idx_buy_order (buy_order_id),
+    INDEX idx_sell_order (sell_order_id)
+);
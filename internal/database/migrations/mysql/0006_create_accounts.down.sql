@@ -0,0 +1,10 @@
+ALTER TABLE orders DROP FOREIGN KEY fk_orders_account;
+
+ALTER TABLE orders
+    DROP INDEX idx_account,
+    DROP COLUMN account_id,
+    DROP COLUMN reserved;
+
+DROP TABLE balances;
+
+DROP TABLE accounts;
@@ -0,0 +1,22 @@
+CREATE TABLE accounts (
+    id VARCHAR(36) PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Balances are only changed by adding to them, so shards committing in parallel never overwrite each other. Trades settle to 16 decimal places, price times quantity.
+CREATE TABLE balances (
+    account_id VARCHAR(36) NOT NULL,
+    asset VARCHAR(10) NOT NULL,
+    available DECIMAL(36,16) NOT NULL DEFAULT 0,
+    locked DECIMAL(36,16) NOT NULL DEFAULT 0,
+    
+    PRIMARY KEY (account_id, asset),
+    FOREIGN KEY (account_id) REFERENCES accounts(id)
+);
+
+-- Orders placed before accounts existed have no owner and reserve nothing
+ALTER TABLE orders
+    ADD COLUMN account_id VARCHAR(36) NULL,
+    ADD COLUMN reserved DECIMAL(36,16) NOT NULL DEFAULT 0,
+    ADD INDEX idx_account (account_id),
+    ADD CONSTRAINT fk_orders_account FOREIGN KEY (account_id) REFERENCES accounts(id);
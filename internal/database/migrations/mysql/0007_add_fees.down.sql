@@ -0,0 +1,16 @@
+ALTER TABLE trades
+    DROP INDEX idx_executed,
+    DROP COLUMN taker_side,
+    DROP COLUMN maker_account_id,
+    DROP COLUMN taker_account_id,
+    DROP COLUMN maker_fee,
+    DROP COLUMN maker_fee_asset,
+    DROP COLUMN taker_fee,
+    DROP COLUMN taker_fee_asset;
+
+ALTER TABLE orders
+    DROP COLUMN maker_fee_rate,
+    DROP COLUMN taker_fee_rate;
+
+ALTER TABLE accounts DROP COLUMN fee_schedule;
+ALTER TABLE symbols DROP COLUMN fee_schedule;
@@ -0,0 +1,19 @@
+-- Fee schedules are stored as JSON. A symbol without one charges no fees; an account's replaces the symbol's.
+ALTER TABLE symbols ADD COLUMN fee_schedule TEXT NULL;
+ALTER TABLE accounts ADD COLUMN fee_schedule TEXT NULL;
+
+-- Orders carry the fee rates fixed when they were accepted
+ALTER TABLE orders
+    ADD COLUMN maker_fee_rate DECIMAL(10,6) NOT NULL DEFAULT 0,
+    ADD COLUMN taker_fee_rate DECIMAL(10,6) NOT NULL DEFAULT 0;
+
+-- Each side's fee is charged in the asset it receives. Trades executed before fees existed have no taker side or accounts.
+ALTER TABLE trades
+    ADD COLUMN taker_side VARCHAR(4) NULL,
+    ADD COLUMN maker_account_id VARCHAR(36) NULL,
+    ADD COLUMN taker_account_id VARCHAR(36) NULL,
+    ADD COLUMN maker_fee DECIMAL(36,16) NOT NULL DEFAULT 0,
+    ADD COLUMN maker_fee_asset VARCHAR(10) NULL,
+    ADD COLUMN taker_fee DECIMAL(36,16) NOT NULL DEFAULT 0,
+    ADD COLUMN taker_fee_asset VARCHAR(10) NULL,
+    ADD INDEX idx_executed (executed_at);
@@ -0,0 +1,3 @@
+DROP TABLE order_events;
+
+ALTER TABLE orders DROP COLUMN self_trade_prevention;
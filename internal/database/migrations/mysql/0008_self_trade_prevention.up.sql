@@ -0,0 +1,16 @@
+ALTER TABLE orders ADD COLUMN self_trade_prevention VARCHAR(20) NULL;
+
+-- Order history besides trades, such as self-trades that were prevented
+CREATE TABLE order_events (
+    id VARCHAR(36) PRIMARY KEY,
+    order_id VARCHAR(36) NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    counter_order_id VARCHAR(36) NOT NULL,
+    self_trade_prevention VARCHAR(20) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    quantity DECIMAL(15,8) NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL,
+    
+    FOREIGN KEY (order_id) REFERENCES orders(id),
+    INDEX idx_order_created (order_id, created_at)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP INDEX idx_account_client_order,
+    DROP COLUMN client_order_id;
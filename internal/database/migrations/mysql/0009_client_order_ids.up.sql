@@ -0,0 +1,4 @@
+-- Client order IDs are chosen by the account placing the order and are unique within it. Orders without one are NULL, which the index does not constrain.
+ALTER TABLE orders
+    ADD COLUMN client_order_id VARCHAR(64) NULL,
+    ADD UNIQUE INDEX idx_account_client_order (account_id, client_order_id);
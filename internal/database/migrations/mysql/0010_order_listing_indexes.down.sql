@@ -0,0 +1,5 @@
+ALTER TABLE orders
+    DROP INDEX idx_created_id,
+    DROP INDEX idx_symbol_created,
+    DROP INDEX idx_account_created,
+    DROP INDEX idx_status_created;
@@ -0,0 +1,6 @@
+-- Order listings page through created_at then id, usually narrowed to a symbol, an account or the open statuses.
+ALTER TABLE orders
+    ADD INDEX idx_created_id (created_at, id),
+    ADD INDEX idx_symbol_created (symbol, created_at, id),
+    ADD INDEX idx_account_created (account_id, created_at, id),
+    ADD INDEX idx_status_created (status, created_at, id);
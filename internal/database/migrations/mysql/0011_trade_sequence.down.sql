@@ -0,0 +1,4 @@
+ALTER TABLE trades
+    DROP INDEX idx_symbol_sequence,
+    DROP COLUMN sequence,
+    DROP COLUMN fill_index;
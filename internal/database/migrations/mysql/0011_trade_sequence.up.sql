@@ -0,0 +1,6 @@
+-- Every trade of a command shares its executed_at, so trades are ordered by the journal sequence of the command that executed
+-- them and their position among its fills. Trades executed before this migration keep 0 and are ordered by executed_at.
+ALTER TABLE trades
+    ADD COLUMN sequence BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN fill_index INT NOT NULL DEFAULT 0,
+    ADD INDEX idx_symbol_sequence (symbol, sequence, fill_index);
@@ -0,0 +1,3 @@
+-- A digest of the request that placed an order with a client order ID, which a retry reusing the ID is compared with after the
+-- order has been amended, repriced or decremented. Orders placed before this migration have none and are compared with their current state.
+ALTER TABLE orders ADD COLUMN request_hash CHAR(64) NULL;
@@ -0,0 +1,3 @@
+-- Order listings page through (created_at, id), and the cursor of an order still held in memory carries its creation time to
+-- the microsecond. Kept to the second, created_at would not match that cursor and same-second orders would fall back to id order.
+ALTER TABLE orders MODIFY COLUMN created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6);
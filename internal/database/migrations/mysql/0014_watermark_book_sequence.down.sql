@@ -0,0 +1 @@
+ALTER TABLE journal_watermarks DROP COLUMN book_sequence;
@@ -0,0 +1,3 @@
+-- The version of each symbol's order book as of its watermark, so a restart from storage carries the book's sequence on
+-- rather than counting it again from the resting orders. Symbols committed before this migration start from that count.
+ALTER TABLE journal_watermarks ADD COLUMN book_sequence BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,22 @@
+-- Instrument registry and trading rules
+CREATE TABLE symbols (
+    symbol VARCHAR(10) PRIMARY KEY,
+    base_asset VARCHAR(10) NOT NULL,
+    quote_asset VARCHAR(10) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'halted', 'delisted')),
+    price_precision SMALLINT NOT NULL DEFAULT 8 CHECK (price_precision >= 0),
+    quantity_precision SMALLINT NOT NULL DEFAULT 8 CHECK (quantity_precision >= 0),
+    tick_size NUMERIC(15,8) NOT NULL DEFAULT 0.00000001,
+    lot_size NUMERIC(15,8) NOT NULL DEFAULT 0.00000001,
+    min_quantity NUMERIC(15,8) NOT NULL DEFAULT 0.00000001,
+    max_quantity NUMERIC(15,8) NULL,
+    min_notional NUMERIC(20,8) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_symbols_status ON symbols (status);
+
+INSERT INTO symbols (symbol, base_asset, quote_asset, price_precision, quantity_precision, tick_size, lot_size, min_quantity, max_quantity, min_notional) VALUES
+    ('BTCUSD', 'BTC', 'USD', 2, 8, 0.01, 0.00001, 0.00001, 1000, 10),
+    ('ETHUSD', 'ETH', 'USD', 2, 8, 0.01, 0.0001, 0.0001, 10000, 10);
@@ -0,0 +1,28 @@
+CREATE TABLE orders (
+    id VARCHAR(36) PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL REFERENCES symbols (symbol),
+    side VARCHAR(4) NOT NULL CHECK (side IN ('buy', 'sell')),
+    type VARCHAR(11) NOT NULL CHECK (type IN ('limit', 'market', 'stop_market', 'stop_limit')),
+    price NUMERIC(15,8) NULL,
+    stop_price NUMERIC(15,8) NULL,
+    initial_quantity NUMERIC(15,8) NOT NULL,
+    remaining_quantity NUMERIC(15,8) NOT NULL,
+    display_quantity NUMERIC(15,8) NULL,
+    visible_quantity NUMERIC(15,8) NULL,
+    status VARCHAR(11) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'filled', 'canceled', 'partial', 'untriggered', 'expired', 'rejected')),
+    time_in_force VARCHAR(3) NOT NULL DEFAULT 'gtc' CHECK (time_in_force IN ('gtc', 'ioc', 'fok', 'gtd', 'day')),
+    expires_at TIMESTAMPTZ NULL,
+    post_only BOOLEAN NOT NULL DEFAULT FALSE,
+    post_only_action VARCHAR(7) NULL CHECK (post_only_action IN ('reject', 'reprice')),
+    reject_reason VARCHAR(255) NULL,
+    triggered_at TIMESTAMPTZ NULL,
+    priority_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_orders_symbol_side_status ON orders (symbol, side, status);
+CREATE INDEX idx_orders_price_created ON orders (price, created_at);
+CREATE INDEX idx_orders_status_expires ON orders (status, expires_at);
+CREATE INDEX idx_orders_status ON orders (status);
+CREATE INDEX idx_orders_created_at ON orders (created_at);
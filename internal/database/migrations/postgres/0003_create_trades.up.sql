@@ -0,0 +1,13 @@
+CREATE TABLE trades (
+    id VARCHAR(36) PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    buy_order_id VARCHAR(36) NOT NULL REFERENCES orders (id),
+    sell_order_id VARCHAR(36) NOT NULL REFERENCES orders (id),
+    price NUMERIC(15,8) NOT NULL,
+    quantity NUMERIC(15,8) NOT NULL,
+    executed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_trades_symbol_executed ON trades (symbol, executed_at);
+CREATE INDEX idx_trades_buy_order ON trades (buy_order_id);
+CREATE INDEX idx_trades_sell_order ON trades (sell_order_id);
@@ -0,0 +1,10 @@
+-- Symbols only commit out of order while commands are in flight, so after a clean shutdown the newest watermark covers every symbol
+CREATE TABLE journal_watermark (
+    id INTEGER PRIMARY KEY,
+    sequence BIGINT NOT NULL
+);
+
+INSERT INTO journal_watermark (id, sequence)
+SELECT 1, COALESCE(MAX(sequence), 0) FROM journal_watermarks;
+
+DROP TABLE journal_watermarks;
@@ -0,0 +1,10 @@
+-- Symbols are matched independently and commit out of order, so each keeps its own watermark
+CREATE TABLE journal_watermarks (
+    symbol VARCHAR(10) PRIMARY KEY,
+    sequence BIGINT NOT NULL
+);
+
+INSERT INTO journal_watermarks (symbol, sequence)
+SELECT symbols.symbol, journal_watermark.sequence FROM symbols, journal_watermark;
+
+DROP TABLE journal_watermark;
@@ -0,0 +1,8 @@
+DROP INDEX idx_orders_account;
+
+ALTER TABLE orders DROP COLUMN reserved;
+ALTER TABLE orders DROP COLUMN account_id;
+
+DROP TABLE balances;
+
+DROP TABLE accounts;
@@ -0,0 +1,19 @@
+CREATE TABLE accounts (
+    id VARCHAR(36) PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Balances are only changed by adding to them, so shards committing in parallel never overwrite each other. Trades settle to 16 decimal places, price times quantity.
+CREATE TABLE balances (
+    account_id VARCHAR(36) NOT NULL REFERENCES accounts (id),
+    asset VARCHAR(10) NOT NULL,
+    available NUMERIC(36,16) NOT NULL DEFAULT 0,
+    locked NUMERIC(36,16) NOT NULL DEFAULT 0,
+    PRIMARY KEY (account_id, asset)
+);
+
+-- Orders placed before accounts existed have no owner and reserve nothing
+ALTER TABLE orders ADD COLUMN account_id VARCHAR(36) NULL REFERENCES accounts (id);
+ALTER TABLE orders ADD COLUMN reserved NUMERIC(36,16) NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_orders_account ON orders (account_id);
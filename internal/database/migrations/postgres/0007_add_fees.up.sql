@@ -0,0 +1,18 @@
+-- Fee schedules are stored as JSON. A symbol without one charges no fees; an account's replaces the symbol's.
+ALTER TABLE symbols ADD COLUMN fee_schedule TEXT NULL;
+ALTER TABLE accounts ADD COLUMN fee_schedule TEXT NULL;
+
+-- Orders carry the fee rates fixed when they were accepted
+ALTER TABLE orders ADD COLUMN maker_fee_rate NUMERIC(10,6) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN taker_fee_rate NUMERIC(10,6) NOT NULL DEFAULT 0;
+
+-- Each side's fee is charged in the asset it receives. Trades executed before fees existed have no taker side or accounts.
+ALTER TABLE trades ADD COLUMN taker_side VARCHAR(4) NULL;
+ALTER TABLE trades ADD COLUMN maker_account_id VARCHAR(36) NULL;
+ALTER TABLE trades ADD COLUMN taker_account_id VARCHAR(36) NULL;
+ALTER TABLE trades ADD COLUMN maker_fee NUMERIC(36,16) NOT NULL DEFAULT 0;
+ALTER TABLE trades ADD COLUMN maker_fee_asset VARCHAR(10) NULL;
+ALTER TABLE trades ADD COLUMN taker_fee NUMERIC(36,16) NOT NULL DEFAULT 0;
+ALTER TABLE trades ADD COLUMN taker_fee_asset VARCHAR(10) NULL;
+
+CREATE INDEX idx_trades_executed ON trades (executed_at);
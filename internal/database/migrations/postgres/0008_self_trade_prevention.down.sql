@@ -0,0 +1,5 @@
+DROP INDEX idx_order_events_order_created;
+
+DROP TABLE order_events;
+
+ALTER TABLE orders DROP COLUMN self_trade_prevention;
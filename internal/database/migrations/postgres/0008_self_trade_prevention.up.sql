@@ -0,0 +1,15 @@
+ALTER TABLE orders ADD COLUMN self_trade_prevention VARCHAR(20) NULL;
+
+-- Order history besides trades, such as self-trades that were prevented
+CREATE TABLE order_events (
+    id VARCHAR(36) PRIMARY KEY,
+    order_id VARCHAR(36) NOT NULL REFERENCES orders (id),
+    type VARCHAR(30) NOT NULL,
+    counter_order_id VARCHAR(36) NOT NULL,
+    self_trade_prevention VARCHAR(20) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    quantity NUMERIC(15,8) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_order_events_order_created ON order_events (order_id, created_at);
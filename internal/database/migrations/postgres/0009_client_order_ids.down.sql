@@ -0,0 +1,3 @@
+DROP INDEX idx_orders_account_client_order;
+
+ALTER TABLE orders DROP COLUMN client_order_id;
@@ -0,0 +1,4 @@
+DROP INDEX idx_orders_created_id;
+DROP INDEX idx_orders_symbol_created;
+DROP INDEX idx_orders_account_created;
+DROP INDEX idx_orders_status_created;
@@ -0,0 +1,5 @@
+-- Order listings page through created_at then id, usually narrowed to a symbol, an account or the open statuses.
+CREATE INDEX idx_orders_created_id ON orders (created_at, id);
+CREATE INDEX idx_orders_symbol_created ON orders (symbol, created_at, id);
+CREATE INDEX idx_orders_account_created ON orders (account_id, created_at, id);
+CREATE INDEX idx_orders_status_created ON orders (status, created_at, id);
@@ -0,0 +1,4 @@
+DROP INDEX idx_trades_symbol_sequence;
+
+ALTER TABLE trades DROP COLUMN fill_index;
+ALTER TABLE trades DROP COLUMN sequence;
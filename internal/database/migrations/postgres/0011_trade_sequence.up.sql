@@ -0,0 +1,6 @@
+-- Every trade of a command shares its executed_at, so trades are ordered by the journal sequence of the command that executed
+-- them and their position among its fills. Trades executed before this migration keep 0 and are ordered by executed_at.
+ALTER TABLE trades ADD COLUMN sequence BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE trades ADD COLUMN fill_index INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_trades_symbol_sequence ON trades (symbol, sequence, fill_index);
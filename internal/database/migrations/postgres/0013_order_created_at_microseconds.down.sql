@@ -0,0 +1 @@
+-- MySQL widens orders.created_at to microseconds here. This driver already keeps them, so there is nothing to change.
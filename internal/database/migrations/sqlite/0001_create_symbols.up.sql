@@ -0,0 +1,22 @@
+-- Instrument registry and trading rules. Decimals are stored as TEXT so they round-trip exactly.
+CREATE TABLE symbols (
+    symbol TEXT PRIMARY KEY,
+    base_asset TEXT NOT NULL,
+    quote_asset TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'halted', 'delisted')),
+    price_precision INTEGER NOT NULL DEFAULT 8,
+    quantity_precision INTEGER NOT NULL DEFAULT 8,
+    tick_size TEXT NOT NULL DEFAULT '0.00000001',
+    lot_size TEXT NOT NULL DEFAULT '0.00000001',
+    min_quantity TEXT NOT NULL DEFAULT '0.00000001',
+    max_quantity TEXT NULL,
+    min_notional TEXT NOT NULL DEFAULT '0',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_symbols_status ON symbols (status);
+
+INSERT INTO symbols (symbol, base_asset, quote_asset, price_precision, quantity_precision, tick_size, lot_size, min_quantity, max_quantity, min_notional) VALUES
+    ('BTCUSD', 'BTC', 'USD', 2, 8, '0.01', '0.00001', '0.00001', '1000', '10'),
+    ('ETHUSD', 'ETH', 'USD', 2, 8, '0.01', '0.0001', '0.0001', '10000', '10');
@@ -0,0 +1,29 @@
+-- Timestamps are written as UTC text ("2006-01-02 15:04:05.999999999+00:00"), which sorts chronologically.
+CREATE TABLE orders (
+    id TEXT PRIMARY KEY,
+    symbol TEXT NOT NULL REFERENCES symbols (symbol),
+    side TEXT NOT NULL CHECK (side IN ('buy', 'sell')),
+    type TEXT NOT NULL CHECK (type IN ('limit', 'market', 'stop_market', 'stop_limit')),
+    price TEXT NULL,
+    stop_price TEXT NULL,
+    initial_quantity TEXT NOT NULL,
+    remaining_quantity TEXT NOT NULL,
+    display_quantity TEXT NULL,
+    visible_quantity TEXT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'filled', 'canceled', 'partial', 'untriggered', 'expired', 'rejected')),
+    time_in_force TEXT NOT NULL DEFAULT 'gtc' CHECK (time_in_force IN ('gtc', 'ioc', 'fok', 'gtd', 'day')),
+    expires_at TIMESTAMP NULL,
+    post_only BOOLEAN NOT NULL DEFAULT FALSE,
+    post_only_action TEXT NULL CHECK (post_only_action IN ('reject', 'reprice')),
+    reject_reason TEXT NULL,
+    triggered_at TIMESTAMP NULL,
+    priority_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_orders_symbol_side_status ON orders (symbol, side, status);
+CREATE INDEX idx_orders_price_created ON orders (price, created_at);
+CREATE INDEX idx_orders_status_expires ON orders (status, expires_at);
+CREATE INDEX idx_orders_status ON orders (status);
+CREATE INDEX idx_orders_created_at ON orders (created_at);
@@ -0,0 +1,13 @@
+CREATE TABLE trades (
+    id TEXT PRIMARY KEY,
+    symbol TEXT NOT NULL,
+    buy_order_id TEXT NOT NULL REFERENCES orders (id),
+    sell_order_id TEXT NOT NULL REFERENCES orders (id),
+    price TEXT NOT NULL,
+    quantity TEXT NOT NULL,
+    executed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_trades_symbol_executed ON trades (symbol, executed_at);
+CREATE INDEX idx_trades_buy_order ON trades (buy_order_id);
+CREATE INDEX idx_trades_sell_order ON trades (sell_order_id);
@@ -0,0 +1,7 @@
+-- Last journal sequence whose effects are committed, advanced in the same transaction as the command
+CREATE TABLE journal_watermark (
+    id INTEGER PRIMARY KEY,
+    sequence BIGINT NOT NULL
+);
+
+INSERT INTO journal_watermark (id, sequence) VALUES (1, 0);
@@ -0,0 +1,19 @@
+CREATE TABLE accounts (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Balances are only changed by adding to them, so shards committing in parallel never overwrite each other
+CREATE TABLE balances (
+    account_id TEXT NOT NULL REFERENCES accounts (id),
+    asset TEXT NOT NULL,
+    available TEXT NOT NULL DEFAULT '0',
+    locked TEXT NOT NULL DEFAULT '0',
+    PRIMARY KEY (account_id, asset)
+);
+
+-- Orders placed before accounts existed have no owner and reserve nothing
+ALTER TABLE orders ADD COLUMN account_id TEXT NULL REFERENCES accounts (id);
+ALTER TABLE orders ADD COLUMN reserved TEXT NOT NULL DEFAULT '0';
+
+CREATE INDEX idx_orders_account ON orders (account_id);
@@ -0,0 +1,15 @@
+DROP INDEX idx_trades_executed;
+
+ALTER TABLE trades DROP COLUMN taker_fee_asset;
+ALTER TABLE trades DROP COLUMN taker_fee;
+ALTER TABLE trades DROP COLUMN maker_fee_asset;
+ALTER TABLE trades DROP COLUMN maker_fee;
+ALTER TABLE trades DROP COLUMN taker_account_id;
+ALTER TABLE trades DROP COLUMN maker_account_id;
+ALTER TABLE trades DROP COLUMN taker_side;
+
+ALTER TABLE orders DROP COLUMN taker_fee_rate;
+ALTER TABLE orders DROP COLUMN maker_fee_rate;
+
+ALTER TABLE accounts DROP COLUMN fee_schedule;
+ALTER TABLE symbols DROP COLUMN fee_schedule;
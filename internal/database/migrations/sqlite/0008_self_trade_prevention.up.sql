@@ -0,0 +1,15 @@
+ALTER TABLE orders ADD COLUMN self_trade_prevention TEXT NULL;
+
+-- Order history besides trades, such as self-trades that were prevented
+CREATE TABLE order_events (
+    id TEXT PRIMARY KEY,
+    order_id TEXT NOT NULL REFERENCES orders (id),
+    type TEXT NOT NULL,
+    counter_order_id TEXT NOT NULL,
+    self_trade_prevention TEXT NOT NULL,
+    action TEXT NOT NULL,
+    quantity TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_order_events_order_created ON order_events (order_id, created_at);
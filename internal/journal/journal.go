@@ -0,0 +1,270 @@
+package journal
+
+import (
+    "bufio"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "log"
+    "order-matching-system/internal/models"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+// Record types, one per engine command that changes state, and TypeAbort, which marks an earlier
+// command that failed to commit and was rolled back.
+const (
+    TypePlace            = "place"
+    TypeCancel           = "cancel"
+    TypeAmend            = "amend"
+    TypeExpire           = "expire"
+    TypeCreateInstrument = "create_instrument"
+    TypeUpdateInstrument = "update_instrument"
+    TypeCreateAccount    = "create_account"
+    TypeDeposit          = "deposit"
+    TypeWithdraw         = "withdraw"
+    TypeSetAccountFees   = "set_account_fees"
+    TypeAbort            = "abort"
+)
+
+// headerSize is the length prefix and CRC-32C that precede every record's JSON payload.
+const headerSize = 8
+
+// maxRecordSize guards against reading a garbage length as an allocation size.
+const maxRecordSize = 16 << 20
+
+var (
+    ErrCorrupt          = errors.New("journal is corrupt")
+    ErrSequenceNotAfter = errors.New("journal sequence must increase")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Record is one command accepted by the matching engine, with everything needed to apply it
+// again: the sequence number, the timestamp the engine processed it at, and its arguments.
+type Record struct {
+    Sequence         uint64                          `json:"seq"`
+    Time             time.Time                       `json:"time"`
+    Type             string                          `json:"type"`
+    Order            *models.Order                   `json:"order,omitempty"`
+    OrderID          string                          `json:"order_id,omitempty"`
+    Amend            *models.AmendOrderRequest       `json:"amend,omitempty"`
+    Symbol           string                          `json:"symbol,omitempty"`
+    Instrument       *models.Instrument              `json:"instrument,omitempty"`
+    InstrumentUpdate *models.UpdateInstrumentRequest `json:"instrument_update,omitempty"`
+    Aborts           uint64                          `json:"aborts,omitempty"` // Sequence of the command an abort undoes
+    Funds            *decimal.Decimal                `json:"funds,omitempty"`  // Extra funds an amend locked for its order
+    Account          *models.Account                 `json:"account,omitempty"`
+    AccountID        string                          `json:"account_id,omitempty"`
+    Asset            string                          `json:"asset,omitempty"`
+    Amount           *decimal.Decimal                `json:"amount,omitempty"` // Deposited or withdrawn
+    Fees             *models.FeeSchedule             `json:"fees,omitempty"`   // An account's fee schedule; nil clears it
+}
+
+// Journal is an append-only file of records. Each record is written as a big-endian uint32
+// payload length, a CRC-32C of the payload and the JSON payload, and is fsynced before Append
+// returns.
+type Journal struct {
+    path         string
+    file         *os.File
+    lastSequence uint64
+    mutex        sync.Mutex
+}
+
+// Open opens the journal at path, creating it and its directory if needed. A torn record at the
+// end of the file, left by a crash during a write, was never acknowledged and is cut off; damage
+// anywhere else is reported as ErrCorrupt.
+func Open(path string) (*Journal, error) {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return nil, fmt.Errorf("failed to create journal directory: %w", err)
+    }
+    
+    file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open journal: %w", err)
+    }
+    
+    var lastSequence uint64
+    end, err := scan(file, func(record *Record) error {
+        lastSequence = record.Sequence
+        return nil
+    })
+    if err != nil {
+        file.Close()
+        return nil, err
+    }
+    
+    size, err := file.Seek(0, io.SeekEnd)
+    if err != nil {
+        file.Close()
+        return nil, err
+    }
+    
+    if end < size {
+        log.Printf("Truncating torn journal tail: %d bytes after offset %d", size-end, end)
+        if err := file.Truncate(end); err != nil {
+            file.Close()
+            return nil, fmt.Errorf("failed to truncate journal: %w", err)
+        }
+        if err := file.Sync(); err != nil {
+            file.Close()
+            return nil, err
+        }
+    }
+    
+    if _, err := file.Seek(end, io.SeekStart); err != nil {
+        file.Close()
+        return nil, err
+    }
+    
+    return &Journal{path: path, file: file, lastSequence: lastSequence}, nil
+}
+
+func (j *Journal) Path() string {
+    return j.path
+}
+
+// LastSequence returns the sequence number of the last record in the journal, or 0 if it is empty.
+func (j *Journal) LastSequence() uint64 {
+    j.mutex.Lock()
+    defer j.mutex.Unlock()
+    
+    return j.lastSequence
+}
+
+// Append writes a record and waits for it to reach stable storage. Sequence numbers must
+// increase; they may skip values.
+func (j *Journal) Append(record *Record) error {
+    j.mutex.Lock()
+    defer j.mutex.Unlock()
+    
+    if record.Sequence <= j.lastSequence {
+        return fmt.Errorf("%w: %d after %d", ErrSequenceNotAfter, record.Sequence, j.lastSequence)
+    }
+    
+    payload, err := json.Marshal(record)
+    if err != nil {
+        return fmt.Errorf("failed to encode journal record: %w", err)
+    }
+    
+    frame := make([]byte, headerSize+len(payload))
+    binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+    binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+    copy(frame[headerSize:], payload)
+    
+    if _, err := j.file.Write(frame); err != nil {
+        return fmt.Errorf("failed to write journal: %w", err)
+    }
+    
+    if err := j.file.Sync(); err != nil {
+        return fmt.Errorf("failed to sync journal: %w", err)
+    }
+    
+    j.lastSequence = record.Sequence
+    return nil
+}
+
+// ReadFrom calls fn for every record after the given sequence number, in order.
+func (j *Journal) ReadFrom(after uint64, fn func(*Record) error) error {
+    return Read(j.path, after, fn)
+}
+
+func (j *Journal) Close() error {
+    j.mutex.Lock()
+    defer j.mutex.Unlock()
+    
+    return j.file.Close()
+}
+
+// Read calls fn for every record in the journal at path after the given sequence number, in
+// order. It does not modify the file; a torn final record is ignored.
+func Read(path string, after uint64, fn func(*Record) error) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return fmt.Errorf("failed to open journal: %w", err)
+    }
+    defer file.Close()
+    
+    _, err = scan(file, func(record *Record) error {
+        if record.Sequence <= after {
+            return nil
+        }
+        return fn(record)
+    })
+    return err
+}
+
+// scan reads records from the start of file and returns the offset just past the last complete
+// one. A record cut short at the end of the file, or whose checksum fails with nothing after it,
+// is treated as torn and ends the scan without error.
+func scan(file *os.File, fn func(*Record) error) (int64, error) {
+    info, err := file.Stat()
+    if err != nil {
+        return 0, err
+    }
+    size := info.Size()
+    
+    if _, err := file.Seek(0, io.SeekStart); err != nil {
+        return 0, err
+    }
+    
+    reader := bufio.NewReader(file)
+    header := make([]byte, headerSize)
+    var offset int64
+    var lastSequence uint64
+    
+    for {
+        if _, err := io.ReadFull(reader, header); err != nil {
+            if err == io.EOF || err == io.ErrUnexpectedEOF {
+                return offset, nil
+            }
+            return offset, err
+        }
+        
+        length := int64(binary.BigEndian.Uint32(header[0:4]))
+        checksum := binary.BigEndian.Uint32(header[4:8])
+        end := offset + headerSize + length
+        
+        if end > size {
+            return offset, nil
+        }
+        
+        if length > maxRecordSize {
+            return offset, fmt.Errorf("%w: record at offset %d claims %d bytes", ErrCorrupt, offset, length)
+        }
+        
+        payload := make([]byte, length)
+        if _, err := io.ReadFull(reader, payload); err != nil {
+            return offset, err
+        }
+        
+        if crc32.Checksum(payload, crcTable) != checksum {
+            if end == size {
+                return offset, nil
+            }
+            return offset, fmt.Errorf("%w: checksum mismatch at offset %d", ErrCorrupt, offset)
+        }
+        
+        var record Record
+        if err := json.Unmarshal(payload, &record); err != nil {
+            return offset, fmt.Errorf("%w: undecodable record at offset %d: %v", ErrCorrupt, offset, err)
+        }
+        
+        if record.Sequence <= lastSequence {
+            return offset, fmt.Errorf("%w: sequence %d follows %d at offset %d", ErrCorrupt, record.Sequence, lastSequence, offset)
+        }
+        lastSequence = record.Sequence
+        
+        if err := fn(&record); err != nil {
+            return offset, err
+        }
+        offset = end
+    }
+}
@@ -0,0 +1,171 @@
+package journal
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// writeJournal appends records with the given sequences to a new journal and returns its path
+// and the offset at which each record starts.
+func writeJournal(t *testing.T, sequences ...uint64) (string, []int64) {
+    path := filepath.Join(t.TempDir(), "journal.log")
+    j, err := Open(path)
+    if err != nil {
+        t.Fatalf("open: %v", err)
+    }
+    defer j.Close()
+    
+    var offsets []int64
+    for _, sequence := range sequences {
+        info, err := os.Stat(path)
+        if err != nil {
+            t.Fatalf("stat: %v", err)
+        }
+        offsets = append(offsets, info.Size())
+        
+        record := &Record{Sequence: sequence, Time: time.Unix(int64(sequence), 0).UTC(), Type: TypeCancel, OrderID: "order", Symbol: "BTCUSD"}
+        if err := j.Append(record); err != nil {
+            t.Fatalf("append %d: %v", sequence, err)
+        }
+    }
+    return path, offsets
+}
+
+func readSequences(t *testing.T, path string, after uint64) []uint64 {
+    var sequences []uint64
+    err := Read(path, after, func(record *Record) error {
+        sequences = append(sequences, record.Sequence)
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("read: %v", err)
+    }
+    return sequences
+}
+
+func equalSequences(got, want []uint64) bool {
+    if len(got) != len(want) {
+        return false
+    }
+    for i := range got {
+        if got[i] != want[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func TestAppendAndRead(t *testing.T) {
+    path, _ := writeJournal(t, 1, 2, 5)
+    
+    j, err := Open(path)
+    if err != nil {
+        t.Fatalf("reopen: %v", err)
+    }
+    defer j.Close()
+    
+    if got := j.LastSequence(); got != 5 {
+        t.Fatalf("last sequence %d, want 5", got)
+    }
+    if err := j.Append(&Record{Sequence: 5, Type: TypeCancel}); !errors.Is(err, ErrSequenceNotAfter) {
+        t.Fatalf("append a repeated sequence: got %v, want ErrSequenceNotAfter", err)
+    }
+    if err := j.Append(&Record{Sequence: 7, Type: TypeAbort, Aborts: 5}); err != nil {
+        t.Fatalf("append: %v", err)
+    }
+    
+    if got := readSequences(t, path, 0); !equalSequences(got, []uint64{1, 2, 5, 7}) {
+        t.Fatalf("read %v", got)
+    }
+    if got := readSequences(t, path, 2); !equalSequences(got, []uint64{5, 7}) {
+        t.Fatalf("read after 2: %v", got)
+    }
+    
+    var abort *Record
+    err = j.ReadFrom(5, func(record *Record) error {
+        abort = record
+        return nil
+    })
+    if err != nil || abort == nil || abort.Type != TypeAbort || abort.Aborts != 5 {
+        t.Fatalf("abort record %+v, %v", abort, err)
+    }
+}
+
+// TestOpenTruncatesTornTail checks that a record cut short by a crash, or whose checksum fails with
+// nothing after it, is dropped and the journal carries on from the record before it.
+func TestOpenTruncatesTornTail(t *testing.T) {
+    for _, tear := range []struct {
+        name string
+        tear func(data []byte, last int64) []byte
+    }{
+        {"short header", func(data []byte, last int64) []byte { return data[:last+3] }},
+        {"short payload", func(data []byte, last int64) []byte { return data[:len(data)-5] }},
+        {"bad checksum", func(data []byte, last int64) []byte {
+            data[len(data)-2] ^= 0xff
+            return data
+        }},
+    } {
+        t.Run(tear.name, func(t *testing.T) {
+            path, offsets := writeJournal(t, 1, 2, 3)
+            data, err := os.ReadFile(path)
+            if err != nil {
+                t.Fatalf("read file: %v", err)
+            }
+            last := offsets[len(offsets)-1]
+            if err := os.WriteFile(path, tear.tear(data, last), 0o644); err != nil {
+                t.Fatalf("write file: %v", err)
+            }
+            
+            // Read leaves the file alone and skips the torn record
+            if got := readSequences(t, path, 0); !equalSequences(got, []uint64{1, 2}) {
+                t.Fatalf("read torn journal: %v", got)
+            }
+            
+            j, err := Open(path)
+            if err != nil {
+                t.Fatalf("open torn journal: %v", err)
+            }
+            defer j.Close()
+            
+            if info, _ := os.Stat(path); info.Size() != last {
+                t.Fatalf("size after open %d, want %d", info.Size(), last)
+            }
+            if got := j.LastSequence(); got != 2 {
+                t.Fatalf("last sequence %d, want 2", got)
+            }
+            if err := j.Append(&Record{Sequence: 3, Type: TypeCancel}); err != nil {
+                t.Fatalf("append after truncation: %v", err)
+            }
+            if got := readSequences(t, path, 0); !equalSequences(got, []uint64{1, 2, 3}) {
+                t.Fatalf("read after append: %v", got)
+            }
+        })
+    }
+}
+
+// TestChecksumMismatchMidFile checks that damage with records after it is reported rather than
+// cut off, since those records were acknowledged.
+func TestChecksumMismatchMidFile(t *testing.T) {
+    path, offsets := writeJournal(t, 1, 2, 3)
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatalf("read file: %v", err)
+    }
+    data[offsets[1]+headerSize+2] ^= 0xff
+    if err := os.WriteFile(path, data, 0o644); err != nil {
+        t.Fatalf("write file: %v", err)
+    }
+    
+    if _, err := Open(path); !errors.Is(err, ErrCorrupt) {
+        t.Fatalf("open: got %v, want ErrCorrupt", err)
+    }
+    if err := Read(path, 0, func(*Record) error { return nil }); !errors.Is(err, ErrCorrupt) {
+        t.Fatalf("read: got %v, want ErrCorrupt", err)
+    }
+    if info, _ := os.Stat(path); info.Size() != int64(len(data)) {
+        t.Fatalf("corrupt journal was truncated to %d bytes", info.Size())
+    }
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+// Account owns orders and holds a balance of every asset it has been funded with or has traded.
+type Account struct {
+    ID        string       `json:"id"`
+    CreatedAt time.Time    `json:"created_at"`
+    Fees      *FeeSchedule `json:"fees,omitempty"` // Overrides the fees of every symbol when set
+    Balances  []Balance    `json:"balances"`
+}
+
+// Balance is an account's holding of one asset. Locked funds are reserved by open orders and
+// cannot be spent or withdrawn until the orders trade, or release them when they finish.
+type Balance struct {
+    AccountID string          `json:"-"`
+    Asset     string          `json:"asset"`
+    Available decimal.Decimal `json:"available"`
+    Locked    decimal.Decimal `json:"locked"`
+}
+
+// TransferRequest moves funds into or out of an account's available balance.
+type TransferRequest struct {
+    Asset  string          `json:"asset" binding:"required"`
+    Amount decimal.Decimal `json:"amount" binding:"required"`
+}
+
+func (r *TransferRequest) Validate() error {
+    if !assetPattern.MatchString(r.Asset) {
+        return ErrInvalidAsset
+    }
+    
+    if r.Amount.LessThanOrEqual(decimal.Zero) {
+        return ErrInvalidAmount
+    }
+    return nil
+}
+
+// Account errors
+var (
+    ErrAccountRequired   = NewAPIError(400, "ACCOUNT_REQUIRED", "Orders must name the account that places them")
+    ErrAccountNotFound   = NewAPIError(404, "ACCOUNT_NOT_FOUND", "Account not found")
+    ErrInvalidAmount     = NewAPIError(400, "INVALID_AMOUNT", "Amount must be positive")
+    ErrUnknownAsset      = NewAPIError(400, "UNKNOWN_ASSET", "Asset is not traded by any listed instrument")
+    ErrInsufficientFunds = NewAPIError(400, "INSUFFICIENT_FUNDS", "Available balance does not cover the funds the request needs")
+    ErrUnauthorized      = NewAPIError(401, "UNAUTHORIZED", "Missing or invalid bearer token")
+    ErrForbidden         = NewAPIError(403, "FORBIDDEN", "The request names an account other than the caller's")
+)
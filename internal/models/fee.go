@@ -0,0 +1,80 @@
+package models
+
+import (
+    "github.com/shopspring/decimal"
+)
+
+// FeeScale is the number of decimal places fees are rounded to, the scale of the balance columns.
+const FeeScale = 16
+
+// feeRatePrecision matches the scale of the DECIMAL(10,6) fee rate columns.
+const feeRatePrecision = 6
+
+// maxFeeRate caps fee rates at 10%.
+var maxFeeRate = decimal.New(1, -1)
+
+// FeeSchedule sets the fees charged on trades. An account's tier is the highest one whose minimum
+// volume its trading over the last 30 days has reached, measured in the symbol's quote asset.
+type FeeSchedule struct {
+    Tiers []FeeTier `json:"tiers"`
+}
+
+// FeeTier is a fraction of each trade charged to the maker and to the taker. A negative maker rate
+// is a rebate.
+type FeeTier struct {
+    MinVolume decimal.Decimal `json:"min_volume"`
+    MakerRate decimal.Decimal `json:"maker_rate"`
+    TakerRate decimal.Decimal `json:"taker_rate"`
+}
+
+// Validate checks that the tiers start at zero volume, ascend, and charge rates within bounds. A
+// maker rebate may not exceed the taker fee of its tier.
+func (s *FeeSchedule) Validate() error {
+    if len(s.Tiers) == 0 || !s.Tiers[0].MinVolume.IsZero() {
+        return ErrInvalidFeeSchedule
+    }
+    
+    for i, tier := range s.Tiers {
+        if i > 0 && !tier.MinVolume.GreaterThan(s.Tiers[i-1].MinVolume) {
+            return ErrInvalidFeeSchedule
+        }
+        if !validFeeRate(tier.TakerRate) || tier.TakerRate.IsNegative() {
+            return ErrInvalidFeeSchedule
+        }
+        if !validFeeRate(tier.MakerRate) || tier.MakerRate.LessThan(tier.TakerRate.Neg()) {
+            return ErrInvalidFeeSchedule
+        }
+    }
+    return nil
+}
+
+func validFeeRate(rate decimal.Decimal) bool {
+    return rate.LessThanOrEqual(maxFeeRate) && rate.Truncate(feeRatePrecision).Equal(rate)
+}
+
+// Tier returns the tier an account with volume trades at. A nil schedule charges nothing.
+func (s *FeeSchedule) Tier(volume decimal.Decimal) FeeTier {
+    var tier FeeTier
+    if s == nil {
+        return tier
+    }
+    
+    for _, candidate := range s.Tiers {
+        if volume.LessThan(candidate.MinVolume) {
+            break
+        }
+        tier = candidate
+    }
+    return tier
+}
+
+// Fee returns the fee at rate on amount, rounded up to FeeScale places, so that rebates round
+// toward zero.
+func Fee(amount, rate decimal.Decimal) decimal.Decimal {
+    return amount.Mul(rate).RoundCeil(FeeScale)
+}
+
+// Fee errors
+var (
+    ErrInvalidFeeSchedule = NewAPIError(400, "INVALID_FEE_SCHEDULE", "Fee tiers must start at zero volume, ascend, and charge rates of at most 0.1 with up to 6 decimal places; maker rebates may not exceed the taker rate")
+)
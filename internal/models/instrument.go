@@ -0,0 +1,262 @@
+package models
+
+import (
+    "regexp"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+type InstrumentStatus string
+
+const (
+    ACTIVE   InstrumentStatus = "active"
+    HALTED   InstrumentStatus = "halted"   // Resting orders stay on the book but no new orders are accepted
+    DELISTED InstrumentStatus = "delisted" // Resting orders are cancelled and the symbol no longer trades
+)
+
+// maxPrecision matches the scale of the DECIMAL(15,8) price and quantity columns.
+const maxPrecision = 8
+
+var (
+    symbolPattern = regexp.MustCompile(`^[A-Z0-9]{2,10}$`)
+    assetPattern  = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
+)
+
+type Instrument struct {
+    Symbol            string           `json:"symbol"`
+    BaseAsset         string           `json:"base_asset"`
+    QuoteAsset        string           `json:"quote_asset"`
+    Status            InstrumentStatus `json:"status"`
+    PricePrecision    int32            `json:"price_precision"`
+    QuantityPrecision int32            `json:"quantity_precision"`
+    TickSize          decimal.Decimal  `json:"tick_size"`              // Prices must be a multiple of this
+    LotSize           decimal.Decimal  `json:"lot_size"`               // Quantities must be a multiple of this
+    MinQuantity       decimal.Decimal  `json:"min_quantity"`
+    MaxQuantity       *decimal.Decimal `json:"max_quantity,omitempty"` // No upper bound when unset
+    MinNotional       decimal.Decimal  `json:"min_notional"`           // Minimum price * quantity for priced orders
+    Fees              *FeeSchedule     `json:"fees,omitempty"`           // No fees when unset
+    CreatedAt         time.Time        `json:"created_at"`
+    UpdatedAt         time.Time        `json:"updated_at"`
+}
+
+type CreateInstrumentRequest struct {
+    Symbol            string           `json:"symbol" binding:"required"`
+    BaseAsset         string           `json:"base_asset" binding:"required"`
+    QuoteAsset        string           `json:"quote_asset" binding:"required"`
+    Status            InstrumentStatus `json:"status,omitempty"`
+    PricePrecision    int32            `json:"price_precision"`
+    QuantityPrecision int32            `json:"quantity_precision"`
+    TickSize          *decimal.Decimal `json:"tick_size,omitempty"`
+    LotSize           *decimal.Decimal `json:"lot_size,omitempty"`
+    MinQuantity       *decimal.Decimal `json:"min_quantity,omitempty"`
+    MaxQuantity       *decimal.Decimal `json:"max_quantity,omitempty"`
+    MinNotional       *decimal.Decimal `json:"min_notional,omitempty"`
+    Fees              *FeeSchedule     `json:"fees,omitempty"`
+}
+
+type UpdateInstrumentRequest struct {
+    Status            *InstrumentStatus `json:"status,omitempty"`
+    PricePrecision    *int32            `json:"price_precision,omitempty"`
+    QuantityPrecision *int32            `json:"quantity_precision,omitempty"`
+    TickSize          *decimal.Decimal  `json:"tick_size,omitempty"`
+    LotSize           *decimal.Decimal  `json:"lot_size,omitempty"`
+    MinQuantity       *decimal.Decimal  `json:"min_quantity,omitempty"`
+    MaxQuantity       *decimal.Decimal  `json:"max_quantity,omitempty"`
+    MinNotional       *decimal.Decimal  `json:"min_notional,omitempty"`
+    Fees              *FeeSchedule      `json:"fees,omitempty"`
+}
+
+func (s InstrumentStatus) valid() bool {
+    return s == ACTIVE || s == HALTED || s == DELISTED
+}
+
+func validPrecision(precision int32) bool {
+    return precision >= 0 && precision <= maxPrecision
+}
+
+func (r *CreateInstrumentRequest) Validate() error {
+    if !symbolPattern.MatchString(r.Symbol) {
+        return ErrInvalidSymbol
+    }
+    
+    if !assetPattern.MatchString(r.BaseAsset) || !assetPattern.MatchString(r.QuoteAsset) {
+        return ErrInvalidAsset
+    }
+    
+    if r.Status != "" && !r.Status.valid() {
+        return ErrInvalidInstrumentStatus
+    }
+    
+    if !validPrecision(r.PricePrecision) || !validPrecision(r.QuantityPrecision) {
+        return ErrInvalidPrecision
+    }
+    
+    return nil
+}
+
+func (r *UpdateInstrumentRequest) Validate() error {
+    if r.Status == nil && r.PricePrecision == nil && r.QuantityPrecision == nil && r.TickSize == nil &&
+        r.LotSize == nil && r.MinQuantity == nil && r.MaxQuantity == nil && r.MinNotional == nil && r.Fees == nil {
+        return ErrEmptyInstrumentUpdate
+    }
+    
+    if r.Status != nil && !r.Status.valid() {
+        return ErrInvalidInstrumentStatus
+    }
+    
+    if (r.PricePrecision != nil && !validPrecision(*r.PricePrecision)) ||
+        (r.QuantityPrecision != nil && !validPrecision(*r.QuantityPrecision)) {
+        return ErrInvalidPrecision
+    }
+    
+    return nil
+}
+
+// Apply copies the requested changes onto the instrument.
+func (r *UpdateInstrumentRequest) Apply(instrument *Instrument) {
+    if r.Status != nil {
+        instrument.Status = *r.Status
+    }
+    if r.PricePrecision != nil {
+        instrument.PricePrecision = *r.PricePrecision
+    }
+    if r.QuantityPrecision != nil {
+        instrument.QuantityPrecision = *r.QuantityPrecision
+    }
+    if r.TickSize != nil {
+        instrument.TickSize = *r.TickSize
+    }
+    if r.LotSize != nil {
+        instrument.LotSize = *r.LotSize
+    }
+    if r.MinQuantity != nil {
+        instrument.MinQuantity = *r.MinQuantity
+    }
+    if r.MaxQuantity != nil {
+        instrument.MaxQuantity = r.MaxQuantity
+    }
+    if r.MinNotional != nil {
+        instrument.MinNotional = *r.MinNotional
+    }
+    if r.Fees != nil {
+        instrument.Fees = r.Fees
+    }
+}
+
+// Validate checks that the trading rules are consistent with each other and with the precisions.
+func (i *Instrument) Validate() error {
+    if !i.TickSize.IsPositive() || !i.TickSize.Truncate(i.PricePrecision).Equal(i.TickSize) {
+        return ErrInvalidTradingRules
+    }
+    
+    if !i.LotSize.IsPositive() || !i.LotSize.Truncate(i.QuantityPrecision).Equal(i.LotSize) {
+        return ErrInvalidTradingRules
+    }
+    
+    if !i.MinQuantity.IsPositive() || !isMultiple(i.MinQuantity, i.LotSize) {
+        return ErrInvalidTradingRules
+    }
+    
+    if i.MaxQuantity != nil && (i.MaxQuantity.LessThan(i.MinQuantity) || !isMultiple(*i.MaxQuantity, i.LotSize)) {
+        return ErrInvalidTradingRules
+    }
+    
+    if i.MinNotional.IsNegative() {
+        return ErrInvalidTradingRules
+    }
+    
+    if i.Fees != nil {
+        return i.Fees.Validate()
+    }
+    
+    return nil
+}
+
+// ValidateOrder applies the instrument's tick size, lot size, quantity bounds and minimum notional
+// to an order request. Market orders have no price, so the notional check only covers priced orders;
+// stop market orders are checked at their stop price.
+func (i *Instrument) ValidateOrder(r *PlaceOrderRequest) error {
+    if r.Price != nil && !isMultiple(*r.Price, i.TickSize) {
+        return ErrPriceNotOnTick
+    }
+    
+    if r.StopPrice != nil && !isMultiple(*r.StopPrice, i.TickSize) {
+        return ErrPriceNotOnTick
+    }
+    
+    if err := i.validateQuantity(r.Quantity); err != nil {
+        return err
+    }
+    
+    if r.DisplayQuantity != nil && !isMultiple(*r.DisplayQuantity, i.LotSize) {
+        return ErrQuantityNotOnLot
+    }
+    
+    notionalPrice := r.Price
+    if notionalPrice == nil {
+        notionalPrice = r.StopPrice
+    }
+    if notionalPrice != nil && notionalPrice.Mul(r.Quantity).LessThan(i.MinNotional) {
+        return ErrNotionalTooSmall
+    }
+    
+    return nil
+}
+
+// ValidateAmend applies the trading rules to an amended price and total quantity.
+func (i *Instrument) ValidateAmend(price, quantity decimal.Decimal) error {
+    if !isMultiple(price, i.TickSize) {
+        return ErrPriceNotOnTick
+    }
+    
+    if err := i.validateQuantity(quantity); err != nil {
+        return err
+    }
+    
+    if price.Mul(quantity).LessThan(i.MinNotional) {
+        return ErrNotionalTooSmall
+    }
+    
+    return nil
+}
+
+func (i *Instrument) validateQuantity(quantity decimal.Decimal) error {
+    if !isMultiple(quantity, i.LotSize) {
+        return ErrQuantityNotOnLot
+    }
+    
+    if quantity.LessThan(i.MinQuantity) {
+        return ErrQuantityTooSmall
+    }
+    
+    if i.MaxQuantity != nil && quantity.GreaterThan(*i.MaxQuantity) {
+        return ErrQuantityTooLarge
+    }
+    
+    return nil
+}
+
+func isMultiple(value, step decimal.Decimal) bool {
+    return value.Mod(step).IsZero()
+}
+
+// Instrument errors
+var (
+    ErrUnknownSymbol           = NewAPIError(400, "UNKNOWN_SYMBOL", "Symbol is not listed")
+    ErrSymbolNotActive         = NewAPIError(400, "SYMBOL_NOT_ACTIVE", "Symbol is halted or delisted")
+    ErrInstrumentNotFound      = NewAPIError(404, "INSTRUMENT_NOT_FOUND", "Instrument not found")
+    ErrInstrumentExists        = NewAPIError(409, "INSTRUMENT_EXISTS", "Instrument already exists")
+    ErrInvalidSymbol           = NewAPIError(400, "INVALID_SYMBOL", "Symbol must be 2-10 upper-case letters or digits")
+    ErrInvalidAsset            = NewAPIError(400, "INVALID_ASSET", "Assets must be 1-10 upper-case letters or digits")
+    ErrInvalidInstrumentStatus = NewAPIError(400, "INVALID_INSTRUMENT_STATUS", "Status must be 'active', 'halted' or 'delisted'")
+    ErrInvalidPrecision        = NewAPIError(400, "INVALID_PRECISION", "Precision must be between 0 and 8")
+    ErrEmptyInstrumentUpdate   = NewAPIError(400, "EMPTY_INSTRUMENT_UPDATE", "Update must change status, precision, trading rules or fees")
+    ErrInstrumentDelisted      = NewAPIError(400, "INSTRUMENT_DELISTED", "Delisted instruments cannot be changed")
+    ErrInvalidTradingRules     = NewAPIError(400, "INVALID_TRADING_RULES", "Tick size, lot size and quantity limits must be positive, consistent and within precision")
+    ErrPriceNotOnTick          = NewAPIError(400, "PRICE_NOT_ON_TICK", "Price must be a multiple of the instrument's tick size")
+    ErrQuantityNotOnLot        = NewAPIError(400, "QUANTITY_NOT_ON_LOT", "Quantity must be a multiple of the instrument's lot size")
+    ErrQuantityTooSmall        = NewAPIError(400, "QUANTITY_TOO_SMALL", "Quantity is below the instrument's minimum")
+    ErrQuantityTooLarge        = NewAPIError(400, "QUANTITY_TOO_LARGE", "Quantity is above the instrument's maximum")
+    ErrNotionalTooSmall        = NewAPIError(400, "NOTIONAL_TOO_SMALL", "Price times quantity is below the instrument's minimum notional")
+)
@@ -9,6 +9,9 @@ import (
 type OrderSide string
 type OrderType string
 type OrderStatus string
+type TimeInForce string
+type PostOnlyAction string
+type SelfTradePrevention string
 
 const (
     BUY  OrderSide = "buy"
@@ -16,54 +19,304 @@ const (
 )
 
 const (
-    LIMIT  OrderType = "limit"
-    MARKET OrderType = "market"
+    LIMIT       OrderType = "limit"
+    MARKET      OrderType = "market"
+    STOP_MARKET OrderType = "stop_market"
+    STOP_LIMIT  OrderType = "stop_limit"
 )
 
 const (
-    OPEN     OrderStatus = "open"
-    FILLED   OrderStatus = "filled"
-    CANCELED OrderStatus = "canceled"
-    PARTIAL  OrderStatus = "partial"
+    OPEN        OrderStatus = "open"
+    FILLED      OrderStatus = "filled"
+    CANCELED    OrderStatus = "canceled"
+    PARTIAL     OrderStatus = "partial"
+    UNTRIGGERED OrderStatus = "untriggered" // Stop order waiting for its trigger price
+    EXPIRED     OrderStatus = "expired"
+    REJECTED    OrderStatus = "rejected"
 )
 
+const (
+    GTC TimeInForce = "gtc" // Good-til-cancelled
+    IOC TimeInForce = "ioc" // Immediate-or-cancel
+    FOK TimeInForce = "fok" // Fill-or-kill
+    GTD TimeInForce = "gtd" // Good-til-date, requires expires_at
+    DAY TimeInForce = "day" // Expires at the end of the UTC trading day
+)
+
+const (
+    POST_ONLY_REJECT  PostOnlyAction = "reject"  // Reject a post-only order that would take liquidity
+    POST_ONLY_REPRICE PostOnlyAction = "reprice" // Move it one tick behind the touch instead
+)
+
+// Self-trade prevention modes decide what happens when an order would trade against a resting
+// order of the same account. The incoming order's mode applies.
+const (
+    STP_CANCEL_NEWEST        SelfTradePrevention = "cancel_newest"        // Cancel the rest of the incoming order
+    STP_CANCEL_OLDEST        SelfTradePrevention = "cancel_oldest"        // Cancel the resting order and keep matching
+    STP_CANCEL_BOTH          SelfTradePrevention = "cancel_both"          // Cancel both
+    STP_DECREMENT_AND_CANCEL SelfTradePrevention = "decrement_and_cancel" // Cancel the smaller order and take its size off the larger
+)
+
+// IsImmediate reports whether an unfilled remainder must be cancelled instead of resting.
+func (t TimeInForce) IsImmediate() bool {
+    return t == IOC || t == FOK
+}
+
+// IsStop reports whether the order type waits for a stop price before matching.
+func (t OrderType) IsStop() bool {
+    return t == STOP_MARKET || t == STOP_LIMIT
+}
+
+// IsMarket reports whether the order type executes without a limit price.
+func (t OrderType) IsMarket() bool {
+    return t == MARKET || t == STOP_MARKET
+}
+
 type Order struct {
     ID                string          `json:"id"`
+    AccountID         string          `json:"account_id,omitempty"`
+    ClientOrderID     string          `json:"client_order_id,omitempty"` // Unique within the account
     Symbol            string          `json:"symbol"`
     Side              OrderSide       `json:"side"`
     Type              OrderType       `json:"type"`
     Price             *decimal.Decimal `json:"price,omitempty"`
+    StopPrice         *decimal.Decimal `json:"stop_price,omitempty"`
     InitialQuantity   decimal.Decimal `json:"initial_quantity"`
     RemainingQuantity decimal.Decimal `json:"remaining_quantity"`
+    DisplayQuantity   *decimal.Decimal `json:"display_quantity,omitempty"` // Iceberg slice size
+    VisibleQuantity   *decimal.Decimal `json:"visible_quantity,omitempty"` // What is left of the current iceberg slice
+    Reserved          decimal.Decimal `json:"reserved"` // Funds still locked for the order: quote asset for buys, base asset for sells
+    MakerFeeRate      decimal.Decimal `json:"maker_fee_rate"` // Fixed when the order is accepted
+    TakerFeeRate      decimal.Decimal `json:"taker_fee_rate"`
     Status            OrderStatus     `json:"status"`
+    TimeInForce       TimeInForce     `json:"time_in_force"`
+    ExpiresAt         *time.Time      `json:"expires_at,omitempty"`
+    PostOnly          bool            `json:"post_only"`
+    PostOnlyAction    PostOnlyAction  `json:"post_only_action,omitempty"`
+    SelfTradePrevention SelfTradePrevention `json:"self_trade_prevention,omitempty"` // Self-trades are allowed when unset
+    RejectReason      string          `json:"reject_reason,omitempty"`
+    TriggeredAt       *time.Time      `json:"triggered_at,omitempty"`
+    PriorityAt        time.Time       `json:"-"` // Queue position at its price level, reset when an iceberg refills
+    RequestHash       string          `json:"-"` // Digest of the placement request, which a retry reusing the client order ID must match
     CreatedAt         time.Time       `json:"created_at"`
     UpdatedAt         time.Time       `json:"updated_at"`
 }
 
+// StopTriggered reports whether the last trade price has crossed the order's stop price.
+// Buy stops trigger at or above the stop price, sell stops at or below it.
+func (o *Order) StopTriggered(lastPrice decimal.Decimal) bool {
+    if o.Side == BUY {
+        return lastPrice.GreaterThanOrEqual(*o.StopPrice)
+    }
+    return lastPrice.LessThanOrEqual(*o.StopPrice)
+}
+
+// IsResting reports whether the order still has quantity on the book.
+func (o *Order) IsResting() bool {
+    return o.Status == OPEN || o.Status == PARTIAL
+}
+
+// IsFinished reports whether the order can no longer trade.
+func (o *Order) IsFinished() bool {
+    switch o.Status {
+    case FILLED, CANCELED, EXPIRED, REJECTED:
+        return true
+    }
+    return false
+}
+
+// IsIceberg reports whether only a display slice of the order is shown on the book.
+func (o *Order) IsIceberg() bool {
+    return o.DisplayQuantity != nil
+}
+
+// DisplayedQuantity returns the quantity the order shows on the book and can trade at its current queue position.
+func (o *Order) DisplayedQuantity() decimal.Decimal {
+    if o.IsIceberg() && o.VisibleQuantity != nil {
+        return *o.VisibleQuantity
+    }
+    return o.RemainingQuantity
+}
+
+// Fill takes quantity off the order and, for icebergs, off the visible slice.
+func (o *Order) Fill(quantity decimal.Decimal) {
+    o.RemainingQuantity = o.RemainingQuantity.Sub(quantity)
+    if o.IsIceberg() && o.VisibleQuantity != nil {
+        visible := o.VisibleQuantity.Sub(quantity)
+        o.VisibleQuantity = &visible
+    }
+}
+
+// Decrement takes quantity off an order without trading it. A resting order keeps its place in
+// the queue.
+func (o *Order) Decrement(quantity decimal.Decimal) {
+    o.InitialQuantity = o.InitialQuantity.Sub(quantity)
+    o.RemainingQuantity = o.RemainingQuantity.Sub(quantity)
+    if o.IsIceberg() && o.VisibleQuantity != nil {
+        visible := decimal.Min(*o.VisibleQuantity, o.RemainingQuantity)
+        o.VisibleQuantity = &visible
+    }
+}
+
+// Replenish refills an iceberg's visible slice from its hidden reserve.
+func (o *Order) Replenish() {
+    visible := decimal.Min(*o.DisplayQuantity, o.RemainingQuantity)
+    o.VisibleQuantity = &visible
+}
+
+// Expired reports whether a GTD or DAY order has reached its expiry time.
+func (o *Order) Expired(now time.Time) bool {
+    return o.ExpiresAt != nil && !o.ExpiresAt.After(now)
+}
+
+// maxClientOrderIDLength matches the client_order_id column.
+const maxClientOrderIDLength = 64
+
 type PlaceOrderRequest struct {
-    Symbol   string          `json:"symbol" binding:"required"`
-    Side     OrderSide       `json:"side" binding:"required"`
-    Type     OrderType       `json:"type" binding:"required"`
+    AccountID       string           `json:"account_id"`
+    ClientOrderID   string           `json:"client_order_id,omitempty"`
+    Symbol          string           `json:"symbol" binding:"required"`
+    Side            OrderSide        `json:"side" binding:"required"`
+    Type            OrderType        `json:"type" binding:"required"`
+    Price           *decimal.Decimal `json:"price,omitempty"`
+    StopPrice       *decimal.Decimal `json:"stop_price,omitempty"`
+    Quantity        decimal.Decimal  `json:"quantity" binding:"required"`
+    DisplayQuantity *decimal.Decimal `json:"display_quantity,omitempty"`
+    TimeInForce     TimeInForce      `json:"time_in_force,omitempty"`
+    ExpiresAt       *time.Time       `json:"expires_at,omitempty"`
+    PostOnly        bool             `json:"post_only,omitempty"`
+    PostOnlyAction  PostOnlyAction   `json:"post_only_action,omitempty"`
+    SelfTradePrevention SelfTradePrevention `json:"self_trade_prevention,omitempty"`
+}
+
+// CancelOrderResult is the engine's verdict on a cancel request.
+type CancelOrderResult struct {
+    Order            *Order          `json:"order"`
+    FilledQuantity   decimal.Decimal `json:"filled_quantity"`
+    CanceledQuantity decimal.Decimal `json:"canceled_quantity"`
+}
+
+// AmendOrderRequest changes the price and/or total quantity of a resting limit order.
+type AmendOrderRequest struct {
     Price    *decimal.Decimal `json:"price,omitempty"`
-    Quantity decimal.Decimal `json:"quantity" binding:"required"`
+    Quantity *decimal.Decimal `json:"quantity,omitempty"`
+}
+
+func (r *AmendOrderRequest) Validate() error {
+    if r.Price == nil && r.Quantity == nil {
+        return ErrEmptyAmendment
+    }
+    
+    if r.Price != nil && r.Price.LessThanOrEqual(decimal.Zero) {
+        return ErrInvalidPrice
+    }
+    
+    if r.Quantity != nil && r.Quantity.LessThanOrEqual(decimal.Zero) {
+        return ErrInvalidQuantity
+    }
+    
+    return nil
 }
 
 func (r *PlaceOrderRequest) Validate() error {
+    if r.AccountID == "" {
+        return ErrAccountRequired
+    }
+    
+    switch r.Type {
+    case LIMIT, MARKET, STOP_LIMIT, STOP_MARKET:
+    default:
+        return ErrInvalidOrderType
+    }
+    
     if r.Quantity.LessThanOrEqual(decimal.Zero) {
         return ErrInvalidQuantity
     }
     
-    if r.Type == LIMIT && (r.Price == nil || r.Price.LessThanOrEqual(decimal.Zero)) {
+    if r.ClientOrderID != "" && !validClientOrderID(r.ClientOrderID) {
+        return ErrInvalidClientOrderID
+    }
+    
+    if !r.Type.IsMarket() && (r.Price == nil || r.Price.LessThanOrEqual(decimal.Zero)) {
         return ErrInvalidPrice
     }
     
-    if r.Type == MARKET && r.Price != nil {
+    if r.Type.IsMarket() && r.Price != nil {
         return ErrMarketOrderWithPrice
     }
     
+    if r.Type.IsStop() && (r.StopPrice == nil || r.StopPrice.LessThanOrEqual(decimal.Zero)) {
+        return ErrInvalidStopPrice
+    }
+    
+    if !r.Type.IsStop() && r.StopPrice != nil {
+        return ErrStopPriceNotAllowed
+    }
+    
     if r.Side != BUY && r.Side != SELL {
         return ErrInvalidSide
     }
     
+    switch r.TimeInForce {
+    case "", GTC, IOC, FOK, GTD, DAY:
+    default:
+        return ErrInvalidTimeInForce
+    }
+    
+    if r.Type.IsMarket() && r.TimeInForce != "" && r.TimeInForce != IOC {
+        return ErrTimeInForceNotAllowed
+    }
+    
+    if r.TimeInForce == GTD && (r.ExpiresAt == nil || !r.ExpiresAt.After(time.Now())) {
+        return ErrInvalidExpiry
+    }
+    
+    if r.TimeInForce != GTD && r.ExpiresAt != nil {
+        return ErrExpiryNotAllowed
+    }
+    
+    switch r.PostOnlyAction {
+    case "", POST_ONLY_REJECT, POST_ONLY_REPRICE:
+    default:
+        return ErrInvalidPostOnlyAction
+    }
+    
+    if r.PostOnlyAction != "" && !r.PostOnly {
+        return ErrInvalidPostOnlyAction
+    }
+    
+    switch r.SelfTradePrevention {
+    case "", STP_CANCEL_NEWEST, STP_CANCEL_OLDEST, STP_CANCEL_BOTH, STP_DECREMENT_AND_CANCEL:
+    default:
+        return ErrInvalidSelfTradePrevention
+    }
+    
+    if r.PostOnly && (r.Type.IsMarket() || r.TimeInForce.IsImmediate()) {
+        return ErrPostOnlyNotAllowed
+    }
+    
+    if r.DisplayQuantity != nil {
+        if r.Type.IsMarket() || r.TimeInForce.IsImmediate() {
+            return ErrIcebergNotAllowed
+        }
+        if r.DisplayQuantity.LessThanOrEqual(decimal.Zero) || r.DisplayQuantity.GreaterThanOrEqual(r.Quantity) {
+            return ErrInvalidDisplayQuantity
+        }
+    }
+    
     return nil
+}
+
+// validClientOrderID accepts up to 64 printable ASCII characters other than spaces and slashes, so
+// the ID fits in a URL path segment.
+func validClientOrderID(id string) bool {
+    if len(id) > maxClientOrderIDLength {
+        return false
+    }
+    for _, c := range id {
+        if c <= ' ' || c > '~' || c == '/' {
+            return false
+        }
+    }
+    return true
 }
\ No newline at end of file
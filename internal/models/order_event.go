@@ -0,0 +1,33 @@
+package models
+
+import (
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+type OrderEventType string
+type OrderEventAction string
+
+const (
+    SELF_TRADE_PREVENTED OrderEventType = "self_trade_prevented" // The order met a resting order of its own account
+)
+
+const (
+    EVENT_CANCELED    OrderEventAction = "canceled"    // The order's remaining quantity was cancelled
+    EVENT_DECREMENTED OrderEventAction = "decremented" // Quantity was taken off the order, which stays live
+    EVENT_UNCHANGED   OrderEventAction = "unchanged"
+)
+
+// OrderEvent is an entry in an order's history for something other than a trade. A prevented
+// self-trade records one event against each of the two orders.
+type OrderEvent struct {
+    ID                  string              `json:"id"`
+    OrderID             string              `json:"order_id"`
+    Type                OrderEventType      `json:"type"`
+    CounterOrderID      string              `json:"counter_order_id"`      // The other order involved
+    SelfTradePrevention SelfTradePrevention `json:"self_trade_prevention"` // Mode that was applied
+    Action              OrderEventAction    `json:"action"`                // What happened to this order
+    Quantity            decimal.Decimal     `json:"quantity"`              // Quantity cancelled or taken off this order
+    CreatedAt           time.Time           `json:"created_at"`
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+    "encoding/base64"
+    "strings"
+    "time"
+)
+
+const (
+    DefaultOrderPageSize = 100
+    MaxOrderPageSize     = 500
+)
+
+// OrderQuery selects a page of orders. Empty filters match every order. Orders are listed
+// oldest first, by created_at and then id, and After continues from the last order of the
+// previous page.
+type OrderQuery struct {
+    AccountID   string
+    Symbol      string
+    Side        OrderSide
+    Type        OrderType
+    Statuses    []OrderStatus // Any of these
+    OpenOnly    bool          // Only orders that can still trade
+    CreatedFrom *time.Time    // Inclusive
+    CreatedTo   *time.Time    // Exclusive
+    After       *OrderCursor
+    Limit       int
+}
+
+// OrderCursor is the position of an order in the listing order.
+type OrderCursor struct {
+    CreatedAt time.Time
+    ID        string
+}
+
+type OrderPage struct {
+    Orders     []Order `json:"orders"`
+    NextCursor string  `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// OpenStatuses are the statuses of orders that can still trade.
+var OpenStatuses = []OrderStatus{OPEN, PARTIAL, UNTRIGGERED}
+
+func (q *OrderQuery) Validate() error {
+    switch q.Side {
+    case "", BUY, SELL:
+    default:
+        return ErrInvalidSide
+    }
+    
+    switch q.Type {
+    case "", LIMIT, MARKET, STOP_LIMIT, STOP_MARKET:
+    default:
+        return ErrInvalidOrderType
+    }
+    
+    for _, status := range q.Statuses {
+        switch status {
+        case OPEN, FILLED, CANCELED, PARTIAL, UNTRIGGERED, EXPIRED, REJECTED:
+        default:
+            return ErrInvalidOrderStatus
+        }
+    }
+    
+    if q.CreatedFrom != nil && q.CreatedTo != nil && !q.CreatedFrom.Before(*q.CreatedTo) {
+        return ErrInvalidCreatedRange
+    }
+    
+    if q.Limit < 0 || q.Limit > MaxOrderPageSize {
+        return ErrInvalidPageSize
+    }
+    return nil
+}
+
+// Matches reports whether the order passes the query's filters. The cursor and limit are not
+// filters.
+func (q *OrderQuery) Matches(order *Order) bool {
+    if q.AccountID != "" && order.AccountID != q.AccountID {
+        return false
+    }
+    if q.Symbol != "" && order.Symbol != q.Symbol {
+        return false
+    }
+    if q.Side != "" && order.Side != q.Side {
+        return false
+    }
+    if q.Type != "" && order.Type != q.Type {
+        return false
+    }
+    if len(q.Statuses) > 0 && !hasStatus(q.Statuses, order.Status) {
+        return false
+    }
+    if q.OpenOnly && !hasStatus(OpenStatuses, order.Status) {
+        return false
+    }
+    if q.CreatedFrom != nil && order.CreatedAt.Before(*q.CreatedFrom) {
+        return false
+    }
+    if q.CreatedTo != nil && !order.CreatedAt.Before(*q.CreatedTo) {
+        return false
+    }
+    return true
+}
+
+func hasStatus(statuses []OrderStatus, status OrderStatus) bool {
+    for _, candidate := range statuses {
+        if candidate == status {
+            return true
+        }
+    }
+    return false
+}
+
+// Follows reports whether the order comes after the cursor in the listing order.
+func (c *OrderCursor) Follows(order *Order) bool {
+    if !order.CreatedAt.Equal(c.CreatedAt) {
+        return order.CreatedAt.After(c.CreatedAt)
+    }
+    return order.ID > c.ID
+}
+
+// CursorOf returns the cursor that continues a listing after the order.
+func CursorOf(order *Order) *OrderCursor {
+    return &OrderCursor{CreatedAt: order.CreatedAt, ID: order.ID}
+}
+
+// String encodes the cursor as the opaque token clients pass back.
+func (c *OrderCursor) String() string {
+    return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + " " + c.ID))
+}
+
+func ParseOrderCursor(token string) (*OrderCursor, error) {
+    decoded, err := base64.RawURLEncoding.DecodeString(token)
+    if err != nil {
+        return nil, ErrInvalidCursor
+    }
+    
+    createdAt, id, found := strings.Cut(string(decoded), " ")
+    if !found || id == "" {
+        return nil, ErrInvalidCursor
+    }
+    
+    parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+    if err != nil {
+        return nil, ErrInvalidCursor
+    }
+    return &OrderCursor{CreatedAt: parsed, ID: id}, nil
+}
@@ -1,15 +1,14 @@
 package models
 
 import (
-    "sort"
-    
     "github.com/shopspring/decimal"
 )
 
 type OrderBook struct {
-    Symbol string       `json:"symbol"`
-    Bids   []PriceLevel `json:"bids"`
-    Asks   []PriceLevel `json:"asks"`
+    Symbol   string       `json:"symbol"`
+    Sequence uint64       `json:"sequence"` // Book version the snapshot was taken at, increases with every change
+    Bids     []PriceLevel `json:"bids"`
+    Asks     []PriceLevel `json:"asks"`
 }
 
 type PriceLevel struct {
@@ -18,73 +17,92 @@ type PriceLevel struct {
     Orders   int             `json:"orders"`
 }
 
-func NewOrderBook(symbol string, orders []Order) *OrderBook {
-    ob := &OrderBook{
-        Symbol: symbol,
-        Bids:   make([]PriceLevel, 0),
-        Asks:   make([]PriceLevel, 0),
+// OrderIterator calls fn for resting orders in book priority order until fn returns false.
+type OrderIterator func(fn func(*Order) bool)
+
+// NewOrderBook aggregates resting orders into price levels. depth limits the number of levels
+// per side (0 returns every level) and a positive increment groups prices into buckets: bids
+// round down and asks round up, so a bucket never looks better than the orders in it.
+func NewOrderBook(symbol string, sequence uint64, bids, asks OrderIterator, depth int, increment decimal.Decimal) *OrderBook {
+    return &OrderBook{
+        Symbol:   symbol,
+        Sequence: sequence,
+        Bids: aggregateLevels(bids, depth, func(price decimal.Decimal) decimal.Decimal {
+            return price.Div(increment).Floor().Mul(increment)
+        }, increment),
+        Asks: aggregateLevels(asks, depth, func(price decimal.Decimal) decimal.Decimal {
+            return price.Div(increment).Ceil().Mul(increment)
+        }, increment),
+    }
+}
+
+func aggregateLevels(orders OrderIterator, depth int, bucket func(decimal.Decimal) decimal.Decimal, increment decimal.Decimal) []PriceLevel {
+    levels := make([]PriceLevel, 0)
+    if orders == nil {
+        return levels
     }
     
-    // Group orders by price level
-    bidLevels := make(map[string]PriceLevel)
-    askLevels := make(map[string]PriceLevel)
-    
-    for _, order := range orders {
-        if order.Status != OPEN && order.Status != PARTIAL {
-            continue
+    orders(func(order *Order) bool {
+        price := *order.Price
+        if increment.IsPositive() {
+            price = bucket(price)
         }
         
-        if order.Type == MARKET {
-            continue // Market orders don't sit on the book
+        // Orders are sorted, so a new price always starts a new level
+        if len(levels) == 0 || !levels[len(levels)-1].Price.Equal(price) {
+            if depth > 0 && len(levels) == depth {
+                return false
+            }
+            levels = append(levels, PriceLevel{Price: price})
         }
         
-        priceStr := order.Price.String()
-        
-        if order.Side == BUY {
-            level := bidLevels[priceStr]
-            level.Price = *order.Price
-            level.Quantity = level.Quantity.Add(order.RemainingQuantity)
-            level.Orders++
-            bidLevels[priceStr] = level
-        } else {
-            level := askLevels[priceStr]
-            level.Price = *order.Price
-            level.Quantity = level.Quantity.Add(order.RemainingQuantity)
-            level.Orders++
-            askLevels[priceStr] = level
-        }
-    }
-    
-    // Convert maps to slices
-    for _, level := range bidLevels {
-        ob.Bids = append(ob.Bids, level)
-    }
-    for _, level := range askLevels {
-        ob.Asks = append(ob.Asks, level)
-    }
-    
-    // Sort bids (highest price first)
-    sort.Slice(ob.Bids, func(i, j int) bool {
-        return ob.Bids[i].Price.GreaterThan(ob.Bids[j].Price)
-    })
-    
-    // Sort asks (lowest price first)
-    sort.Slice(ob.Asks, func(i, j int) bool {
-        return ob.Asks[i].Price.LessThan(ob.Asks[j].Price)
+        level := &levels[len(levels)-1]
+        level.Quantity = level.Quantity.Add(order.DisplayedQuantity())
+        level.Orders++
+        return true
     })
     
-    return ob
+    return levels
 }
 
 // Custom errors
 var (
-    ErrInvalidQuantity      = NewAPIError(400, "INVALID_QUANTITY", "Quantity must be positive")
-    ErrInvalidPrice         = NewAPIError(400, "INVALID_PRICE", "Price must be positive for limit orders")
-    ErrMarketOrderWithPrice = NewAPIError(400, "MARKET_ORDER_WITH_PRICE", "Market orders cannot have a price")
-    ErrInvalidSide          = NewAPIError(400, "INVALID_SIDE", "Side must be 'buy' or 'sell'")
-    ErrOrderNotFound        = NewAPIError(404, "ORDER_NOT_FOUND", "Order not found")
-    ErrOrderAlreadyFilled   = NewAPIError(400, "ORDER_ALREADY_FILLED", "Order is already filled")
-    ErrOrderAlreadyCanceled = NewAPIError(400, "ORDER_ALREADY_CANCELED", "Order is already canceled")
+    ErrInvalidQuantity        = NewAPIError(400, "INVALID_QUANTITY", "Quantity must be positive")
+    ErrInvalidPrice           = NewAPIError(400, "INVALID_PRICE", "Price must be positive for limit orders")
+    ErrMarketOrderWithPrice   = NewAPIError(400, "MARKET_ORDER_WITH_PRICE", "Market orders cannot have a price")
+    ErrInvalidSide            = NewAPIError(400, "INVALID_SIDE", "Side must be 'buy' or 'sell'")
+    ErrInvalidOrderType       = NewAPIError(400, "INVALID_ORDER_TYPE", "Type must be 'limit', 'market', 'stop_limit' or 'stop_market'")
+    ErrInvalidStopPrice       = NewAPIError(400, "INVALID_STOP_PRICE", "Stop price must be positive for stop orders")
+    ErrStopPriceNotAllowed    = NewAPIError(400, "STOP_PRICE_NOT_ALLOWED", "Only stop orders can have a stop price")
+    ErrInvalidTimeInForce     = NewAPIError(400, "INVALID_TIME_IN_FORCE", "Time in force must be 'gtc', 'ioc', 'fok', 'gtd' or 'day'")
+    ErrTimeInForceNotAllowed  = NewAPIError(400, "TIME_IN_FORCE_NOT_ALLOWED", "Market orders are always immediate-or-cancel")
+    ErrInvalidExpiry          = NewAPIError(400, "INVALID_EXPIRY", "GTD orders require an expires_at in the future")
+    ErrExpiryNotAllowed       = NewAPIError(400, "EXPIRY_NOT_ALLOWED", "Only GTD orders can have an expires_at")
+    ErrInvalidPostOnlyAction  = NewAPIError(400, "INVALID_POST_ONLY_ACTION", "Post-only action must be 'reject' or 'reprice' and requires post_only")
+    ErrInvalidSelfTradePrevention = NewAPIError(400, "INVALID_SELF_TRADE_PREVENTION", "Self-trade prevention must be 'cancel_newest', 'cancel_oldest', 'cancel_both' or 'decrement_and_cancel'")
+    ErrPostOnlyNotAllowed     = NewAPIError(400, "POST_ONLY_NOT_ALLOWED", "Post-only is only allowed on resting limit orders")
+    ErrPostOnlyWouldCross     = NewAPIError(400, "POST_ONLY_WOULD_CROSS", "Post-only order would take liquidity")
+    ErrIcebergNotAllowed      = NewAPIError(400, "ICEBERG_NOT_ALLOWED", "Display quantity is only allowed on resting limit orders")
+    ErrInvalidDisplayQuantity = NewAPIError(400, "INVALID_DISPLAY_QUANTITY", "Display quantity must be positive and smaller than quantity")
+    ErrOrderNotFound          = NewAPIError(404, "ORDER_NOT_FOUND", "Order not found")
+    ErrOrderAlreadyFilled     = NewAPIError(400, "ORDER_ALREADY_FILLED", "Order is already filled")
+    ErrEmptyAmendment         = NewAPIError(400, "EMPTY_AMENDMENT", "Amendment must change price or quantity")
+    ErrInvalidAmendQuantity   = NewAPIError(400, "INVALID_AMEND_QUANTITY", "Amended quantity must be greater than the filled quantity")
+    ErrOrderNotAmendable      = NewAPIError(400, "ORDER_NOT_AMENDABLE", "Only resting limit orders can be amended")
+    ErrOrderAlreadyCanceled   = NewAPIError(400, "ORDER_ALREADY_CANCELED", "Order is already canceled")
+    ErrOrderAlreadyExpired    = NewAPIError(400, "ORDER_ALREADY_EXPIRED", "Order has already expired")
+    ErrOrderAlreadyRejected   = NewAPIError(400, "ORDER_ALREADY_REJECTED", "Order was rejected")
+    ErrInvalidClientOrderID   = NewAPIError(400, "INVALID_CLIENT_ORDER_ID", "Client order ID must be at most 64 printable characters without spaces or slashes")
+    ErrDuplicateClientOrderID = NewAPIError(409, "DUPLICATE_CLIENT_ORDER_ID", "An order with this client order ID is still being processed")
+    ErrClientOrderIDConflict  = NewAPIError(409, "CLIENT_ORDER_ID_CONFLICT", "Client order ID was already used for a different order")
+    ErrInvalidOrderStatus     = NewAPIError(400, "INVALID_ORDER_STATUS", "Status must be 'open', 'partial', 'untriggered', 'filled', 'canceled', 'expired' or 'rejected'")
+    ErrInvalidCreatedRange    = NewAPIError(400, "INVALID_CREATED_RANGE", "created_from must be before created_to")
+    ErrInvalidPageSize        = NewAPIError(400, "INVALID_PAGE_SIZE", "Limit must be between 1 and 500")
+    ErrInvalidCursor          = NewAPIError(400, "INVALID_CURSOR", "Cursor must be a next_cursor returned by a previous page")
+    ErrOrderNotOnBook         = NewAPIError(409, "ORDER_NOT_ON_BOOK", "Order is not resting on the book")
+    ErrSnapshotsDisabled      = NewAPIError(409, "SNAPSHOTS_DISABLED", "Snapshots are not configured")
+    ErrWriteBehindDisabled    = NewAPIError(409, "WRITE_BEHIND_DISABLED", "Commands are committed to storage one at a time")
+    ErrNotApplied             = NewAPIError(503, "NOT_APPLIED", "The request could not be saved and had no effect")
 )
 
 type APIError struct {
@@ -6,12 +6,44 @@ import (
     "github.com/shopspring/decimal"
 )
 
+// Trade records an execution and the fee charged to each side. Each side pays its fee in the
+// asset it receives: the buyer in the base asset, the seller in the quote asset. A negative fee is
+// a rebate.
 type Trade struct {
-    ID          string          `json:"id"`
-    Symbol      string          `json:"symbol"`
-    BuyOrderID  string          `json:"buy_order_id"`
-    SellOrderID string          `json:"sell_order_id"`
-    Price       decimal.Decimal `json:"price"`
-    Quantity    decimal.Decimal `json:"quantity"`
-    ExecutedAt  time.Time       `json:"executed_at"`
+    ID             string          `json:"id"`
+    Symbol         string          `json:"symbol"`
+    BuyOrderID     string          `json:"buy_order_id"`
+    SellOrderID    string          `json:"sell_order_id"`
+    Price          decimal.Decimal `json:"price"`
+    Quantity       decimal.Decimal `json:"quantity"`
+    TakerSide      OrderSide       `json:"taker_side,omitempty"`
+    MakerAccountID string          `json:"maker_account_id,omitempty"`
+    TakerAccountID string          `json:"taker_account_id,omitempty"`
+    MakerFee       decimal.Decimal `json:"maker_fee"`
+    MakerFeeAsset  string          `json:"maker_fee_asset,omitempty"`
+    TakerFee       decimal.Decimal `json:"taker_fee"`
+    TakerFeeAsset  string          `json:"taker_fee_asset,omitempty"`
+    ExecutedAt     time.Time       `json:"executed_at"`
+    Sequence       uint64          `json:"sequence"`   // Journal sequence of the command that executed it
+    FillIndex      int             `json:"fill_index"` // Position among that command's trades
+}
+
+// After reports whether the trade was executed after other. Trades of one command share
+// ExecutedAt; trades stored before sequences existed have none and fall back to time and ID.
+func (t *Trade) After(other *Trade) bool {
+    if t.Sequence != other.Sequence {
+        return t.Sequence > other.Sequence
+    }
+    if t.FillIndex != other.FillIndex {
+        return t.FillIndex > other.FillIndex
+    }
+    if !t.ExecutedAt.Equal(other.ExecutedAt) {
+        return t.ExecutedAt.After(other.ExecutedAt)
+    }
+    return t.ID > other.ID
+}
+
+// Cost returns the trade's value in the quote asset.
+func (t *Trade) Cost() decimal.Decimal {
+    return t.Price.Mul(t.Quantity)
 }
\ No newline at end of file
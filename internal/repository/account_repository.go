@@ -0,0 +1,176 @@
+package repository
+
+import (
+    "database/sql"
+    "order-matching-system/internal/database"
+    "order-matching-system/internal/models"
+    
+    "github.com/shopspring/decimal"
+)
+
+type AccountRepository struct {
+    db DBTX
+}
+
+func NewAccountRepository(db DBTX) *AccountRepository {
+    return &AccountRepository{db: db}
+}
+
+func (r *AccountRepository) Create(account *models.Account) error {
+    fees, err := feeScheduleJSON(account.Fees)
+    if err != nil {
+        return err
+    }
+    _, err = r.db.Exec(`INSERT INTO accounts (id, fee_schedule, created_at) VALUES (?, ?, ?)`, account.ID, fees, account.CreatedAt)
+    return err
+}
+
+func (r *AccountRepository) GetByID(id string) (*models.Account, error) {
+    account, err := r.scanAccount(r.db.QueryRow(`SELECT id, fee_schedule, created_at FROM accounts WHERE id = ?`, id))
+    if err == sql.ErrNoRows {
+        return nil, models.ErrAccountNotFound
+    }
+    return account, err
+}
+
+func (r *AccountRepository) GetAll() ([]models.Account, error) {
+    rows, err := r.db.Query(`SELECT id, fee_schedule, created_at FROM accounts ORDER BY id ASC`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    var accounts []models.Account
+    for rows.Next() {
+        account, err := r.scanAccount(rows)
+        if err != nil {
+            return nil, err
+        }
+        accounts = append(accounts, *account)
+    }
+    return accounts, rows.Err()
+}
+
+// SetFees replaces the account's fee schedule; nil clears it.
+func (r *AccountRepository) SetFees(accountID string, fees *models.FeeSchedule) error {
+    encoded, err := feeScheduleJSON(fees)
+    if err != nil {
+        return err
+    }
+    
+    result, err := r.db.Exec(`UPDATE accounts SET fee_schedule = ? WHERE id = ?`, encoded, accountID)
+    if err != nil {
+        return err
+    }
+    // MySQL counts only changed rows, so an unchanged schedule is not taken for a missing account
+    if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+        if _, err := r.GetByID(accountID); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (r *AccountRepository) scanAccount(scanner interface {
+    Scan(dest ...interface{}) error
+}) (*models.Account, error) {
+    var account models.Account
+    var fees sql.NullString
+    if err := scanner.Scan(&account.ID, &fees, &account.CreatedAt); err != nil {
+        return nil, err
+    }
+    
+    var err error
+    if account.Fees, err = parseFeeSchedule(fees); err != nil {
+        return nil, err
+    }
+    return &account, nil
+}
+
+// BalanceRepository reads and changes balances. Add reads a balance before writing it, since
+// SQLite cannot add exactly to the decimals it stores as text, so it must run in a transaction.
+type BalanceRepository struct {
+    db     DBTX
+    driver string
+}
+
+func NewBalanceRepository(db DBTX, driver string) *BalanceRepository {
+    return &BalanceRepository{db: db, driver: driver}
+}
+
+func (r *BalanceRepository) GetAll() ([]models.Balance, error) {
+    return r.query(`SELECT account_id, asset, available, locked FROM balances ORDER BY account_id ASC, asset ASC`)
+}
+
+func (r *BalanceRepository) GetByAccount(accountID string) ([]models.Balance, error) {
+    return r.query(`SELECT account_id, asset, available, locked FROM balances WHERE account_id = ? ORDER BY asset ASC`, accountID)
+}
+
+func (r *BalanceRepository) query(query string, args ...interface{}) ([]models.Balance, error) {
+    rows, err := r.db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    var balances []models.Balance
+    for rows.Next() {
+        var balance models.Balance
+        if err := rows.Scan(&balance.AccountID, &balance.Asset, &balance.Available, &balance.Locked); err != nil {
+            return nil, err
+        }
+        balances = append(balances, balance)
+    }
+    return balances, rows.Err()
+}
+
+// Add applies a change to a balance, creating the balance the first time the account holds the
+// asset. The row is locked as it is read, so transactions changing the same balance queue up
+// instead of losing each other's changes.
+func (r *BalanceRepository) Add(change *models.Balance) error {
+    available, locked, err := r.lock(change.AccountID, change.Asset)
+    if err == sql.ErrNoRows {
+        if err := r.insert(change.AccountID, change.Asset); err != nil {
+            return err
+        }
+        available, locked, err = r.lock(change.AccountID, change.Asset)
+    }
+    if err == sql.ErrNoRows {
+        return models.ErrAccountNotFound
+    }
+    if err != nil {
+        return err
+    }
+    
+    _, err = r.db.Exec(`UPDATE balances SET available = ?, locked = ? WHERE account_id = ? AND asset = ?`,
+        available.Add(change.Available).String(),
+        locked.Add(change.Locked).String(),
+        change.AccountID,
+        change.Asset,
+    )
+    return err
+}
+
+// lock reads a balance for update. SQLite has no row locks; its writing transactions hold the
+// whole database instead.
+func (r *BalanceRepository) lock(accountID, asset string) (available, locked decimal.Decimal, err error) {
+    query := `SELECT available, locked FROM balances WHERE account_id = ? AND asset = ?`
+    if r.driver != database.DriverSQLite {
+        query += ` FOR UPDATE`
+    }
+    err = r.db.QueryRow(query, accountID, asset).Scan(&available, &locked)
+    return available, locked, err
+}
+
+// insert creates an empty balance unless a concurrent transaction already has. MySQL also ignores
+// an unknown account here, which the read that follows reports.
+func (r *BalanceRepository) insert(accountID, asset string) error {
+    query := `INSERT INTO balances (account_id, asset, available, locked) VALUES (?, ?, '0', '0')`
+    if r.driver == database.DriverMySQL {
+        query = `INSERT IGNORE INTO balances (account_id, asset, available, locked) VALUES (?, ?, '0', '0')`
+    } else {
+        query += ` ON CONFLICT (account_id, asset) DO NOTHING`
+    }
+    _, err := r.db.Exec(query, accountID, asset)
+    return err
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+    "fmt"
+    "order-matching-system/internal/database"
+    "order-matching-system/internal/models"
+    "sort"
+    "strings"
+)
+
+// Batch is the combined effect of a run of committed units of work, written to storage in one
+// transaction.
+type Batch struct {
+    Orders         []models.Order       // Latest state of every order created or updated
+    Trades         []models.Trade       // In the order they were created
+    OrderEvents    []models.OrderEvent  // In the order they were created
+    NewInstruments []models.Instrument  // Latest state of instruments created in the batch
+    Instruments    []models.Instrument  // Latest state of instruments that existed before it
+    Balances       []models.Balance     // Sum of the changes to each balance, by account and asset
+    Watermarks     map[string]Watermark // Last watermark set for each symbol
+}
+
+// BatchWriter writes a batch in one transaction, orders and trades as multi-row statements.
+type BatchWriter interface {
+    WriteBatch(batch *Batch) error
+}
+
+// batchRows bounds the rows in one multi-row statement, keeping it well under every driver's
+// limit on bound parameters.
+const batchRows = 200
+
+func (u *sqlUnitOfWork) WriteBatch(batch *Batch) error {
+    tx, err := u.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    
+    conn := u.wrap(tx)
+    instruments := NewInstrumentRepository(conn)
+    for i := range batch.NewInstruments {
+        if err := instruments.Create(&batch.NewInstruments[i]); err != nil {
+            return err
+        }
+    }
+    for i := range batch.Instruments {
+        if err := instruments.Update(&batch.Instruments[i]); err != nil {
+            return err
+        }
+    }
+    
+    // Orders before the trades that reference them
+    if err := NewOrderRepository(conn).SaveAll(batch.Orders, u.driver); err != nil {
+        return err
+    }
+    if err := NewTradeRepository(conn).CreateAll(batch.Trades); err != nil {
+        return err
+    }
+    if err := NewOrderEventRepository(conn).CreateAll(batch.OrderEvents); err != nil {
+        return err
+    }
+    
+    balances := NewBalanceRepository(conn, u.driver)
+    for i := range batch.Balances {
+        if err := balances.Add(&batch.Balances[i]); err != nil {
+            return err
+        }
+    }
+    
+    watermarks := NewWatermarkRepository(conn)
+    for _, symbol := range sortedSymbols(batch.Watermarks) {
+        if err := watermarks.Set(symbol, batch.Watermarks[symbol]); err != nil {
+            return err
+        }
+    }
+    
+    return tx.Commit()
+}
+
+// placeholders returns rows groups of columns ? placeholders for a multi-row VALUES list.
+func placeholders(rows, columns int) string {
+    row := "(" + strings.TrimSuffix(strings.Repeat("?, ", columns), ", ") + ")"
+    return strings.TrimSuffix(strings.Repeat(row+", ", rows), ", ")
+}
+
+// upsertClause makes a multi-row INSERT update the given columns of rows whose key already
+// exists. MySQL spells this differently from Postgres and SQLite.
+func upsertClause(driver, key string, columns []string) string {
+    assignments := make([]string, len(columns))
+    for i, column := range columns {
+        if driver == database.DriverMySQL {
+            assignments[i] = fmt.Sprintf("%s = VALUES(%s)", column, column)
+        } else {
+            assignments[i] = fmt.Sprintf("%s = excluded.%s", column, column)
+        }
+    }
+    
+    if driver == database.DriverMySQL {
+        return "ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+    }
+    return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", key, strings.Join(assignments, ", "))
+}
+
+func sortedSymbols(watermarks map[string]Watermark) []string {
+    symbols := make([]string, 0, len(watermarks))
+    for symbol := range watermarks {
+        symbols = append(symbols, symbol)
+    }
+    sort.Strings(symbols)
+    return symbols
+}
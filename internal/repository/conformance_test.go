@@ -0,0 +1,1108 @@
+package repository_test
+
+import (
+    "database/sql"
+    "fmt"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "order-matching-system/internal/database"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+
+    "github.com/shopspring/decimal"
+)
+
+// The conformance suite runs every backend through the same checks. Memory and SQLite always
+// run; MySQL and Postgres run when TEST_MYSQL_DSN or TEST_POSTGRES_DSN point at a scratch
+// database, which the suite migrates and empties.
+func TestConformance(t *testing.T) {
+    backends := map[string]func(t *testing.T) *repository.Store{
+        "memory": newMemoryBackend,
+        "sqlite": func(t *testing.T) *repository.Store {
+            path := filepath.Join(t.TempDir(), "conformance.db")
+            dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite&_txlock=immediate", path)
+            return newSQLBackend(t, database.DriverSQLite, dsn, repository.NewSQLiteStore)
+        },
+        // Flushes small batches often, so reads race writes moving rows from memory to storage
+        "sqlite-write-behind": func(t *testing.T) *repository.Store {
+            path := filepath.Join(t.TempDir(), "conformance.db")
+            dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite&_txlock=immediate", path)
+            return newWriteBehindBackend(t, newSQLBackend(t, database.DriverSQLite, dsn, repository.NewSQLiteStore))
+        },
+        "mysql": func(t *testing.T) *repository.Store {
+            dsn := os.Getenv("TEST_MYSQL_DSN")
+            if dsn == "" {
+                t.Skip("TEST_MYSQL_DSN not set")
+            }
+            return newSQLBackend(t, database.DriverMySQL, dsn, repository.NewMySQLStore)
+        },
+        "postgres": func(t *testing.T) *repository.Store {
+            dsn := os.Getenv("TEST_POSTGRES_DSN")
+            if dsn == "" {
+                t.Skip("TEST_POSTGRES_DSN not set")
+            }
+            return newSQLBackend(t, database.DriverPostgres, dsn, repository.NewPostgresStore)
+        },
+    }
+
+    tests := map[string]func(t *testing.T, store *repository.Store){
+        "OrderRoundTrip":       testOrderRoundTrip,
+        "OrderNotFound":        testOrderNotFound,
+        "OrderUpdate":          testOrderUpdate,
+        "OpenOrdersBySymbol":   testOpenOrdersBySymbol,
+        "TradesBySymbol":       testTradesBySymbol,
+        "TransactionCommit":    testTransactionCommit,
+        "TransactionRollback":  testTransactionRollback,
+        "Instruments":          testInstruments,
+        "Watermark":            testWatermark,
+        "Balances":             testBalances,
+        "Fees":                 testFees,
+        "OrderEvents":          testOrderEvents,
+        "ClientOrderIDs":       testClientOrderIDs,
+        "ListOrders":           testListOrders,
+        "WriteBatch":           testWriteBatch,
+    }
+
+    for backendName, newStore := range backends {
+        newStore := newStore
+        t.Run(backendName, func(t *testing.T) {
+            for testName, test := range tests {
+                test := test
+                t.Run(testName, func(t *testing.T) {
+                    test(t, newStore(t))
+                })
+            }
+        })
+    }
+}
+
+func newMemoryBackend(t *testing.T) *repository.Store {
+    store := repository.NewMemoryStore()
+    for _, symbol := range []string{"BTCUSD", "ETHUSD"} {
+        instrument := &models.Instrument{
+            Symbol:            symbol,
+            BaseAsset:         symbol[:3],
+            QuoteAsset:        "USD",
+            Status:            models.ACTIVE,
+            PricePrecision:    2,
+            QuantityPrecision: 8,
+            TickSize:          decimal.RequireFromString("0.01"),
+            LotSize:           decimal.RequireFromString("0.0001"),
+            MinQuantity:       decimal.RequireFromString("0.0001"),
+            MinNotional:       decimal.NewFromInt(10),
+            CreatedAt:         baseTime,
+            UpdatedAt:         baseTime,
+        }
+        if err := store.Instruments.Create(instrument); err != nil {
+            t.Fatalf("seed instrument: %v", err)
+        }
+    }
+    return store
+}
+
+func newSQLBackend(t *testing.T, driver, dsn string, newStore func(*sql.DB) *repository.Store) *repository.Store {
+    db, err := database.Connect(driver, dsn)
+    if err != nil {
+        t.Fatalf("connect: %v", err)
+    }
+    t.Cleanup(func() { db.Close() })
+
+    if err := database.RunMigrations(db, driver); err != nil {
+        t.Fatalf("migrate: %v", err)
+    }
+
+    // Migrations seed BTCUSD and ETHUSD; start every test from just those, active and without fees, and no orders,
+    // trades, events, accounts or watermarks. Rows that reference orders go before the orders themselves.
+    for _, statement := range []string{
+        "DELETE FROM trades",
+        "DELETE FROM order_events",
+        "DELETE FROM orders",
+        "DELETE FROM balances",
+        "DELETE FROM accounts",
+        "DELETE FROM symbols WHERE symbol NOT IN ('BTCUSD', 'ETHUSD')",
+        "UPDATE symbols SET status = 'active', fee_schedule = NULL",
+        "DELETE FROM journal_watermarks",
+    } {
+        if _, err := db.Exec(statement); err != nil {
+            t.Fatalf("%s: %v", statement, err)
+        }
+    }
+    return newStore(db)
+}
+
+func newWriteBehindBackend(t *testing.T, target *repository.Store) *repository.Store {
+    writeBehind, err := repository.NewWriteBehind(target, repository.WriteBehindConfig{
+        FlushSize:     2,
+        FlushInterval: time.Millisecond,
+        MaxPending:    4,
+    })
+    if err != nil {
+        t.Fatalf("write-behind: %v", err)
+    }
+    t.Cleanup(func() { writeBehind.Close() })
+    return writeBehind.Store()
+}
+
+// Whole seconds, since MySQL keeps fractions of a second only in orders.created_at and priority_at.
+var baseTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func dec(value string) decimal.Decimal {
+    return decimal.RequireFromString(value)
+}
+
+func decPtr(value string) *decimal.Decimal {
+    d := dec(value)
+    return &d
+}
+
+func newOrder(id, symbol string, side models.OrderSide, price string, createdAt time.Time) *models.Order {
+    return &models.Order{
+        ID:                id,
+        Symbol:            symbol,
+        Side:              side,
+        Type:              models.LIMIT,
+        Price:             decPtr(price),
+        InitialQuantity:   dec("1.5"),
+        RemainingQuantity: dec("1.5"),
+        Status:            models.OPEN,
+        TimeInForce:       models.GTC,
+        PriorityAt:        createdAt,
+        CreatedAt:         createdAt,
+        UpdatedAt:         createdAt,
+    }
+}
+
+func newTrade(id, symbol, buyID, sellID string, executedAt time.Time) *models.Trade {
+    return &models.Trade{
+        ID:          id,
+        Symbol:      symbol,
+        BuyOrderID:  buyID,
+        SellOrderID: sellID,
+        Price:       dec("100.25"),
+        Quantity:    dec("0.5"),
+        ExecutedAt:  executedAt,
+    }
+}
+
+func mustCreateOrder(t *testing.T, store repository.OrderStore, order *models.Order) {
+    t.Helper()
+    if err := store.Create(order); err != nil {
+        t.Fatalf("create order %s: %v", order.ID, err)
+    }
+}
+
+func orderIDs(orders []models.Order) []string {
+    ids := make([]string, len(orders))
+    for i, order := range orders {
+        ids[i] = order.ID
+    }
+    return ids
+}
+
+func tradeIDs(trades []models.Trade) []string {
+    ids := make([]string, len(trades))
+    for i, trade := range trades {
+        ids[i] = trade.ID
+    }
+    return ids
+}
+
+func assertIDs(t *testing.T, got, want []string) {
+    t.Helper()
+    if fmt.Sprint(got) != fmt.Sprint(want) {
+        t.Fatalf("got ids %v, want %v", got, want)
+    }
+}
+
+func assertDecimal(t *testing.T, field string, got *decimal.Decimal, want string) {
+    t.Helper()
+    if want == "" {
+        if got != nil {
+            t.Fatalf("%s = %s, want NULL", field, got)
+        }
+        return
+    }
+    if got == nil || !got.Equal(dec(want)) {
+        t.Fatalf("%s = %v, want %s", field, got, want)
+    }
+}
+
+func testOrderRoundTrip(t *testing.T, store *repository.Store) {
+    expiresAt := baseTime.Add(time.Hour)
+    account := &models.Account{ID: "account-1", CreatedAt: baseTime}
+    if err := store.Accounts.Create(account); err != nil {
+        t.Fatalf("create account: %v", err)
+    }
+    order := &models.Order{
+        ID:                "00000000-0000-0000-0000-000000000001",
+        AccountID:         account.ID,
+        ClientOrderID:     "client-1",
+        Symbol:            "BTCUSD",
+        Side:              models.SELL,
+        Type:              models.STOP_LIMIT,
+        Price:             decPtr("12345.67"),
+        StopPrice:         decPtr("12000.01"),
+        InitialQuantity:   dec("0.12345678"),
+        RemainingQuantity: dec("0.00000001"),
+        DisplayQuantity:   decPtr("0.1"),
+        VisibleQuantity:   decPtr("0.00000001"),
+        Reserved:          dec("0.00000001"),
+        MakerFeeRate:      dec("-0.0001"),
+        TakerFeeRate:      dec("0.0025"),
+        Status:            models.UNTRIGGERED,
+        TimeInForce:       models.GTD,
+        ExpiresAt:         &expiresAt,
+        PostOnly:          true,
+        PostOnlyAction:    models.POST_ONLY_REPRICE,
+        SelfTradePrevention: models.STP_DECREMENT_AND_CANCEL,
+        PriorityAt:        baseTime,
+        CreatedAt:         baseTime,
+        UpdatedAt:         baseTime,
+        RequestHash:       strings.Repeat("ab", 32),
+    }
+    mustCreateOrder(t, store.Orders, order)
+
+    got, err := store.Orders.GetByID(order.ID)
+    if err != nil {
+        t.Fatalf("get order: %v", err)
+    }
+
+    if got.Symbol != order.Symbol || got.Side != order.Side || got.Type != order.Type || got.Status != order.Status ||
+        got.TimeInForce != order.TimeInForce || !got.PostOnly || got.PostOnlyAction != order.PostOnlyAction || got.RejectReason != "" ||
+        got.AccountID != account.ID || got.ClientOrderID != order.ClientOrderID || got.SelfTradePrevention != order.SelfTradePrevention ||
+        got.RequestHash != order.RequestHash {
+        t.Fatalf("got %+v, want %+v", got, order)
+    }
+
+    assertDecimal(t, "price", got.Price, "12345.67")
+    assertDecimal(t, "stop_price", got.StopPrice, "12000.01")
+    assertDecimal(t, "initial_quantity", &got.InitialQuantity, "0.12345678")
+    assertDecimal(t, "remaining_quantity", &got.RemainingQuantity, "0.00000001")
+    assertDecimal(t, "display_quantity", got.DisplayQuantity, "0.1")
+    assertDecimal(t, "visible_quantity", got.VisibleQuantity, "0.00000001")
+    assertDecimal(t, "reserved", &got.Reserved, "0.00000001")
+    assertDecimal(t, "maker_fee_rate", &got.MakerFeeRate, "-0.0001")
+    assertDecimal(t, "taker_fee_rate", &got.TakerFeeRate, "0.0025")
+
+    if got.ExpiresAt == nil || !got.ExpiresAt.Equal(expiresAt) {
+        t.Fatalf("expires_at = %v, want %v", got.ExpiresAt, expiresAt)
+    }
+    if got.TriggeredAt != nil {
+        t.Fatalf("triggered_at = %v, want NULL", got.TriggeredAt)
+    }
+    if !got.CreatedAt.Equal(baseTime) || !got.PriorityAt.Equal(baseTime) {
+        t.Fatalf("created_at = %v, priority_at = %v, want %v", got.CreatedAt, got.PriorityAt, baseTime)
+    }
+
+    market := newOrder("00000000-0000-0000-0000-000000000002", "BTCUSD", models.BUY, "1", baseTime)
+    market.Type = models.MARKET
+    market.Price = nil
+    market.TimeInForce = models.IOC
+    mustCreateOrder(t, store.Orders, market)
+
+    if got, err = store.Orders.GetByID(market.ID); err != nil {
+        t.Fatalf("get market order: %v", err)
+    }
+    assertDecimal(t, "market price", got.Price, "")
+    if got.ExpiresAt != nil || got.PostOnlyAction != "" || got.AccountID != "" || got.ClientOrderID != "" || got.SelfTradePrevention != "" {
+        t.Fatalf("optional fields not NULL: %+v", got)
+    }
+}
+
+func testOrderNotFound(t *testing.T, store *repository.Store) {
+    if _, err := store.Orders.GetByID("missing"); err != models.ErrOrderNotFound {
+        t.Fatalf("got %v, want ErrOrderNotFound", err)
+    }
+}
+
+func testOrderUpdate(t *testing.T, store *repository.Store) {
+    order := newOrder("00000000-0000-0000-0000-000000000001", "BTCUSD", models.BUY, "100", baseTime)
+    mustCreateOrder(t, store.Orders, order)
+
+    triggeredAt := baseTime.Add(time.Minute)
+    order.Price = decPtr("101.5")
+    order.RemainingQuantity = dec("0.25")
+    order.Status = models.PARTIAL
+    order.RejectReason = "note"
+    order.TriggeredAt = &triggeredAt
+    order.PriorityAt = baseTime.Add(2 * time.Minute)
+    order.UpdatedAt = baseTime.Add(3 * time.Minute)
+    if err := store.Orders.Update(order); err != nil {
+        t.Fatalf("update: %v", err)
+    }
+
+    got, err := store.Orders.GetByID(order.ID)
+    if err != nil {
+        t.Fatalf("get order: %v", err)
+    }
+    assertDecimal(t, "price", got.Price, "101.5")
+    assertDecimal(t, "remaining_quantity", &got.RemainingQuantity, "0.25")
+    if got.Status != models.PARTIAL || got.RejectReason != "note" || got.TriggeredAt == nil || !got.TriggeredAt.Equal(triggeredAt) ||
+        !got.PriorityAt.Equal(order.PriorityAt) || !got.UpdatedAt.Equal(order.UpdatedAt) {
+        t.Fatalf("got %+v, want %+v", got, order)
+    }
+}
+
+func testOpenOrdersBySymbol(t *testing.T, store *repository.Store) {
+    // Inserted out of order; same created_at falls back to id
+    orders := []*models.Order{
+        newOrder("c", "BTCUSD", models.BUY, "100", baseTime.Add(time.Second)),
+        newOrder("b", "BTCUSD", models.SELL, "101", baseTime),
+        newOrder("a", "BTCUSD", models.BUY, "99", baseTime.Add(time.Second)),
+        newOrder("d", "BTCUSD", models.BUY, "98", baseTime.Add(-time.Second)),
+        newOrder("e", "ETHUSD", models.BUY, "10", baseTime),
+        newOrder("f", "BTCUSD", models.BUY, "97", baseTime),
+        newOrder("g", "BTCUSD", models.BUY, "96", baseTime),
+        newOrder("h", "BTCUSD", models.SELL, "120", baseTime),
+    }
+    orders[1].Status = models.PARTIAL
+    orders[5].Status = models.FILLED
+    orders[6].Status = models.CANCELED
+    orders[7].Status = models.UNTRIGGERED
+
+    for _, order := range orders {
+        mustCreateOrder(t, store.Orders, order)
+    }
+
+    got, err := store.Orders.GetOpenOrdersBySymbol("BTCUSD")
+    if err != nil {
+        t.Fatalf("get open orders: %v", err)
+    }
+    assertIDs(t, orderIDs(got), []string{"d", "b", "h", "a", "c"})
+
+    if got, err = store.Orders.GetOpenOrdersBySymbol("SOLUSD"); err != nil || len(got) != 0 {
+        t.Fatalf("got %v, %v for unknown symbol", got, err)
+    }
+}
+
+func testTradesBySymbol(t *testing.T, store *repository.Store) {
+    buy := newOrder("buy", "BTCUSD", models.BUY, "100", baseTime)
+    sell := newOrder("sell", "BTCUSD", models.SELL, "100", baseTime)
+    ethBuy := newOrder("eth-buy", "ETHUSD", models.BUY, "10", baseTime)
+    ethSell := newOrder("eth-sell", "ETHUSD", models.SELL, "10", baseTime)
+    for _, order := range []*models.Order{buy, sell, ethBuy, ethSell} {
+        mustCreateOrder(t, store.Orders, order)
+    }
+
+    trades := []*models.Trade{
+        newTrade("t1", "BTCUSD", "buy", "sell", baseTime),
+        newTrade("t3", "BTCUSD", "buy", "sell", baseTime.Add(time.Second)),
+        newTrade("t2", "BTCUSD", "buy", "sell", baseTime.Add(time.Second)),
+        newTrade("t4", "BTCUSD", "buy", "sell", baseTime.Add(2*time.Second)),
+        newTrade("t5", "ETHUSD", "eth-buy", "eth-sell", baseTime.Add(3*time.Second)),
+    }
+    for _, trade := range trades {
+        if err := store.Trades.Create(trade); err != nil {
+            t.Fatalf("create trade: %v", err)
+        }
+    }
+
+    got, err := store.Trades.GetBySymbol("BTCUSD", 10)
+    if err != nil {
+        t.Fatalf("get trades: %v", err)
+    }
+    assertIDs(t, tradeIDs(got), []string{"t4", "t3", "t2", "t1"})
+
+    if !got[0].Price.Equal(dec("100.25")) || !got[0].Quantity.Equal(dec("0.5")) || !got[0].ExecutedAt.Equal(baseTime.Add(2*time.Second)) {
+        t.Fatalf("got %+v", got[0])
+    }
+
+    if got, err = store.Trades.GetBySymbol("BTCUSD", 2); err != nil {
+        t.Fatalf("get trades: %v", err)
+    }
+    assertIDs(t, tradeIDs(got), []string{"t4", "t3"})
+
+    // Trades of one command share executed_at and are ordered by fill, whatever their ids
+    for i, id := range []string{"z", "m", "a"} {
+        trade := newTrade(id, "BTCUSD", "buy", "sell", baseTime.Add(4*time.Second))
+        trade.Sequence = 7
+        trade.FillIndex = i
+        if err := store.Trades.Create(trade); err != nil {
+            t.Fatalf("create trade: %v", err)
+        }
+    }
+    if got, err = store.Trades.GetBySymbol("BTCUSD", 4); err != nil {
+        t.Fatalf("get trades: %v", err)
+    }
+    assertIDs(t, tradeIDs(got), []string{"a", "m", "z", "t4"})
+    if got[0].Sequence != 7 || got[0].FillIndex != 2 {
+        t.Fatalf("got %+v", got[0])
+    }
+    if got, err = store.Trades.GetSince(baseTime.Add(2 * time.Second)); err != nil {
+        t.Fatalf("get trades since: %v", err)
+    }
+    assertIDs(t, tradeIDs(got), []string{"t4", "t5", "z", "m", "a"})
+}
+
+func testTransactionCommit(t *testing.T, store *repository.Store) {
+    resting := newOrder("resting", "BTCUSD", models.SELL, "100", baseTime)
+    mustCreateOrder(t, store.Orders, resting)
+
+    tx, err := store.UnitOfWork.Begin()
+    if err != nil {
+        t.Fatalf("begin: %v", err)
+    }
+    defer tx.Rollback()
+
+    incoming := newOrder("incoming", "BTCUSD", models.BUY, "100", baseTime.Add(time.Second))
+    incoming.Status = models.FILLED
+    mustCreateOrder(t, tx.Orders(), incoming)
+
+    resting.Status = models.PARTIAL
+    resting.RemainingQuantity = dec("0.5")
+    if err := tx.Orders().Update(resting); err != nil {
+        t.Fatalf("update in tx: %v", err)
+    }
+    if err := tx.Trades().Create(newTrade("trade", "BTCUSD", "incoming", "resting", baseTime.Add(time.Second))); err != nil {
+        t.Fatalf("create trade in tx: %v", err)
+    }
+
+    // The transaction sees its own writes
+    got, err := tx.Orders().GetByID("resting")
+    if err != nil || got.Status != models.PARTIAL {
+        t.Fatalf("in tx got %+v, %v", got, err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        t.Fatalf("commit: %v", err)
+    }
+    if err := tx.Rollback(); err != nil {
+        t.Fatalf("rollback after commit: %v", err)
+    }
+
+    if got, err = store.Orders.GetByID("resting"); err != nil || got.Status != models.PARTIAL || !got.RemainingQuantity.Equal(dec("0.5")) {
+        t.Fatalf("after commit got %+v, %v", got, err)
+    }
+    if _, err = store.Orders.GetByID("incoming"); err != nil {
+        t.Fatalf("incoming order not committed: %v", err)
+    }
+    trades, err := store.Trades.GetBySymbol("BTCUSD", 10)
+    if err != nil {
+        t.Fatalf("get trades: %v", err)
+    }
+    assertIDs(t, tradeIDs(trades), []string{"trade"})
+}
+
+func testTransactionRollback(t *testing.T, store *repository.Store) {
+    resting := newOrder("resting", "BTCUSD", models.SELL, "100", baseTime)
+    mustCreateOrder(t, store.Orders, resting)
+
+    tx, err := store.UnitOfWork.Begin()
+    if err != nil {
+        t.Fatalf("begin: %v", err)
+    }
+
+    mustCreateOrder(t, tx.Orders(), newOrder("incoming", "BTCUSD", models.BUY, "100", baseTime))
+    updated := *resting
+    updated.Status = models.FILLED
+    if err := tx.Orders().Update(&updated); err != nil {
+        t.Fatalf("update in tx: %v", err)
+    }
+    if err := tx.Trades().Create(newTrade("trade", "BTCUSD", "incoming", "resting", baseTime)); err != nil {
+        t.Fatalf("create trade in tx: %v", err)
+    }
+
+    if err := tx.Rollback(); err != nil {
+        t.Fatalf("rollback: %v", err)
+    }
+
+    if got, err := store.Orders.GetByID("resting"); err != nil || got.Status != models.OPEN {
+        t.Fatalf("after rollback got %+v, %v", got, err)
+    }
+    if _, err := store.Orders.GetByID("incoming"); err != models.ErrOrderNotFound {
+        t.Fatalf("rolled back order visible: %v", err)
+    }
+    if trades, err := store.Trades.GetBySymbol("BTCUSD", 10); err != nil || len(trades) != 0 {
+        t.Fatalf("rolled back trades visible: %v, %v", trades, err)
+    }
+}
+
+func testInstruments(t *testing.T, store *repository.Store) {
+    instrument := &models.Instrument{
+        Symbol:            "SOLUSD",
+        BaseAsset:         "SOL",
+        QuoteAsset:        "USD",
+        Status:            models.HALTED,
+        PricePrecision:    3,
+        QuantityPrecision: 4,
+        TickSize:          dec("0.005"),
+        LotSize:           dec("0.001"),
+        MinQuantity:       dec("0.01"),
+        MaxQuantity:       decPtr("5000"),
+        MinNotional:       dec("2.5"),
+        CreatedAt:         baseTime,
+        UpdatedAt:         baseTime,
+    }
+    if err := store.Instruments.Create(instrument); err != nil {
+        t.Fatalf("create instrument: %v", err)
+    }
+
+    got, err := store.Instruments.GetBySymbol("SOLUSD")
+    if err != nil {
+        t.Fatalf("get instrument: %v", err)
+    }
+    if got.Status != models.HALTED || got.PricePrecision != 3 || got.QuantityPrecision != 4 || !got.TickSize.Equal(dec("0.005")) ||
+        !got.MinNotional.Equal(dec("2.5")) || got.MaxQuantity == nil || !got.MaxQuantity.Equal(dec("5000")) {
+        t.Fatalf("got %+v, want %+v", got, instrument)
+    }
+
+    instrument.Status = models.ACTIVE
+    instrument.MaxQuantity = nil
+    instrument.UpdatedAt = baseTime.Add(time.Minute)
+    if err := store.Instruments.Update(instrument); err != nil {
+        t.Fatalf("update instrument: %v", err)
+    }
+    if got, err = store.Instruments.GetBySymbol("SOLUSD"); err != nil || got.Status != models.ACTIVE || got.MaxQuantity != nil {
+        t.Fatalf("after update got %+v, %v", got, err)
+    }
+
+    all, err := store.Instruments.GetAll()
+    if err != nil {
+        t.Fatalf("get all: %v", err)
+    }
+    symbols := make([]string, len(all))
+    for i, instrument := range all {
+        symbols[i] = instrument.Symbol
+    }
+    assertIDs(t, symbols, []string{"BTCUSD", "ETHUSD", "SOLUSD"})
+
+    if _, err := store.Instruments.GetBySymbol("XRPUSD"); err != models.ErrInstrumentNotFound {
+        t.Fatalf("got %v, want ErrInstrumentNotFound", err)
+    }
+}
+
+func testWatermark(t *testing.T, store *repository.Store) {
+    if watermarks, err := store.Watermark.Get(); err != nil || len(watermarks) != 0 {
+        t.Fatalf("initial watermarks = %v, %v", watermarks, err)
+    }
+
+    tx, err := store.UnitOfWork.Begin()
+    if err != nil {
+        t.Fatalf("begin: %v", err)
+    }
+    if err := tx.Watermark().Set("BTCUSD", repository.Watermark{Sequence: 41, BookSequence: 9}); err != nil {
+        t.Fatalf("set in tx: %v", err)
+    }
+    if err := tx.Rollback(); err != nil {
+        t.Fatalf("rollback: %v", err)
+    }
+    if watermarks, err := store.Watermark.Get(); err != nil || len(watermarks) != 0 {
+        t.Fatalf("after rollback watermarks = %v, %v", watermarks, err)
+    }
+
+    if tx, err = store.UnitOfWork.Begin(); err != nil {
+        t.Fatalf("begin: %v", err)
+    }
+    defer tx.Rollback()
+
+    instrument := &models.Instrument{
+        Symbol:      "SOLUSD",
+        BaseAsset:   "SOL",
+        QuoteAsset:  "USD",
+        Status:      models.ACTIVE,
+        TickSize:    dec("0.01"),
+        LotSize:     dec("0.01"),
+        MinQuantity: dec("0.01"),
+        MinNotional: dec("0"),
+        CreatedAt:   baseTime,
+        UpdatedAt:   baseTime,
+    }
+    if err := tx.Instruments().Create(instrument); err != nil {
+        t.Fatalf("create instrument in tx: %v", err)
+    }
+    // Setting the same watermark twice changes no row, which MySQL reports as nothing updated
+    for _, set := range []struct {
+        symbol    string
+        watermark repository.Watermark
+    }{
+        {"SOLUSD", repository.Watermark{Sequence: 42, BookSequence: 1}},
+        {"BTCUSD", repository.Watermark{Sequence: 40, BookSequence: 10}},
+        {"BTCUSD", repository.Watermark{Sequence: 43, BookSequence: 12}},
+        {"BTCUSD", repository.Watermark{Sequence: 43, BookSequence: 12}},
+    } {
+        if err := tx.Watermark().Set(set.symbol, set.watermark); err != nil {
+            t.Fatalf("set %s in tx: %v", set.symbol, err)
+        }
+    }
+    want := map[string]repository.Watermark{
+        "SOLUSD": {Sequence: 42, BookSequence: 1},
+        "BTCUSD": {Sequence: 43, BookSequence: 12},
+    }
+    if watermarks, err := tx.Watermark().Get(); err != nil || !reflect.DeepEqual(watermarks, want) {
+        t.Fatalf("in tx watermarks = %v, %v", watermarks, err)
+    }
+    if err := tx.Commit(); err != nil {
+        t.Fatalf("commit: %v", err)
+    }
+
+    watermarks, err := store.Watermark.Get()
+    if err != nil || !reflect.DeepEqual(watermarks, want) {
+        t.Fatalf("after commit watermarks = %v, %v", watermarks, err)
+    }
+    if _, err := store.Instruments.GetBySymbol("SOLUSD"); err != nil {
+        t.Fatalf("instrument not committed: %v", err)
+    }
+}
+
+func testBalances(t *testing.T, store *repository.Store) {
+    for _, id := range []string{"bob", "alice"} {
+        if err := store.Accounts.Create(&models.Account{ID: id, CreatedAt: baseTime}); err != nil {
+            t.Fatalf("create account %s: %v", id, err)
+        }
+    }
+    if _, err := store.Accounts.GetByID("carol"); err != models.ErrAccountNotFound {
+        t.Fatalf("missing account: %v", err)
+    }
+    if accounts, err := store.Accounts.GetAll(); err != nil || len(accounts) != 2 || accounts[0].ID != "alice" {
+        t.Fatalf("accounts = %+v, %v", accounts, err)
+    }
+
+    // Outside a transaction, a change commits on its own
+    if err := store.Balances.Add(&models.Balance{AccountID: "alice", Asset: "USD", Available: dec("1000.0000000000000001")}); err != nil {
+        t.Fatalf("deposit: %v", err)
+    }
+
+    tx, err := store.UnitOfWork.Begin()
+    if err != nil {
+        t.Fatalf("begin: %v", err)
+    }
+    if err := tx.Balances().Add(&models.Balance{AccountID: "alice", Asset: "USD", Available: dec("-1000")}); err != nil {
+        t.Fatalf("add in tx: %v", err)
+    }
+    if err := tx.Rollback(); err != nil {
+        t.Fatalf("rollback: %v", err)
+    }
+
+    if tx, err = store.UnitOfWork.Begin(); err != nil {
+        t.Fatalf("begin: %v", err)
+    }
+    defer tx.Rollback()
+
+    for _, change := range []models.Balance{
+        {AccountID: "alice", Asset: "USD", Available: dec("-250.5"), Locked: dec("250.5")},
+        {AccountID: "bob", Asset: "BTC", Available: dec("2")},
+        {AccountID: "alice", Asset: "USD", Locked: dec("-100.25")},
+        {AccountID: "alice", Asset: "BTC", Available: dec("0.001")},
+    } {
+        change := change
+        if err := tx.Balances().Add(&change); err != nil {
+            t.Fatalf("add %+v in tx: %v", change, err)
+        }
+    }
+    if balances, err := tx.Balances().GetByAccount("alice"); err != nil || len(balances) != 2 || !balances[1].Locked.Equal(dec("150.25")) {
+        t.Fatalf("in tx balances = %+v, %v", balances, err)
+    }
+    if err := tx.Commit(); err != nil {
+        t.Fatalf("commit: %v", err)
+    }
+
+    balances, err := store.Balances.GetAll()
+    if err != nil || len(balances) != 3 {
+        t.Fatalf("balances = %+v, %v", balances, err)
+    }
+    for i, want := range []struct {
+        accountID, asset, available, locked string
+    }{
+        {"alice", "BTC", "0.001", "0"},
+        {"alice", "USD", "749.5000000000000001", "150.25"},
+        {"bob", "BTC", "2", "0"},
+    } {
+        got := balances[i]
+        if got.AccountID != want.accountID || got.Asset != want.asset || !got.Available.Equal(dec(want.available)) || !got.Locked.Equal(dec(want.locked)) {
+            t.Fatalf("balance %d = %+v, want %+v", i, got, want)
+        }
+    }
+}
+
+func testFees(t *testing.T, store *repository.Store) {
+    schedule := &models.FeeSchedule{Tiers: []models.FeeTier{
+        {MinVolume: dec("0"), MakerRate: dec("0.001"), TakerRate: dec("0.002")},
+        {MinVolume: dec("1000000"), MakerRate: dec("-0.0001"), TakerRate: dec("0.0005")},
+    }}
+
+    instrument, err := store.Instruments.GetBySymbol("BTCUSD")
+    if err != nil {
+        t.Fatalf("get instrument: %v", err)
+    }
+    if instrument.Fees != nil {
+        t.Fatalf("seeded fees = %+v, want none", instrument.Fees)
+    }
+    instrument.Fees = schedule
+    if err := store.Instruments.Update(instrument); err != nil {
+        t.Fatalf("update instrument: %v", err)
+    }
+    if instrument, err = store.Instruments.GetBySymbol("BTCUSD"); err != nil || instrument.Fees == nil || len(instrument.Fees.Tiers) != 2 ||
+        !instrument.Fees.Tiers[1].MakerRate.Equal(dec("-0.0001")) || !instrument.Fees.Tiers[1].MinVolume.Equal(dec("1000000")) {
+        t.Fatalf("instrument fees = %+v, %v", instrument, err)
+    }
+
+    for _, account := range []*models.Account{{ID: "maker", CreatedAt: baseTime}, {ID: "taker", CreatedAt: baseTime, Fees: schedule}} {
+        if err := store.Accounts.Create(account); err != nil {
+            t.Fatalf("create account %s: %v", account.ID, err)
+        }
+    }
+    if err := store.Accounts.SetFees("maker", schedule); err != nil {
+        t.Fatalf("set fees: %v", err)
+    }
+    if err := store.Accounts.SetFees("taker", nil); err != nil {
+        t.Fatalf("clear fees: %v", err)
+    }
+    if err := store.Accounts.SetFees("carol", schedule); err != models.ErrAccountNotFound {
+        t.Fatalf("set fees of missing account: %v", err)
+    }
+    accounts, err := store.Accounts.GetAll()
+    if err != nil || len(accounts) != 2 || accounts[0].Fees == nil || !accounts[0].Fees.Tiers[0].TakerRate.Equal(dec("0.002")) || accounts[1].Fees != nil {
+        t.Fatalf("accounts = %+v, %v", accounts, err)
+    }
+
+    buy := newOrder("buy", "BTCUSD", models.BUY, "100", baseTime)
+    sell := newOrder("sell", "BTCUSD", models.SELL, "100", baseTime)
+    for _, order := range []*models.Order{buy, sell} {
+        mustCreateOrder(t, store.Orders, order)
+    }
+
+    charged := newTrade("t2", "BTCUSD", "buy", "sell", baseTime.Add(time.Hour))
+    charged.TakerSide = models.SELL
+    charged.MakerAccountID = "maker"
+    charged.TakerAccountID = "taker"
+    charged.MakerFee = dec("-0.00005")
+    charged.MakerFeeAsset = "BTC"
+    charged.TakerFee = dec("0.0250625000000001")
+    charged.TakerFeeAsset = "USD"
+    for _, trade := range []*models.Trade{
+        newTrade("t1", "BTCUSD", "buy", "sell", baseTime),
+        charged,
+        newTrade("t3", "BTCUSD", "buy", "sell", baseTime.Add(time.Hour)),
+    } {
+        if err := store.Trades.Create(trade); err != nil {
+            t.Fatalf("create trade: %v", err)
+        }
+    }
+
+    trades, err := store.Trades.GetSince(baseTime.Add(time.Minute))
+    if err != nil {
+        t.Fatalf("get trades since: %v", err)
+    }
+    assertIDs(t, tradeIDs(trades), []string{"t2", "t3"})
+
+    got := trades[0]
+    if got.TakerSide != models.SELL || got.MakerAccountID != "maker" || got.TakerAccountID != "taker" || got.MakerFeeAsset != "BTC" || got.TakerFeeAsset != "USD" {
+        t.Fatalf("got %+v, want %+v", got, charged)
+    }
+    assertDecimal(t, "maker_fee", &got.MakerFee, "-0.00005")
+    assertDecimal(t, "taker_fee", &got.TakerFee, "0.0250625000000001")
+
+    if trades[1].TakerSide != "" || trades[1].MakerAccountID != "" || !trades[1].TakerFee.IsZero() || trades[1].TakerFeeAsset != "" {
+        t.Fatalf("trade without fees = %+v", trades[1])
+    }
+}
+
+func testOrderEvents(t *testing.T, store *repository.Store) {
+    for _, order := range []*models.Order{
+        newOrder("incoming", "BTCUSD", models.BUY, "100", baseTime),
+        newOrder("resting", "BTCUSD", models.SELL, "100", baseTime),
+    } {
+        mustCreateOrder(t, store.Orders, order)
+    }
+
+    event := func(id, orderID, counterOrderID string, action models.OrderEventAction, quantity string, createdAt time.Time) *models.OrderEvent {
+        return &models.OrderEvent{
+            ID:                  id,
+            OrderID:             orderID,
+            Type:                models.SELF_TRADE_PREVENTED,
+            CounterOrderID:      counterOrderID,
+            SelfTradePrevention: models.STP_DECREMENT_AND_CANCEL,
+            Action:              action,
+            Quantity:            dec(quantity),
+            CreatedAt:           createdAt,
+        }
+    }
+
+    if err := store.OrderEvents.Create(event("e2", "resting", "incoming", models.EVENT_DECREMENTED, "0.5", baseTime.Add(time.Minute))); err != nil {
+        t.Fatalf("create event: %v", err)
+    }
+
+    tx, err := store.UnitOfWork.Begin()
+    if err != nil {
+        t.Fatalf("begin: %v", err)
+    }
+    defer tx.Rollback()
+    for _, e := range []*models.OrderEvent{
+        event("e3", "resting", "incoming", models.EVENT_CANCELED, "0.25", baseTime.Add(time.Minute)),
+        event("e1", "resting", "incoming", models.EVENT_UNCHANGED, "0", baseTime),
+        event("e4", "incoming", "resting", models.EVENT_CANCELED, "1.5", baseTime),
+    } {
+        if err := tx.OrderEvents().Create(e); err != nil {
+            t.Fatalf("create event in transaction: %v", err)
+        }
+    }
+    if events, err := tx.OrderEvents().GetByOrder("resting"); err != nil || len(events) != 3 {
+        t.Fatalf("events in transaction = %+v, %v", events, err)
+    }
+    if err := tx.Commit(); err != nil {
+        t.Fatalf("commit: %v", err)
+    }
+
+    events, err := store.OrderEvents.GetByOrder("resting")
+    if err != nil || len(events) != 3 {
+        t.Fatalf("events = %+v, %v", events, err)
+    }
+    var ids []string
+    for _, e := range events {
+        ids = append(ids, e.ID)
+    }
+    assertIDs(t, ids, []string{"e1", "e2", "e3"})
+
+    got := events[1]
+    if got.OrderID != "resting" || got.Type != models.SELF_TRADE_PREVENTED || got.CounterOrderID != "incoming" ||
+        got.SelfTradePrevention != models.STP_DECREMENT_AND_CANCEL || got.Action != models.EVENT_DECREMENTED || !got.CreatedAt.Equal(baseTime.Add(time.Minute)) {
+        t.Fatalf("got %+v", got)
+    }
+    assertDecimal(t, "quantity", &got.Quantity, "0.5")
+
+    if events, err := store.OrderEvents.GetByOrder("missing"); err != nil || len(events) != 0 {
+        t.Fatalf("events of missing order = %+v, %v", events, err)
+    }
+}
+
+func testClientOrderIDs(t *testing.T, store *repository.Store) {
+    for _, id := range []string{"alice", "bob"} {
+        if err := store.Accounts.Create(&models.Account{ID: id, CreatedAt: baseTime}); err != nil {
+            t.Fatalf("create account %s: %v", id, err)
+        }
+    }
+    order := func(id, accountID, clientOrderID string) *models.Order {
+        order := newOrder(id, "BTCUSD", models.BUY, "100", baseTime)
+        order.AccountID = accountID
+        order.ClientOrderID = clientOrderID
+        return order
+    }
+
+    mustCreateOrder(t, store.Orders, order("a1", "alice", "retry-me"))
+    mustCreateOrder(t, store.Orders, order("b1", "bob", "retry-me"))
+    mustCreateOrder(t, store.Orders, order("a2", "alice", ""))
+    mustCreateOrder(t, store.Orders, order("a3", "alice", ""))
+    if err := store.Orders.Create(order("a4", "alice", "retry-me")); err == nil {
+        t.Fatalf("created a second order with the same client order id")
+    }
+
+    filled := order("a1", "alice", "retry-me")
+    filled.Status = models.FILLED
+    if err := store.Orders.Update(filled); err != nil {
+        t.Fatalf("update order: %v", err)
+    }
+    got, err := store.Orders.GetByClientOrderID("alice", "retry-me")
+    if err != nil || got.ID != "a1" || got.Status != models.FILLED {
+        t.Fatalf("got %+v, %v", got, err)
+    }
+    if got, err = store.Orders.GetByClientOrderID("bob", "retry-me"); err != nil || got.ID != "b1" {
+        t.Fatalf("got %+v, %v", got, err)
+    }
+    if _, err := store.Orders.GetByClientOrderID("carol", "retry-me"); err != models.ErrOrderNotFound {
+        t.Fatalf("got %v, want ErrOrderNotFound", err)
+    }
+
+    // Inside a transaction, its own orders are found too
+    tx, err := store.UnitOfWork.Begin()
+    if err != nil {
+        t.Fatalf("begin: %v", err)
+    }
+    defer tx.Rollback()
+    mustCreateOrder(t, tx.Orders(), order("a5", "alice", "in-tx"))
+    if got, err := tx.Orders().GetByClientOrderID("alice", "in-tx"); err != nil || got.ID != "a5" {
+        t.Fatalf("got %+v, %v", got, err)
+    }
+    if err := tx.Commit(); err != nil {
+        t.Fatalf("commit: %v", err)
+    }
+    if got, err := store.Orders.GetByClientOrderID("alice", "in-tx"); err != nil || got.ID != "a5" {
+        t.Fatalf("got %+v, %v", got, err)
+    }
+}
+
+func testListOrders(t *testing.T, store *repository.Store) {
+    if err := store.Accounts.Create(&models.Account{ID: "alice", CreatedAt: baseTime}); err != nil {
+        t.Fatalf("create account: %v", err)
+    }
+    orders := []*models.Order{
+        newOrder("c", "BTCUSD", models.BUY, "100", baseTime.Add(time.Second)),
+        newOrder("b", "BTCUSD", models.SELL, "101", baseTime),
+        newOrder("a", "BTCUSD", models.BUY, "99", baseTime.Add(time.Second)),
+        newOrder("d", "BTCUSD", models.BUY, "98", baseTime.Add(-time.Second)),
+        newOrder("e", "ETHUSD", models.BUY, "10", baseTime),
+        newOrder("f", "BTCUSD", models.BUY, "97", baseTime.Add(2*time.Second)),
+    }
+    orders[1].Status = models.PARTIAL
+    orders[4].AccountID = "alice"
+    orders[5].Status = models.FILLED
+    orders[5].Type = models.STOP_LIMIT
+    for _, order := range orders {
+        mustCreateOrder(t, store.Orders, order)
+    }
+
+    list := func(query models.OrderQuery) []string {
+        t.Helper()
+        got, err := store.Orders.List(&query)
+        if err != nil {
+            t.Fatalf("list %+v: %v", query, err)
+        }
+        return orderIDs(got)
+    }
+    from, to := baseTime, baseTime.Add(2*time.Second)
+
+    assertIDs(t, list(models.OrderQuery{Limit: 10}), []string{"d", "b", "e", "a", "c", "f"})
+    assertIDs(t, list(models.OrderQuery{Symbol: "BTCUSD", Side: models.BUY, Limit: 10}), []string{"d", "a", "c", "f"})
+    assertIDs(t, list(models.OrderQuery{AccountID: "alice", Limit: 10}), []string{"e"})
+    assertIDs(t, list(models.OrderQuery{Type: models.STOP_LIMIT, Limit: 10}), []string{"f"})
+    assertIDs(t, list(models.OrderQuery{Statuses: []models.OrderStatus{models.PARTIAL, models.FILLED}, Limit: 10}), []string{"b", "f"})
+    assertIDs(t, list(models.OrderQuery{OpenOnly: true, Statuses: []models.OrderStatus{models.PARTIAL, models.FILLED}, Limit: 10}), []string{"b"})
+    assertIDs(t, list(models.OrderQuery{CreatedFrom: &from, CreatedTo: &to, Limit: 10}), []string{"b", "e", "a", "c"})
+
+    // Pages continue from the cursor, ties on created_at broken by id
+    after := &models.OrderCursor{CreatedAt: baseTime.Add(time.Second), ID: "a"}
+    assertIDs(t, list(models.OrderQuery{After: after, Limit: 10}), []string{"c", "f"})
+    assertIDs(t, list(models.OrderQuery{OpenOnly: true, Limit: 2}), []string{"d", "b"})
+
+    // Within a second, orders keep their creation order to the microsecond rather than falling
+    // back to their ids, and a cursor taken from an order before it was stored resumes after it
+    withinSecond := []*models.Order{
+        newOrder("z", "ETHUSD", models.SELL, "11", baseTime.Add(500*time.Millisecond)),
+        newOrder("y", "ETHUSD", models.SELL, "12", baseTime.Add(500*time.Millisecond+time.Microsecond)),
+        newOrder("x", "ETHUSD", models.SELL, "13", baseTime.Add(750*time.Millisecond)),
+    }
+    for _, order := range withinSecond {
+        mustCreateOrder(t, store.Orders, order)
+    }
+    assertIDs(t, list(models.OrderQuery{Symbol: "ETHUSD", Side: models.SELL, Limit: 10}), []string{"z", "y", "x"})
+    after = &models.OrderCursor{CreatedAt: withinSecond[0].CreatedAt, ID: "z"}
+    assertIDs(t, list(models.OrderQuery{Symbol: "ETHUSD", Side: models.SELL, After: after, Limit: 10}), []string{"y", "x"})
+    after = &models.OrderCursor{CreatedAt: withinSecond[1].CreatedAt, ID: "y"}
+    assertIDs(t, list(models.OrderQuery{Symbol: "ETHUSD", After: after, Limit: 10}), []string{"x"})
+
+    // An order that stops matching drops out, and a transaction sees its own orders
+    filled := newOrder("d", "BTCUSD", models.BUY, "98", baseTime.Add(-time.Second))
+    filled.Status = models.FILLED
+    if err := store.Orders.Update(filled); err != nil {
+        t.Fatalf("update order: %v", err)
+    }
+    tx, err := store.UnitOfWork.Begin()
+    if err != nil {
+        t.Fatalf("begin: %v", err)
+    }
+    defer tx.Rollback()
+    mustCreateOrder(t, tx.Orders(), newOrder("g", "BTCUSD", models.SELL, "102", baseTime))
+    got, err := tx.Orders().List(&models.OrderQuery{OpenOnly: true, Limit: 3})
+    if err != nil {
+        t.Fatalf("list in transaction: %v", err)
+    }
+    assertIDs(t, orderIDs(got), []string{"b", "e", "g"})
+}
+
+func testWriteBatch(t *testing.T, store *repository.Store) {
+    if store.Batches == nil {
+        t.Skip("store does not write batches")
+    }
+
+    resting := newOrder("resting", "BTCUSD", models.SELL, "100", baseTime)
+    mustCreateOrder(t, store.Orders, resting)
+
+    // More rows than fit in one statement
+    batch := &repository.Batch{Watermarks: map[string]repository.Watermark{"BTCUSD": {Sequence: 7, BookSequence: 70}, "SOLUSD": {Sequence: 3}}}
+    for i := 0; i < 450; i++ {
+        order := newOrder(fmt.Sprintf("buy-%03d", i), "BTCUSD", models.BUY, "100", baseTime.Add(time.Duration(i)*time.Second))
+        order.Status = models.FILLED
+        batch.Orders = append(batch.Orders, *order)
+        batch.Trades = append(batch.Trades, *newTrade(fmt.Sprintf("trade-%03d", i), "BTCUSD", order.ID, "resting", order.CreatedAt))
+    }
+    resting.Status = models.PARTIAL
+    resting.RemainingQuantity = dec("0.25")
+    resting.UpdatedAt = baseTime.Add(time.Hour)
+    batch.Orders = append(batch.Orders, *resting)
+    batch.OrderEvents = []models.OrderEvent{{
+        ID:                  "event",
+        OrderID:             "buy-449",
+        Type:                models.SELF_TRADE_PREVENTED,
+        CounterOrderID:      "resting",
+        SelfTradePrevention: models.STP_CANCEL_NEWEST,
+        Action:              models.EVENT_CANCELED,
+        Quantity:            dec("1"),
+        CreatedAt:           baseTime,
+    }}
+
+    batch.NewInstruments = []models.Instrument{{
+        Symbol:      "SOLUSD",
+        BaseAsset:   "SOL",
+        QuoteAsset:  "USD",
+        Status:      models.ACTIVE,
+        TickSize:    dec("0.01"),
+        LotSize:     dec("0.01"),
+        MinQuantity: dec("0.01"),
+        MinNotional: dec("0"),
+        CreatedAt:   baseTime,
+        UpdatedAt:   baseTime,
+    }}
+    eth, err := store.Instruments.GetBySymbol("ETHUSD")
+    if err != nil {
+        t.Fatalf("get instrument: %v", err)
+    }
+    eth.Status = models.HALTED
+    batch.Instruments = []models.Instrument{*eth}
+
+    if err := store.Accounts.Create(&models.Account{ID: "batch", CreatedAt: baseTime}); err != nil {
+        t.Fatalf("create account: %v", err)
+    }
+    batch.Balances = []models.Balance{
+        {AccountID: "batch", Asset: "BTC", Available: dec("0.25")},
+        {AccountID: "batch", Asset: "USD", Available: dec("-25")},
+    }
+
+    if err := store.Batches.WriteBatch(batch); err != nil {
+        t.Fatalf("write batch: %v", err)
+    }
+
+    got, err := store.Orders.GetByID("resting")
+    if err != nil || got.Status != models.PARTIAL || !got.RemainingQuantity.Equal(dec("0.25")) || !got.UpdatedAt.Equal(resting.UpdatedAt) {
+        t.Fatalf("updated order = %+v, %v", got, err)
+    }
+    if got, err = store.Orders.GetByID("buy-449"); err != nil || got.Status != models.FILLED {
+        t.Fatalf("inserted order = %+v, %v", got, err)
+    }
+    trades, err := store.Trades.GetBySymbol("BTCUSD", 1000)
+    if err != nil || len(trades) != 450 || trades[0].ID != "trade-449" {
+        t.Fatalf("got %d trades, %v", len(trades), err)
+    }
+    if events, err := store.OrderEvents.GetByOrder("buy-449"); err != nil || len(events) != 1 || events[0].Action != models.EVENT_CANCELED {
+        t.Fatalf("events = %+v, %v", events, err)
+    }
+    if instrument, err := store.Instruments.GetBySymbol("SOLUSD"); err != nil || instrument.Status != models.ACTIVE {
+        t.Fatalf("new instrument = %+v, %v", instrument, err)
+    }
+    if instrument, err := store.Instruments.GetBySymbol("ETHUSD"); err != nil || instrument.Status != models.HALTED {
+        t.Fatalf("updated instrument = %+v, %v", instrument, err)
+    }
+    if watermarks, err := store.Watermark.Get(); err != nil || watermarks["BTCUSD"] != (repository.Watermark{Sequence: 7, BookSequence: 70}) || watermarks["SOLUSD"].Sequence != 3 {
+        t.Fatalf("watermarks = %v, %v", watermarks, err)
+    }
+
+    if balances, err := store.Balances.GetByAccount("batch"); err != nil || len(balances) != 2 ||
+        !balances[0].Available.Equal(dec("0.25")) || !balances[1].Available.Equal(dec("-25")) {
+        t.Fatalf("balances = %+v, %v", balances, err)
+    }
+
+    // A failing batch leaves nothing behind
+    failing := &repository.Batch{
+        Orders: []models.Order{*newOrder("lost", "BTCUSD", models.BUY, "100", baseTime)},
+        Trades: []models.Trade{*newTrade("trade-000", "BTCUSD", "lost", "resting", baseTime)},
+    }
+    if err := store.Batches.WriteBatch(failing); err == nil {
+        t.Fatal("duplicate trade written")
+    }
+    if _, err := store.Orders.GetByID("lost"); err != models.ErrOrderNotFound {
+        t.Fatalf("order from failed batch: %v", err)
+    }
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+    "database/sql"
+    "encoding/json"
+    "order-matching-system/internal/models"
+)
+
+type InstrumentRepository struct {
+    db DBTX
+}
+
+func NewInstrumentRepository(db DBTX) *InstrumentRepository {
+    return &InstrumentRepository{db: db}
+}
+
+func (r *InstrumentRepository) Create(instrument *models.Instrument) error {
+    query := `
+        INSERT INTO symbols (symbol, base_asset, quote_asset, status, price_precision, quantity_precision,
+            tick_size, lot_size, min_quantity, max_quantity, min_notional, fee_schedule, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `
+    
+    fees, err := feeScheduleJSON(instrument.Fees)
+    if err != nil {
+        return err
+    }
+    
+    _, err = r.db.Exec(query,
+        instrument.Symbol,
+        instrument.BaseAsset,
+        instrument.QuoteAsset,
+        instrument.Status,
+        instrument.PricePrecision,
+        instrument.QuantityPrecision,
+        instrument.TickSize.String(),
+        instrument.LotSize.String(),
+        instrument.MinQuantity.String(),
+        nullDecimal(instrument.MaxQuantity),
+        instrument.MinNotional.String(),
+        fees,
+        instrument.CreatedAt,
+        instrument.UpdatedAt,
+    )
+    
+    return err
+}
+
+func (r *InstrumentRepository) GetBySymbol(symbol string) (*models.Instrument, error) {
+    query := `
+        SELECT symbol, base_asset, quote_asset, status, price_precision, quantity_precision,
+            tick_size, lot_size, min_quantity, max_quantity, min_notional, fee_schedule, created_at, updated_at
+        FROM symbols
+        WHERE symbol = ?
+    `
+    
+    row := r.db.QueryRow(query, symbol)
+    return r.scanInstrument(row)
+}
+
+func (r *InstrumentRepository) GetAll() ([]models.Instrument, error) {
+    query := `
+        SELECT symbol, base_asset, quote_asset, status, price_precision, quantity_precision,
+            tick_size, lot_size, min_quantity, max_quantity, min_notional, fee_schedule, created_at, updated_at
+        FROM symbols
+        ORDER BY symbol ASC
+    `
+    
+    rows, err := r.db.Query(query)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    var instruments []models.Instrument
+    for rows.Next() {
+        instrument, err := r.scanInstrument(rows)
+        if err != nil {
+            return nil, err
+        }
+        instruments = append(instruments, *instrument)
+    }
+    
+    return instruments, rows.Err()
+}
+
+func (r *InstrumentRepository) Update(instrument *models.Instrument) error {
+    query := `
+        UPDATE symbols
+        SET status = ?, price_precision = ?, quantity_precision = ?,
+            tick_size = ?, lot_size = ?, min_quantity = ?, max_quantity = ?, min_notional = ?, fee_schedule = ?, updated_at = ?
+        WHERE symbol = ?
+    `
+    
+    fees, err := feeScheduleJSON(instrument.Fees)
+    if err != nil {
+        return err
+    }
+    
+    _, err = r.db.Exec(query,
+        instrument.Status,
+        instrument.PricePrecision,
+        instrument.QuantityPrecision,
+        instrument.TickSize.String(),
+        instrument.LotSize.String(),
+        instrument.MinQuantity.String(),
+        nullDecimal(instrument.MaxQuantity),
+        instrument.MinNotional.String(),
+        fees,
+        instrument.UpdatedAt,
+        instrument.Symbol,
+    )
+    
+    return err
+}
+
+func (r *InstrumentRepository) scanInstrument(scanner interface {
+    Scan(dest ...interface{}) error
+}) (*models.Instrument, error) {
+    var instrument models.Instrument
+    var maxQuantity, fees sql.NullString
+    
+    err := scanner.Scan(
+        &instrument.Symbol,
+        &instrument.BaseAsset,
+        &instrument.QuoteAsset,
+        &instrument.Status,
+        &instrument.PricePrecision,
+        &instrument.QuantityPrecision,
+        &instrument.TickSize,
+        &instrument.LotSize,
+        &instrument.MinQuantity,
+        &maxQuantity,
+        &instrument.MinNotional,
+        &fees,
+        &instrument.CreatedAt,
+        &instrument.UpdatedAt,
+    )
+    
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, models.ErrInstrumentNotFound
+        }
+        return nil, err
+    }
+    
+    if instrument.MaxQuantity, err = parseNullDecimal(maxQuantity); err != nil {
+        return nil, err
+    }
+    
+    if instrument.Fees, err = parseFeeSchedule(fees); err != nil {
+        return nil, err
+    }
+    
+    return &instrument, nil
+}
+
+// feeScheduleJSON encodes a fee schedule for its TEXT column, or NULL when there is none.
+func feeScheduleJSON(fees *models.FeeSchedule) (interface{}, error) {
+    if fees == nil {
+        return nil, nil
+    }
+    encoded, err := json.Marshal(fees)
+    if err != nil {
+        return nil, err
+    }
+    return string(encoded), nil
+}
+
+func parseFeeSchedule(value sql.NullString) (*models.FeeSchedule, error) {
+    if !value.Valid {
+        return nil, nil
+    }
+    var fees models.FeeSchedule
+    if err := json.Unmarshal([]byte(value.String), &fees); err != nil {
+        return nil, err
+    }
+    return &fees, nil
+}
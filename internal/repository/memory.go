@@ -0,0 +1,813 @@
+package repository
+
+import (
+    "errors"
+    "fmt"
+    "order-matching-system/internal/models"
+    "sort"
+    "sync"
+    "time"
+)
+
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// memoryData is the committed state shared by the in-memory stores. Values are stored by
+// copy so callers cannot change committed rows without going through Update.
+type memoryData struct {
+    mutex       sync.RWMutex
+    orders      map[string]models.Order
+    clientIDs   map[clientOrderKey]string // Order IDs by account and client order ID
+    trades      []models.Trade
+    tradeIDs    map[string]bool
+    events      []models.OrderEvent
+    instruments map[string]models.Instrument
+    accounts    map[string]models.Account
+    balances    map[balanceKey]models.Balance
+    watermarks  map[string]Watermark
+}
+
+type balanceKey struct {
+    accountID string
+    asset     string
+}
+
+type clientOrderKey struct {
+    accountID     string
+    clientOrderID string
+}
+
+func clientOrderKeyOf(order *models.Order) (clientOrderKey, bool) {
+    return clientOrderKey{accountID: order.AccountID, clientOrderID: order.ClientOrderID}, order.AccountID != "" && order.ClientOrderID != ""
+}
+
+// NewMemoryStore returns a store that keeps everything in process memory. It supports
+// transactions, but nothing survives a restart.
+func NewMemoryStore() *Store {
+    data := &memoryData{
+        orders:      make(map[string]models.Order),
+        clientIDs:   make(map[clientOrderKey]string),
+        tradeIDs:    make(map[string]bool),
+        instruments: make(map[string]models.Instrument),
+        accounts:    make(map[string]models.Account),
+        balances:    make(map[balanceKey]models.Balance),
+        watermarks:  make(map[string]Watermark),
+    }
+    
+    return &Store{
+        Orders:      &memoryOrderStore{data: data},
+        Trades:      &memoryTradeStore{data: data},
+        OrderEvents: &memoryOrderEventStore{data: data},
+        Instruments: &memoryInstrumentStore{data: data},
+        Accounts:    &memoryAccountStore{data: data},
+        Balances:    &memoryBalanceStore{data: data},
+        Watermark:   &memoryWatermarkStore{data: data},
+        UnitOfWork:  &memoryUnitOfWork{data: data},
+        Batches:     &memoryUnitOfWork{data: data},
+    }
+}
+
+type memoryUnitOfWork struct {
+    data *memoryData
+}
+
+func (u *memoryUnitOfWork) Begin() (Tx, error) {
+    return &memoryTx{
+        data:        u.data,
+        orders:      make(map[string]models.Order),
+        instruments: make(map[string]models.Instrument),
+        watermarks:  make(map[string]Watermark),
+    }, nil
+}
+
+// WriteBatch applies a batch in one transaction. Orders that do not exist yet are created.
+func (u *memoryUnitOfWork) WriteBatch(batch *Batch) error {
+    tx, err := u.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    
+    for i := range batch.NewInstruments {
+        if err := tx.Instruments().Create(&batch.NewInstruments[i]); err != nil {
+            return err
+        }
+    }
+    for i := range batch.Instruments {
+        if err := tx.Instruments().Update(&batch.Instruments[i]); err != nil {
+            return err
+        }
+    }
+    
+    for i := range batch.Orders {
+        order := &batch.Orders[i]
+        save := tx.Orders().Update
+        if _, err := tx.Orders().GetByID(order.ID); err == models.ErrOrderNotFound {
+            save = tx.Orders().Create
+        }
+        if err := save(order); err != nil {
+            return err
+        }
+    }
+    for i := range batch.Trades {
+        if err := tx.Trades().Create(&batch.Trades[i]); err != nil {
+            return err
+        }
+    }
+    for i := range batch.OrderEvents {
+        if err := tx.OrderEvents().Create(&batch.OrderEvents[i]); err != nil {
+            return err
+        }
+    }
+    for i := range batch.Balances {
+        if err := tx.Balances().Add(&batch.Balances[i]); err != nil {
+            return err
+        }
+    }
+    for symbol, watermark := range batch.Watermarks {
+        if err := tx.Watermark().Set(symbol, watermark); err != nil {
+            return err
+        }
+    }
+    
+    return tx.Commit()
+}
+
+// memoryTx buffers writes in an overlay that reads inside the transaction see first.
+// Commit applies the overlay to the shared data in one step.
+type memoryTx struct {
+    data               *memoryData
+    orders             map[string]models.Order
+    created            []string // IDs of orders inserted in this transaction, in insertion order
+    trades             []models.Trade
+    events             []models.OrderEvent
+    instruments        map[string]models.Instrument
+    createdInstruments []string
+    balances           []models.Balance // Changes, applied in order on commit
+    watermarks         map[string]Watermark
+    done               bool
+}
+
+func (t *memoryTx) Orders() OrderStore {
+    return &memoryOrderStore{data: t.data, tx: t}
+}
+
+func (t *memoryTx) Trades() TradeStore {
+    return &memoryTradeStore{data: t.data, tx: t}
+}
+
+func (t *memoryTx) OrderEvents() OrderEventStore {
+    return &memoryOrderEventStore{data: t.data, tx: t}
+}
+
+func (t *memoryTx) Instruments() InstrumentStore {
+    return &memoryInstrumentStore{data: t.data, tx: t}
+}
+
+func (t *memoryTx) Balances() BalanceStore {
+    return &memoryBalanceStore{data: t.data, tx: t}
+}
+
+func (t *memoryTx) Watermark() WatermarkStore {
+    return &memoryWatermarkStore{data: t.data, tx: t}
+}
+
+func (t *memoryTx) Commit() error {
+    if t.done {
+        return ErrTxDone
+    }
+    t.done = true
+    
+    t.data.mutex.Lock()
+    defer t.data.mutex.Unlock()
+    
+    for _, id := range t.created {
+        order := t.orders[id]
+        if _, exists := t.data.orders[id]; exists {
+            return fmt.Errorf("duplicate order id %s", id)
+        }
+        if key, ok := clientOrderKeyOf(&order); ok && t.data.clientIDs[key] != "" {
+            return fmt.Errorf("duplicate client order id %s", order.ClientOrderID)
+        }
+    }
+    for _, trade := range t.trades {
+        if t.data.tradeIDs[trade.ID] {
+            return fmt.Errorf("duplicate trade id %s", trade.ID)
+        }
+    }
+    for _, symbol := range t.createdInstruments {
+        if _, exists := t.data.instruments[symbol]; exists {
+            return fmt.Errorf("duplicate symbol %s", symbol)
+        }
+    }
+    
+    for id, order := range t.orders {
+        t.data.orders[id] = order
+    }
+    for _, id := range t.created {
+        order := t.orders[id]
+        if key, ok := clientOrderKeyOf(&order); ok {
+            t.data.clientIDs[key] = id
+        }
+    }
+    for _, trade := range t.trades {
+        t.data.trades = append(t.data.trades, trade)
+        t.data.tradeIDs[trade.ID] = true
+    }
+    t.data.events = append(t.data.events, t.events...)
+    for symbol, instrument := range t.instruments {
+        t.data.instruments[symbol] = instrument
+    }
+    for i := range t.balances {
+        t.data.add(&t.balances[i])
+    }
+    for symbol, watermark := range t.watermarks {
+        t.data.watermarks[symbol] = watermark
+    }
+    return nil
+}
+
+func (t *memoryTx) Rollback() error {
+    t.done = true
+    return nil
+}
+
+type memoryOrderStore struct {
+    data *memoryData
+    tx   *memoryTx // nil outside a transaction
+}
+
+func (s *memoryOrderStore) Create(order *models.Order) error {
+    if s.tx != nil {
+        if s.tx.done {
+            return ErrTxDone
+        }
+        if _, exists := s.lookup(order.ID); exists {
+            return fmt.Errorf("duplicate order id %s", order.ID)
+        }
+        if _, err := s.GetByClientOrderID(order.AccountID, order.ClientOrderID); err == nil {
+            return fmt.Errorf("duplicate client order id %s", order.ClientOrderID)
+        }
+        s.tx.orders[order.ID] = *order
+        s.tx.created = append(s.tx.created, order.ID)
+        return nil
+    }
+    
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    if _, exists := s.data.orders[order.ID]; exists {
+        return fmt.Errorf("duplicate order id %s", order.ID)
+    }
+    key, hasClientID := clientOrderKeyOf(order)
+    if hasClientID && s.data.clientIDs[key] != "" {
+        return fmt.Errorf("duplicate client order id %s", order.ClientOrderID)
+    }
+    s.data.orders[order.ID] = *order
+    if hasClientID {
+        s.data.clientIDs[key] = order.ID
+    }
+    return nil
+}
+
+func (s *memoryOrderStore) GetByID(id string) (*models.Order, error) {
+    order, exists := s.lookup(id)
+    if !exists {
+        return nil, models.ErrOrderNotFound
+    }
+    return &order, nil
+}
+
+func (s *memoryOrderStore) GetByClientOrderID(accountID, clientOrderID string) (*models.Order, error) {
+    if accountID == "" || clientOrderID == "" {
+        return nil, models.ErrOrderNotFound
+    }
+    
+    if s.tx != nil {
+        for _, id := range s.tx.created {
+            if order := s.tx.orders[id]; order.AccountID == accountID && order.ClientOrderID == clientOrderID {
+                return &order, nil
+            }
+        }
+    }
+    
+    s.data.mutex.RLock()
+    id := s.data.clientIDs[clientOrderKey{accountID: accountID, clientOrderID: clientOrderID}]
+    s.data.mutex.RUnlock()
+    
+    if id == "" {
+        return nil, models.ErrOrderNotFound
+    }
+    return s.GetByID(id)
+}
+
+func (s *memoryOrderStore) GetOpenOrdersBySymbol(symbol string) ([]models.Order, error) {
+    return openOrders(s.all(), symbol), nil
+}
+
+func (s *memoryOrderStore) List(query *models.OrderQuery) ([]models.Order, error) {
+    return listOrders(s.all(), query), nil
+}
+
+// all returns every order this store sees, with the transaction's writes over the committed ones.
+func (s *memoryOrderStore) all() map[string]models.Order {
+    s.data.mutex.RLock()
+    merged := make(map[string]models.Order, len(s.data.orders))
+    for id, order := range s.data.orders {
+        merged[id] = order
+    }
+    s.data.mutex.RUnlock()
+    
+    if s.tx != nil {
+        for id, order := range s.tx.orders {
+            merged[id] = order
+        }
+    }
+    return merged
+}
+
+// openOrders returns the symbol's open orders in the same order as the SQL stores.
+func openOrders(merged map[string]models.Order, symbol string) []models.Order {
+    var orders []models.Order
+    for _, order := range merged {
+        if order.Symbol != symbol {
+            continue
+        }
+        switch order.Status {
+        case models.OPEN, models.PARTIAL, models.UNTRIGGERED:
+            orders = append(orders, order)
+        }
+    }
+    
+    sortOrders(orders)
+    return orders
+}
+
+// listOrders applies a query to orders the way OrderRepository.List does.
+func listOrders(merged map[string]models.Order, query *models.OrderQuery) []models.Order {
+    var orders []models.Order
+    for _, order := range merged {
+        if query.Matches(&order) && (query.After == nil || query.After.Follows(&order)) {
+            orders = append(orders, order)
+        }
+    }
+    
+    sortOrders(orders)
+    if len(orders) > query.Limit {
+        orders = orders[:query.Limit]
+    }
+    return orders
+}
+
+// sortOrders sorts orders the way the SQL stores list them: created_at, then id.
+func sortOrders(orders []models.Order) {
+    sort.Slice(orders, func(i, j int) bool {
+        if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+            return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+        }
+        return orders[i].ID < orders[j].ID
+    })
+}
+
+// Update replaces a stored order. Like an UPDATE that matches no rows, updating an unknown
+// order is not an error.
+func (s *memoryOrderStore) Update(order *models.Order) error {
+    if s.tx != nil {
+        if s.tx.done {
+            return ErrTxDone
+        }
+        if _, exists := s.lookup(order.ID); exists {
+            s.tx.orders[order.ID] = *order
+        }
+        return nil
+    }
+    
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    if _, exists := s.data.orders[order.ID]; exists {
+        s.data.orders[order.ID] = *order
+    }
+    return nil
+}
+
+func (s *memoryOrderStore) lookup(id string) (models.Order, bool) {
+    if s.tx != nil {
+        if order, exists := s.tx.orders[id]; exists {
+            return order, true
+        }
+    }
+    
+    s.data.mutex.RLock()
+    defer s.data.mutex.RUnlock()
+    
+    order, exists := s.data.orders[id]
+    return order, exists
+}
+
+type memoryTradeStore struct {
+    data *memoryData
+    tx   *memoryTx // nil outside a transaction
+}
+
+func (s *memoryTradeStore) Create(trade *models.Trade) error {
+    if s.tx != nil {
+        if s.tx.done {
+            return ErrTxDone
+        }
+        s.tx.trades = append(s.tx.trades, *trade)
+        return nil
+    }
+    
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    if s.data.tradeIDs[trade.ID] {
+        return fmt.Errorf("duplicate trade id %s", trade.ID)
+    }
+    s.data.trades = append(s.data.trades, *trade)
+    s.data.tradeIDs[trade.ID] = true
+    return nil
+}
+
+// GetBySymbol returns the most recent trades first, ordered like the SQL stores.
+func (s *memoryTradeStore) GetBySymbol(symbol string, limit int) ([]models.Trade, error) {
+    s.data.mutex.RLock()
+    all := append([]models.Trade(nil), s.data.trades...)
+    s.data.mutex.RUnlock()
+    
+    if s.tx != nil {
+        all = append(all, s.tx.trades...)
+    }
+    
+    return latestTrades(all, symbol, limit), nil
+}
+
+func (s *memoryTradeStore) GetSince(since time.Time) ([]models.Trade, error) {
+    s.data.mutex.RLock()
+    all := append([]models.Trade(nil), s.data.trades...)
+    s.data.mutex.RUnlock()
+    
+    if s.tx != nil {
+        all = append(all, s.tx.trades...)
+    }
+    
+    return tradesSince(all, since), nil
+}
+
+// latestTrades returns up to limit of the symbol's trades, most recent first, ordered like the
+// SQL stores.
+func latestTrades(all []models.Trade, symbol string, limit int) []models.Trade {
+    var trades []models.Trade
+    for _, trade := range all {
+        if trade.Symbol == symbol {
+            trades = append(trades, trade)
+        }
+    }
+    
+    sort.Slice(trades, func(i, j int) bool {
+        return trades[i].After(&trades[j])
+    })
+    
+    if limit >= 0 && len(trades) > limit {
+        trades = trades[:limit]
+    }
+    return trades
+}
+
+// tradesSince returns the trades executed at or after since, oldest first, ordered like the SQL
+// stores.
+func tradesSince(all []models.Trade, since time.Time) []models.Trade {
+    var trades []models.Trade
+    for _, trade := range all {
+        if !trade.ExecutedAt.Before(since) {
+            trades = append(trades, trade)
+        }
+    }
+    
+    sort.Slice(trades, func(i, j int) bool {
+        return trades[j].After(&trades[i])
+    })
+    return trades
+}
+
+type memoryOrderEventStore struct {
+    data *memoryData
+    tx   *memoryTx // nil outside a transaction
+}
+
+func (s *memoryOrderEventStore) Create(event *models.OrderEvent) error {
+    if s.tx != nil {
+        if s.tx.done {
+            return ErrTxDone
+        }
+        s.tx.events = append(s.tx.events, *event)
+        return nil
+    }
+    
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    s.data.events = append(s.data.events, *event)
+    return nil
+}
+
+func (s *memoryOrderEventStore) GetByOrder(orderID string) ([]models.OrderEvent, error) {
+    s.data.mutex.RLock()
+    all := append([]models.OrderEvent(nil), s.data.events...)
+    s.data.mutex.RUnlock()
+    
+    if s.tx != nil {
+        all = append(all, s.tx.events...)
+    }
+    
+    return orderEvents(all, orderID), nil
+}
+
+// orderEvents returns an order's events ordered like the SQL stores: oldest first, then by id.
+func orderEvents(all []models.OrderEvent, orderID string) []models.OrderEvent {
+    var events []models.OrderEvent
+    for _, event := range all {
+        if event.OrderID == orderID {
+            events = append(events, event)
+        }
+    }
+    
+    sort.Slice(events, func(i, j int) bool {
+        if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+            return events[i].CreatedAt.Before(events[j].CreatedAt)
+        }
+        return events[i].ID < events[j].ID
+    })
+    return events
+}
+
+type memoryInstrumentStore struct {
+    data *memoryData
+    tx   *memoryTx // nil outside a transaction
+}
+
+func (s *memoryInstrumentStore) Create(instrument *models.Instrument) error {
+    if s.tx != nil {
+        if s.tx.done {
+            return ErrTxDone
+        }
+        if _, exists := s.lookup(instrument.Symbol); exists {
+            return fmt.Errorf("duplicate symbol %s", instrument.Symbol)
+        }
+        s.tx.instruments[instrument.Symbol] = *instrument
+        s.tx.createdInstruments = append(s.tx.createdInstruments, instrument.Symbol)
+        return nil
+    }
+    
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    if _, exists := s.data.instruments[instrument.Symbol]; exists {
+        return fmt.Errorf("duplicate symbol %s", instrument.Symbol)
+    }
+    s.data.instruments[instrument.Symbol] = *instrument
+    return nil
+}
+
+func (s *memoryInstrumentStore) GetBySymbol(symbol string) (*models.Instrument, error) {
+    instrument, exists := s.lookup(symbol)
+    if !exists {
+        return nil, models.ErrInstrumentNotFound
+    }
+    return &instrument, nil
+}
+
+func (s *memoryInstrumentStore) GetAll() ([]models.Instrument, error) {
+    s.data.mutex.RLock()
+    merged := make(map[string]models.Instrument, len(s.data.instruments))
+    for symbol, instrument := range s.data.instruments {
+        merged[symbol] = instrument
+    }
+    s.data.mutex.RUnlock()
+    
+    if s.tx != nil {
+        for symbol, instrument := range s.tx.instruments {
+            merged[symbol] = instrument
+        }
+    }
+    
+    var instruments []models.Instrument
+    for _, instrument := range merged {
+        instruments = append(instruments, instrument)
+    }
+    
+    sort.Slice(instruments, func(i, j int) bool {
+        return instruments[i].Symbol < instruments[j].Symbol
+    })
+    return instruments, nil
+}
+
+func (s *memoryInstrumentStore) Update(instrument *models.Instrument) error {
+    if s.tx != nil {
+        if s.tx.done {
+            return ErrTxDone
+        }
+        if _, exists := s.lookup(instrument.Symbol); exists {
+            s.tx.instruments[instrument.Symbol] = *instrument
+        }
+        return nil
+    }
+    
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    if _, exists := s.data.instruments[instrument.Symbol]; exists {
+        s.data.instruments[instrument.Symbol] = *instrument
+    }
+    return nil
+}
+
+func (s *memoryInstrumentStore) lookup(symbol string) (models.Instrument, bool) {
+    if s.tx != nil {
+        if instrument, exists := s.tx.instruments[symbol]; exists {
+            return instrument, true
+        }
+    }
+    
+    s.data.mutex.RLock()
+    defer s.data.mutex.RUnlock()
+    
+    instrument, exists := s.data.instruments[symbol]
+    return instrument, exists
+}
+
+type memoryAccountStore struct {
+    data *memoryData
+}
+
+func (s *memoryAccountStore) Create(account *models.Account) error {
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    if _, exists := s.data.accounts[account.ID]; exists {
+        return fmt.Errorf("duplicate account id %s", account.ID)
+    }
+    stored := *account
+    stored.Balances = nil
+    s.data.accounts[account.ID] = stored
+    return nil
+}
+
+func (s *memoryAccountStore) GetByID(id string) (*models.Account, error) {
+    s.data.mutex.RLock()
+    defer s.data.mutex.RUnlock()
+    
+    account, exists := s.data.accounts[id]
+    if !exists {
+        return nil, models.ErrAccountNotFound
+    }
+    return &account, nil
+}
+
+func (s *memoryAccountStore) GetAll() ([]models.Account, error) {
+    s.data.mutex.RLock()
+    defer s.data.mutex.RUnlock()
+    
+    var accounts []models.Account
+    for _, account := range s.data.accounts {
+        accounts = append(accounts, account)
+    }
+    
+    sort.Slice(accounts, func(i, j int) bool {
+        return accounts[i].ID < accounts[j].ID
+    })
+    return accounts, nil
+}
+
+func (s *memoryAccountStore) SetFees(accountID string, fees *models.FeeSchedule) error {
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    account, exists := s.data.accounts[accountID]
+    if !exists {
+        return models.ErrAccountNotFound
+    }
+    account.Fees = fees
+    s.data.accounts[accountID] = account
+    return nil
+}
+
+// memoryBalanceStore does not check that accounts exist, so a replay into memory can settle
+// orders of accounts it has never seen.
+type memoryBalanceStore struct {
+    data *memoryData
+    tx   *memoryTx // nil outside a transaction
+}
+
+func (s *memoryBalanceStore) GetAll() ([]models.Balance, error) {
+    return s.merged(func(balance *models.Balance) bool { return true }), nil
+}
+
+func (s *memoryBalanceStore) GetByAccount(accountID string) ([]models.Balance, error) {
+    return s.merged(func(balance *models.Balance) bool { return balance.AccountID == accountID }), nil
+}
+
+func (s *memoryBalanceStore) Add(change *models.Balance) error {
+    if s.tx != nil {
+        if s.tx.done {
+            return ErrTxDone
+        }
+        s.tx.balances = append(s.tx.balances, *change)
+        return nil
+    }
+    
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    s.data.add(change)
+    return nil
+}
+
+// merged returns the balances that match, with the transaction's changes applied.
+func (s *memoryBalanceStore) merged(match func(*models.Balance) bool) []models.Balance {
+    s.data.mutex.RLock()
+    merged := make(map[balanceKey]models.Balance, len(s.data.balances))
+    for key, balance := range s.data.balances {
+        merged[key] = balance
+    }
+    s.data.mutex.RUnlock()
+    
+    if s.tx != nil {
+        for _, change := range s.tx.balances {
+            addBalance(merged, change)
+        }
+    }
+    
+    var balances []models.Balance
+    for _, balance := range merged {
+        if match(&balance) {
+            balances = append(balances, balance)
+        }
+    }
+    sortBalances(balances)
+    return balances
+}
+
+// add applies a balance change to the committed data. The caller holds the write lock.
+func (d *memoryData) add(change *models.Balance) {
+    addBalance(d.balances, *change)
+}
+
+// addBalance adds a change to the balance it applies to in balances.
+func addBalance(balances map[balanceKey]models.Balance, change models.Balance) {
+    key := balanceKey{accountID: change.AccountID, asset: change.Asset}
+    balance, exists := balances[key]
+    if !exists {
+        balance = models.Balance{AccountID: change.AccountID, Asset: change.Asset}
+    }
+    balance.Available = balance.Available.Add(change.Available)
+    balance.Locked = balance.Locked.Add(change.Locked)
+    balances[key] = balance
+}
+
+// sortBalances puts balances in the same order as the SQL stores: account, then asset.
+func sortBalances(balances []models.Balance) {
+    sort.Slice(balances, func(i, j int) bool {
+        if balances[i].AccountID != balances[j].AccountID {
+            return balances[i].AccountID < balances[j].AccountID
+        }
+        return balances[i].Asset < balances[j].Asset
+    })
+}
+
+type memoryWatermarkStore struct {
+    data *memoryData
+    tx   *memoryTx // nil outside a transaction
+}
+
+func (s *memoryWatermarkStore) Get() (map[string]Watermark, error) {
+    s.data.mutex.RLock()
+    defer s.data.mutex.RUnlock()
+    
+    watermarks := make(map[string]Watermark, len(s.data.watermarks))
+    for symbol, watermark := range s.data.watermarks {
+        watermarks[symbol] = watermark
+    }
+    if s.tx != nil {
+        for symbol, watermark := range s.tx.watermarks {
+            watermarks[symbol] = watermark
+        }
+    }
+    return watermarks, nil
+}
+
+func (s *memoryWatermarkStore) Set(symbol string, watermark Watermark) error {
+    if s.tx != nil {
+        if s.tx.done {
+            return ErrTxDone
+        }
+        s.tx.watermarks[symbol] = watermark
+        return nil
+    }
+    
+    s.data.mutex.Lock()
+    defer s.data.mutex.Unlock()
+    
+    s.data.watermarks[symbol] = watermark
+    return nil
+}
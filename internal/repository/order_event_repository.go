@@ -0,0 +1,82 @@
+package repository
+
+import (
+    "order-matching-system/internal/models"
+)
+
+type OrderEventRepository struct {
+    db DBTX
+}
+
+func NewOrderEventRepository(db DBTX) *OrderEventRepository {
+    return &OrderEventRepository{db: db}
+}
+
+func (r *OrderEventRepository) Create(event *models.OrderEvent) error {
+    return r.CreateAll([]models.OrderEvent{*event})
+}
+
+// CreateAll inserts events with multi-row statements.
+func (r *OrderEventRepository) CreateAll(events []models.OrderEvent) error {
+    for start := 0; start < len(events); start += batchRows {
+        chunk := events[start:min(start+batchRows, len(events))]
+        query := `
+            INSERT INTO order_events (id, order_id, type, counter_order_id, self_trade_prevention, action, quantity, created_at)
+            VALUES ` + placeholders(len(chunk), 8)
+        
+        args := make([]interface{}, 0, len(chunk)*8)
+        for _, event := range chunk {
+            args = append(args,
+                event.ID,
+                event.OrderID,
+                event.Type,
+                event.CounterOrderID,
+                event.SelfTradePrevention,
+                event.Action,
+                event.Quantity.String(),
+                event.CreatedAt,
+            )
+        }
+        if _, err := r.db.Exec(query, args...); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// GetByOrder returns an order's events, oldest first.
+func (r *OrderEventRepository) GetByOrder(orderID string) ([]models.OrderEvent, error) {
+    query := `
+        SELECT id, order_id, type, counter_order_id, self_trade_prevention, action, quantity, created_at
+        FROM order_events
+        WHERE order_id = ?
+        ORDER BY created_at ASC, id ASC
+    `
+    
+    rows, err := r.db.Query(query, orderID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    var events []models.OrderEvent
+    for rows.Next() {
+        var event models.OrderEvent
+        err := rows.Scan(
+            &event.ID,
+            &event.OrderID,
+            &event.Type,
+            &event.CounterOrderID,
+            &event.SelfTradePrevention,
+            &event.Action,
+            &event.Quantity,
+            &event.CreatedAt,
+        )
+        if err != nil {
+            return nil, err
+        }
+        events = append(events, event)
+    }
+    
+    return events, rows.Err()
+}
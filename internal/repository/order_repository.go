@@ -4,60 +4,94 @@ import (
     "database/sql"
     "order-matching-system/internal/models"
     "log"
+    "strings"
     "github.com/shopspring/decimal"
 )
 
 type OrderRepository struct {
-    db *sql.DB
+    db DBTX
 }
 
-func NewOrderRepository(db *sql.DB) *OrderRepository {
+func NewOrderRepository(db DBTX) *OrderRepository {
     return &OrderRepository{db: db}
 }
 
+// orderColumns lists the columns orderValues and scanOrder handle, in their order.
+const orderColumns = `id, account_id, client_order_id, symbol, side, type, price, stop_price, initial_quantity, remaining_quantity,
+    display_quantity, visible_quantity, reserved, maker_fee_rate, taker_fee_rate, status, time_in_force, expires_at, post_only,
+    post_only_action, self_trade_prevention, reject_reason, triggered_at, priority_at, created_at, updated_at, request_hash`
+
 func (r *OrderRepository) Create(order *models.Order) error {
-    query := `
-        INSERT INTO orders (id, symbol, side, type, price, initial_quantity, remaining_quantity, status, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-    `
+    query := `INSERT INTO orders (` + orderColumns + `) VALUES ` + placeholders(1, 27)
 
-    var price interface{} = nil
-    if order.Price != nil {
-        price = order.Price.String() // string representation of decimal
+    _, err := r.db.Exec(query, orderValues(order)...)
+
+    if err != nil {
+        log.Printf("Failed to insert order: %v", err)
     }
 
-    // Convert decimal.Decimal to string for quantities (assuming InitialQuantity and RemainingQuantity are decimal.Decimal)
-    initialQty := order.InitialQuantity.String()
-    remainingQty := order.RemainingQuantity.String()
+    return err
+}
 
-    // Execute query passing actual values (not pointers)
-    _, err := r.db.Exec(query,
+// SaveAll inserts new orders and updates existing ones with multi-row statements. Existing rows
+// get the same columns Update writes.
+func (r *OrderRepository) SaveAll(orders []models.Order, driver string) error {
+    upsert := upsertClause(driver, "id", []string{
+        "price", "initial_quantity", "remaining_quantity", "visible_quantity", "reserved", "status", "reject_reason", "triggered_at", "priority_at", "updated_at",
+    })
+    
+    for start := 0; start < len(orders); start += batchRows {
+        chunk := orders[start:min(start+batchRows, len(orders))]
+        query := `INSERT INTO orders (` + orderColumns + `) VALUES ` + placeholders(len(chunk), 27) + `
+            ` + upsert
+        
+        args := make([]interface{}, 0, len(chunk)*27)
+        for i := range chunk {
+            args = append(args, orderValues(&chunk[i])...)
+        }
+        if _, err := r.db.Exec(query, args...); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// orderValues lists an order's columns in the order Create and SaveAll insert them.
+func orderValues(order *models.Order) []interface{} {
+    return []interface{}{
         order.ID,
+        nullString(order.AccountID),
+        nullString(order.ClientOrderID),
         order.Symbol,
         order.Side,
         order.Type,
-        price,
-        initialQty,
-        remainingQty,
+        nullDecimal(order.Price),
+        nullDecimal(order.StopPrice),
+        order.InitialQuantity.String(),
+        order.RemainingQuantity.String(),
+        nullDecimal(order.DisplayQuantity),
+        nullDecimal(order.VisibleQuantity),
+        order.Reserved.String(),
+        order.MakerFeeRate.String(),
+        order.TakerFeeRate.String(),
         order.Status,
+        order.TimeInForce,
+        order.ExpiresAt,
+        order.PostOnly,
+        nullString(string(order.PostOnlyAction)),
+        nullString(string(order.SelfTradePrevention)),
+        nullString(order.RejectReason),
+        order.TriggeredAt,
+        order.PriorityAt,
         order.CreatedAt,
         order.UpdatedAt,
-    )
-
-    if err != nil {
-        log.Printf("Failed to insert order: %v", err)
+        nullString(order.RequestHash),
     }
-
-    return err
 }
 
-
-    
-
-
 func (r *OrderRepository) GetByID(id string) (*models.Order, error) {
     query := `
-        SELECT id, symbol, side, type, price, initial_quantity, remaining_quantity, status, created_at, updated_at
+        SELECT ` + orderColumns + `
         FROM orders
         WHERE id = ?
     `
@@ -66,15 +100,90 @@ func (r *OrderRepository) GetByID(id string) (*models.Order, error) {
     return r.scanOrder(row)
 }
 
+// GetByClientOrderID finds an order by the ID its account gave it.
+func (r *OrderRepository) GetByClientOrderID(accountID, clientOrderID string) (*models.Order, error) {
+    query := `
+        SELECT ` + orderColumns + `
+        FROM orders
+        WHERE account_id = ? AND client_order_id = ?
+    `
+    
+    row := r.db.QueryRow(query, accountID, clientOrderID)
+    return r.scanOrder(row)
+}
+
 func (r *OrderRepository) GetOpenOrdersBySymbol(symbol string) ([]models.Order, error) {
     query := `
-        SELECT id, symbol, side, type, price, initial_quantity, remaining_quantity, status, created_at, updated_at
+        SELECT ` + orderColumns + `
         FROM orders
-        WHERE symbol = ? AND status IN ('open', 'partial')
-        ORDER BY created_at ASC
+        WHERE symbol = ? AND status IN ('open', 'partial', 'untriggered')
+        ORDER BY created_at ASC, id ASC
+    `
+    
+    return r.query(query, symbol)
+}
+
+// List returns up to the query's limit of the orders it matches, oldest first, starting after
+// its cursor.
+func (r *OrderRepository) List(q *models.OrderQuery) ([]models.Order, error) {
+    var conditions []string
+    var args []interface{}
+    
+    if q.AccountID != "" {
+        conditions = append(conditions, "account_id = ?")
+        args = append(args, q.AccountID)
+    }
+    if q.Symbol != "" {
+        conditions = append(conditions, "symbol = ?")
+        args = append(args, q.Symbol)
+    }
+    if q.Side != "" {
+        conditions = append(conditions, "side = ?")
+        args = append(args, q.Side)
+    }
+    if q.Type != "" {
+        conditions = append(conditions, "type = ?")
+        args = append(args, q.Type)
+    }
+    if len(q.Statuses) > 0 {
+        conditions = append(conditions, "status IN "+placeholders(1, len(q.Statuses)))
+        for _, status := range q.Statuses {
+            args = append(args, status)
+        }
+    }
+    if q.OpenOnly {
+        conditions = append(conditions, "status IN ('open', 'partial', 'untriggered')")
+    }
+    if q.CreatedFrom != nil {
+        conditions = append(conditions, "created_at >= ?")
+        args = append(args, *q.CreatedFrom)
+    }
+    if q.CreatedTo != nil {
+        conditions = append(conditions, "created_at < ?")
+        args = append(args, *q.CreatedTo)
+    }
+    if q.After != nil {
+        conditions = append(conditions, "(created_at > ? OR (created_at = ? AND id > ?))")
+        args = append(args, q.After.CreatedAt, q.After.CreatedAt, q.After.ID)
+    }
+    
+    query := `
+        SELECT ` + orderColumns + `
+        FROM orders`
+    if len(conditions) > 0 {
+        query += `
+        WHERE ` + strings.Join(conditions, " AND ")
+    }
+    query += `
+        ORDER BY created_at ASC, id ASC
+        LIMIT ?
     `
     
-    rows, err := r.db.Query(query, symbol)
+    return r.query(query, append(args, q.Limit)...)
+}
+
+func (r *OrderRepository) query(query string, args ...interface{}) ([]models.Order, error) {
+    rows, err := r.db.Query(query, args...)
     if err != nil {
         return nil, err
     }
@@ -89,36 +198,26 @@ func (r *OrderRepository) GetOpenOrdersBySymbol(symbol string) ([]models.Order,
         orders = append(orders, *order)
     }
     
-    return orders, nil
+    return orders, rows.Err()
 }
 
 func (r *OrderRepository) Update(order *models.Order) error {
     query := `
         UPDATE orders
-        SET remaining_quantity = ?, status = ?, updated_at = ?
+        SET price = ?, initial_quantity = ?, remaining_quantity = ?, visible_quantity = ?, reserved = ?, status = ?, reject_reason = ?, triggered_at = ?, priority_at = ?, updated_at = ?
         WHERE id = ?
     `
     
     _, err := r.db.Exec(query,
+        nullDecimal(order.Price),
+        order.InitialQuantity,
         order.RemainingQuantity,
+        nullDecimal(order.VisibleQuantity),
+        order.Reserved.String(),
         order.Status,
-        order.UpdatedAt,
-        order.ID,
-    )
-    
-    return err
-}
-
-func (r *OrderRepository) UpdateWithTx(tx *sql.Tx, order *models.Order) error {
-    query := `
-        UPDATE orders
-        SET remaining_quantity = ?, status = ?, updated_at = ?
-        WHERE id = ?
-    `
-    
-    _, err := tx.Exec(query,
-        order.RemainingQuantity,
-        order.Status,
+        nullString(order.RejectReason),
+        order.TriggeredAt,
+        order.PriorityAt,
         order.UpdatedAt,
         order.ID,
     )
@@ -130,19 +229,37 @@ func (r *OrderRepository) scanOrder(scanner interface {
     Scan(dest ...interface{}) error
 }) (*models.Order, error) {
     var order models.Order
-    var price sql.NullString
+    var accountID, clientOrderID, price, stopPrice, displayQuantity, visibleQuantity, postOnlyAction, selfTradePrevention, rejectReason, requestHash sql.NullString
+    var expiresAt, triggeredAt sql.NullTime
     
     err := scanner.Scan(
         &order.ID,
+        &accountID,
+        &clientOrderID,
         &order.Symbol,
         &order.Side,
         &order.Type,
         &price,
+        &stopPrice,
         &order.InitialQuantity,
         &order.RemainingQuantity,
+        &displayQuantity,
+        &visibleQuantity,
+        &order.Reserved,
+        &order.MakerFeeRate,
+        &order.TakerFeeRate,
         &order.Status,
+        &order.TimeInForce,
+        &expiresAt,
+        &order.PostOnly,
+        &postOnlyAction,
+        &selfTradePrevention,
+        &rejectReason,
+        &triggeredAt,
+        &order.PriorityAt,
         &order.CreatedAt,
         &order.UpdatedAt,
+        &requestHash,
     )
     
     if err != nil {
@@ -152,13 +269,62 @@ func (r *OrderRepository) scanOrder(scanner interface {
         return nil, err
     }
     
-    if price.Valid {
-        p, err := decimal.NewFromString(price.String)
-        if err != nil {
-            return nil, err
-        }
-        order.Price = &p
+    if order.Price, err = parseNullDecimal(price); err != nil {
+        return nil, err
+    }
+    
+    if order.StopPrice, err = parseNullDecimal(stopPrice); err != nil {
+        return nil, err
+    }
+    
+    if order.DisplayQuantity, err = parseNullDecimal(displayQuantity); err != nil {
+        return nil, err
+    }
+    
+    if order.VisibleQuantity, err = parseNullDecimal(visibleQuantity); err != nil {
+        return nil, err
+    }
+    
+    order.AccountID = accountID.String
+    order.ClientOrderID = clientOrderID.String
+    order.PostOnlyAction = models.PostOnlyAction(postOnlyAction.String)
+    order.SelfTradePrevention = models.SelfTradePrevention(selfTradePrevention.String)
+    order.RejectReason = rejectReason.String
+    order.RequestHash = requestHash.String
+    
+    if expiresAt.Valid {
+        order.ExpiresAt = &expiresAt.Time
+    }
+    
+    if triggeredAt.Valid {
+        order.TriggeredAt = &triggeredAt.Time
     }
     
     return &order, nil
+}
+
+// nullDecimal converts an optional decimal into a value the driver stores as NULL or its exact string form.
+func nullDecimal(d *decimal.Decimal) interface{} {
+    if d == nil {
+        return nil
+    }
+    return d.String()
+}
+
+func nullString(value string) interface{} {
+    if value == "" {
+        return nil
+    }
+    return value
+}
+
+func parseNullDecimal(value sql.NullString) (*decimal.Decimal, error) {
+    if !value.Valid {
+        return nil, nil
+    }
+    d, err := decimal.NewFromString(value.String)
+    if err != nil {
+        return nil, err
+    }
+    return &d, nil
 }
\ No newline at end of file
@@ -0,0 +1,40 @@
+package repository
+
+import (
+    "database/sql"
+    "order-matching-system/internal/database"
+)
+
+// NewPostgresStore returns the SQL repositories running against Postgres, with their ?
+// placeholders rewritten to $n.
+func NewPostgresStore(db *sql.DB) *Store {
+    conn := postgresConn{db: db}
+    unitOfWork := &sqlUnitOfWork{db: db, driver: database.DriverPostgres, wrap: func(tx DBTX) DBTX { return postgresConn{db: tx} }}
+    return &Store{
+        Orders:      NewOrderRepository(conn),
+        Trades:      NewTradeRepository(conn),
+        OrderEvents: NewOrderEventRepository(conn),
+        Instruments: NewInstrumentRepository(conn),
+        Accounts:    NewAccountRepository(conn),
+        Balances:    &sqlBalanceStore{BalanceRepository: NewBalanceRepository(conn, database.DriverPostgres), unitOfWork: unitOfWork},
+        Watermark:   NewWatermarkRepository(conn),
+        UnitOfWork:  unitOfWork,
+        Batches:     unitOfWork,
+    }
+}
+
+type postgresConn struct {
+    db DBTX
+}
+
+func (c postgresConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return c.db.Exec(database.Rebind(database.DriverPostgres, query), args...)
+}
+
+func (c postgresConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+    return c.db.Query(database.Rebind(database.DriverPostgres, query), args...)
+}
+
+func (c postgresConn) QueryRow(query string, args ...interface{}) *sql.Row {
+    return c.db.QueryRow(database.Rebind(database.DriverPostgres, query), args...)
+}
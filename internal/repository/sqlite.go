@@ -0,0 +1,60 @@
+package repository
+
+import (
+    "database/sql"
+    "order-matching-system/internal/database"
+    "time"
+)
+
+// NewSQLiteStore returns the SQL repositories running against SQLite. The schema stores
+// decimals as TEXT, which the repositories already write and scan as exact strings.
+func NewSQLiteStore(db *sql.DB) *Store {
+    conn := sqliteConn{db: db}
+    unitOfWork := &sqlUnitOfWork{db: db, driver: database.DriverSQLite, wrap: func(tx DBTX) DBTX { return sqliteConn{db: tx} }}
+    return &Store{
+        Orders:      NewOrderRepository(conn),
+        Trades:      NewTradeRepository(conn),
+        OrderEvents: NewOrderEventRepository(conn),
+        Instruments: NewInstrumentRepository(conn),
+        Accounts:    NewAccountRepository(conn),
+        Balances:    &sqlBalanceStore{BalanceRepository: NewBalanceRepository(conn, database.DriverSQLite), unitOfWork: unitOfWork},
+        Watermark:   NewWatermarkRepository(conn),
+        UnitOfWork:  unitOfWork,
+        Batches:     unitOfWork,
+    }
+}
+
+// sqliteConn converts time arguments to UTC. SQLite keeps timestamps as text, which only
+// sorts chronologically when every value is written in the same zone.
+type sqliteConn struct {
+    db DBTX
+}
+
+func (c sqliteConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return c.db.Exec(query, utcArgs(args)...)
+}
+
+func (c sqliteConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+    return c.db.Query(query, utcArgs(args)...)
+}
+
+func (c sqliteConn) QueryRow(query string, args ...interface{}) *sql.Row {
+    return c.db.QueryRow(query, utcArgs(args)...)
+}
+
+func utcArgs(args []interface{}) []interface{} {
+    converted := make([]interface{}, len(args))
+    for i, arg := range args {
+        switch value := arg.(type) {
+        case time.Time:
+            converted[i] = value.UTC()
+        case *time.Time:
+            if value != nil {
+                converted[i] = value.UTC()
+            }
+        default:
+            converted[i] = arg
+        }
+    }
+    return converted
+}
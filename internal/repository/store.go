@@ -0,0 +1,195 @@
+package repository
+
+import (
+    "database/sql"
+    "order-matching-system/internal/database"
+    "order-matching-system/internal/models"
+    "time"
+)
+
+type OrderStore interface {
+    Create(order *models.Order) error
+    GetByID(id string) (*models.Order, error)
+    GetByClientOrderID(accountID, clientOrderID string) (*models.Order, error)
+    GetOpenOrdersBySymbol(symbol string) ([]models.Order, error)
+    List(query *models.OrderQuery) ([]models.Order, error) // Oldest first, after query.After, at most query.Limit
+    Update(order *models.Order) error
+}
+
+type TradeStore interface {
+    Create(trade *models.Trade) error
+    GetBySymbol(symbol string, limit int) ([]models.Trade, error)
+    GetSince(since time.Time) ([]models.Trade, error) // Oldest first
+}
+
+// OrderEventStore holds order history besides trades.
+type OrderEventStore interface {
+    Create(event *models.OrderEvent) error
+    GetByOrder(orderID string) ([]models.OrderEvent, error) // Oldest first
+}
+
+type InstrumentStore interface {
+    Create(instrument *models.Instrument) error
+    GetBySymbol(symbol string) (*models.Instrument, error)
+    GetAll() ([]models.Instrument, error)
+    Update(instrument *models.Instrument) error
+}
+
+type AccountStore interface {
+    Create(account *models.Account) error
+    GetByID(id string) (*models.Account, error)
+    GetAll() ([]models.Account, error)
+    SetFees(accountID string, fees *models.FeeSchedule) error // nil clears the account's schedule
+}
+
+// BalanceStore holds account balances. A balance only changes by Add, which applies a difference
+// rather than a new value, so transactions changing the same balance never overwrite each other.
+// Outside a transaction, Add commits on its own.
+type BalanceStore interface {
+    GetAll() ([]models.Balance, error)
+    GetByAccount(accountID string) ([]models.Balance, error)
+    Add(change *models.Balance) error // Available and Locked hold the amounts to add
+}
+
+// Watermark is how far a symbol's committed state goes: the last journal sequence applied to it
+// and the version its order book was left at.
+type Watermark struct {
+    Sequence     uint64
+    BookSequence uint64 // 0 for symbols last committed before book versions were stored
+}
+
+// WatermarkStore records, per symbol, the last journal sequence whose effects have been
+// committed. Symbols without a watermark have committed nothing from the journal. Account and
+// funding commands keep theirs under a name no symbol can take.
+type WatermarkStore interface {
+    Get() (map[string]Watermark, error)
+    Set(symbol string, watermark Watermark) error
+}
+
+// Tx is an open unit of work. Writes made through its stores become visible to other
+// readers only on Commit; Rollback after Commit is a no-op, so it can always be deferred.
+type Tx interface {
+    Orders() OrderStore
+    Trades() TradeStore
+    OrderEvents() OrderEventStore
+    Instruments() InstrumentStore
+    Balances() BalanceStore
+    Watermark() WatermarkStore
+    Commit() error
+    Rollback() error
+}
+
+type UnitOfWork interface {
+    Begin() (Tx, error)
+}
+
+// Store bundles the storage the engine and services run against.
+type Store struct {
+    Orders      OrderStore
+    Trades      TradeStore
+    OrderEvents OrderEventStore
+    Instruments InstrumentStore
+    Accounts    AccountStore
+    Balances    BalanceStore
+    Watermark   WatermarkStore
+    UnitOfWork  UnitOfWork
+    Batches     BatchWriter // nil when the store cannot write batches
+}
+
+// DBTX is the part of *sql.DB and *sql.Tx the MySQL repositories use, so the same
+// repository can run inside or outside a transaction.
+type DBTX interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+    Query(query string, args ...interface{}) (*sql.Rows, error)
+    QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func NewMySQLStore(db *sql.DB) *Store {
+    unitOfWork := &sqlUnitOfWork{db: db, driver: database.DriverMySQL, wrap: func(tx DBTX) DBTX { return tx }}
+    return &Store{
+        Orders:      NewOrderRepository(db),
+        Trades:      NewTradeRepository(db),
+        OrderEvents: NewOrderEventRepository(db),
+        Instruments: NewInstrumentRepository(db),
+        Accounts:    NewAccountRepository(db),
+        Balances:    &sqlBalanceStore{BalanceRepository: NewBalanceRepository(db, database.DriverMySQL), unitOfWork: unitOfWork},
+        Watermark:   NewWatermarkRepository(db),
+        UnitOfWork:  unitOfWork,
+        Batches:     unitOfWork,
+    }
+}
+
+type sqlUnitOfWork struct {
+    db     *sql.DB
+    driver string
+    wrap   func(DBTX) DBTX // Applied to each transaction before the repositories see it
+}
+
+func (u *sqlUnitOfWork) Begin() (Tx, error) {
+    tx, err := u.db.Begin()
+    if err != nil {
+        return nil, err
+    }
+    return &sqlTx{tx: tx, conn: u.wrap(tx), driver: u.driver}, nil
+}
+
+// sqlBalanceStore gives a balance change made outside a transaction one of its own, since Add
+// reads the balance before writing it.
+type sqlBalanceStore struct {
+    *BalanceRepository
+    unitOfWork *sqlUnitOfWork
+}
+
+func (s *sqlBalanceStore) Add(change *models.Balance) error {
+    tx, err := s.unitOfWork.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    
+    if err := tx.Balances().Add(change); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+type sqlTx struct {
+    tx     *sql.Tx
+    conn   DBTX
+    driver string
+}
+
+func (t *sqlTx) Orders() OrderStore {
+    return NewOrderRepository(t.conn)
+}
+
+func (t *sqlTx) Trades() TradeStore {
+    return NewTradeRepository(t.conn)
+}
+
+func (t *sqlTx) OrderEvents() OrderEventStore {
+    return NewOrderEventRepository(t.conn)
+}
+
+func (t *sqlTx) Instruments() InstrumentStore {
+    return NewInstrumentRepository(t.conn)
+}
+
+func (t *sqlTx) Balances() BalanceStore {
+    return NewBalanceRepository(t.conn, t.driver)
+}
+
+func (t *sqlTx) Watermark() WatermarkStore {
+    return NewWatermarkRepository(t.conn)
+}
+
+func (t *sqlTx) Commit() error {
+    return t.tx.Commit()
+}
+
+func (t *sqlTx) Rollback() error {
+    if err := t.tx.Rollback(); err != sql.ErrTxDone {
+        return err
+    }
+    return nil
+}
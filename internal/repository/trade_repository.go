@@ -3,64 +3,96 @@ package repository
 import (
     "database/sql"
     "order-matching-system/internal/models"
+    "time"
 )
 
 type TradeRepository struct {
-    db *sql.DB
+    db DBTX
 }
 
-func NewTradeRepository(db *sql.DB) *TradeRepository {
+func NewTradeRepository(db DBTX) *TradeRepository {
     return &TradeRepository{db: db}
 }
 
+// tradeColumns lists the columns tradeValues and query handle, in their order.
+const tradeColumns = `id, symbol, buy_order_id, sell_order_id, price, quantity, taker_side, maker_account_id, taker_account_id,
+    maker_fee, maker_fee_asset, taker_fee, taker_fee_asset, executed_at, sequence, fill_index`
+
 func (r *TradeRepository) Create(trade *models.Trade) error {
-    query := `
-        INSERT INTO trades (id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?)
-    `
+    query := `INSERT INTO trades (` + tradeColumns + `) VALUES ` + placeholders(1, 16)
     
-    _, err := r.db.Exec(query,
-        trade.ID,
-        trade.Symbol,
-        trade.BuyOrderID,
-        trade.SellOrderID,
-        trade.Price,
-        trade.Quantity,
-        trade.ExecutedAt,
-    )
+    _, err := r.db.Exec(query, tradeValues(trade)...)
     
     return err
 }
 
-func (r *TradeRepository) CreateWithTx(tx *sql.Tx, trade *models.Trade) error {
-    query := `
-        INSERT INTO trades (id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?)
-    `
-    
-    _, err := tx.Exec(query,
+// CreateAll inserts trades with multi-row statements.
+func (r *TradeRepository) CreateAll(trades []models.Trade) error {
+    for start := 0; start < len(trades); start += batchRows {
+        chunk := trades[start:min(start+batchRows, len(trades))]
+        query := `INSERT INTO trades (` + tradeColumns + `) VALUES ` + placeholders(len(chunk), 16)
+        
+        args := make([]interface{}, 0, len(chunk)*16)
+        for i := range chunk {
+            args = append(args, tradeValues(&chunk[i])...)
+        }
+        if _, err := r.db.Exec(query, args...); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// tradeValues lists a trade's columns in the order Create and CreateAll insert them.
+func tradeValues(trade *models.Trade) []interface{} {
+    return []interface{}{
         trade.ID,
         trade.Symbol,
         trade.BuyOrderID,
         trade.SellOrderID,
-        trade.Price,
-        trade.Quantity,
+        trade.Price.String(),
+        trade.Quantity.String(),
+        nullString(string(trade.TakerSide)),
+        nullString(trade.MakerAccountID),
+        nullString(trade.TakerAccountID),
+        trade.MakerFee.String(),
+        nullString(trade.MakerFeeAsset),
+        trade.TakerFee.String(),
+        nullString(trade.TakerFeeAsset),
         trade.ExecutedAt,
-    )
-    
-    return err
+        trade.Sequence,
+        trade.FillIndex,
+    }
 }
 
+// GetBySymbol returns the symbol's latest trades, newest first. Trades of one command share
+// executed_at, so they are ordered by command sequence and fill index.
 func (r *TradeRepository) GetBySymbol(symbol string, limit int) ([]models.Trade, error) {
     query := `
-        SELECT id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at
+        SELECT ` + tradeColumns + `
         FROM trades
         WHERE symbol = ?
-        ORDER BY executed_at DESC
+        ORDER BY sequence DESC, fill_index DESC, executed_at DESC, id DESC
         LIMIT ?
     `
     
-    rows, err := r.db.Query(query, symbol, limit)
+    return r.query(query, symbol, limit)
+}
+
+// GetSince returns every trade executed at or after since, oldest first.
+func (r *TradeRepository) GetSince(since time.Time) ([]models.Trade, error) {
+    query := `
+        SELECT ` + tradeColumns + `
+        FROM trades
+        WHERE executed_at >= ?
+        ORDER BY sequence ASC, fill_index ASC, executed_at ASC, id ASC
+    `
+    
+    return r.query(query, since)
+}
+
+func (r *TradeRepository) query(query string, args ...interface{}) ([]models.Trade, error) {
+    rows, err := r.db.Query(query, args...)
     if err != nil {
         return nil, err
     }
@@ -69,6 +101,7 @@ func (r *TradeRepository) GetBySymbol(symbol string, limit int) ([]models.Trade,
     var trades []models.Trade
     for rows.Next() {
         var trade models.Trade
+        var takerSide, makerAccountID, takerAccountID, makerFeeAsset, takerFeeAsset sql.NullString
         err := rows.Scan(
             &trade.ID,
             &trade.Symbol,
@@ -76,13 +109,28 @@ func (r *TradeRepository) GetBySymbol(symbol string, limit int) ([]models.Trade,
             &trade.SellOrderID,
             &trade.Price,
             &trade.Quantity,
+            &takerSide,
+            &makerAccountID,
+            &takerAccountID,
+            &trade.MakerFee,
+            &makerFeeAsset,
+            &trade.TakerFee,
+            &takerFeeAsset,
             &trade.ExecutedAt,
+            &trade.Sequence,
+            &trade.FillIndex,
         )
         if err != nil {
             return nil, err
         }
+        
+        trade.TakerSide = models.OrderSide(takerSide.String)
+        trade.MakerAccountID = makerAccountID.String
+        trade.TakerAccountID = takerAccountID.String
+        trade.MakerFeeAsset = makerFeeAsset.String
+        trade.TakerFeeAsset = takerFeeAsset.String
         trades = append(trades, trade)
     }
     
-    return trades, nil
-}
\ No newline at end of file
+    return trades, rows.Err()
+}
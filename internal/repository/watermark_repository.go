@@ -0,0 +1,52 @@
+package repository
+
+type WatermarkRepository struct {
+    db DBTX
+}
+
+func NewWatermarkRepository(db DBTX) *WatermarkRepository {
+    return &WatermarkRepository{db: db}
+}
+
+func (r *WatermarkRepository) Get() (map[string]Watermark, error) {
+    rows, err := r.db.Query(`SELECT symbol, sequence, book_sequence FROM journal_watermarks`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    watermarks := make(map[string]Watermark)
+    for rows.Next() {
+        var symbol string
+        var watermark Watermark
+        if err := rows.Scan(&symbol, &watermark.Sequence, &watermark.BookSequence); err != nil {
+            return nil, err
+        }
+        watermarks[symbol] = watermark
+    }
+    return watermarks, rows.Err()
+}
+
+// Set updates the symbol's watermark, inserting it the first time the symbol commits. Upserts
+// are spelled differently by every driver, so this takes an update and, if needed, an insert.
+func (r *WatermarkRepository) Set(symbol string, watermark Watermark) error {
+    result, err := r.db.Exec(`UPDATE journal_watermarks SET sequence = ?, book_sequence = ? WHERE symbol = ?`, watermark.Sequence, watermark.BookSequence, symbol)
+    if err != nil {
+        return err
+    }
+    if updated, err := result.RowsAffected(); err == nil && updated > 0 {
+        return nil
+    }
+    
+    // MySQL counts only changed rows, so an unchanged watermark also lands here
+    var count int
+    if err := r.db.QueryRow(`SELECT COUNT(*) FROM journal_watermarks WHERE symbol = ?`, symbol).Scan(&count); err != nil {
+        return err
+    }
+    if count > 0 {
+        return nil
+    }
+    
+    _, err = r.db.Exec(`INSERT INTO journal_watermarks (symbol, sequence, book_sequence) VALUES (?, ?, ?)`, symbol, watermark.Sequence, watermark.BookSequence)
+    return err
+}
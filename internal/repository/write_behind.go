@@ -0,0 +1,1019 @@
+package repository
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "order-matching-system/internal/models"
+    "sort"
+    "sync"
+    "time"
+)
+
+var ErrWriteBehindClosed = errors.New("write-behind store is closed")
+
+// WriteBehindConfig tunes how a write-behind store batches its writes.
+type WriteBehindConfig struct {
+    FlushSize     int           // Most commits written in one batch; a full batch is written at once
+    FlushInterval time.Duration // How often commits short of a full batch are written
+    MaxPending    int           // Commits held in memory before Commit blocks
+}
+
+// WriteBehind is a store whose transactions commit to memory. A worker writes the commits to
+// the underlying store in batches, in commit order, each batch in one transaction. Reads see
+// committed writes before they reach storage.
+//
+// Commits are only as durable as the journal: each batch carries the watermarks its commits set,
+// so after a crash the journal is replayed from wherever storage got to. When storage falls
+// behind by MaxPending commits, Commit blocks until the worker catches up.
+type WriteBehind struct {
+    target *Store
+    config WriteBehindConfig
+    
+    mutex        sync.Mutex
+    room         *sync.Cond                    // Signalled when a write frees room in the queue, or on Close
+    queue        []*pendingCommit              // Committed but not yet written, oldest first
+    next         uint64                        // Number of the last commit
+    orders       map[string]pendingOrder       // Newest unwritten state of each order
+    clientOrders map[clientOrderKey]string     // IDs of the unwritten orders that have a client order ID
+    instruments  map[string]pendingInstrument
+    balances     map[balanceKey]models.Balance // Sum of the unwritten changes to each balance
+    watermarks   map[string]pendingWatermark
+    generation   uint64                        // Bumped by every write, so reads that merge storage and memory can retry
+    closed       bool
+    stats        WriteBehindStats
+    stalled      time.Duration
+    
+    flushMutex sync.Mutex // Keeps the worker and Flush from writing the same commits
+    wake       chan struct{}
+    stop       chan struct{}
+    done       chan struct{}
+}
+
+// WriteBehindStats reports how far storage is behind the engine.
+type WriteBehindStats struct {
+    PendingCommits   int        `json:"pending_commits"`
+    PendingOrders    int        `json:"pending_orders"`
+    MaxPending       int        `json:"max_pending"`
+    LagSeconds       float64    `json:"lag_seconds"` // Age of the oldest commit not yet written
+    FlushedBatches   uint64     `json:"flushed_batches"`
+    FlushedCommits   uint64     `json:"flushed_commits"`
+    FlushedOrders    uint64     `json:"flushed_orders"`
+    FlushedTrades    uint64     `json:"flushed_trades"`
+    LastFlushAt      *time.Time `json:"last_flush_at,omitempty"`
+    LastFlushCommits int        `json:"last_flush_commits"`
+    LastFlushSeconds float64    `json:"last_flush_seconds"`
+    FailedFlushes    uint64     `json:"failed_flushes"`
+    LastError        string     `json:"last_error,omitempty"` // Cleared by the next successful write
+    StalledCommits   uint64     `json:"stalled_commits"`      // Commits that waited for room in the queue
+    StalledSeconds   float64    `json:"stalled_seconds"`
+}
+
+type pendingCommit struct {
+    number      uint64
+    committedAt time.Time
+    orders      []models.Order
+    trades      []models.Trade
+    events      []models.OrderEvent
+    instruments []models.Instrument
+    created     map[string]bool // Symbols of the instruments the commit created
+    balances    []models.Balance
+    watermarks  map[string]Watermark
+}
+
+type pendingOrder struct {
+    order  models.Order
+    commit uint64
+}
+
+type pendingInstrument struct {
+    instrument models.Instrument
+    commit     uint64
+}
+
+type pendingWatermark struct {
+    watermark Watermark
+    commit    uint64
+}
+
+// NewWriteBehind starts a write-behind store in front of target, which must be able to write
+// batches.
+func NewWriteBehind(target *Store, config WriteBehindConfig) (*WriteBehind, error) {
+    if target.Batches == nil {
+        return nil, errors.New("storage cannot write batches")
+    }
+    if config.FlushSize < 1 || config.FlushInterval <= 0 || config.MaxPending < config.FlushSize {
+        return nil, fmt.Errorf("invalid write-behind config %+v", config)
+    }
+    
+    w := &WriteBehind{
+        target:       target,
+        config:       config,
+        orders:       make(map[string]pendingOrder),
+        clientOrders: make(map[clientOrderKey]string),
+        instruments:  make(map[string]pendingInstrument),
+        balances:     make(map[balanceKey]models.Balance),
+        watermarks:   make(map[string]pendingWatermark),
+        wake:         make(chan struct{}, 1),
+        stop:         make(chan struct{}),
+        done:         make(chan struct{}),
+    }
+    w.room = sync.NewCond(&w.mutex)
+    w.stats.MaxPending = config.MaxPending
+    
+    go w.run()
+    return w, nil
+}
+
+// Store returns repositories that read and write through the write-behind store.
+func (w *WriteBehind) Store() *Store {
+    return &Store{
+        Orders:      &writeBehindOrderStore{store: w},
+        Trades:      &writeBehindTradeStore{store: w},
+        OrderEvents: &writeBehindOrderEventStore{store: w},
+        Instruments: &writeBehindInstrumentStore{store: w},
+        Accounts:    w.target.Accounts,
+        Balances:    &writeBehindBalanceStore{store: w},
+        Watermark:   &writeBehindWatermarkStore{store: w},
+        UnitOfWork:  w,
+    }
+}
+
+func (w *WriteBehind) Begin() (Tx, error) {
+    return &writeBehindTx{
+        store:        w,
+        orders:       make(map[string]models.Order),
+        clientOrders: make(map[clientOrderKey]string),
+        instruments:  make(map[string]models.Instrument),
+        created:      make(map[string]bool),
+        balances:     make(map[balanceKey]models.Balance),
+        watermarks:   make(map[string]Watermark),
+    }, nil
+}
+
+// Stats reports the commits waiting to be written and how the worker has been doing.
+func (w *WriteBehind) Stats() WriteBehindStats {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    
+    stats := w.stats
+    stats.PendingCommits = len(w.queue)
+    stats.PendingOrders = len(w.orders)
+    if len(w.queue) > 0 {
+        stats.LagSeconds = time.Since(w.queue[0].committedAt).Seconds()
+    }
+    stats.StalledSeconds = w.stalled.Seconds()
+    return stats
+}
+
+// Flush writes every commit made before the call.
+func (w *WriteBehind) Flush() error {
+    w.mutex.Lock()
+    until := w.next
+    w.mutex.Unlock()
+    
+    return w.flush(until, false)
+}
+
+// Close stops accepting commits, writes the ones already made and stops the worker.
+func (w *WriteBehind) Close() error {
+    w.mutex.Lock()
+    if w.closed {
+        w.mutex.Unlock()
+        return nil
+    }
+    w.closed = true
+    w.room.Broadcast()
+    w.mutex.Unlock()
+    
+    close(w.stop)
+    <-w.done
+    return w.Flush()
+}
+
+func (w *WriteBehind) run() {
+    defer close(w.done)
+    
+    ticker := time.NewTicker(w.config.FlushInterval)
+    defer ticker.Stop()
+    
+    for {
+        select {
+        case <-w.wake:
+            w.flush(^uint64(0), true)
+        case <-ticker.C:
+            w.flush(^uint64(0), false)
+        case <-w.stop:
+            return
+        }
+    }
+}
+
+// flush writes commits numbered up to until in batches of FlushSize. With fullOnly it stops at
+// the first batch that would be short. It gives up at the first failed write, leaving that batch
+// queued for the next attempt.
+func (w *WriteBehind) flush(until uint64, fullOnly bool) error {
+    w.flushMutex.Lock()
+    defer w.flushMutex.Unlock()
+    
+    for {
+        w.mutex.Lock()
+        count := 0
+        for count < len(w.queue) && count < w.config.FlushSize && w.queue[count].number <= until {
+            count++
+        }
+        commits := w.queue[:count:count]
+        w.mutex.Unlock()
+        
+        if count == 0 || (fullOnly && count < w.config.FlushSize) {
+            return nil
+        }
+        if err := w.write(commits); err != nil {
+            return err
+        }
+    }
+}
+
+func (w *WriteBehind) write(commits []*pendingCommit) error {
+    batch := newBatch(commits)
+    started := time.Now()
+    err := w.target.Batches.WriteBatch(batch)
+    elapsed := time.Since(started)
+    
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    
+    if err != nil {
+        w.stats.FailedFlushes++
+        w.stats.LastError = err.Error()
+        log.Printf("Error writing %d commits to storage: %v", len(commits), err)
+        return err
+    }
+    
+    // Drop the written state from memory unless a later commit has replaced it
+    last := commits[len(commits)-1].number
+    for _, commit := range commits {
+        for _, order := range commit.orders {
+            if w.orders[order.ID].commit <= last {
+                delete(w.orders, order.ID)
+                if key, named := clientOrderKeyOf(&order); named {
+                    delete(w.clientOrders, key)
+                }
+            }
+        }
+        for _, instrument := range commit.instruments {
+            if w.instruments[instrument.Symbol].commit <= last {
+                delete(w.instruments, instrument.Symbol)
+            }
+        }
+        for _, change := range commit.balances {
+            subtractBalance(w.balances, change)
+        }
+        for symbol := range commit.watermarks {
+            if w.watermarks[symbol].commit <= last {
+                delete(w.watermarks, symbol)
+            }
+        }
+    }
+    w.queue = w.queue[len(commits):]
+    w.generation++
+    w.room.Broadcast()
+    
+    now := time.Now()
+    w.stats.FlushedBatches++
+    w.stats.FlushedCommits += uint64(len(commits))
+    w.stats.FlushedOrders += uint64(len(batch.Orders))
+    w.stats.FlushedTrades += uint64(len(batch.Trades))
+    w.stats.LastFlushAt = &now
+    w.stats.LastFlushCommits = len(commits)
+    w.stats.LastFlushSeconds = elapsed.Seconds()
+    w.stats.LastError = ""
+    return nil
+}
+
+// newBatch combines commits into one batch, keeping the latest state of each row.
+func newBatch(commits []*pendingCommit) *Batch {
+    batch := &Batch{Watermarks: make(map[string]Watermark)}
+    orders := make(map[string]int)
+    instruments := make(map[string]int)
+    var latest []models.Instrument
+    created := make(map[string]bool)
+    balances := make(map[balanceKey]models.Balance)
+    
+    for _, commit := range commits {
+        for _, order := range commit.orders {
+            if i, seen := orders[order.ID]; seen {
+                batch.Orders[i] = order
+                continue
+            }
+            orders[order.ID] = len(batch.Orders)
+            batch.Orders = append(batch.Orders, order)
+        }
+        batch.Trades = append(batch.Trades, commit.trades...)
+        batch.OrderEvents = append(batch.OrderEvents, commit.events...)
+        
+        for _, instrument := range commit.instruments {
+            if i, seen := instruments[instrument.Symbol]; seen {
+                latest[i] = instrument
+                continue
+            }
+            instruments[instrument.Symbol] = len(latest)
+            latest = append(latest, instrument)
+        }
+        for symbol := range commit.created {
+            created[symbol] = true
+        }
+        for _, change := range commit.balances {
+            addBalance(balances, change)
+        }
+        
+        for symbol, watermark := range commit.watermarks {
+            batch.Watermarks[symbol] = watermark
+        }
+    }
+    
+    for _, instrument := range latest {
+        if created[instrument.Symbol] {
+            batch.NewInstruments = append(batch.NewInstruments, instrument)
+        } else {
+            batch.Instruments = append(batch.Instruments, instrument)
+        }
+    }
+    for _, balance := range balances {
+        batch.Balances = append(batch.Balances, balance)
+    }
+    sortBalances(batch.Balances)
+    return batch
+}
+
+// enqueue makes a commit visible to readers and queues it for the worker, first waiting for
+// room in the queue if storage has fallen behind.
+func (w *WriteBehind) enqueue(commit *pendingCommit) error {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    
+    if len(w.queue) >= w.config.MaxPending && !w.closed {
+        started := time.Now()
+        for len(w.queue) >= w.config.MaxPending && !w.closed {
+            w.room.Wait()
+        }
+        w.stats.StalledCommits++
+        w.stalled += time.Since(started)
+    }
+    if w.closed {
+        return ErrWriteBehindClosed
+    }
+    
+    w.next++
+    commit.number = w.next
+    commit.committedAt = time.Now()
+    w.queue = append(w.queue, commit)
+    
+    for _, order := range commit.orders {
+        w.orders[order.ID] = pendingOrder{order: order, commit: commit.number}
+        if key, named := clientOrderKeyOf(&order); named {
+            w.clientOrders[key] = order.ID
+        }
+    }
+    for _, instrument := range commit.instruments {
+        w.instruments[instrument.Symbol] = pendingInstrument{instrument: instrument, commit: commit.number}
+    }
+    for _, change := range commit.balances {
+        addBalance(w.balances, change)
+    }
+    for symbol, watermark := range commit.watermarks {
+        w.watermarks[symbol] = pendingWatermark{watermark: watermark, commit: commit.number}
+    }
+    
+    if len(w.queue) >= w.config.FlushSize {
+        select {
+        case w.wake <- struct{}{}:
+        default:
+        }
+    }
+    return nil
+}
+
+// merged runs a read that combines storage with unwritten commits, retrying it if a write
+// moved rows from memory to storage while it ran.
+func (w *WriteBehind) merged(read func() error) error {
+    for {
+        w.mutex.Lock()
+        generation := w.generation
+        w.mutex.Unlock()
+        
+        if err := read(); err != nil {
+            return err
+        }
+        
+        w.mutex.Lock()
+        unchanged := generation == w.generation
+        w.mutex.Unlock()
+        if unchanged {
+            return nil
+        }
+    }
+}
+
+// writeBehindTx buffers writes until Commit hands them to the write-behind store. Reads see the
+// transaction's own writes, then unwritten commits, then storage.
+type writeBehindTx struct {
+    store        *WriteBehind
+    orders       map[string]models.Order
+    clientOrders map[clientOrderKey]string     // IDs of the orders created in the transaction that have a client order ID
+    trades       []models.Trade
+    events       []models.OrderEvent
+    instruments  map[string]models.Instrument
+    created      map[string]bool
+    balances     map[balanceKey]models.Balance // Sum of the transaction's changes to each balance
+    watermarks   map[string]Watermark
+    done         bool
+}
+
+func (t *writeBehindTx) Orders() OrderStore {
+    return &writeBehindOrderStore{store: t.store, tx: t}
+}
+
+func (t *writeBehindTx) Trades() TradeStore {
+    return &writeBehindTradeStore{store: t.store, tx: t}
+}
+
+func (t *writeBehindTx) OrderEvents() OrderEventStore {
+    return &writeBehindOrderEventStore{store: t.store, tx: t}
+}
+
+func (t *writeBehindTx) Instruments() InstrumentStore {
+    return &writeBehindInstrumentStore{store: t.store, tx: t}
+}
+
+func (t *writeBehindTx) Balances() BalanceStore {
+    return &writeBehindBalanceStore{store: t.store, tx: t}
+}
+
+func (t *writeBehindTx) Watermark() WatermarkStore {
+    return &writeBehindWatermarkStore{store: t.store, tx: t}
+}
+
+func (t *writeBehindTx) Commit() error {
+    if t.done {
+        return ErrTxDone
+    }
+    t.done = true
+    
+    commit := &pendingCommit{
+        trades:     t.trades,
+        events:     t.events,
+        created:    t.created,
+        watermarks: t.watermarks,
+    }
+    for _, order := range t.orders {
+        commit.orders = append(commit.orders, order)
+    }
+    for _, instrument := range t.instruments {
+        commit.instruments = append(commit.instruments, instrument)
+    }
+    for _, balance := range t.balances {
+        commit.balances = append(commit.balances, balance)
+    }
+    return t.store.enqueue(commit)
+}
+
+func (t *writeBehindTx) Rollback() error {
+    t.done = true
+    return nil
+}
+
+// autocommit runs a write made outside a transaction as a transaction of its own.
+func (w *WriteBehind) autocommit(write func(tx *writeBehindTx) error) error {
+    tx, _ := w.Begin()
+    if err := write(tx.(*writeBehindTx)); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+type writeBehindOrderStore struct {
+    store *WriteBehind
+    tx    *writeBehindTx // nil outside a transaction
+}
+
+// Create does not look for the order in storage, which would cost a query per order; IDs are
+// expected to be unique, and a batch storage rejects holds back every commit after it.
+func (s *writeBehindOrderStore) Create(order *models.Order) error {
+    if s.tx == nil {
+        return s.store.autocommit(func(tx *writeBehindTx) error {
+            return tx.Orders().Create(order)
+        })
+    }
+    if s.tx.done {
+        return ErrTxDone
+    }
+    
+    if _, exists := s.pending(order.ID); exists {
+        return fmt.Errorf("duplicate order id %s", order.ID)
+    }
+    // Storage would reject the duplicate only when the batch is written, failing every commit in it
+    if _, err := s.GetByClientOrderID(order.AccountID, order.ClientOrderID); err != models.ErrOrderNotFound {
+        if err != nil {
+            return err
+        }
+        return fmt.Errorf("duplicate client order id %s", order.ClientOrderID)
+    }
+    s.tx.orders[order.ID] = *order
+    if key, named := clientOrderKeyOf(order); named {
+        s.tx.clientOrders[key] = order.ID
+    }
+    return nil
+}
+
+func (s *writeBehindOrderStore) GetByID(id string) (*models.Order, error) {
+    if order, exists := s.pending(id); exists {
+        return &order, nil
+    }
+    return s.store.target.Orders.GetByID(id)
+}
+
+// GetByClientOrderID looks in the transaction and unwritten commits before storage, and returns
+// the order's latest state whichever holds it.
+func (s *writeBehindOrderStore) GetByClientOrderID(accountID, clientOrderID string) (*models.Order, error) {
+    if accountID == "" || clientOrderID == "" {
+        return nil, models.ErrOrderNotFound
+    }
+    
+    key := clientOrderKey{accountID: accountID, clientOrderID: clientOrderID}
+    if s.tx != nil {
+        if id, exists := s.tx.clientOrders[key]; exists {
+            return s.GetByID(id)
+        }
+    }
+    
+    s.store.mutex.Lock()
+    id, exists := s.store.clientOrders[key]
+    s.store.mutex.Unlock()
+    if exists {
+        return s.GetByID(id)
+    }
+    
+    stored, err := s.store.target.Orders.GetByClientOrderID(accountID, clientOrderID)
+    if err != nil {
+        return nil, err
+    }
+    return s.GetByID(stored.ID)
+}
+
+func (s *writeBehindOrderStore) GetOpenOrdersBySymbol(symbol string) ([]models.Order, error) {
+    merged := make(map[string]models.Order)
+    err := s.store.merged(func() error {
+        stored, err := s.store.target.Orders.GetOpenOrdersBySymbol(symbol)
+        if err != nil {
+            return err
+        }
+        
+        merged = make(map[string]models.Order, len(stored))
+        for _, order := range stored {
+            merged[order.ID] = order
+        }
+        s.store.mutex.Lock()
+        for id, pending := range s.store.orders {
+            merged[id] = pending.order
+        }
+        s.store.mutex.Unlock()
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    if s.tx != nil {
+        for id, order := range s.tx.orders {
+            merged[id] = order
+        }
+    }
+    return openOrders(merged, symbol), nil
+}
+
+// List reads storage a page at a time from the query's cursor, skipping the stored orders that
+// unwritten changes replace, until it has a page of stored orders or storage runs out, and merges
+// in the unwritten orders the query matches.
+func (s *writeBehindOrderStore) List(query *models.OrderQuery) ([]models.Order, error) {
+    var orders []models.Order
+    err := s.store.merged(func() error {
+        s.store.mutex.Lock()
+        pending := make(map[string]models.Order, len(s.store.orders))
+        for id, order := range s.store.orders {
+            pending[id] = order.order
+        }
+        s.store.mutex.Unlock()
+        if s.tx != nil {
+            for id, order := range s.tx.orders {
+                pending[id] = order
+            }
+        }
+        orders = listOrders(pending, query)
+        
+        page := *query
+        for found := 0; found < query.Limit; {
+            stored, err := s.store.target.Orders.List(&page)
+            if err != nil {
+                return err
+            }
+            for _, order := range stored {
+                if _, replaced := pending[order.ID]; !replaced {
+                    orders = append(orders, order)
+                    found++
+                }
+            }
+            if len(stored) < page.Limit {
+                break
+            }
+            page.After = models.CursorOf(&stored[len(stored)-1])
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    sortOrders(orders)
+    if len(orders) > query.Limit {
+        orders = orders[:query.Limit]
+    }
+    return orders, nil
+}
+
+// Update records the order's new state. Whether the order exists is only checked when the batch
+// is written, where an unknown order is inserted.
+func (s *writeBehindOrderStore) Update(order *models.Order) error {
+    if s.tx == nil {
+        return s.store.autocommit(func(tx *writeBehindTx) error {
+            return tx.Orders().Update(order)
+        })
+    }
+    if s.tx.done {
+        return ErrTxDone
+    }
+    
+    s.tx.orders[order.ID] = *order
+    return nil
+}
+
+// pending returns the order's state in the transaction or in unwritten commits.
+func (s *writeBehindOrderStore) pending(id string) (models.Order, bool) {
+    if s.tx != nil {
+        if order, exists := s.tx.orders[id]; exists {
+            return order, true
+        }
+    }
+    
+    s.store.mutex.Lock()
+    defer s.store.mutex.Unlock()
+    
+    pending, exists := s.store.orders[id]
+    return pending.order, exists
+}
+
+type writeBehindTradeStore struct {
+    store *WriteBehind
+    tx    *writeBehindTx // nil outside a transaction
+}
+
+func (s *writeBehindTradeStore) Create(trade *models.Trade) error {
+    if s.tx == nil {
+        return s.store.autocommit(func(tx *writeBehindTx) error {
+            return tx.Trades().Create(trade)
+        })
+    }
+    if s.tx.done {
+        return ErrTxDone
+    }
+    
+    s.tx.trades = append(s.tx.trades, *trade)
+    return nil
+}
+
+func (s *writeBehindTradeStore) GetBySymbol(symbol string, limit int) ([]models.Trade, error) {
+    var all []models.Trade
+    err := s.store.merged(func() error {
+        stored, err := s.store.target.Trades.GetBySymbol(symbol, limit)
+        if err != nil {
+            return err
+        }
+        
+        all = stored
+        s.store.mutex.Lock()
+        for _, commit := range s.store.queue {
+            all = append(all, commit.trades...)
+        }
+        s.store.mutex.Unlock()
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    if s.tx != nil {
+        all = append(all, s.tx.trades...)
+    }
+    return latestTrades(all, symbol, limit), nil
+}
+
+func (s *writeBehindTradeStore) GetSince(since time.Time) ([]models.Trade, error) {
+    var all []models.Trade
+    err := s.store.merged(func() error {
+        stored, err := s.store.target.Trades.GetSince(since)
+        if err != nil {
+            return err
+        }
+        
+        all = stored
+        s.store.mutex.Lock()
+        for _, commit := range s.store.queue {
+            all = append(all, commit.trades...)
+        }
+        s.store.mutex.Unlock()
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    if s.tx != nil {
+        all = append(all, s.tx.trades...)
+    }
+    return tradesSince(all, since), nil
+}
+
+type writeBehindOrderEventStore struct {
+    store *WriteBehind
+    tx    *writeBehindTx // nil outside a transaction
+}
+
+func (s *writeBehindOrderEventStore) Create(event *models.OrderEvent) error {
+    if s.tx == nil {
+        return s.store.autocommit(func(tx *writeBehindTx) error {
+            return tx.OrderEvents().Create(event)
+        })
+    }
+    if s.tx.done {
+        return ErrTxDone
+    }
+    
+    s.tx.events = append(s.tx.events, *event)
+    return nil
+}
+
+func (s *writeBehindOrderEventStore) GetByOrder(orderID string) ([]models.OrderEvent, error) {
+    var all []models.OrderEvent
+    err := s.store.merged(func() error {
+        stored, err := s.store.target.OrderEvents.GetByOrder(orderID)
+        if err != nil {
+            return err
+        }
+        
+        all = stored
+        s.store.mutex.Lock()
+        for _, commit := range s.store.queue {
+            all = append(all, commit.events...)
+        }
+        s.store.mutex.Unlock()
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    if s.tx != nil {
+        all = append(all, s.tx.events...)
+    }
+    return orderEvents(all, orderID), nil
+}
+
+type writeBehindInstrumentStore struct {
+    store *WriteBehind
+    tx    *writeBehindTx // nil outside a transaction
+}
+
+func (s *writeBehindInstrumentStore) Create(instrument *models.Instrument) error {
+    if s.tx == nil {
+        return s.store.autocommit(func(tx *writeBehindTx) error {
+            return tx.Instruments().Create(instrument)
+        })
+    }
+    if s.tx.done {
+        return ErrTxDone
+    }
+    
+    if _, err := s.GetBySymbol(instrument.Symbol); err == nil {
+        return fmt.Errorf("duplicate symbol %s", instrument.Symbol)
+    } else if err != models.ErrInstrumentNotFound {
+        return err
+    }
+    s.tx.instruments[instrument.Symbol] = *instrument
+    s.tx.created[instrument.Symbol] = true
+    return nil
+}
+
+func (s *writeBehindInstrumentStore) GetBySymbol(symbol string) (*models.Instrument, error) {
+    if s.tx != nil {
+        if instrument, exists := s.tx.instruments[symbol]; exists {
+            return &instrument, nil
+        }
+    }
+    
+    s.store.mutex.Lock()
+    pending, exists := s.store.instruments[symbol]
+    s.store.mutex.Unlock()
+    if exists {
+        return &pending.instrument, nil
+    }
+    return s.store.target.Instruments.GetBySymbol(symbol)
+}
+
+func (s *writeBehindInstrumentStore) GetAll() ([]models.Instrument, error) {
+    merged := make(map[string]models.Instrument)
+    err := s.store.merged(func() error {
+        stored, err := s.store.target.Instruments.GetAll()
+        if err != nil {
+            return err
+        }
+        
+        merged = make(map[string]models.Instrument, len(stored))
+        for _, instrument := range stored {
+            merged[instrument.Symbol] = instrument
+        }
+        s.store.mutex.Lock()
+        for symbol, pending := range s.store.instruments {
+            merged[symbol] = pending.instrument
+        }
+        s.store.mutex.Unlock()
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    if s.tx != nil {
+        for symbol, instrument := range s.tx.instruments {
+            merged[symbol] = instrument
+        }
+    }
+    
+    instruments := make([]models.Instrument, 0, len(merged))
+    for _, instrument := range merged {
+        instruments = append(instruments, instrument)
+    }
+    sort.Slice(instruments, func(i, j int) bool {
+        return instruments[i].Symbol < instruments[j].Symbol
+    })
+    return instruments, nil
+}
+
+func (s *writeBehindInstrumentStore) Update(instrument *models.Instrument) error {
+    if s.tx == nil {
+        return s.store.autocommit(func(tx *writeBehindTx) error {
+            return tx.Instruments().Update(instrument)
+        })
+    }
+    if s.tx.done {
+        return ErrTxDone
+    }
+    
+    if _, err := s.GetBySymbol(instrument.Symbol); err == models.ErrInstrumentNotFound {
+        return nil
+    } else if err != nil {
+        return err
+    }
+    s.tx.instruments[instrument.Symbol] = *instrument
+    return nil
+}
+
+type writeBehindWatermarkStore struct {
+    store *WriteBehind
+    tx    *writeBehindTx // nil outside a transaction
+}
+
+func (s *writeBehindWatermarkStore) Get() (map[string]Watermark, error) {
+    var watermarks map[string]Watermark
+    err := s.store.merged(func() error {
+        stored, err := s.store.target.Watermark.Get()
+        if err != nil {
+            return err
+        }
+        
+        watermarks = stored
+        s.store.mutex.Lock()
+        for symbol, pending := range s.store.watermarks {
+            watermarks[symbol] = pending.watermark
+        }
+        s.store.mutex.Unlock()
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    if s.tx != nil {
+        for symbol, watermark := range s.tx.watermarks {
+            watermarks[symbol] = watermark
+        }
+    }
+    return watermarks, nil
+}
+
+func (s *writeBehindWatermarkStore) Set(symbol string, watermark Watermark) error {
+    if s.tx == nil {
+        return s.store.autocommit(func(tx *writeBehindTx) error {
+            return tx.Watermark().Set(symbol, watermark)
+        })
+    }
+    if s.tx.done {
+        return ErrTxDone
+    }
+    
+    s.tx.watermarks[symbol] = watermark
+    return nil
+}
+
+type writeBehindBalanceStore struct {
+    store *WriteBehind
+    tx    *writeBehindTx // nil outside a transaction
+}
+
+func (s *writeBehindBalanceStore) GetAll() ([]models.Balance, error) {
+    return s.merged(s.store.target.Balances.GetAll, func(balance *models.Balance) bool { return true })
+}
+
+func (s *writeBehindBalanceStore) GetByAccount(accountID string) ([]models.Balance, error) {
+    return s.merged(func() ([]models.Balance, error) {
+        return s.store.target.Balances.GetByAccount(accountID)
+    }, func(balance *models.Balance) bool {
+        return balance.AccountID == accountID
+    })
+}
+
+// Add buffers a change made in a transaction. A change made outside one is a deposit or
+// withdrawal, which the journal does not record, so it is written to storage straight away.
+func (s *writeBehindBalanceStore) Add(change *models.Balance) error {
+    if s.tx == nil {
+        return s.store.target.Balances.Add(change)
+    }
+    if s.tx.done {
+        return ErrTxDone
+    }
+    
+    addBalance(s.tx.balances, *change)
+    return nil
+}
+
+// merged adds the unwritten changes, and the transaction's, to the balances read from storage.
+func (s *writeBehindBalanceStore) merged(read func() ([]models.Balance, error), match func(*models.Balance) bool) ([]models.Balance, error) {
+    merged := make(map[balanceKey]models.Balance)
+    err := s.store.merged(func() error {
+        stored, err := read()
+        if err != nil {
+            return err
+        }
+        
+        merged = make(map[balanceKey]models.Balance, len(stored))
+        for _, balance := range stored {
+            merged[balanceKey{accountID: balance.AccountID, asset: balance.Asset}] = balance
+        }
+        s.store.mutex.Lock()
+        for _, change := range s.store.balances {
+            addBalance(merged, change)
+        }
+        s.store.mutex.Unlock()
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    if s.tx != nil {
+        for _, change := range s.tx.balances {
+            addBalance(merged, change)
+        }
+    }
+    
+    var balances []models.Balance
+    for _, balance := range merged {
+        if match(&balance) {
+            balances = append(balances, balance)
+        }
+    }
+    sortBalances(balances)
+    return balances, nil
+}
+
+// subtractBalance takes a written change out of the unwritten sums, dropping sums that reach zero.
+func subtractBalance(balances map[balanceKey]models.Balance, change models.Balance) {
+    key := balanceKey{accountID: change.AccountID, asset: change.Asset}
+    balance := balances[key]
+    balance.Available = balance.Available.Sub(change.Available)
+    balance.Locked = balance.Locked.Sub(change.Locked)
+    if balance.Available.IsZero() && balance.Locked.IsZero() {
+        delete(balances, key)
+        return
+    }
+    balances[key] = balance
+}
@@ -0,0 +1,204 @@
+package repository_test
+
+import (
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "testing"
+    "time"
+)
+
+// gatedWriter holds every batch until the test lets it through.
+type gatedWriter struct {
+    repository.BatchWriter
+    gate chan struct{}
+}
+
+func (w *gatedWriter) WriteBatch(batch *repository.Batch) error {
+    <-w.gate
+    return w.BatchWriter.WriteBatch(batch)
+}
+
+func TestWriteBehindFlush(t *testing.T) {
+    target := newMemoryBackend(t)
+    writeBehind, err := repository.NewWriteBehind(target, repository.WriteBehindConfig{
+        FlushSize:     100,
+        FlushInterval: time.Hour,
+        MaxPending:    100,
+    })
+    if err != nil {
+        t.Fatalf("write-behind: %v", err)
+    }
+    defer writeBehind.Close()
+    store := writeBehind.Store()
+
+    tx, _ := store.UnitOfWork.Begin()
+    mustCreateOrder(t, tx.Orders(), newOrder("buy", "BTCUSD", models.BUY, "100", baseTime))
+    mustCreateOrder(t, tx.Orders(), newOrder("sell", "BTCUSD", models.SELL, "100", baseTime))
+    if err := tx.Trades().Create(newTrade("trade", "BTCUSD", "buy", "sell", baseTime)); err != nil {
+        t.Fatalf("create trade: %v", err)
+    }
+    if err := tx.Watermark().Set("BTCUSD", repository.Watermark{Sequence: 5, BookSequence: 2}); err != nil {
+        t.Fatalf("set watermark: %v", err)
+    }
+    if err := tx.Commit(); err != nil {
+        t.Fatalf("commit: %v", err)
+    }
+
+    // Visible through the write-behind store, not yet in storage
+    if _, err := store.Orders.GetByID("buy"); err != nil {
+        t.Fatalf("pending order not visible: %v", err)
+    }
+    if trades, err := store.Trades.GetBySymbol("BTCUSD", 10); err != nil || len(trades) != 1 {
+        t.Fatalf("pending trades = %v, %v", trades, err)
+    }
+    if _, err := target.Orders.GetByID("buy"); err != models.ErrOrderNotFound {
+        t.Fatalf("order written before flush: %v", err)
+    }
+    if stats := writeBehind.Stats(); stats.PendingCommits != 1 || stats.PendingOrders != 2 {
+        t.Fatalf("stats before flush = %+v", stats)
+    }
+
+    if err := writeBehind.Flush(); err != nil {
+        t.Fatalf("flush: %v", err)
+    }
+    if _, err := target.Orders.GetByID("buy"); err != nil {
+        t.Fatalf("order not written: %v", err)
+    }
+    if watermarks, err := target.Watermark.Get(); err != nil || watermarks["BTCUSD"] != (repository.Watermark{Sequence: 5, BookSequence: 2}) {
+        t.Fatalf("watermarks = %v, %v", watermarks, err)
+    }
+    if stats := writeBehind.Stats(); stats.PendingCommits != 0 || stats.FlushedCommits != 1 || stats.FlushedTrades != 1 || stats.LagSeconds != 0 {
+        t.Fatalf("stats after flush = %+v", stats)
+    }
+}
+
+func TestWriteBehindBackpressure(t *testing.T) {
+    target := newMemoryBackend(t)
+    writer := &gatedWriter{BatchWriter: target.Batches, gate: make(chan struct{})}
+    target.Batches = writer
+
+    writeBehind, err := repository.NewWriteBehind(target, repository.WriteBehindConfig{
+        FlushSize:     1,
+        FlushInterval: time.Hour,
+        MaxPending:    1,
+    })
+    if err != nil {
+        t.Fatalf("write-behind: %v", err)
+    }
+    store := writeBehind.Store()
+
+    // The worker takes the first order and waits at the gate; the second has no room
+    mustCreateOrder(t, store.Orders, newOrder("first", "BTCUSD", models.BUY, "100", baseTime))
+    committed := make(chan error)
+    go func() {
+        committed <- store.Orders.Create(newOrder("second", "BTCUSD", models.BUY, "100", baseTime))
+    }()
+
+    select {
+    case err := <-committed:
+        t.Fatalf("commit did not wait for room: %v", err)
+    case <-time.After(50 * time.Millisecond):
+    }
+
+    close(writer.gate)
+    if err := <-committed; err != nil {
+        t.Fatalf("commit: %v", err)
+    }
+    if err := writeBehind.Close(); err != nil {
+        t.Fatalf("close: %v", err)
+    }
+
+    if _, err := target.Orders.GetByID("second"); err != nil {
+        t.Fatalf("order not written by close: %v", err)
+    }
+    if stats := writeBehind.Stats(); stats.StalledCommits != 1 || stats.StalledSeconds <= 0 {
+        t.Fatalf("stats = %+v", stats)
+    }
+}
+
+func TestWriteBehindListOrders(t *testing.T) {
+    target := newMemoryBackend(t)
+    for i, id := range []string{"a", "b", "c", "d"} {
+        mustCreateOrder(t, target.Orders, newOrder(id, "BTCUSD", models.BUY, "100", baseTime.Add(time.Duration(i)*time.Second)))
+    }
+    writeBehind, err := repository.NewWriteBehind(target, repository.WriteBehindConfig{
+        FlushSize:     100,
+        FlushInterval: time.Hour,
+        MaxPending:    100,
+    })
+    if err != nil {
+        t.Fatalf("write-behind: %v", err)
+    }
+    defer writeBehind.Close()
+    store := writeBehind.Store()
+
+    // Unwritten fills take the first page of stored open orders out of the listing
+    for i, id := range []string{"a", "b"} {
+        filled := newOrder(id, "BTCUSD", models.BUY, "100", baseTime.Add(time.Duration(i)*time.Second))
+        filled.Status = models.FILLED
+        if err := store.Orders.Update(filled); err != nil {
+            t.Fatalf("update order: %v", err)
+        }
+    }
+
+    got, err := store.Orders.List(&models.OrderQuery{OpenOnly: true, Limit: 2})
+    if err != nil {
+        t.Fatalf("list: %v", err)
+    }
+    assertIDs(t, orderIDs(got), []string{"c", "d"})
+
+    // Storage is read a page at a time past every replaced order
+    got, err = store.Orders.List(&models.OrderQuery{OpenOnly: true, Limit: 1})
+    if err != nil {
+        t.Fatalf("list: %v", err)
+    }
+    assertIDs(t, orderIDs(got), []string{"c"})
+
+    // Unwritten orders take their place among the stored ones
+    mustCreateOrder(t, store.Orders, newOrder("bc", "BTCUSD", models.BUY, "100", baseTime.Add(1500*time.Millisecond)))
+    got, err = store.Orders.List(&models.OrderQuery{OpenOnly: true, Limit: 2})
+    if err != nil {
+        t.Fatalf("list: %v", err)
+    }
+    assertIDs(t, orderIDs(got), []string{"bc", "c"})
+}
+
+func TestWriteBehindClientOrderIDs(t *testing.T) {
+    target := newMemoryBackend(t)
+    writeBehind, err := repository.NewWriteBehind(target, repository.WriteBehindConfig{
+        FlushSize:     100,
+        FlushInterval: time.Hour,
+        MaxPending:    100,
+    })
+    if err != nil {
+        t.Fatalf("write-behind: %v", err)
+    }
+    defer writeBehind.Close()
+    store := writeBehind.Store()
+
+    order := newOrder("a1", "BTCUSD", models.BUY, "100", baseTime)
+    order.AccountID = "alice"
+    order.ClientOrderID = "retry-me"
+    mustCreateOrder(t, store.Orders, order)
+    order.Status = models.FILLED
+    if err := store.Orders.Update(order); err != nil {
+        t.Fatalf("update order: %v", err)
+    }
+
+    // The order is found by its client order ID in its latest state before and after it is written
+    for _, stage := range []string{"pending", "flushed"} {
+        got, err := store.Orders.GetByClientOrderID("alice", "retry-me")
+        if err != nil || got.ID != "a1" || got.Status != models.FILLED {
+            t.Fatalf("%s: got %+v, %v", stage, got, err)
+        }
+        if _, err := store.Orders.GetByClientOrderID("alice", "other"); err != models.ErrOrderNotFound {
+            t.Fatalf("%s: got %v, want ErrOrderNotFound", stage, err)
+        }
+        if err := writeBehind.Flush(); err != nil {
+            t.Fatalf("flush: %v", err)
+        }
+    }
+    if stats := writeBehind.Stats(); stats.PendingOrders != 0 {
+        t.Fatalf("pending orders = %d after flush", stats.PendingOrders)
+    }
+}
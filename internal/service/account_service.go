@@ -0,0 +1,93 @@
+package service
+
+import (
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "time"
+    
+    "github.com/google/uuid"
+)
+
+type AccountService struct {
+    accountRepo    repository.AccountStore
+    balanceRepo    repository.BalanceStore
+    matchingEngine *MatchingEngine
+}
+
+func NewAccountService(accountRepo repository.AccountStore, balanceRepo repository.BalanceStore, matchingEngine *MatchingEngine) *AccountService {
+    return &AccountService{
+        accountRepo:    accountRepo,
+        balanceRepo:    balanceRepo,
+        matchingEngine: matchingEngine,
+    }
+}
+
+func (s *AccountService) CreateAccount() (*models.Account, error) {
+    account := &models.Account{
+        ID:        uuid.New().String(),
+        CreatedAt: time.Now(),
+        Balances:  []models.Balance{},
+    }
+    
+    if err := s.matchingEngine.CreateAccount(account); err != nil {
+        return nil, err
+    }
+    return account, nil
+}
+
+// GetAccount returns an account with its balances as of the last committed command.
+func (s *AccountService) GetAccount(accountID string) (*models.Account, error) {
+    account, err := s.accountRepo.GetByID(accountID)
+    if err != nil {
+        return nil, err
+    }
+    
+    balances, err := s.balanceRepo.GetByAccount(accountID)
+    if err != nil {
+        return nil, err
+    }
+    account.Balances = append([]models.Balance{}, balances...)
+    return account, nil
+}
+
+func (s *AccountService) Deposit(accountID string, req *models.TransferRequest) (*models.Account, error) {
+    if err := req.Validate(); err != nil {
+        return nil, err
+    }
+    
+    if err := s.matchingEngine.Deposit(accountID, req.Asset, req.Amount); err != nil {
+        return nil, err
+    }
+    return s.GetAccount(accountID)
+}
+
+// SetFees gives an account its own fee schedule, replacing the symbols' schedules for its orders.
+func (s *AccountService) SetFees(accountID string, fees *models.FeeSchedule) (*models.Account, error) {
+    if err := fees.Validate(); err != nil {
+        return nil, err
+    }
+    
+    if err := s.matchingEngine.SetAccountFees(accountID, fees); err != nil {
+        return nil, err
+    }
+    return s.GetAccount(accountID)
+}
+
+// ClearFees returns an account to the symbols' fee schedules.
+func (s *AccountService) ClearFees(accountID string) (*models.Account, error) {
+    if err := s.matchingEngine.SetAccountFees(accountID, nil); err != nil {
+        return nil, err
+    }
+    return s.GetAccount(accountID)
+}
+
+func (s *AccountService) Withdraw(accountID string, req *models.TransferRequest) (*models.Account, error) {
+    if err := req.Validate(); err != nil {
+        return nil, err
+    }
+    
+    if err := s.matchingEngine.Withdraw(accountID, req.Asset, req.Amount); err != nil {
+        return nil, err
+    }
+    return s.GetAccount(accountID)
+}
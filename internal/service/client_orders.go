@@ -0,0 +1,87 @@
+package service
+
+import (
+    "order-matching-system/internal/models"
+    "sync"
+)
+
+type clientOrderKey struct {
+    accountID     string
+    clientOrderID string
+}
+
+// clientOrderClaims holds the client order IDs of orders that have been routed but not applied
+// yet. Shards reject an order whose client order ID storage already holds, but an account's orders
+// on different symbols are applied by different shards, so neither would see the other's until it
+// commits. The router refuses a client order ID that is still claimed instead.
+type clientOrderClaims struct {
+    mutex  sync.Mutex
+    claims map[clientOrderKey]string // Order IDs by account and client order ID
+}
+
+func newClientOrderClaims() *clientOrderClaims {
+    return &clientOrderClaims{claims: make(map[clientOrderKey]string)}
+}
+
+// claim reserves a new order's client order ID. It reports false if another order holds it.
+func (c *clientOrderClaims) claim(order *models.Order) bool {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    
+    key := clientOrderKey{accountID: order.AccountID, clientOrderID: order.ClientOrderID}
+    if _, claimed := c.claims[key]; claimed {
+        return false
+    }
+    c.claims[key] = order.ID
+    return true
+}
+
+// release gives up an order's claim, once the order is in storage or will never be.
+func (c *clientOrderClaims) release(order *models.Order) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    
+    key := clientOrderKey{accountID: order.AccountID, clientOrderID: order.ClientOrderID}
+    if c.claims[key] == order.ID {
+        delete(c.claims, key)
+    }
+}
+
+// claimClientOrderID reserves a new order's client order ID until its shard has applied it.
+func (me *MatchingEngine) claimClientOrderID(cmd *engineCommand) error {
+    if cmd.kind != placeCommand || cmd.order.ClientOrderID == "" {
+        return nil
+    }
+    if !me.clientOrders.claim(cmd.order) {
+        return models.ErrDuplicateClientOrderID
+    }
+    return nil
+}
+
+func (me *MatchingEngine) releaseClientOrderID(cmd *engineCommand) {
+    if cmd.kind != placeCommand || cmd.order.ClientOrderID == "" {
+        return
+    }
+    me.clientOrders.release(cmd.order)
+}
+
+// checkClientOrderID rejects an order whose client order ID its account has already used. It reads
+// storage rather than the command's transaction, which is a scratch store while catching up from a
+// snapshot. Storage may then be ahead of the command, but the only order it can hold with the same
+// client order ID besides an earlier one is the order itself.
+func (sh *shard) checkClientOrderID(order *models.Order) error {
+    if order.ClientOrderID == "" {
+        return nil
+    }
+    
+    existing, err := sh.engine.orderRepo.GetByClientOrderID(order.AccountID, order.ClientOrderID)
+    switch {
+    case err == models.ErrOrderNotFound:
+        return nil
+    case err != nil:
+        return err
+    case existing.ID != order.ID:
+        return models.ErrDuplicateClientOrderID
+    }
+    return nil
+}
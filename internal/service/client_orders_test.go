@@ -0,0 +1,150 @@
+package service
+
+import (
+    "fmt"
+    "order-matching-system/internal/models"
+    "testing"
+    
+    "github.com/shopspring/decimal"
+)
+
+func TestClientOrderIDs(t *testing.T) {
+    orders, engine, _, _ := newTestEngine(t)
+    
+    request := func(price string) *models.PlaceOrderRequest {
+        return &models.PlaceOrderRequest{
+            AccountID:     testAccount,
+            ClientOrderID: "retry-me",
+            Symbol:        "BTCUSD",
+            Side:          models.BUY,
+            Type:          models.LIMIT,
+            Price:         decimalPtr(price),
+            Quantity:      decimal.RequireFromString("1"),
+        }
+    }
+    
+    original, err := orders.PlaceOrder(request("100"))
+    if err != nil {
+        t.Fatalf("place: %v", err)
+    }
+    
+    // Resubmitting the same request returns the original order instead of placing another
+    again, err := orders.PlaceOrder(request("100.00"))
+    if err != nil || again.ID != original.ID {
+        t.Fatalf("resubmitted = %+v, %v", again, err)
+    }
+    if _, err := orders.PlaceOrder(request("101")); err != models.ErrClientOrderIDConflict {
+        t.Fatalf("got %v, want ErrClientOrderIDConflict", err)
+    }
+    // The shard turns away a duplicate that got past the service
+    duplicate := *original
+    duplicate.ID = "duplicate"
+    duplicate.Reserved = decimal.Zero
+    if err := engine.PlaceOrder(&duplicate); err != models.ErrDuplicateClientOrderID {
+        t.Fatalf("got %v, want ErrDuplicateClientOrderID", err)
+    }
+    if want := "\ntrader BTC 100/0\ntrader USD 99900/100"; ledgerState(engine) != want {
+        t.Fatalf("ledger:%s\nwant:%s", ledgerState(engine), want)
+    }
+    
+    // An order the engine has accepted but not applied yet holds its client order ID
+    pending := &models.Order{ID: "pending", AccountID: testAccount, ClientOrderID: "in-flight"}
+    engine.clientOrders.claim(pending)
+    req := request("100")
+    req.ClientOrderID = "in-flight"
+    if _, err := orders.PlaceOrder(req); err != models.ErrDuplicateClientOrderID {
+        t.Fatalf("got %v, want ErrDuplicateClientOrderID", err)
+    }
+    engine.clientOrders.release(pending)
+    
+    if got, err := orders.GetOrderByClientID(testAccount, "retry-me"); err != nil || got.ID != original.ID {
+        t.Fatalf("get by client id = %+v, %v", got, err)
+    }
+    result, err := orders.CancelOrderByClientID(testAccount, "retry-me")
+    if err != nil || result.Order.ID != original.ID || result.Order.Status != models.CANCELED {
+        t.Fatalf("cancel by client id = %+v, %v", result, err)
+    }
+    if _, err := orders.CancelOrderByClientID("someone-else", "retry-me"); err != models.ErrOrderNotFound {
+        t.Fatalf("got %v, want ErrOrderNotFound", err)
+    }
+}
+
+// TestClientOrderIDRetryAfterChange resubmits a request after the order it placed has changed
+// price or quantity, which must still return that order.
+func TestClientOrderIDRetryAfterChange(t *testing.T) {
+    tests := []struct {
+        name   string
+        req    models.PlaceOrderRequest
+        change func(t *testing.T, orders *OrderService, order *models.Order)
+        want   string // Price and initial quantity after the change
+    }{
+        {
+            name: "amend",
+            req:  models.PlaceOrderRequest{Price: decimalPtr("99"), Quantity: decimal.RequireFromString("2")},
+            change: func(t *testing.T, orders *OrderService, order *models.Order) {
+                if _, err := orders.AmendOrder(order.ID, &models.AmendOrderRequest{Price: decimalPtr("98"), Quantity: decimalPtr("1")}); err != nil {
+                    t.Fatalf("amend: %v", err)
+                }
+            },
+            want: "98 1",
+        },
+        {
+            name: "post-only reprice",
+            req: models.PlaceOrderRequest{
+                Price:          decimalPtr("100"),
+                Quantity:       decimal.RequireFromString("2"),
+                PostOnly:       true,
+                PostOnlyAction: models.POST_ONLY_REPRICE,
+            },
+            want: "99.99 2",
+        },
+        {
+            name: "self-trade decrement",
+            req: models.PlaceOrderRequest{
+                Price:               decimalPtr("100"),
+                Quantity:            decimal.RequireFromString("3"),
+                SelfTradePrevention: models.STP_DECREMENT_AND_CANCEL,
+            },
+            want: "100 1",
+        },
+    }
+    
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            orders, _, _, _ := newTestEngine(t)
+            place(t, orders, models.SELL, models.LIMIT, "100", "", "2")
+            
+            request := func() *models.PlaceOrderRequest {
+                req := test.req
+                req.AccountID = testAccount
+                req.ClientOrderID = "retry-me"
+                req.Symbol = "BTCUSD"
+                req.Side = models.BUY
+                req.Type = models.LIMIT
+                return &req
+            }
+            
+            original, err := orders.PlaceOrder(request())
+            if err != nil {
+                t.Fatalf("place: %v", err)
+            }
+            if test.change != nil {
+                test.change(t, orders, original)
+            }
+            
+            again, err := orders.PlaceOrder(request())
+            if err != nil || again.ID != original.ID {
+                t.Fatalf("resubmitted = %+v, %v", again, err)
+            }
+            if got := fmt.Sprintf("%v %v", again.Price, again.InitialQuantity); got != test.want {
+                t.Errorf("order = %s, want %s", got, test.want)
+            }
+            
+            changed := request()
+            changed.Quantity = changed.Quantity.Add(decimal.RequireFromString("1"))
+            if _, err := orders.PlaceOrder(changed); err != models.ErrClientOrderIDConflict {
+                t.Errorf("got %v, want ErrClientOrderIDConflict", err)
+            }
+        })
+    }
+}
@@ -0,0 +1,482 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "order-matching-system/internal/journal"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/snapshot"
+    "time"
+    
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+type commandType int
+
+const (
+    placeCommand commandType = iota
+    cancelCommand
+    amendCommand
+    expireCommand
+    createInstrumentCommand
+    updateInstrumentCommand
+    createAccountCommand
+    depositCommand
+    withdrawCommand
+    setAccountFeesCommand
+    snapshotCommand // Reads state only, so it is neither journaled nor sequenced
+    abortCommand    // Journaled after a command that failed to commit; never applied
+)
+
+// changesAccount reports whether a command is an account or funding command, which belongs to no
+// symbol and is applied by the router rather than a shard.
+func (kind commandType) changesAccount() bool {
+    return kind == createAccountCommand || kind == depositCommand || kind == withdrawCommand || kind == setAccountFeesCommand
+}
+
+// tradeIDSpace namespaces trade IDs derived from a command's sequence number, so that replaying
+// the journal produces the same trade IDs as the original run.
+var tradeIDSpace = uuid.MustParse("6f1c0a52-3d1e-4f0b-9a57-2c8e4b6d1f90")
+
+// eventIDSpace does the same for order events.
+var eventIDSpace = uuid.MustParse("b3d7e915-82a4-4c6e-8f1d-5a09c2e7b468")
+
+// engineCommand is a single unit of work for the shard that owns its symbol. Every change to a
+// book is applied by that shard, in the order the router journaled the commands.
+type engineCommand struct {
+    kind             commandType
+    order            *models.Order
+    orderID          string
+    amend            *models.AmendOrderRequest
+    symbol           string // Owning shard, resolved by the router
+    instrument       *models.Instrument
+    instrumentUpdate *models.UpdateInstrumentRequest
+    aborts           uint64    // Sequence of the command an abort marks as rolled back
+    reserve          decimal.Decimal // Extra funds an amend locks for its order
+    hold             *fundsHold      // Funds the router took from the ledger for the command; not journaled
+    account          *models.Account
+    accountID        string
+    asset            string
+    amount           decimal.Decimal     // Deposited or withdrawn
+    fees             *models.FeeSchedule // An account's fee schedule; nil clears it
+    sequence         uint64    // Journal sequence, assigned when the command is accepted
+    now              time.Time // Processing time, assigned when the command is accepted
+    reply            chan commandResult // nil when the caller does not wait for the outcome
+}
+
+type commandResult struct {
+    order      *models.Order
+    instrument *models.Instrument
+    trades     []*models.Trade
+    book       *snapshot.Book
+    err        error
+}
+
+// submit routes a command to its shard and blocks until the shard has applied it. A command that
+// could not be journaled or committed has had no effect, and the caller gets ErrNotApplied.
+func (me *MatchingEngine) submit(cmd *engineCommand) commandResult {
+    if err := me.resolveSymbol(cmd); err != nil {
+        return commandResult{err: err}
+    }
+    
+    cmd.reply = make(chan commandResult, 1)
+    if err := me.route(cmd); err != nil {
+        if !isRejection(err) {
+            err = models.ErrNotApplied
+        }
+        return commandResult{err: err}
+    }
+    
+    result := <-cmd.reply
+    if result.err != nil && !isRejection(result.err) {
+        log.Printf("Command %d on %s was not applied: %v", cmd.sequence, cmd.symbol, result.err)
+        me.abort(cmd)
+        return commandResult{err: models.ErrNotApplied}
+    }
+    return result
+}
+
+// abort journals that a command was rolled back, before its caller hears of the failure, so that
+// replay skips it rather than applying a command the caller was told had no effect.
+func (me *MatchingEngine) abort(cmd *engineCommand) {
+    me.routeMutex.Lock()
+    defer me.routeMutex.Unlock()
+    
+    if err := me.accept(&engineCommand{kind: abortCommand, symbol: cmd.symbol, aborts: cmd.sequence}); err != nil {
+        log.Printf("Error journaling abort of command %d: %v", cmd.sequence, err)
+    }
+}
+
+// resolveSymbol fills in the symbol of the shard that owns a command. Cancels and amends only name
+// an order, so its symbol is read from storage.
+func (me *MatchingEngine) resolveSymbol(cmd *engineCommand) error {
+    switch cmd.kind {
+    case placeCommand:
+        cmd.symbol = cmd.order.Symbol
+    case createInstrumentCommand:
+        cmd.symbol = cmd.instrument.Symbol
+    case cancelCommand, amendCommand:
+        if cmd.symbol != "" {
+            return nil
+        }
+        order, err := me.orderRepo.GetByID(cmd.orderID)
+        if err != nil {
+            return err
+        }
+        cmd.symbol = order.Symbol
+    }
+    return nil
+}
+
+// route accepts a command and hands it to its shard. Sequencing, journaling and queueing happen
+// under routeMutex, so every shard receives its commands in journal order. Funds for orders are
+// checked and fee rates fixed here too, in journal order, so replay reaches the same verdicts
+// without deciding again.
+//
+// Instrument changes drain the shard first and are applied inline, since they decide whether the
+// shard should be running at all: it runs only while its instrument is active.
+func (me *MatchingEngine) route(cmd *engineCommand) error {
+    me.routeMutex.Lock()
+    defer me.routeMutex.Unlock()
+    
+    if cmd.kind.changesAccount() {
+        return me.routeAccountCommand(cmd)
+    }
+    
+    sh := me.getShard(cmd.symbol)
+    if sh == nil {
+        if cmd.kind != createInstrumentCommand {
+            return models.ErrUnknownSymbol
+        }
+        sh = me.addShard(cmd.symbol)
+    }
+    
+    if err := me.claimClientOrderID(cmd); err != nil {
+        return err
+    }
+    me.assignFees(cmd)
+    if err := me.holdFunds(sh, cmd); err != nil {
+        me.releaseClientOrderID(cmd)
+        return err
+    }
+    
+    changesInstrument := cmd.kind == createInstrumentCommand || cmd.kind == updateInstrumentCommand
+    if changesInstrument {
+        sh.stop()
+    }
+    
+    if err := me.accept(cmd); err != nil {
+        me.releaseHold(cmd.hold)
+        me.releaseClientOrderID(cmd)
+        return err
+    }
+    sh.deliver(cmd)
+    
+    if changesInstrument && me.started && me.checkTradable(sh.symbol) == nil {
+        sh.start()
+    }
+    return nil
+}
+
+// accept stamps a command with the next sequence number and the time it is processed at and
+// writes it to the journal. A command that cannot be journaled is not applied.
+func (me *MatchingEngine) accept(cmd *engineCommand) error {
+    if cmd.now.IsZero() {
+        cmd.now = time.Now()
+    }
+    cmd.now = cmd.now.UTC()
+    cmd.sequence = me.sequence + 1
+    
+    if me.journal != nil {
+        if err := me.journal.Append(commandRecord(cmd)); err != nil {
+            log.Printf("Error journaling command %d: %v", cmd.sequence, err)
+            return err
+        }
+    }
+    
+    me.sequence = cmd.sequence
+    return nil
+}
+
+// isRejection reports whether err is a business outcome returned to the client rather than a
+// failure to process the command.
+func isRejection(err error) bool {
+    var apiErr *models.APIError
+    return errors.As(err, &apiErr)
+}
+
+func commandRecord(cmd *engineCommand) *journal.Record {
+    record := &journal.Record{
+        Sequence:         cmd.sequence,
+        Time:             cmd.now,
+        Order:            cmd.order,
+        OrderID:          cmd.orderID,
+        Amend:            cmd.amend,
+        Symbol:           cmd.symbol,
+        Instrument:       cmd.instrument,
+        InstrumentUpdate: cmd.instrumentUpdate,
+        Aborts:           cmd.aborts,
+        Account:          cmd.account,
+        AccountID:        cmd.accountID,
+        Asset:            cmd.asset,
+        Fees:             cmd.fees,
+    }
+    if !cmd.reserve.IsZero() {
+        reserve := cmd.reserve
+        record.Funds = &reserve
+    }
+    if cmd.kind == depositCommand || cmd.kind == withdrawCommand {
+        amount := cmd.amount
+        record.Amount = &amount
+    }
+    
+    switch cmd.kind {
+    case placeCommand:
+        record.Type = journal.TypePlace
+    case cancelCommand:
+        record.Type = journal.TypeCancel
+    case amendCommand:
+        record.Type = journal.TypeAmend
+    case expireCommand:
+        record.Type = journal.TypeExpire
+    case createInstrumentCommand:
+        record.Type = journal.TypeCreateInstrument
+    case updateInstrumentCommand:
+        record.Type = journal.TypeUpdateInstrument
+    case createAccountCommand:
+        record.Type = journal.TypeCreateAccount
+    case depositCommand:
+        record.Type = journal.TypeDeposit
+    case withdrawCommand:
+        record.Type = journal.TypeWithdraw
+    case setAccountFeesCommand:
+        record.Type = journal.TypeSetAccountFees
+    case abortCommand:
+        record.Type = journal.TypeAbort
+    }
+    return record
+}
+
+func recordCommand(record *journal.Record) (*engineCommand, error) {
+    cmd := &engineCommand{
+        sequence:         record.Sequence,
+        now:              record.Time,
+        order:            record.Order,
+        orderID:          record.OrderID,
+        amend:            record.Amend,
+        symbol:           record.Symbol,
+        instrument:       record.Instrument,
+        instrumentUpdate: record.InstrumentUpdate,
+        aborts:           record.Aborts,
+        account:          record.Account,
+        accountID:        record.AccountID,
+        asset:            record.Asset,
+        fees:             record.Fees,
+    }
+    if record.Funds != nil {
+        cmd.reserve = *record.Funds
+    }
+    if record.Amount != nil {
+        cmd.amount = *record.Amount
+    }
+    
+    switch record.Type {
+    case journal.TypePlace:
+        if record.Order == nil {
+            return nil, fmt.Errorf("journal record %d: place without an order", record.Sequence)
+        }
+        cmd.kind = placeCommand
+        // Queue priority is not serialized; a new order's priority is its creation time
+        cmd.order.PriorityAt = cmd.order.CreatedAt
+        // Nor is the request hash, but the journaled order is the order as its request placed it
+        if cmd.order.ClientOrderID != "" {
+            cmd.order.RequestHash = placementHash(cmd.order)
+        }
+    case journal.TypeCancel:
+        cmd.kind = cancelCommand
+    case journal.TypeAmend:
+        cmd.kind = amendCommand
+    case journal.TypeExpire:
+        cmd.kind = expireCommand
+    case journal.TypeCreateInstrument:
+        cmd.kind = createInstrumentCommand
+    case journal.TypeUpdateInstrument:
+        cmd.kind = updateInstrumentCommand
+    case journal.TypeCreateAccount:
+        if record.Account == nil {
+            return nil, fmt.Errorf("journal record %d: account creation without an account", record.Sequence)
+        }
+        cmd.kind = createAccountCommand
+    case journal.TypeDeposit:
+        cmd.kind = depositCommand
+    case journal.TypeWithdraw:
+        cmd.kind = withdrawCommand
+    case journal.TypeSetAccountFees:
+        cmd.kind = setAccountFeesCommand
+    case journal.TypeAbort:
+        cmd.kind = abortCommand
+    default:
+        return nil, fmt.Errorf("journal record %d: unknown type %q", record.Sequence, record.Type)
+    }
+    return cmd, nil
+}
+
+// replayJournal applies the journaled commands that storage has not seen, which for each symbol
+// are those after its watermark, and for accounts those after the accounts watermark. It runs
+// during Recover, before the shards start.
+func (me *MatchingEngine) replayJournal(watermarks map[string]uint64) error {
+    if me.journal == nil {
+        return nil
+    }
+    
+    from := me.oldestShardSequence()
+    if me.accountSequence < from {
+        from = me.accountSequence
+    }
+    
+    replayed := 0
+    err := me.journal.ReadFrom(from, func(record *journal.Record) error {
+        applied, _, err := me.replay(record, nil)
+        if err != nil {
+            return err
+        }
+        if applied {
+            replayed++
+        }
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+    
+    if me.journal.LastSequence() > me.sequence {
+        me.sequence = me.journal.LastSequence()
+    }
+    
+    // A symbol was replayed if its shard has moved past the watermark storage had for it
+    symbols := 0
+    for _, sh := range me.sortedShards() {
+        if sh.sequence > watermarks[sh.symbol] {
+            symbols++
+        }
+    }
+    
+    log.Printf("Recovered to sequence %d (%d journaled commands replayed on %d symbols)", me.sequence, replayed, symbols)
+    return nil
+}
+
+// Replay applies every command in the journal at path to the engine without journaling them again
+// and passes the trades of each command to emit. The engine must not be running.
+func (me *MatchingEngine) Replay(path string, emit func(*models.Trade) error) error {
+    aborted, err := abortedCommands(func(fn func(*journal.Record) error) error {
+        return journal.Read(path, 0, fn)
+    })
+    if err != nil {
+        return err
+    }
+    me.aborted = aborted
+    
+    return journal.Read(path, me.sequence, func(record *journal.Record) error {
+        _, trades, err := me.replay(record, nil)
+        if err != nil {
+            return err
+        }
+        
+        for _, trade := range trades {
+            if err := emit(trade); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+// replay applies a journaled command inline to every shard it concerns that has not seen it yet,
+// and reports whether any shard applied it. With limits, a shard only applies commands up to its
+// symbol's limit. Aborted commands and the aborts themselves are skipped.
+func (me *MatchingEngine) replay(record *journal.Record, limits map[string]uint64) (bool, []*models.Trade, error) {
+    cmd, err := recordCommand(record)
+    if err != nil {
+        return false, nil, err
+    }
+    
+    if cmd.kind == abortCommand || me.aborted[cmd.sequence] {
+        if cmd.sequence > me.sequence {
+            me.sequence = cmd.sequence
+        }
+        return false, nil, nil
+    }
+    
+    // Catching up from a snapshot only moves books; the ledger is loaded from storage afterwards
+    if cmd.kind.changesAccount() {
+        applied := limits == nil && cmd.sequence > me.accountSequence
+        if applied {
+            if err := me.applyAccountCommand(cmd); err != nil {
+                return false, nil, fmt.Errorf("failed to replay journal record %d: %w", cmd.sequence, err)
+            }
+        }
+        if cmd.sequence > me.sequence {
+            me.sequence = cmd.sequence
+        }
+        return applied, nil, nil
+    }
+    
+    shards, err := me.recordShards(cmd)
+    if err != nil {
+        return false, nil, fmt.Errorf("failed to route journal record %d: %w", cmd.sequence, err)
+    }
+    
+    applied := false
+    var trades []*models.Trade
+    for _, sh := range shards {
+        if cmd.sequence <= sh.sequence || (limits != nil && cmd.sequence > limits[sh.symbol]) {
+            continue
+        }
+        
+        result := sh.apply(cmd)
+        if result.err != nil && !isRejection(result.err) {
+            return false, nil, fmt.Errorf("failed to replay journal record %d: %w", cmd.sequence, result.err)
+        }
+        applied = true
+        trades = append(trades, result.trades...)
+    }
+    
+    if cmd.sequence > me.sequence {
+        me.sequence = cmd.sequence
+    }
+    return applied, trades, nil
+}
+
+// recordShards returns the shards a journaled command applies to, creating any that do not exist
+// yet. Records written before the engine was sharded may lack a symbol: their cancels and amends
+// are routed by the order's symbol, and their expiry passes go to every shard.
+func (me *MatchingEngine) recordShards(cmd *engineCommand) ([]*shard, error) {
+    if cmd.symbol == "" && cmd.kind == expireCommand {
+        return me.sortedShards(), nil
+    }
+    
+    if err := me.resolveSymbol(cmd); err != nil {
+        return nil, err
+    }
+    
+    sh := me.getShard(cmd.symbol)
+    if sh == nil {
+        sh = me.addShard(cmd.symbol)
+    }
+    return []*shard{sh}, nil
+}
+
+// abortedCommands collects the sequences of the commands that aborts in a journal mark as rolled
+// back. read must pass every record of the journal to fn.
+func abortedCommands(read func(fn func(*journal.Record) error) error) (map[uint64]bool, error) {
+    aborted := make(map[uint64]bool)
+    err := read(func(record *journal.Record) error {
+        if record.Type == journal.TypeAbort {
+            aborted[record.Aborts] = true
+        }
+        return nil
+    })
+    return aborted, err
+}
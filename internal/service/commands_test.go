@@ -0,0 +1,195 @@
+package service
+
+import (
+    "encoding/json"
+    "order-matching-system/internal/journal"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "path/filepath"
+    "reflect"
+    "testing"
+    
+    "github.com/shopspring/decimal"
+)
+
+// startJournaled recovers an engine over store and the journal at path, and starts it.
+func startJournaled(t *testing.T, path string, store *repository.Store) (*OrderService, *MatchingEngine, *failingUnitOfWork) {
+    commandJournal, err := journal.Open(path)
+    if err != nil {
+        t.Fatalf("open journal: %v", err)
+    }
+    t.Cleanup(func() { commandJournal.Close() })
+    unitOfWork := &failingUnitOfWork{UnitOfWork: store.UnitOfWork}
+    store.UnitOfWork = unitOfWork
+    
+    engine := NewMatchingEngine(store, commandJournal)
+    if err := engine.Recover(); err != nil {
+        t.Fatalf("recover: %v", err)
+    }
+    engine.Start()
+    return NewOrderService(store.Orders, store.Trades, store.OrderEvents, engine), engine, unitOfWork
+}
+
+// fund opens the test account and deposits enough for every order the tests place.
+func fund(t *testing.T, engine *MatchingEngine) {
+    if err := engine.CreateAccount(&models.Account{ID: testAccount, CreatedAt: benchStart}); err != nil {
+        t.Fatalf("create account: %v", err)
+    }
+    for asset, amount := range map[string]string{"BTC": "100", "USD": "100000"} {
+        if err := engine.Deposit(testAccount, asset, decimal.RequireFromString(amount)); err != nil {
+            t.Fatalf("deposit %s: %v", asset, err)
+        }
+    }
+}
+
+// copyJournal writes the records of the journal at from to a new journal at to, leaving out
+// those skip picks.
+func copyJournal(t *testing.T, from, to string, skip func(*journal.Record) bool) {
+    copied, err := journal.Open(to)
+    if err != nil {
+        t.Fatalf("open journal copy: %v", err)
+    }
+    defer copied.Close()
+    
+    err = journal.Read(from, 0, func(record *journal.Record) error {
+        if skip != nil && skip(record) {
+            return nil
+        }
+        return copied.Append(record)
+    })
+    if err != nil {
+        t.Fatalf("copy journal: %v", err)
+    }
+}
+
+// TestRecoveryReplaysFromWatermarks sends an order that sweeps the asks and makes its commit fail,
+// so storage never sees it while the journal holds it followed by its abort. Restarting on that
+// journal must leave the sweep out. Without the abort the journal looks as it would after a crash
+// between the journal append and the commit: the restart must apply the sweep once, from the
+// watermark on, and a second restart must find nothing left to apply.
+func TestRecoveryReplaysFromWatermarks(t *testing.T) {
+    for _, crashed := range []bool{false, true} {
+        dir := t.TempDir()
+        store := newTestStore(t)
+        path := filepath.Join(dir, "journal.log")
+        orders, engine, unitOfWork := startJournaled(t, path, store)
+        fund(t, engine)
+        
+        place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+        place(t, orders, models.SELL, models.LIMIT, "101", "", "1")
+        place(t, orders, models.BUY, models.LIMIT, "99", "", "1")
+        before := bookState(engine) + ledgerState(engine)
+        
+        unitOfWork.fail = true
+        sweep := &models.PlaceOrderRequest{
+            AccountID: testAccount,
+            Symbol:    "BTCUSD",
+            Side:      models.BUY,
+            Type:      models.LIMIT,
+            Price:     decimalPtr("101"),
+            Quantity:  decimal.RequireFromString("2"),
+        }
+        if _, err := orders.PlaceOrder(sweep); err != models.ErrNotApplied {
+            t.Fatalf("sweep with failing storage: got %v, want ErrNotApplied", err)
+        }
+        unitOfWork.fail = false
+        sweepSequence := engine.sequence - 1
+        engine.journal.Close()
+        
+        restartPath := filepath.Join(dir, "restart.log")
+        copyJournal(t, path, restartPath, func(record *journal.Record) bool {
+            return crashed && record.Type == journal.TypeAbort
+        })
+        
+        for restart := 1; restart <= 2; restart++ {
+            _, engine, _ := startJournaled(t, restartPath, store)
+            trades, _ := store.Trades.GetBySymbol("BTCUSD", 100)
+            stored, _ := store.Watermark.Get()
+            watermark := stored["BTCUSD"].Sequence
+            
+            if !crashed {
+                if after := bookState(engine) + ledgerState(engine); after != before {
+                    t.Fatalf("aborted sweep was replayed:\nbefore %s\nafter  %s", before, after)
+                }
+                if len(trades) != 0 || watermark >= sweepSequence {
+                    t.Fatalf("aborted sweep reached storage: %d trades, watermark %d", len(trades), watermark)
+                }
+                break
+            }
+            
+            if len(trades) != 2 {
+                t.Fatalf("restart %d: %d trades stored, want the sweep's 2", restart, len(trades))
+            }
+            if watermark != sweepSequence {
+                t.Fatalf("restart %d: watermark %d, want %d", restart, watermark, sweepSequence)
+            }
+            if asks := engine.getShard("BTCUSD").book.Asks.Len(); asks != 0 {
+                t.Fatalf("restart %d: %d asks left after the sweep", restart, asks)
+            }
+            if want := "\ntrader BTC 100/0\ntrader USD 99901/99"; ledgerState(engine) != want {
+                t.Fatalf("restart %d: ledger:%s\nwant:%s", restart, ledgerState(engine), want)
+            }
+            engine.journal.Close()
+        }
+    }
+}
+
+// TestReplayIsDeterministic replays a journal twice into fresh engines, as `replay` does, and
+// checks that both emit the trades the live engine made, field for field.
+func TestReplayIsDeterministic(t *testing.T) {
+    store := newTestStore(t)
+    path := filepath.Join(t.TempDir(), "journal.log")
+    orders, engine, unitOfWork := startJournaled(t, path, store)
+    fund(t, engine)
+    
+    fees := &models.FeeSchedule{Tiers: []models.FeeTier{{MinVolume: decimal.Zero, MakerRate: decimal.RequireFromString("0.001"), TakerRate: decimal.RequireFromString("0.002")}}}
+    if err := engine.SetAccountFees(testAccount, fees); err != nil {
+        t.Fatalf("set account fees: %v", err)
+    }
+    
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    place(t, orders, models.SELL, models.LIMIT, "101", "", "2")
+    place(t, orders, models.BUY, models.STOP_MARKET, "", "100.5", "1")
+    place(t, orders, models.BUY, models.LIMIT, "101", "", "1.5")
+    
+    // An aborted command leaves no trades in the replay either
+    unitOfWork.fail = true
+    if _, err := orders.PlaceOrder(&models.PlaceOrderRequest{AccountID: testAccount, Symbol: "BTCUSD", Side: models.BUY, Type: models.MARKET, Quantity: decimal.RequireFromString("0.5")}); err != models.ErrNotApplied {
+        t.Fatalf("market with failing storage: got %v, want ErrNotApplied", err)
+    }
+    unitOfWork.fail = false
+    place(t, orders, models.SELL, models.LIMIT, "99", "", "1")
+    
+    stored, err := store.Trades.GetBySymbol("BTCUSD", 100)
+    if err != nil {
+        t.Fatalf("get trades: %v", err)
+    }
+    var live []string
+    for i := len(stored) - 1; i >= 0; i-- {
+        line, _ := json.Marshal(stored[i])
+        live = append(live, string(line))
+    }
+    if len(live) < 3 {
+        t.Fatalf("got %d trades, want the sweep, the stop and the last sell", len(live))
+    }
+    
+    for run := 1; run <= 2; run++ {
+        replayed := NewMatchingEngine(newTestStore(t), nil)
+        if err := replayed.Recover(); err != nil {
+            t.Fatalf("recover: %v", err)
+        }
+        
+        var output []string
+        err := replayed.Replay(path, func(trade *models.Trade) error {
+            line, err := json.Marshal(trade)
+            output = append(output, string(line))
+            return err
+        })
+        if err != nil {
+            t.Fatalf("replay %d: %v", run, err)
+        }
+        if !reflect.DeepEqual(output, live) {
+            t.Fatalf("replay %d emitted:\n%v\nlive trades:\n%v", run, output, live)
+        }
+    }
+}
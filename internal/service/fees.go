@@ -0,0 +1,187 @@
+package service
+
+import (
+    "log"
+    "order-matching-system/internal/models"
+    "sync"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+// feeWindowDays is how far back trading counts toward an account's fee tier.
+const feeWindowDays = 30
+
+// feeTracker holds what fee tiers are decided from: accounts' own fee schedules, and the volume
+// each account has traded, in each quote asset, in daily buckets over the fee window. Only
+// committed trades count.
+type feeTracker struct {
+    mutex     sync.Mutex
+    overrides map[string]*models.FeeSchedule
+    volumes   map[balanceKey]map[int64]decimal.Decimal // Volume by account and quote asset, then by UTC day
+}
+
+func newFeeTracker() *feeTracker {
+    return &feeTracker{
+        overrides: make(map[string]*models.FeeSchedule),
+        volumes:   make(map[balanceKey]map[int64]decimal.Decimal),
+    }
+}
+
+func (f *feeTracker) resetOverrides(accounts []models.Account) {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    
+    f.overrides = make(map[string]*models.FeeSchedule)
+    for _, account := range accounts {
+        if account.Fees != nil {
+            f.overrides[account.ID] = account.Fees
+        }
+    }
+}
+
+func (f *feeTracker) setOverride(accountID string, fees *models.FeeSchedule) {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    
+    if fees == nil {
+        delete(f.overrides, accountID)
+        return
+    }
+    f.overrides[accountID] = fees
+}
+
+func (f *feeTracker) override(accountID string) *models.FeeSchedule {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    
+    return f.overrides[accountID]
+}
+
+func (f *feeTracker) resetVolumes() {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    
+    f.volumes = make(map[balanceKey]map[int64]decimal.Decimal)
+}
+
+// record adds trades to the volume of both their accounts.
+func (f *feeTracker) record(trades []*models.Trade, quoteAsset string) {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    
+    for _, trade := range trades {
+        day := tradingDay(trade.ExecutedAt)
+        cost := trade.Cost()
+        for _, accountID := range []string{trade.MakerAccountID, trade.TakerAccountID} {
+            if accountID == "" {
+                continue
+            }
+            key := balanceKey{accountID: accountID, asset: quoteAsset}
+            days, exists := f.volumes[key]
+            if !exists {
+                days = make(map[int64]decimal.Decimal)
+                f.volumes[key] = days
+            }
+            days[day] = days[day].Add(cost)
+        }
+    }
+}
+
+// volume returns what an account has traded in a quote asset over the fee window ending on now's
+// day, dropping the days that have left the window.
+func (f *feeTracker) volume(accountID, quoteAsset string, now time.Time) decimal.Decimal {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    
+    oldest := tradingDay(now) - feeWindowDays + 1
+    total := decimal.Zero
+    days := f.volumes[balanceKey{accountID: accountID, asset: quoteAsset}]
+    for day, volume := range days {
+        if day < oldest {
+            delete(days, day)
+            continue
+        }
+        total = total.Add(volume)
+    }
+    return total
+}
+
+func tradingDay(t time.Time) int64 {
+    return t.UTC().Unix() / int64(24*time.Hour/time.Second)
+}
+
+// loadVolumes rebuilds the volumes from the trades storage holds for the fee window. Trades
+// replayed from the journal afterwards are added as they commit.
+func (me *MatchingEngine) loadVolumes() error {
+    since := time.Unix((tradingDay(time.Now())-feeWindowDays+1)*int64(24*time.Hour/time.Second), 0)
+    trades, err := me.tradeRepo.GetSince(since)
+    if err != nil {
+        return err
+    }
+    
+    me.fees.resetVolumes()
+    for i := range trades {
+        instrument, err := me.GetInstrument(trades[i].Symbol)
+        if err != nil {
+            continue
+        }
+        me.fees.record([]*models.Trade{&trades[i]}, instrument.QuoteAsset)
+    }
+    
+    log.Printf("Loaded %d trades into fee volumes", len(trades))
+    return nil
+}
+
+// SetAccountFees replaces an account's fee schedule, which then applies on every symbol in place
+// of the symbol's own; nil returns the account to the symbols' schedules. Orders accepted
+// afterwards get the new rates.
+func (me *MatchingEngine) SetAccountFees(accountID string, fees *models.FeeSchedule) error {
+    return me.submit(&engineCommand{kind: setAccountFeesCommand, accountID: accountID, fees: fees}).err
+}
+
+// assignFees fixes a new order's fee rates from its account's schedule, or else its symbol's, at
+// the tier its volume over the fee window has reached. The rates are journaled with the order, so
+// replay charges the same fees however the volumes have moved since.
+func (me *MatchingEngine) assignFees(cmd *engineCommand) {
+    if cmd.kind != placeCommand {
+        return
+    }
+    
+    order := cmd.order
+    instrument, err := me.GetInstrument(order.Symbol)
+    if err != nil {
+        return
+    }
+    
+    schedule := instrument.Fees
+    volume := decimal.Zero
+    if order.AccountID != "" {
+        if override := me.fees.override(order.AccountID); override != nil {
+            schedule = override
+        }
+        volume = me.fees.volume(order.AccountID, instrument.QuoteAsset, time.Now())
+    }
+    
+    tier := schedule.Tier(volume)
+    order.MakerFeeRate = tier.MakerRate
+    order.TakerFeeRate = tier.TakerRate
+}
+
+// chargeFees sets a trade's fees. Each side pays at its order's maker or taker rate, in the asset
+// it receives: the buyer on the quantity, the seller on the cost.
+func chargeFees(trade *models.Trade, taker, maker *models.Order, instrument *models.Instrument) {
+    trade.TakerSide = taker.Side
+    trade.TakerAccountID = taker.AccountID
+    trade.MakerAccountID = maker.AccountID
+    
+    trade.TakerFee, trade.TakerFeeAsset = sideFee(trade, taker.Side, taker.TakerFeeRate, instrument)
+    trade.MakerFee, trade.MakerFeeAsset = sideFee(trade, maker.Side, maker.MakerFeeRate, instrument)
+}
+
+func sideFee(trade *models.Trade, side models.OrderSide, rate decimal.Decimal, instrument *models.Instrument) (decimal.Decimal, string) {
+    if side == models.BUY {
+        return models.Fee(trade.Quantity, rate), instrument.BaseAsset
+    }
+    return models.Fee(trade.Cost(), rate), instrument.QuoteAsset
+}
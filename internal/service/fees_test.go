@@ -0,0 +1,75 @@
+package service
+
+import (
+    "order-matching-system/internal/models"
+    "testing"
+    
+    "github.com/shopspring/decimal"
+)
+
+func TestTradesChargeFees(t *testing.T) {
+    orders, engine, _, _ := newTestEngine(t)
+    
+    rate := decimal.RequireFromString
+    _, err := engine.UpdateInstrument("BTCUSD", &models.UpdateInstrumentRequest{Fees: &models.FeeSchedule{Tiers: []models.FeeTier{
+        {MinVolume: rate("0"), MakerRate: rate("-0.0001"), TakerRate: rate("0.002")},
+        {MinVolume: rate("150"), MakerRate: rate("0"), TakerRate: rate("0.001")},
+    }}})
+    if err != nil {
+        t.Fatalf("set symbol fees: %v", err)
+    }
+    if err := engine.CreateAccount(&models.Account{ID: "buyer", CreatedAt: benchStart}); err != nil {
+        t.Fatalf("create account: %v", err)
+    }
+    if err := engine.Deposit("buyer", "USD", rate("1000")); err != nil {
+        t.Fatalf("deposit: %v", err)
+    }
+    
+    buy := func() *models.Order {
+        order, err := orders.PlaceOrder(&models.PlaceOrderRequest{
+            AccountID: "buyer",
+            Symbol:    "BTCUSD",
+            Side:      models.BUY,
+            Type:      models.LIMIT,
+            Price:     decimalPtr("100"),
+            Quantity:  rate("1"),
+        })
+        if err != nil {
+            t.Fatalf("place buy: %v", err)
+        }
+        return order
+    }
+    
+    // The buyer takes and pays 0.2% of the BTC it receives; the maker is rebated 0.01% of the USD
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    buy()
+    want := "\nbuyer BTC 0.998/0\nbuyer USD 900/0\ntrader BTC 99/0\ntrader USD 100100.01/0"
+    if got := ledgerState(engine); got != want {
+        t.Fatalf("after trade:%s\nwant:%s", got, want)
+    }
+    
+    trades, err := orders.GetTrades("BTCUSD", 1)
+    if err != nil || len(trades) != 1 {
+        t.Fatalf("trades = %+v, %v", trades, err)
+    }
+    trade := trades[0]
+    if trade.TakerSide != models.BUY || trade.TakerAccountID != "buyer" || trade.MakerAccountID != testAccount ||
+        !trade.TakerFee.Equal(rate("0.002")) || trade.TakerFeeAsset != "BTC" || !trade.MakerFee.Equal(rate("-0.01")) || trade.MakerFeeAsset != "USD" {
+        t.Fatalf("trade = %+v", trade)
+    }
+    
+    // A second trade takes the buyer's 30-day volume to 200, into the next tier
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    buy()
+    if order := buy(); !order.TakerFeeRate.Equal(rate("0.001")) || !order.MakerFeeRate.IsZero() {
+        t.Fatalf("rates after volume = %v/%v", order.MakerFeeRate, order.TakerFeeRate)
+    }
+    
+    // An account's own schedule replaces the symbol's
+    if err := engine.SetAccountFees("buyer", &models.FeeSchedule{Tiers: []models.FeeTier{{MinVolume: rate("0"), TakerRate: rate("0.0005")}}}); err != nil {
+        t.Fatalf("set account fees: %v", err)
+    }
+    if order := buy(); !order.TakerFeeRate.Equal(rate("0.0005")) {
+        t.Fatalf("rates with account schedule = %v/%v", order.MakerFeeRate, order.TakerFeeRate)
+    }
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+    "log"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "sort"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+// loadInstruments fills the engine's symbol registry from the symbols table and gives every
+// symbol a shard.
+func (me *MatchingEngine) loadInstruments() error {
+    instruments, err := me.instrumentRepo.GetAll()
+    if err != nil {
+        return err
+    }
+    
+    for i := range instruments {
+        me.setInstrument(&instruments[i])
+        me.addShard(instruments[i].Symbol)
+    }
+    
+    log.Printf("Loaded %d instruments", len(instruments))
+    return nil
+}
+
+// GetInstrument returns a copy of the registered instrument for a symbol.
+func (me *MatchingEngine) GetInstrument(symbol string) (*models.Instrument, error) {
+    me.mutex.RLock()
+    defer me.mutex.RUnlock()
+    
+    instrument, exists := me.instruments[symbol]
+    if !exists {
+        return nil, models.ErrInstrumentNotFound
+    }
+    
+    result := *instrument
+    return &result, nil
+}
+
+// ListInstruments returns every registered instrument sorted by symbol.
+func (me *MatchingEngine) ListInstruments() []models.Instrument {
+    me.mutex.RLock()
+    defer me.mutex.RUnlock()
+    
+    instruments := make([]models.Instrument, 0, len(me.instruments))
+    for _, instrument := range me.instruments {
+        instruments = append(instruments, *instrument)
+    }
+    
+    sort.Slice(instruments, func(i, j int) bool {
+        return instruments[i].Symbol < instruments[j].Symbol
+    })
+    return instruments
+}
+
+func (me *MatchingEngine) setInstrument(instrument *models.Instrument) {
+    me.mutex.Lock()
+    defer me.mutex.Unlock()
+    
+    me.instruments[instrument.Symbol] = instrument
+}
+
+func (me *MatchingEngine) deleteInstrument(symbol string) {
+    me.mutex.Lock()
+    defer me.mutex.Unlock()
+    
+    delete(me.instruments, symbol)
+}
+
+// setInstrument registers the shard's instrument, remembering the previous one in case the
+// command is rolled back.
+func (sh *shard) setInstrument(instrument *models.Instrument) {
+    previous, err := sh.engine.GetInstrument(sh.symbol)
+    sh.engine.setInstrument(instrument)
+    
+    sh.onUndo(func() {
+        if err != nil {
+            sh.engine.deleteInstrument(sh.symbol)
+        } else {
+            sh.engine.setInstrument(previous)
+        }
+    })
+}
+
+func (me *MatchingEngine) CreateInstrument(instrument *models.Instrument) (*models.Instrument, error) {
+    result := me.submit(&engineCommand{kind: createInstrumentCommand, instrument: instrument})
+    return result.instrument, result.err
+}
+
+func (me *MatchingEngine) UpdateInstrument(symbol string, req *models.UpdateInstrumentRequest) (*models.Instrument, error) {
+    result := me.submit(&engineCommand{kind: updateInstrumentCommand, symbol: symbol, instrumentUpdate: req})
+    return result.instrument, result.err
+}
+
+// tickSize returns the symbol's price increment, falling back to the finest one the orders table can store.
+func (me *MatchingEngine) tickSize(symbol string) decimal.Decimal {
+    me.mutex.RLock()
+    defer me.mutex.RUnlock()
+    
+    if instrument, exists := me.instruments[symbol]; exists {
+        return instrument.TickSize
+    }
+    return minTickSize
+}
+
+// checkTradable rejects symbols that are not listed or not currently active.
+func (me *MatchingEngine) checkTradable(symbol string) error {
+    me.mutex.RLock()
+    defer me.mutex.RUnlock()
+    
+    instrument, exists := me.instruments[symbol]
+    if !exists {
+        return models.ErrUnknownSymbol
+    }
+    if instrument.Status != models.ACTIVE {
+        return models.ErrSymbolNotActive
+    }
+    return nil
+}
+
+func (sh *shard) processCreateInstrument(tx repository.Tx, instrument *models.Instrument) (*models.Instrument, error) {
+    if _, err := sh.engine.GetInstrument(instrument.Symbol); err == nil {
+        return nil, models.ErrInstrumentExists
+    }
+    
+    if err := tx.Instruments().Create(instrument); err != nil {
+        log.Printf("Error creating instrument: %v", err)
+        return nil, err
+    }
+    
+    sh.setInstrument(instrument)
+    
+    log.Printf("Instrument listed: %s (%s)", instrument.Symbol, instrument.Status)
+    
+    result := *instrument
+    return &result, nil
+}
+
+// processUpdateInstrument changes an instrument's status or precision. Delisting cancels every
+// order still resting on the symbol's book, including untriggered stops.
+func (sh *shard) processUpdateInstrument(tx repository.Tx, req *models.UpdateInstrumentRequest) (*models.Instrument, error) {
+    instrument, err := sh.engine.GetInstrument(sh.symbol)
+    if err != nil {
+        return nil, err
+    }
+    
+    if instrument.Status == models.DELISTED {
+        return nil, models.ErrInstrumentDelisted
+    }
+    
+    req.Apply(instrument)
+    instrument.UpdatedAt = sh.now
+    
+    if err := instrument.Validate(); err != nil {
+        return nil, err
+    }
+    
+    if instrument.Status == models.DELISTED {
+        if err := sh.cancelAllOrders(tx, instrument.UpdatedAt); err != nil {
+            return nil, err
+        }
+    }
+    
+    if err := tx.Instruments().Update(instrument); err != nil {
+        log.Printf("Error updating instrument: %v", err)
+        return nil, err
+    }
+    
+    sh.setInstrument(instrument)
+    
+    log.Printf("Instrument updated: %s (%s)", sh.symbol, instrument.Status)
+    
+    result := *instrument
+    return &result, nil
+}
+
+func (sh *shard) cancelAllOrders(tx repository.Tx, now time.Time) error {
+    orderBook := sh.book
+    orderBook.mutex.Lock()
+    defer orderBook.mutex.Unlock()
+    
+    var orders []*models.Order
+    for _, side := range orderBook.sides() {
+        orders = append(orders, side.Orders()...)
+    }
+    
+    for _, order := range orders {
+        sh.save(order)
+        sh.removeFromOrderBook(orderBook, order)
+        order.Status = models.CANCELED
+        order.UpdatedAt = now
+        
+        if err := sh.updateOrder(tx, order); err != nil {
+            log.Printf("Error canceling order on delisted symbol: %v", err)
+            return err
+        }
+    }
+    
+    log.Printf("Canceled %d orders on delisted symbol %s", len(orders), sh.symbol)
+    return nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+    "order-matching-system/internal/models"
+    "testing"
+    
+    "github.com/shopspring/decimal"
+)
+
+// TestSymbolRegistry lists a symbol, trades it through a halt and a delisting, and checks that a
+// restarted engine loads it from storage.
+func TestSymbolRegistry(t *testing.T) {
+    orders, engine, store, _ := newTestEngine(t)
+    instruments := NewInstrumentService(engine)
+    
+    listed, err := instruments.CreateInstrument(&models.CreateInstrumentRequest{
+        Symbol:            "ETHUSD",
+        BaseAsset:         "ETH",
+        QuoteAsset:        "USD",
+        PricePrecision:    2,
+        QuantityPrecision: 3,
+    })
+    if err != nil {
+        t.Fatalf("create instrument: %v", err)
+    }
+    // Unset rules default to the finest increments the precisions allow
+    if listed.Status != models.ACTIVE || !listed.TickSize.Equal(decimal.RequireFromString("0.01")) ||
+        !listed.LotSize.Equal(decimal.RequireFromString("0.001")) || !listed.MinQuantity.Equal(listed.LotSize) {
+        t.Fatalf("listed %+v", listed)
+    }
+    if _, err := instruments.CreateInstrument(&models.CreateInstrumentRequest{Symbol: "ETHUSD", BaseAsset: "ETH", QuoteAsset: "USD"}); err != models.ErrInstrumentExists {
+        t.Fatalf("got %v, want ErrInstrumentExists", err)
+    }
+    var symbols []string
+    for _, instrument := range instruments.ListInstruments() {
+        symbols = append(symbols, instrument.Symbol)
+    }
+    if len(symbols) != 2 || symbols[0] != "BTCUSD" || symbols[1] != "ETHUSD" {
+        t.Fatalf("symbols = %v", symbols)
+    }
+    
+    request := func(symbol string) *models.PlaceOrderRequest {
+        return &models.PlaceOrderRequest{
+            AccountID: testAccount,
+            Symbol:    symbol,
+            Side:      models.BUY,
+            Type:      models.LIMIT,
+            Price:     decimalPtr("10"),
+            Quantity:  decimal.RequireFromString("1"),
+        }
+    }
+    resting, err := orders.PlaceOrder(request("ETHUSD"))
+    if err != nil {
+        t.Fatalf("place on a listed symbol: %v", err)
+    }
+    if _, err := orders.PlaceOrder(request("XRPUSD")); err != models.ErrUnknownSymbol {
+        t.Fatalf("got %v, want ErrUnknownSymbol", err)
+    }
+    
+    setStatus := func(status models.InstrumentStatus) error {
+        _, err := instruments.UpdateInstrument("ETHUSD", &models.UpdateInstrumentRequest{Status: &status})
+        return err
+    }
+    
+    // A halt turns new orders away and leaves resting ones on the book
+    if err := setStatus(models.HALTED); err != nil {
+        t.Fatalf("halt: %v", err)
+    }
+    if _, err := orders.PlaceOrder(request("ETHUSD")); err != models.ErrSymbolNotActive {
+        t.Fatalf("got %v, want ErrSymbolNotActive", err)
+    }
+    if got := storedOrder(t, orders, resting.ID); got.Status != models.OPEN {
+        t.Fatalf("resting order is %s during a halt, want open", got.Status)
+    }
+    if err := setStatus(models.ACTIVE); err != nil {
+        t.Fatalf("resume: %v", err)
+    }
+    if _, err := orders.PlaceOrder(request("ETHUSD")); err != nil {
+        t.Fatalf("place after resuming: %v", err)
+    }
+    
+    // Delisting cancels what rests and is final
+    if err := setStatus(models.DELISTED); err != nil {
+        t.Fatalf("delist: %v", err)
+    }
+    if got := storedOrder(t, orders, resting.ID); got.Status != models.CANCELED {
+        t.Fatalf("resting order is %s after delisting, want canceled", got.Status)
+    }
+    if err := setStatus(models.ACTIVE); err != models.ErrInstrumentDelisted {
+        t.Fatalf("got %v, want ErrInstrumentDelisted", err)
+    }
+    if want := "\ntrader BTC 100/0\ntrader USD 100000/0"; ledgerState(engine) != want {
+        t.Fatalf("ledger:%s\nwant:%s", ledgerState(engine), want)
+    }
+    
+    restarted := NewMatchingEngine(store, nil)
+    if err := restarted.Recover(); err != nil {
+        t.Fatalf("recover: %v", err)
+    }
+    if instrument, err := restarted.GetInstrument("ETHUSD"); err != nil || instrument.Status != models.DELISTED {
+        t.Fatalf("restarted engine has %+v, %v", instrument, err)
+    }
+}
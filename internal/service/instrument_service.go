@@ -0,0 +1,77 @@
+package service
+
+import (
+    "order-matching-system/internal/models"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+type InstrumentService struct {
+    matchingEngine *MatchingEngine
+}
+
+func NewInstrumentService(matchingEngine *MatchingEngine) *InstrumentService {
+    return &InstrumentService{
+        matchingEngine: matchingEngine,
+    }
+}
+
+func (s *InstrumentService) CreateInstrument(req *models.CreateInstrumentRequest) (*models.Instrument, error) {
+    if err := req.Validate(); err != nil {
+        return nil, err
+    }
+    
+    instrument := &models.Instrument{
+        Symbol:            req.Symbol,
+        BaseAsset:         req.BaseAsset,
+        QuoteAsset:        req.QuoteAsset,
+        Status:            req.Status,
+        PricePrecision:    req.PricePrecision,
+        QuantityPrecision: req.QuantityPrecision,
+        CreatedAt:         time.Now(),
+        UpdatedAt:         time.Now(),
+    }
+    
+    if instrument.Status == "" {
+        instrument.Status = models.ACTIVE
+    }
+    
+    // Unset rules default to the finest increments the precisions allow
+    instrument.TickSize = decimalOrDefault(req.TickSize, decimal.New(1, -req.PricePrecision))
+    instrument.LotSize = decimalOrDefault(req.LotSize, decimal.New(1, -req.QuantityPrecision))
+    instrument.MinQuantity = decimalOrDefault(req.MinQuantity, instrument.LotSize)
+    instrument.MaxQuantity = req.MaxQuantity
+    instrument.MinNotional = decimalOrDefault(req.MinNotional, decimal.Zero)
+    instrument.Fees = req.Fees
+    
+    if err := instrument.Validate(); err != nil {
+        return nil, err
+    }
+    
+    return s.matchingEngine.CreateInstrument(instrument)
+}
+
+func (s *InstrumentService) UpdateInstrument(symbol string, req *models.UpdateInstrumentRequest) (*models.Instrument, error) {
+    if err := req.Validate(); err != nil {
+        return nil, err
+    }
+    
+    return s.matchingEngine.UpdateInstrument(symbol, req)
+}
+
+func (s *InstrumentService) GetInstrument(symbol string) (*models.Instrument, error) {
+    return s.matchingEngine.GetInstrument(symbol)
+}
+
+func (s *InstrumentService) ListInstruments() []models.Instrument {
+    return s.matchingEngine.ListInstruments()
+}
+
+
+func decimalOrDefault(value *decimal.Decimal, defaultValue decimal.Decimal) decimal.Decimal {
+    if value != nil {
+        return *value
+    }
+    return defaultValue
+}
\ No newline at end of file
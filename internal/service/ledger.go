@@ -0,0 +1,548 @@
+package service
+
+import (
+    "log"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "sort"
+    "sync"
+    
+    "github.com/shopspring/decimal"
+)
+
+// accountsWatermark names the watermark of account and funding commands, which belong to no
+// symbol. Symbols are upper-case letters and digits, so none can take it.
+const accountsWatermark = "#accounts"
+
+// ledger holds every account's balances in memory. It is what funds are checked against, and it
+// runs ahead of storage by the commands in flight: shards write their changes to storage as
+// additive deltas, in the transaction of the command that made them.
+type ledger struct {
+    mutex    sync.Mutex
+    accounts map[string]bool
+    balances map[balanceKey]*models.Balance
+}
+
+type balanceKey struct {
+    accountID string
+    asset     string
+}
+
+// fundsHold is what the router took from an account's available balance for a command before
+// journaling it: locked for an order, or gone for a withdrawal.
+type fundsHold struct {
+    accountID string
+    asset     string
+    amount    decimal.Decimal
+}
+
+func newLedger() *ledger {
+    return &ledger{
+        accounts: make(map[string]bool),
+        balances: make(map[balanceKey]*models.Balance),
+    }
+}
+
+func (l *ledger) reset(accounts []models.Account, balances []models.Balance) {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    
+    l.accounts = make(map[string]bool, len(accounts))
+    for _, account := range accounts {
+        l.accounts[account.ID] = true
+    }
+    l.balances = make(map[balanceKey]*models.Balance, len(balances))
+    for i := range balances {
+        balance := balances[i]
+        l.balances[balanceKey{accountID: balance.AccountID, asset: balance.Asset}] = &balance
+    }
+}
+
+func (l *ledger) open(accountID string) {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    
+    l.accounts[accountID] = true
+}
+
+func (l *ledger) exists(accountID string) bool {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    
+    return l.accounts[accountID]
+}
+
+// add changes a balance without checking it, for changes already decided, such as replayed ones.
+func (l *ledger) add(accountID, asset string, available, locked decimal.Decimal) {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    
+    balance := l.balance(accountID, asset)
+    balance.Available = balance.Available.Add(available)
+    balance.Locked = balance.Locked.Add(locked)
+}
+
+// debit takes amount from an account's available balance, moving it to locked if lock is set.
+func (l *ledger) debit(accountID, asset string, amount decimal.Decimal, lock bool) error {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    
+    if !l.accounts[accountID] {
+        return models.ErrAccountNotFound
+    }
+    
+    balance := l.balance(accountID, asset)
+    if balance.Available.LessThan(amount) {
+        return models.ErrInsufficientFunds
+    }
+    balance.Available = balance.Available.Sub(amount)
+    if lock {
+        balance.Locked = balance.Locked.Add(amount)
+    }
+    return nil
+}
+
+// balance returns an account's balance of an asset, creating it empty. The caller holds the mutex.
+func (l *ledger) balance(accountID, asset string) *models.Balance {
+    key := balanceKey{accountID: accountID, asset: asset}
+    balance, exists := l.balances[key]
+    if !exists {
+        balance = &models.Balance{AccountID: accountID, Asset: asset}
+        l.balances[key] = balance
+    }
+    return balance
+}
+
+// loadAccounts replaces the ledger with the accounts and balances in storage, which hold each
+// symbol's changes up to its watermark. Changes made while catching up from a snapshot are
+// dropped with it, since storage already has them.
+func (me *MatchingEngine) loadAccounts() error {
+    accounts, err := me.accountRepo.GetAll()
+    if err != nil {
+        return err
+    }
+    balances, err := me.balanceRepo.GetAll()
+    if err != nil {
+        return err
+    }
+    
+    me.ledger.reset(accounts, balances)
+    me.fees.resetOverrides(accounts)
+    
+    log.Printf("Loaded %d accounts", len(accounts))
+    return nil
+}
+
+func (me *MatchingEngine) CreateAccount(account *models.Account) error {
+    return me.submit(&engineCommand{kind: createAccountCommand, account: account}).err
+}
+
+// Deposit credits an account's available balance.
+func (me *MatchingEngine) Deposit(accountID, asset string, amount decimal.Decimal) error {
+    return me.submit(&engineCommand{kind: depositCommand, accountID: accountID, asset: asset, amount: amount}).err
+}
+
+// Withdraw debits an account's available balance.
+func (me *MatchingEngine) Withdraw(accountID, asset string, amount decimal.Decimal) error {
+    return me.submit(&engineCommand{kind: withdrawCommand, accountID: accountID, asset: asset, amount: amount}).err
+}
+
+// routeAccountCommand checks an account or funding command against the ledger, journals it and
+// applies it inline, since it belongs to no shard. A withdrawal leaves the ledger before it is
+// journaled, so no order can reserve the funds meanwhile. The caller holds routeMutex.
+func (me *MatchingEngine) routeAccountCommand(cmd *engineCommand) error {
+    switch cmd.kind {
+    case depositCommand:
+        if !me.ledger.exists(cmd.accountID) {
+            return models.ErrAccountNotFound
+        }
+        if !me.tradesAsset(cmd.asset) {
+            return models.ErrUnknownAsset
+        }
+    case withdrawCommand:
+        if err := me.ledger.debit(cmd.accountID, cmd.asset, cmd.amount, false); err != nil {
+            return err
+        }
+        cmd.hold = &fundsHold{accountID: cmd.accountID, asset: cmd.asset, amount: cmd.amount}
+    case setAccountFeesCommand:
+        if !me.ledger.exists(cmd.accountID) {
+            return models.ErrAccountNotFound
+        }
+    }
+    
+    if err := me.accept(cmd); err != nil {
+        me.returnWithdrawal(cmd)
+        return err
+    }
+    cmd.reply <- commandResult{err: me.applyAccountCommand(cmd)}
+    return nil
+}
+
+// applyAccountCommand writes an account or funding command to storage together with the accounts
+// watermark, and then applies it to the ledger, so nothing can spend a deposit that failed to
+// commit. Replayed commands were checked when they were journaled and are applied as they stand.
+func (me *MatchingEngine) applyAccountCommand(cmd *engineCommand) error {
+    var change *models.Balance
+    switch cmd.kind {
+    case createAccountCommand:
+        // Storage has the account already if the engine stopped before the watermark was written
+        if !me.ledger.exists(cmd.account.ID) {
+            if err := me.accountRepo.Create(cmd.account); err != nil {
+                return err
+            }
+        }
+    case depositCommand:
+        change = &models.Balance{AccountID: cmd.accountID, Asset: cmd.asset, Available: cmd.amount}
+    case withdrawCommand:
+        change = &models.Balance{AccountID: cmd.accountID, Asset: cmd.asset, Available: cmd.amount.Neg()}
+    case setAccountFeesCommand:
+        if err := me.accountRepo.SetFees(cmd.accountID, cmd.fees); err != nil {
+            return err
+        }
+    }
+    
+    if err := me.commitAccountCommand(cmd.sequence, change); err != nil {
+        me.returnWithdrawal(cmd)
+        return err
+    }
+    
+    switch cmd.kind {
+    case createAccountCommand:
+        me.ledger.open(cmd.account.ID)
+    case depositCommand:
+        me.ledger.add(cmd.accountID, cmd.asset, cmd.amount, decimal.Zero)
+    case withdrawCommand:
+        if cmd.hold == nil {
+            me.ledger.add(cmd.accountID, cmd.asset, cmd.amount.Neg(), decimal.Zero)
+        }
+    case setAccountFeesCommand:
+        me.fees.setOverride(cmd.accountID, cmd.fees)
+    }
+    me.accountSequence = cmd.sequence
+    return nil
+}
+
+// commitAccountCommand adds a balance change, if there is one, and moves the accounts watermark
+// to sequence in one transaction.
+func (me *MatchingEngine) commitAccountCommand(sequence uint64, change *models.Balance) error {
+    tx, err := me.unitOfWork.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    
+    if change != nil {
+        if err := tx.Balances().Add(change); err != nil {
+            return err
+        }
+    }
+    if err := tx.Watermark().Set(accountsWatermark, repository.Watermark{Sequence: sequence}); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// returnWithdrawal credits back a withdrawal the router took from the ledger, when it could not
+// be journaled or committed.
+func (me *MatchingEngine) returnWithdrawal(cmd *engineCommand) {
+    if hold := cmd.hold; hold != nil && cmd.kind == withdrawCommand {
+        cmd.hold = nil
+        me.ledger.add(hold.accountID, hold.asset, hold.amount, decimal.Zero)
+    }
+}
+
+// tradesAsset reports whether a listed instrument trades the asset.
+func (me *MatchingEngine) tradesAsset(asset string) bool {
+    for _, instrument := range me.ListInstruments() {
+        if instrument.Status != models.DELISTED && (instrument.BaseAsset == asset || instrument.QuoteAsset == asset) {
+            return true
+        }
+    }
+    return false
+}
+
+// holdFunds locks the funds a new order reserves, or the extra funds an amend needs, before the
+// command is journaled, and rejects the command if the account's available balance is short.
+func (me *MatchingEngine) holdFunds(sh *shard, cmd *engineCommand) error {
+    var order *models.Order
+    var amount decimal.Decimal
+    
+    switch cmd.kind {
+    case placeCommand:
+        order = cmd.order
+        if order.AccountID == "" {
+            return nil
+        }
+        order.Reserved = sh.reserveFor(order)
+        amount = order.Reserved
+    case amendCommand:
+        order, amount = sh.amendFunds(cmd.orderID, cmd.amend)
+        if order == nil || !amount.IsPositive() {
+            return nil
+        }
+        cmd.reserve = amount
+    default:
+        return nil
+    }
+    
+    asset, err := sh.fundsAsset(order)
+    if err != nil {
+        return err
+    }
+    if err := me.ledger.debit(order.AccountID, asset, amount, true); err != nil {
+        return err
+    }
+    
+    cmd.hold = &fundsHold{accountID: order.AccountID, asset: asset, amount: amount}
+    return nil
+}
+
+// releaseHold returns held funds to the account's available balance.
+func (me *MatchingEngine) releaseHold(hold *fundsHold) {
+    if hold != nil {
+        me.ledger.add(hold.accountID, hold.asset, hold.amount, hold.amount.Neg())
+    }
+}
+
+// reserveFor returns the funds a new order locks: its quantity for a sell, and for a buy its
+// price, or else its stop price, times its quantity. A market buy locks what filling it against
+// the asks would cost as the book stands, and never spends more than that.
+func (sh *shard) reserveFor(order *models.Order) decimal.Decimal {
+    switch {
+    case order.Side == models.SELL:
+        return order.RemainingQuantity
+    case order.Price != nil:
+        return order.Price.Mul(order.RemainingQuantity)
+    case order.StopPrice != nil:
+        return order.StopPrice.Mul(order.RemainingQuantity)
+    }
+    
+    sh.book.mutex.RLock()
+    defer sh.book.mutex.RUnlock()
+    
+    cost := decimal.Zero
+    remaining := order.RemainingQuantity
+    sh.book.Asks.Each(func(ask *models.Order) bool {
+        quantity := decimal.Min(remaining, ask.RemainingQuantity)
+        cost = cost.Add(ask.Price.Mul(quantity))
+        remaining = remaining.Sub(quantity)
+        return remaining.IsPositive()
+    })
+    return cost
+}
+
+// amendFunds returns a copy of the resting order an amend changes and the extra funds the amended
+// order needs, as the book stands when the amend is routed.
+func (sh *shard) amendFunds(orderID string, req *models.AmendOrderRequest) (*models.Order, decimal.Decimal) {
+    sh.book.mutex.RLock()
+    defer sh.book.mutex.RUnlock()
+    
+    order := sh.book.Bids.Get(orderID)
+    if order == nil {
+        order = sh.book.Asks.Get(orderID)
+    }
+    if order == nil || order.AccountID == "" {
+        return nil, decimal.Zero
+    }
+    
+    price := *order.Price
+    if req.Price != nil {
+        price = *req.Price
+    }
+    quantity := order.InitialQuantity
+    if req.Quantity != nil {
+        quantity = *req.Quantity
+    }
+    
+    found := *order
+    return &found, amendedFunds(order, price, quantity).Sub(order.Reserved)
+}
+
+// amendedFunds returns the funds an order needs once amended to price and quantity.
+func amendedFunds(order *models.Order, price, quantity decimal.Decimal) decimal.Decimal {
+    amended := *order
+    amended.Price = &price
+    amended.RemainingQuantity = quantity.Sub(order.InitialQuantity.Sub(order.RemainingQuantity))
+    
+    needed, _ := fundsNeeded(&amended)
+    return needed
+}
+
+// fundsNeeded returns the funds an order still needs locked. It reports false for a buy without a
+// price, which keeps what it locked until it finishes.
+func fundsNeeded(order *models.Order) (decimal.Decimal, bool) {
+    switch {
+    case order.IsFinished():
+        return decimal.Zero, true
+    case order.Side == models.SELL:
+        return order.RemainingQuantity, true
+    case order.Price != nil:
+        return order.Price.Mul(order.RemainingQuantity), true
+    }
+    return decimal.Zero, false
+}
+
+// fundsAsset returns the asset an order locks: the quote asset for a buy, the base asset for a sell.
+func (sh *shard) fundsAsset(order *models.Order) (string, error) {
+    instrument, err := sh.engine.GetInstrument(sh.symbol)
+    if err != nil {
+        return "", err
+    }
+    
+    if order.Side == models.BUY {
+        return instrument.QuoteAsset, nil
+    }
+    return instrument.BaseAsset, nil
+}
+
+// lockFunds moves amount from available to locked for an order. Live, the router has already
+// moved it in the ledger, so only storage is left to change; on replay the ledger changes here.
+func (sh *shard) lockFunds(order *models.Order, amount decimal.Decimal) error {
+    if order.AccountID == "" {
+        return nil
+    }
+    
+    asset, err := sh.fundsAsset(order)
+    if err != nil {
+        return err
+    }
+    
+    if sh.hold != nil {
+        sh.hold = nil
+    } else {
+        sh.engine.ledger.add(order.AccountID, asset, amount.Neg(), amount)
+        sh.onUndo(func() {
+            sh.engine.ledger.add(order.AccountID, asset, amount, amount.Neg())
+        })
+    }
+    sh.record(order.AccountID, asset, amount.Neg(), amount)
+    return nil
+}
+
+// returnHold gives back a router hold the command did not use, as when an amend is rejected.
+func (sh *shard) returnHold() {
+    hold := sh.hold
+    if hold == nil {
+        return
+    }
+    sh.hold = nil
+    
+    sh.engine.releaseHold(hold)
+    sh.onUndo(func() {
+        sh.engine.ledger.add(hold.accountID, hold.asset, hold.amount.Neg(), hold.amount)
+    })
+}
+
+// releaseExcess unlocks whatever an order has reserved beyond what it still needs.
+func (sh *shard) releaseExcess(order *models.Order) error {
+    if order.AccountID == "" {
+        return nil
+    }
+    
+    needed, known := fundsNeeded(order)
+    if !known || !order.Reserved.GreaterThan(needed) {
+        return nil
+    }
+    
+    asset, err := sh.fundsAsset(order)
+    if err != nil {
+        return err
+    }
+    
+    excess := order.Reserved.Sub(needed)
+    order.Reserved = needed
+    sh.adjust(order.AccountID, asset, excess, excess.Neg())
+    return nil
+}
+
+// settle charges a trade's fees and moves its funds: the buyer pays the cost from its locked quote
+// asset and receives the base asset, and the seller delivers its locked base asset and receives the
+// cost, each less its fee.
+func (sh *shard) settle(trade *models.Trade, taker, maker *models.Order) error {
+    instrument, err := sh.engine.GetInstrument(sh.symbol)
+    if err != nil {
+        return err
+    }
+    chargeFees(trade, taker, maker, instrument)
+    
+    buyOrder, sellOrder := taker, maker
+    buyerFee, sellerFee := trade.TakerFee, trade.MakerFee
+    if taker.Side == models.SELL {
+        buyOrder, sellOrder = maker, taker
+        buyerFee, sellerFee = trade.MakerFee, trade.TakerFee
+    }
+    
+    quantity, cost := trade.Quantity, trade.Cost()
+    if buyOrder.AccountID != "" {
+        buyOrder.Reserved = buyOrder.Reserved.Sub(cost)
+        sh.adjust(buyOrder.AccountID, instrument.QuoteAsset, decimal.Zero, cost.Neg())
+        sh.adjust(buyOrder.AccountID, instrument.BaseAsset, quantity.Sub(buyerFee), decimal.Zero)
+    }
+    if sellOrder.AccountID != "" {
+        sellOrder.Reserved = sellOrder.Reserved.Sub(quantity)
+        sh.adjust(sellOrder.AccountID, instrument.BaseAsset, decimal.Zero, quantity.Neg())
+        sh.adjust(sellOrder.AccountID, instrument.QuoteAsset, cost.Sub(sellerFee), decimal.Zero)
+    }
+    return nil
+}
+
+// affordableQuantity caps a buy without a price at the whole lots its reserved funds pay for at price.
+func (sh *shard) affordableQuantity(order *models.Order, price decimal.Decimal) decimal.Decimal {
+    instrument, err := sh.engine.GetInstrument(sh.symbol)
+    if err != nil {
+        return decimal.Zero
+    }
+    
+    lotSize := instrument.LotSize
+    quantity := order.Reserved.Div(price.Mul(lotSize)).Floor().Mul(lotSize)
+    if price.Mul(quantity).GreaterThan(order.Reserved) {
+        quantity = quantity.Sub(lotSize) // Division rounds, so the floor can be a lot too high
+    }
+    return decimal.Max(quantity, decimal.Zero)
+}
+
+// adjust changes a balance in the ledger and records the change for storage.
+func (sh *shard) adjust(accountID, asset string, available, locked decimal.Decimal) {
+    sh.engine.ledger.add(accountID, asset, available, locked)
+    sh.onUndo(func() {
+        sh.engine.ledger.add(accountID, asset, available.Neg(), locked.Neg())
+    })
+    sh.record(accountID, asset, available, locked)
+}
+
+// record adds a balance change to those the command writes to storage.
+func (sh *shard) record(accountID, asset string, available, locked decimal.Decimal) {
+    key := balanceKey{accountID: accountID, asset: asset}
+    change, exists := sh.balances[key]
+    if !exists {
+        change = &models.Balance{AccountID: accountID, Asset: asset}
+        sh.balances[key] = change
+    }
+    change.Available = change.Available.Add(available)
+    change.Locked = change.Locked.Add(locked)
+}
+
+// writeBalances adds the command's balance changes to storage in account and asset order, so that
+// commands on different shards lock the balances they share in the same order.
+func (sh *shard) writeBalances(tx repository.Tx) error {
+    changes := make([]*models.Balance, 0, len(sh.balances))
+    for _, change := range sh.balances {
+        if !change.Available.IsZero() || !change.Locked.IsZero() {
+            changes = append(changes, change)
+        }
+    }
+    
+    sort.Slice(changes, func(i, j int) bool {
+        if changes[i].AccountID != changes[j].AccountID {
+            return changes[i].AccountID < changes[j].AccountID
+        }
+        return changes[i].Asset < changes[j].Asset
+    })
+    
+    for _, change := range changes {
+        if err := tx.Balances().Add(change); err != nil {
+            return err
+        }
+    }
+    return nil
+}
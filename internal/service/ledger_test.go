@@ -0,0 +1,160 @@
+package service
+
+import (
+    "fmt"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    
+    "github.com/shopspring/decimal"
+)
+
+// TestLedgerSurvivesRestart funds an account, reserves some of it with an order, withdraws part
+// and gives the account its own fees, then restarts from the journal twice: over the same
+// storage, which has seen every command, and over empty storage, as with STORAGE=memory. Both
+// restarts must rebuild the same ledger, book and fees, and go on taking the account's orders.
+func TestLedgerSurvivesRestart(t *testing.T) {
+    dir := t.TempDir()
+    buy := func(orders *OrderService) (*models.Order, error) {
+        return orders.PlaceOrder(&models.PlaceOrderRequest{
+            AccountID: "alice",
+            Symbol:    "BTCUSD",
+            Side:      models.BUY,
+            Type:      models.LIMIT,
+            Price:     decimalPtr("100"),
+            Quantity:  decimal.RequireFromString("1"),
+        })
+    }
+    
+    store := newTestStore(t)
+    orders, engine, unitOfWork := startJournaled(t, filepath.Join(dir, "journal.log"), store)
+    if err := engine.CreateAccount(&models.Account{ID: "alice", CreatedAt: benchStart}); err != nil {
+        t.Fatalf("create account: %v", err)
+    }
+    if err := engine.Deposit("alice", "USD", decimal.RequireFromString("100000")); err != nil {
+        t.Fatalf("deposit: %v", err)
+    }
+    if _, err := buy(orders); err != nil {
+        t.Fatalf("place: %v", err)
+    }
+    if err := engine.Withdraw("alice", "USD", decimal.RequireFromString("900")); err != nil {
+        t.Fatalf("withdraw: %v", err)
+    }
+    fees := &models.FeeSchedule{Tiers: []models.FeeTier{{MinVolume: decimal.Zero, TakerRate: decimal.RequireFromString("0.001")}}}
+    if err := engine.SetAccountFees("alice", fees); err != nil {
+        t.Fatalf("set account fees: %v", err)
+    }
+    
+    // A deposit that fails to commit is journaled as aborted, and no restart applies it
+    unitOfWork.fail = true
+    if err := engine.Deposit("alice", "USD", decimal.RequireFromString("5")); err != models.ErrNotApplied {
+        t.Fatalf("deposit with failing storage: got %v, want ErrNotApplied", err)
+    }
+    unitOfWork.fail = false
+    
+    ledger, book := ledgerState(engine), bookState(engine)
+    if want := "\nalice USD 99000/100"; ledger != want {
+        t.Fatalf("ledger:%s\nwant:%s", ledger, want)
+    }
+    engine.journal.Close()
+    journaled, err := os.ReadFile(engine.journal.Path())
+    if err != nil {
+        t.Fatalf("read journal: %v", err)
+    }
+    
+    // Each restart gets a copy of the journal, since it journals an order of its own
+    for _, restart := range []struct {
+        name  string
+        store *repository.Store
+    }{
+        {"same storage", store},
+        {"empty storage", newTestStore(t)},
+    } {
+        path := filepath.Join(dir, restart.name+".log")
+        if err := os.WriteFile(path, journaled, 0o644); err != nil {
+            t.Fatalf("copy journal: %v", err)
+        }
+        orders, engine, _ := startJournaled(t, path, restart.store)
+        if got := ledgerState(engine); got != ledger {
+            t.Fatalf("%s: ledger:%s\nwant:%s", restart.name, got, ledger)
+        }
+        if got := bookState(engine); got != book {
+            t.Fatalf("%s: book:\n%s\nwant:\n%s", restart.name, got, book)
+        }
+        if got := engine.fees.override("alice"); got == nil || !got.Tiers[0].TakerRate.Equal(fees.Tiers[0].TakerRate) {
+            t.Fatalf("%s: account fees = %+v", restart.name, got)
+        }
+        if account, err := restart.store.Accounts.GetByID("alice"); err != nil || account.Fees == nil {
+            t.Fatalf("%s: stored account = %+v, %v", restart.name, account, err)
+        }
+        
+        order, err := buy(orders)
+        if err != nil || !order.TakerFeeRate.Equal(fees.Tiers[0].TakerRate) {
+            t.Fatalf("%s: place after restart = %+v, %v", restart.name, order, err)
+        }
+    }
+}
+
+// TestOrdersLockAndSettleFunds checks that orders lock the funds they may spend, that trades move
+// funds between the two accounts, and that finished orders release what they did not use.
+func TestOrdersLockAndSettleFunds(t *testing.T) {
+    orders, engine, store, _ := newTestEngine(t)
+    
+    if err := engine.CreateAccount(&models.Account{ID: "buyer", CreatedAt: benchStart}); err != nil {
+        t.Fatalf("create account: %v", err)
+    }
+    if err := engine.Deposit("buyer", "USD", decimal.RequireFromString("150")); err != nil {
+        t.Fatalf("deposit: %v", err)
+    }
+    
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    
+    buy := func(price, quantity string) (*models.Order, error) {
+        return orders.PlaceOrder(&models.PlaceOrderRequest{
+            AccountID: "buyer",
+            Symbol:    "BTCUSD",
+            Side:      models.BUY,
+            Type:      models.LIMIT,
+            Price:     decimalPtr(price),
+            Quantity:  decimal.RequireFromString(quantity),
+        })
+    }
+    if _, err := buy("100", "2"); err != models.ErrInsufficientFunds {
+        t.Fatalf("got %v, want ErrInsufficientFunds", err)
+    }
+    
+    // Trades 1 at 100 and rests 0.5 at 100, still locking 50
+    order, err := buy("100", "1.5")
+    if err != nil {
+        t.Fatalf("place buy: %v", err)
+    }
+    want := "\nbuyer BTC 1/0\nbuyer USD 0/50\ntrader BTC 99/0\ntrader USD 100100/0"
+    if got := ledgerState(engine); got != want {
+        t.Fatalf("after trade:%s\nwant:%s", got, want)
+    }
+    
+    if _, err := orders.CancelOrder(order.ID); err != nil {
+        t.Fatalf("cancel: %v", err)
+    }
+    want = "\nbuyer BTC 1/0\nbuyer USD 50/0\ntrader BTC 99/0\ntrader USD 100100/0"
+    if got := ledgerState(engine); got != want {
+        t.Fatalf("after cancel:%s\nwant:%s", got, want)
+    }
+    
+    // Storage holds the same balances as the ledger
+    for _, account := range []string{"buyer", testAccount} {
+        balances, err := store.Balances.GetByAccount(account)
+        if err != nil {
+            t.Fatalf("get balances: %v", err)
+        }
+        for _, balance := range balances {
+            line := fmt.Sprintf("\n%s %s %v/%v", account, balance.Asset, balance.Available, balance.Locked)
+            if !strings.Contains(want, line) {
+                t.Fatalf("stored balance%s not in ledger%s", line, want)
+            }
+        }
+    }
+}
@@ -1,177 +1,335 @@
 package service
 
 import (
-    "database/sql"
+    "fmt"
     "log"
+    "order-matching-system/internal/journal"
     "order-matching-system/internal/models"
     "order-matching-system/internal/repository"
+    "order-matching-system/internal/snapshot"
+    "sort"
     "sync"
     "time"
     
-    "github.com/google/uuid"
     "github.com/shopspring/decimal"
 )
 
+// minTickSize is the smallest price increment the orders table can store.
+var minTickSize = decimal.New(1, -8)
+
+// MatchingEngine routes commands to per-symbol shards and owns what they share: the journal,
+// the symbol registry, the ledger of account balances and snapshots.
 type MatchingEngine struct {
-    db              *sql.DB
-    orderRepo       *repository.OrderRepository
-    tradeRepo       *repository.TradeRepository
-    orderChannel    chan *models.Order
-    cancelChannel   chan string
-    orderBooks      map[string]*InMemoryOrderBook
+    unitOfWork      repository.UnitOfWork
+    orderRepo       repository.OrderStore
+    tradeRepo       repository.TradeStore
+    instrumentRepo  repository.InstrumentStore
+    watermark       repository.WatermarkStore
+    accountRepo     repository.AccountStore
+    balanceRepo     repository.BalanceStore
+    journal         *journal.Journal // nil when commands are not journaled, as during replay
+    snapshots       *snapshot.Store  // nil when snapshots are disabled
+    snapshotInterval time.Duration
+    instruments     map[string]*models.Instrument // Symbol registry, guarded by mutex
+    shards          map[string]*shard             // One per symbol ever seen, guarded by mutex
+    ledger          *ledger
+    fees            *feeTracker
+    clientOrders    *clientOrderClaims
+    expiryInterval  time.Duration
     mutex           sync.RWMutex
+    
+    // Guarded by routeMutex, which is held while a command is sequenced, journaled and queued
+    routeMutex      sync.Mutex
+    sequence        uint64 // Last accepted command sequence
+    accountSequence uint64 // Last account or funding command applied
+    started         bool   // Shards of active symbols run their own goroutines once set
+    
+    aborted         map[uint64]bool // Journaled commands that were rolled back, skipped by replay before Start
 }
 
-type InMemoryOrderBook struct {
-    Symbol string
-    Bids   []*models.Order // Sorted by price (desc) then time (asc)
-    Asks   []*models.Order // Sorted by price (asc) then time (asc)
-    mutex  sync.RWMutex
+// NewMatchingEngine creates an engine over the given store. Every accepted command is appended to
+// commandJournal before it is applied; pass nil to run without a journal.
+func NewMatchingEngine(store *repository.Store, commandJournal *journal.Journal) *MatchingEngine {
+    return &MatchingEngine{
+        unitOfWork:     store.UnitOfWork,
+        orderRepo:      store.Orders,
+        tradeRepo:      store.Trades,
+        instrumentRepo: store.Instruments,
+        watermark:      store.Watermark,
+        accountRepo:    store.Accounts,
+        balanceRepo:    store.Balances,
+        journal:        commandJournal,
+        instruments:    make(map[string]*models.Instrument),
+        shards:         make(map[string]*shard),
+        ledger:         newLedger(),
+        fees:           newFeeTracker(),
+        clientOrders:   newClientOrderClaims(),
+        expiryInterval: time.Second,
+    }
 }
 
-func NewMatchingEngine(db *sql.DB) *MatchingEngine {
-    return &MatchingEngine{
-        db:            db,
-        orderRepo:     repository.NewOrderRepository(db),
-        tradeRepo:     repository.NewTradeRepository(db),
-        orderChannel:  make(chan *models.Order, 1000),
-        cancelChannel: make(chan string, 1000),
-        orderBooks:    make(map[string]*InMemoryOrderBook),
+// Recover restores the symbol registry and books from the newest snapshot, or from storage if
+// there is none, loads account balances and fee volumes from storage, and then applies the
+// journaled commands storage has not seen yet. It must run before Start so that requests are never validated against
+// an empty registry.
+func (me *MatchingEngine) Recover() error {
+    stored, err := me.watermark.Get()
+    if err != nil {
+        return fmt.Errorf("failed to read watermarks: %w", err)
+    }
+    
+    // Sequences continue past every command storage has seen, so trades keep sorting after the
+    // stored ones even without a journal to recover the last sequence from
+    watermarks := make(map[string]uint64, len(stored))
+    for symbol, watermark := range stored {
+        watermarks[symbol] = watermark.Sequence
+        if watermark.Sequence > me.sequence {
+            me.sequence = watermark.Sequence
+        }
+    }
+    me.accountSequence = watermarks[accountsWatermark]
+    
+    if me.journal != nil {
+        me.aborted, err = abortedCommands(func(fn func(*journal.Record) error) error {
+            return me.journal.ReadFrom(0, fn)
+        })
+        if err != nil {
+            return fmt.Errorf("failed to read journal: %w", err)
+        }
+    }
+    
+    if !me.restoreSnapshot(watermarks) {
+        if err := me.loadInstruments(); err != nil {
+            return err
+        }
+        me.loadExistingOrders(stored)
+    }
+    
+    if err := me.loadAccounts(); err != nil {
+        return fmt.Errorf("failed to load accounts: %w", err)
+    }
+    
+    if err := me.loadVolumes(); err != nil {
+        return fmt.Errorf("failed to load fee volumes: %w", err)
     }
+    
+    return me.replayJournal(watermarks)
 }
 
+// Start runs a goroutine for the shard of every active symbol. Shards of halted symbols stay
+// stopped until trading resumes; their few commands are applied inline by the router.
 func (me *MatchingEngine) Start() {
     log.Println("Starting matching engine...")
     
-    me.loadExistingOrders()
-    
-    for {
-        select {
-        case order := <-me.orderChannel:
-            me.processOrder(order)
-        case orderID := <-me.cancelChannel:
-            me.processCancelOrder(orderID)
+    me.routeMutex.Lock()
+    me.started = true
+    for _, sh := range me.sortedShards() {
+        if me.checkTradable(sh.symbol) == nil {
+            sh.start()
         }
     }
+    me.routeMutex.Unlock()
+    
+    go me.runExpirySweeper()
+    
+    if me.snapshots != nil && me.snapshotInterval > 0 {
+        go me.runSnapshotter()
+    }
 }
 
-func (me *MatchingEngine) loadExistingOrders() {
-   
-    symbols := []string{"BTCUSD", "ETHUSD"}
-    
-    for _, symbol := range symbols {
+// loadExistingOrders fills every listed symbol's book from storage, which holds each symbol's
+// state as of its watermark, and carries on the book's version from there. Symbols last committed
+// before versions were stored count one per resting order instead.
+func (me *MatchingEngine) loadExistingOrders(watermarks map[string]repository.Watermark) {
+    for _, instrument := range me.ListInstruments() {
+        if instrument.Status == models.DELISTED {
+            continue
+        }
+        
+        symbol := instrument.Symbol
+        sh := me.getShard(symbol)
+        sh.sequence = watermarks[symbol].Sequence
+        
         orders, err := me.orderRepo.GetOpenOrdersBySymbol(symbol)
         if err != nil {
             log.Printf("Error loading orders for %s: %v", symbol, err)
             continue
         }
         
-        me.getOrCreateOrderBook(symbol)
-        for _, order := range orders {
-            me.addToOrderBook(&order)
+        orderBook := sh.book
+        orderBook.mutex.Lock()
+        for i := range orders {
+            order := &orders[i]
+            if order.Status == models.UNTRIGGERED {
+                sh.addToStopBook(orderBook, order)
+            } else {
+                sh.addToOrderBook(order)
+            }
+        }
+        if version := watermarks[symbol].BookSequence; version > 0 {
+            orderBook.Sequence = version
+        }
+        orderBook.mutex.Unlock()
+        
+        trades, err := me.tradeRepo.GetBySymbol(symbol, 1)
+        if err != nil {
+            log.Printf("Error loading last trade for %s: %v", symbol, err)
+            continue
+        }
+        if len(trades) > 0 {
+            orderBook.LastPrice = &trades[0].Price
         }
     }
 }
 
-func (me *MatchingEngine) PlaceOrder(order *models.Order) {
-     me.processOrder(order)
-    
+func (me *MatchingEngine) PlaceOrder(order *models.Order) error {
+    return me.submit(&engineCommand{kind: placeCommand, order: order}).err
 }
 
-func (me *MatchingEngine) CancelOrder(orderID string) {
-    me.cancelChannel <- orderID
+func (me *MatchingEngine) AmendOrder(orderID string, req *models.AmendOrderRequest) (*models.Order, error) {
+    result := me.submit(&engineCommand{kind: amendCommand, orderID: orderID, amend: req})
+    return result.order, result.err
 }
 
-func (me *MatchingEngine) processOrder(order *models.Order) {
-    log.Printf("Processing order: %s %s %s %v @ %v", order.ID, order.Side, order.Type, order.RemainingQuantity, order.Price)
+func (me *MatchingEngine) CancelOrder(orderID string) (*models.Order, error) {
+    result := me.submit(&engineCommand{kind: cancelCommand, orderID: orderID})
+    return result.order, result.err
+}
+
+// processPlaceOrder stores a new order and matches it. The symbol is re-checked by the shard, so
+// an order queued behind a halt is rejected rather than matched on a book that is no longer
+// trading.
+func (sh *shard) processPlaceOrder(tx repository.Tx, order *models.Order) error {
+    if err := sh.checkClientOrderID(order); err != nil {
+        return err
+    }
     
-    orderBook := me.getOrCreateOrderBook(order.Symbol)
+    if err := sh.lockFunds(order, order.Reserved); err != nil {
+        return err
+    }
     
-    if order.Type == models.MARKET {
-        me.processMarketOrder(order, orderBook)
-    } else {
-        me.processLimitOrder(order, orderBook)
+    if err := sh.engine.checkTradable(order.Symbol); err != nil {
+        order.Status = models.REJECTED
+        order.RejectReason = err.Error()
+        order.UpdatedAt = sh.now
+        
+        if releaseErr := sh.releaseExcess(order); releaseErr != nil {
+            return releaseErr
+        }
+        if createErr := tx.Orders().Create(order); createErr != nil {
+            log.Printf("Error creating rejected order: %v", createErr)
+            return createErr
+        }
+        return err
+    }
+    
+    if err := tx.Orders().Create(order); err != nil {
+        log.Printf("Error creating order: %v", err)
+        return err
     }
+    
+    return sh.processOrder(tx, order)
 }
 
-func (me *MatchingEngine) processMarketOrder(order *models.Order, orderBook *InMemoryOrderBook) {
-    orderBook.mutex.Lock()
-    defer orderBook.mutex.Unlock()
+func (sh *shard) processOrder(tx repository.Tx, order *models.Order) error {
+    log.Printf("Processing order: %s %s %s %v @ %v", order.ID, order.Side, order.Type, order.RemainingQuantity, order.Price)
     
-    tx, err := me.db.Begin()
-    if err != nil {
-        log.Printf("Error starting transaction: %v", err)
-        return
-    }
-    defer tx.Rollback()
+    orderBook := sh.book
     
-    var oppositeOrders []*models.Order
-    if order.Side == models.BUY {
-        oppositeOrders = orderBook.Asks
-    } else {
-        oppositeOrders = orderBook.Bids
+    if order.Type.IsStop() && order.TriggeredAt == nil && !sh.triggerOrRestStop(order, orderBook) {
+        return nil
     }
     
-    remainingQuantity := order.RemainingQuantity
+    err := sh.executeOrder(tx, order, orderBook)
+    if err != nil && !isRejection(err) {
+        return err
+    }
     
-    for i, restingOrder := range oppositeOrders {
-        if remainingQuantity.IsZero() {
+    // Trades may have moved the last price through resting stops, which can cascade
+    for {
+        triggered := sh.releaseTriggeredStops(orderBook)
+        if len(triggered) == 0 {
             break
         }
-        
-        matchQuantity := decimal.Min(remainingQuantity, restingOrder.RemainingQuantity)
-        tradePrice := *restingOrder.Price 
-        
-        // Create trade
-        trade := &models.Trade{
-            ID:          uuid.New().String(),
-            Symbol:      order.Symbol,
-            Price:       tradePrice,
-            Quantity:    matchQuantity,
-            ExecutedAt:  time.Now(),
-        }
-        
-        if order.Side == models.BUY {
-            trade.BuyOrderID = order.ID
-            trade.SellOrderID = restingOrder.ID
-        } else {
-            trade.BuyOrderID = restingOrder.ID
-            trade.SellOrderID = order.ID
-        }
-        
-        // Update quantities
-        remainingQuantity = remainingQuantity.Sub(matchQuantity)
-        restingOrder.RemainingQuantity = restingOrder.RemainingQuantity.Sub(matchQuantity)
-        restingOrder.UpdatedAt = time.Now()
-        
-        // Update statuses
-        if restingOrder.RemainingQuantity.IsZero() {
-            restingOrder.Status = models.FILLED
-        } else {
-            restingOrder.Status = models.PARTIAL
-        }
-        
-        // Save to database
-        if err := me.tradeRepo.CreateWithTx(tx, trade); err != nil {
-            log.Printf("Error saving trade: %v", err)
-            return
-        }
-        
-        if err := me.orderRepo.UpdateWithTx(tx, restingOrder); err != nil {
-            log.Printf("Error updating resting order: %v", err)
-            return
-        }
-        
-        // Remove filled orders from order book
-        if restingOrder.RemainingQuantity.IsZero() {
-            if order.Side == models.BUY {
-                orderBook.Asks = append(orderBook.Asks[:i], orderBook.Asks[i+1:]...)
-            } else {
-                orderBook.Bids = append(orderBook.Bids[:i], orderBook.Bids[i+1:]...)
+        for _, stopOrder := range triggered {
+            log.Printf("Stop order triggered: %s @ %v", stopOrder.ID, stopOrder.StopPrice)
+            if stopErr := sh.executeOrder(tx, stopOrder, orderBook); stopErr != nil {
+                log.Printf("Error executing triggered stop order %s: %v", stopOrder.ID, stopErr)
+                if !isRejection(stopErr) {
+                    return stopErr
+                }
             }
         }
-        
-        log.Printf("Trade executed: %v @ %v", matchQuantity, tradePrice)
+    }
+    
+    return err
+}
+
+func (sh *shard) executeOrder(tx repository.Tx, order *models.Order, orderBook *InMemoryOrderBook) error {
+    if order.Type.IsMarket() {
+        return sh.processMarketOrder(tx, order, orderBook)
+    }
+    return sh.processLimitOrder(tx, order, orderBook)
+}
+
+// triggerOrRestStop marks a new stop order as triggered when the last trade price already crosses
+// its stop price, otherwise it parks the order in the trigger book. It reports whether the order
+// should be executed now.
+func (sh *shard) triggerOrRestStop(order *models.Order, orderBook *InMemoryOrderBook) bool {
+    orderBook.mutex.Lock()
+    defer orderBook.mutex.Unlock()
+    
+    if orderBook.LastPrice != nil && order.StopTriggered(*orderBook.LastPrice) {
+        sh.markTriggered(order)
+        return true
+    }
+    
+    sh.addToStopBook(orderBook, order)
+    log.Printf("Stop order resting: %s %s @ %v", order.ID, order.Side, order.StopPrice)
+    return false
+}
+
+// releaseTriggeredStops removes every stop whose trigger has been crossed by the last trade price
+// and returns them in trigger priority order.
+func (sh *shard) releaseTriggeredStops(orderBook *InMemoryOrderBook) []*models.Order {
+    orderBook.mutex.Lock()
+    defer orderBook.mutex.Unlock()
+    
+    if orderBook.LastPrice == nil {
+        return nil
+    }
+    
+    var triggered []*models.Order
+    for _, stops := range []*bookSide{orderBook.BuyStops, orderBook.SellStops} {
+        for stop := stops.Best(); stop != nil && stop.StopTriggered(*orderBook.LastPrice); stop = stops.Best() {
+            order, restore := stops.Take(stop.ID)
+            sh.onUndo(restore)
+            triggered = append(triggered, order)
+        }
+    }
+    
+    for _, order := range triggered {
+        sh.markTriggered(order)
+    }
+    return triggered
+}
+
+func (sh *shard) markTriggered(order *models.Order) {
+    sh.save(order)
+    
+    now := sh.now
+    order.TriggeredAt = &now
+    order.Status = models.OPEN
+    order.UpdatedAt = now
+}
+
+func (sh *shard) processMarketOrder(tx repository.Tx, order *models.Order, orderBook *InMemoryOrderBook) error {
+    orderBook.mutex.Lock()
+    defer orderBook.mutex.Unlock()
+    
+    remainingQuantity, _, err := sh.matchOrder(tx, order, orderBook)
+    if err != nil {
+        return err
     }
     
     // Update market order status
@@ -181,83 +339,166 @@ func (me *MatchingEngine) processMarketOrder(order *models.Order, orderBook *InM
         order.Status = models.CANCELED 
     }
     order.RemainingQuantity = decimal.Zero
-    order.UpdatedAt = time.Now()
+    order.UpdatedAt = sh.now
     
-    if err := me.orderRepo.UpdateWithTx(tx, order); err != nil {
+    if err := sh.updateOrder(tx, order); err != nil {
         log.Printf("Error updating market order: %v", err)
-        return
-    }
-    
-    if err := tx.Commit(); err != nil {
-        log.Printf("Error committing transaction: %v", err)
-        return
+        return err
     }
     
     log.Printf("Market order processed: %s", order.ID)
+    return nil
 }
 
-func (me *MatchingEngine) processLimitOrder(order *models.Order, orderBook *InMemoryOrderBook) {
+func (sh *shard) processLimitOrder(tx repository.Tx, order *models.Order, orderBook *InMemoryOrderBook) error {
     orderBook.mutex.Lock()
     defer orderBook.mutex.Unlock()
     
-    tx, err := me.db.Begin()
+    oppositeOrders := orderBook.oppositeSide(order.Side)
+    touch := oppositeOrders.Best()
+    
+    if order.PostOnly && touch != nil && crosses(order, touch) {
+        if order.PostOnlyAction == models.POST_ONLY_REPRICE && repriceBehindTouch(order, touch, sh.engine.tickSize(order.Symbol)) {
+            log.Printf("Post-only order repriced: %s to %v", order.ID, order.Price)
+        } else {
+            order.Status = models.REJECTED
+            order.RejectReason = models.ErrPostOnlyWouldCross.Message
+            order.UpdatedAt = sh.now
+            
+            if err := sh.updateOrder(tx, order); err != nil {
+                log.Printf("Error updating rejected order: %v", err)
+                return err
+            }
+            
+            log.Printf("Post-only order rejected: %s", order.ID)
+            return models.ErrPostOnlyWouldCross
+        }
+    }
+    
+    if order.TimeInForce == models.FOK && crossingQuantity(order, oppositeOrders).LessThan(order.RemainingQuantity) {
+        // Fill-or-kill: nothing trades unless the whole order can be filled right now
+        order.Status = models.CANCELED
+        order.UpdatedAt = sh.now
+        
+        if err := sh.updateOrder(tx, order); err != nil {
+            log.Printf("Error updating killed order: %v", err)
+            return err
+        }
+        
+        log.Printf("Fill-or-kill order killed: %s", order.ID)
+        return nil
+    }
+    
+    remainingQuantity, stopped, err := sh.matchOrder(tx, order, orderBook)
     if err != nil {
-        log.Printf("Error starting transaction: %v", err)
-        return
+        return err
     }
-    defer tx.Rollback()
     
-    var oppositeOrders []*models.Order
-    if order.Side == models.BUY {
-        oppositeOrders = orderBook.Asks
+    // Update incoming order
+    order.RemainingQuantity = remainingQuantity
+    order.UpdatedAt = sh.now
+    
+    if stopped {
+        order.Status = models.CANCELED // Self-trade prevention cancelled the rest of the order
+    } else if remainingQuantity.IsZero() {
+        order.Status = models.FILLED
+    } else if order.TimeInForce.IsImmediate() {
+        order.Status = models.CANCELED // Unfilled remainder of an IOC order never rests
+    } else if remainingQuantity.LessThan(order.InitialQuantity) {
+        order.Status = models.PARTIAL
     } else {
-        oppositeOrders = orderBook.Bids
+        order.Status = models.OPEN
+    }
+    
+    // Only the first display slice of an iceberg is shown when it starts resting
+    if order.IsResting() && order.IsIceberg() {
+        order.Replenish()
+    }
+    
+    if err := sh.updateOrder(tx, order); err != nil {
+        log.Printf("Error updating limit order: %v", err)
+        return err
+    }
+    
+    // Add remaining quantity to order book
+    if order.IsResting() {
+        sh.addToOrderBook(order)
     }
     
+    log.Printf("Limit order processed: %s", order.ID)
+    return nil
+}
+
+// matchOrder trades an incoming order against the opposite side of the book in price-time priority
+// and returns its unfilled quantity. Orders without a price (market orders) match at any price.
+// Resting icebergs trade their visible slice; when it runs out the slice is refilled from the hidden
+// reserve and the order goes to the back of its price level, so the rest of the level trades first.
+// It also reports whether self-trade prevention cancelled the incoming order, ending the match.
+func (sh *shard) matchOrder(tx repository.Tx, order *models.Order, orderBook *InMemoryOrderBook) (decimal.Decimal, bool, error) {
     remainingQuantity := order.RemainingQuantity
-    matchedOrders := make([]*models.Order, 0)
     
-    for _, restingOrder := range oppositeOrders {
-        if remainingQuantity.IsZero() {
+    oppositeOrders := orderBook.oppositeSide(order.Side)
+    
+    for !remainingQuantity.IsZero() {
+        restingOrder := oppositeOrders.Best()
+        if restingOrder == nil {
             break
         }
         
         // Check if prices cross
-        var canMatch bool
-        if order.Side == models.BUY {
-            canMatch = order.Price.GreaterThanOrEqual(*restingOrder.Price)
-        } else {
-            canMatch = order.Price.LessThanOrEqual(*restingOrder.Price)
+        if order.Price != nil && !crosses(order, restingOrder) {
+            break
         }
         
-        if !canMatch {
-            break
+        if selfTrade(order, restingOrder) {
+            var stopped bool
+            var err error
+            remainingQuantity, stopped, err = sh.preventSelfTrade(tx, order, restingOrder, remainingQuantity, orderBook)
+            if err != nil || stopped {
+                return remainingQuantity, stopped, err
+            }
+            continue
         }
         
-        matchQuantity := decimal.Min(remainingQuantity, restingOrder.RemainingQuantity)
+        matchQuantity := decimal.Min(remainingQuantity, restingOrder.DisplayedQuantity())
         tradePrice := *restingOrder.Price 
         
+        // A buy without a price spends no more than it reserved
+        if order.Side == models.BUY && order.Price == nil && order.AccountID != "" {
+            matchQuantity = decimal.Min(matchQuantity, sh.affordableQuantity(order, tradePrice))
+            if matchQuantity.IsZero() {
+                break
+            }
+        }
+        
         // Create trade
         trade := &models.Trade{
-            ID:          uuid.New().String(),
+            ID:          sh.nextTradeID(),
             Symbol:      order.Symbol,
             Price:       tradePrice,
             Quantity:    matchQuantity,
-            ExecutedAt:  time.Now(),
+            ExecutedAt:  sh.now,
+            Sequence:    sh.sequence,
+            FillIndex:   len(sh.trades),
         }
         
-        if order.Side == models.BUY {
-            trade.BuyOrderID = order.ID
-            trade.SellOrderID = restingOrder.ID
-        } else {
-            trade.BuyOrderID = restingOrder.ID
-            trade.SellOrderID = order.ID
+        buyOrder, sellOrder := order, restingOrder
+        if order.Side == models.SELL {
+            buyOrder, sellOrder = restingOrder, order
         }
+        trade.BuyOrderID = buyOrder.ID
+        trade.SellOrderID = sellOrder.ID
         
         // Update quantities
+        sh.save(restingOrder)
         remainingQuantity = remainingQuantity.Sub(matchQuantity)
-        restingOrder.RemainingQuantity = restingOrder.RemainingQuantity.Sub(matchQuantity)
-        restingOrder.UpdatedAt = time.Now()
+        restingOrder.Fill(matchQuantity)
+        restingOrder.UpdatedAt = sh.now
+        orderBook.Sequence++
+        
+        if err := sh.settle(trade, order, restingOrder); err != nil {
+            return remainingQuantity, false, err
+        }
         
         // Update statuses
         if restingOrder.RemainingQuantity.IsZero() {
@@ -266,164 +507,443 @@ func (me *MatchingEngine) processLimitOrder(order *models.Order, orderBook *InMe
             restingOrder.Status = models.PARTIAL
         }
         
+        // Update the book: filled orders leave it, exhausted iceberg slices are refilled at the back of the level
+        if restingOrder.RemainingQuantity.IsZero() {
+            sh.removeFromOrderBook(orderBook, restingOrder)
+        } else if restingOrder.DisplayedQuantity().IsZero() {
+            sh.removeFromOrderBook(orderBook, restingOrder)
+            restingOrder.Replenish()
+            restingOrder.PriorityAt = restingOrder.UpdatedAt
+            sh.addToOrderBook(restingOrder)
+        }
+        
         // Save to database
-        if err := me.tradeRepo.CreateWithTx(tx, trade); err != nil {
+        if err := tx.Trades().Create(trade); err != nil {
             log.Printf("Error saving trade: %v", err)
-            return
+            return remainingQuantity, false, err
         }
+        sh.trades = append(sh.trades, trade)
         
-        if err := me.orderRepo.UpdateWithTx(tx, restingOrder); err != nil {
+        if err := sh.updateOrder(tx, restingOrder); err != nil {
             log.Printf("Error updating resting order: %v", err)
-            return
+            return remainingQuantity, false, err
         }
         
-        matchedOrders = append(matchedOrders, restingOrder)
+        orderBook.LastPrice = &tradePrice
         
         log.Printf("Trade executed: %v @ %v", matchQuantity, tradePrice)
     }
     
-    // Remove filled orders from order book
-    for _, matchedOrder := range matchedOrders {
-        if matchedOrder.RemainingQuantity.IsZero() {
-            me.removeFromOrderBook(orderBook, matchedOrder)
+    return remainingQuantity, false, nil
+}
+
+// crosses reports whether a limit order's price reaches a resting order on the opposite side.
+func crosses(order *models.Order, restingOrder *models.Order) bool {
+    if order.Side == models.BUY {
+        return order.Price.GreaterThanOrEqual(*restingOrder.Price)
+    }
+    return order.Price.LessThanOrEqual(*restingOrder.Price)
+}
+
+// crossingQuantity sums the resting quantity a limit order could trade against at its price or better.
+// Under self-trade prevention the order's own resting orders are never traded against: the count
+// stops at the first one, unless the mode cancels them and matching carries on past them.
+func crossingQuantity(order *models.Order, oppositeOrders *bookSide) decimal.Decimal {
+    total := decimal.Zero
+    oppositeOrders.Each(func(restingOrder *models.Order) bool {
+        if !crosses(order, restingOrder) {
+            return false
+        }
+        if selfTrade(order, restingOrder) {
+            return order.SelfTradePrevention == models.STP_CANCEL_OLDEST
         }
+        total = total.Add(restingOrder.RemainingQuantity)
+        return true
+    })
+    return total
+}
+
+// repriceBehindTouch moves a post-only order one tick behind the best opposite price so it rests
+// as a maker. It reports false when no positive price is left to move to.
+func repriceBehindTouch(order *models.Order, touch *models.Order, tickSize decimal.Decimal) bool {
+    var price decimal.Decimal
+    if order.Side == models.BUY {
+        price = touch.Price.Sub(tickSize)
+    } else {
+        price = touch.Price.Add(tickSize)
     }
     
-    // Update incoming order
-    order.RemainingQuantity = remainingQuantity
-    order.UpdatedAt = time.Now()
+    if price.LessThanOrEqual(decimal.Zero) {
+        return false
+    }
     
-    if remainingQuantity.IsZero() {
-        order.Status = models.FILLED
-    } else if remainingQuantity.LessThan(order.InitialQuantity) {
-        order.Status = models.PARTIAL
-    } else {
-        order.Status = models.OPEN
+    order.Price = &price
+    return true
+}
+
+// processAmendOrder changes a resting order's price and/or total quantity in a single transaction.
+// A pure quantity reduction keeps the order's queue position; a price change or quantity increase
+// sends it to the back of the queue at its (new) price level, matching first if the new price crosses.
+func (sh *shard) processAmendOrder(tx repository.Tx, orderID string, req *models.AmendOrderRequest, reserve decimal.Decimal) (*models.Order, error) {
+    log.Printf("Processing amend order: %s", orderID)
+    
+    stored, err := tx.Orders().GetByID(orderID)
+    if err != nil {
+        return nil, err
     }
     
-    if err := me.orderRepo.UpdateWithTx(tx, order); err != nil {
-        log.Printf("Error updating limit order: %v", err)
-        return
+    if err := sh.engine.checkTradable(stored.Symbol); err != nil {
+        return nil, err
     }
     
-    // Add remaining quantity to order book
-    if !remainingQuantity.IsZero() {
-        me.addToOrderBook(order)
+    orderBook := sh.book
+    orderBook.mutex.Lock()
+    defer orderBook.mutex.Unlock()
+    
+    order := orderBook.find(stored)
+    if order == nil {
+        switch stored.Status {
+        case models.FILLED:
+            return nil, models.ErrOrderAlreadyFilled
+        case models.CANCELED:
+            return nil, models.ErrOrderAlreadyCanceled
+        default:
+            return nil, models.ErrOrderNotAmendable
+        }
     }
     
-    if err := tx.Commit(); err != nil {
-        log.Printf("Error committing transaction: %v", err)
-        return
+    newPrice := *order.Price
+    if req.Price != nil {
+        newPrice = *req.Price
+    }
+    newQuantity := order.InitialQuantity
+    if req.Quantity != nil {
+        newQuantity = *req.Quantity
     }
     
-    log.Printf("Limit order processed: %s", order.ID)
+    filledQuantity := order.InitialQuantity.Sub(order.RemainingQuantity)
+    if newQuantity.LessThanOrEqual(filledQuantity) {
+        return nil, models.ErrInvalidAmendQuantity
+    }
+    
+    instrument, err := sh.engine.GetInstrument(order.Symbol)
+    if err != nil {
+        return nil, err
+    }
+    
+    if err := instrument.ValidateAmend(newPrice, newQuantity); err != nil {
+        return nil, err
+    }
+    
+    priceChanged := !newPrice.Equal(*order.Price)
+    losesPriority := priceChanged || newQuantity.GreaterThan(order.InitialQuantity)
+    
+    if order.PostOnly && priceChanged {
+        touch := orderBook.oppositeSide(order.Side).Best()
+        repriced := *order
+        repriced.Price = &newPrice
+        if touch != nil && crosses(&repriced, touch) {
+            return nil, models.ErrPostOnlyWouldCross
+        }
+    }
+    
+    // The router locked the extra funds the amend needs, as the book stood then
+    if order.AccountID != "" && amendedFunds(order, newPrice, newQuantity).GreaterThan(order.Reserved.Add(reserve)) {
+        return nil, models.ErrInsufficientFunds
+    }
+    
+    sh.save(order)
+    
+    if err := sh.lockFunds(order, reserve); err != nil {
+        return nil, err
+    }
+    order.Reserved = order.Reserved.Add(reserve)
+    
+    now := sh.now
+    order.Price = &newPrice
+    order.InitialQuantity = newQuantity
+    order.RemainingQuantity = newQuantity.Sub(filledQuantity)
+    order.UpdatedAt = now
+    
+    if order.IsIceberg() {
+        visible := decimal.Min(*order.VisibleQuantity, order.RemainingQuantity)
+        order.VisibleQuantity = &visible
+    }
+    orderBook.Sequence++
+    
+    if losesPriority {
+        sh.removeFromOrderBook(orderBook, order)
+        order.PriorityAt = now
+        
+        if order.IsIceberg() {
+            order.Replenish()
+        }
+        
+        // A new price may cross the spread, in which case the order trades as an aggressor first
+        stopped := false
+        if priceChanged {
+            remainingQuantity, canceled, err := sh.matchOrder(tx, order, orderBook)
+            if err != nil {
+                return nil, err
+            }
+            order.RemainingQuantity = remainingQuantity
+            stopped = canceled
+        }
+        
+        if stopped {
+            order.Status = models.CANCELED // Self-trade prevention cancelled the rest of the order
+        } else if order.RemainingQuantity.IsZero() {
+            order.Status = models.FILLED
+        } else if order.RemainingQuantity.LessThan(order.InitialQuantity) {
+            order.Status = models.PARTIAL
+        } else {
+            order.Status = models.OPEN
+        }
+        
+        if order.IsResting() {
+            if order.IsIceberg() {
+                order.Replenish()
+            }
+            sh.addToOrderBook(order)
+        }
+    }
+    
+    if err := sh.updateOrder(tx, order); err != nil {
+        log.Printf("Error updating amended order: %v", err)
+        return nil, err
+    }
+    
+    log.Printf("Order amended: %s %v @ %v", orderID, order.InitialQuantity, order.Price)
+    
+    amended := *order
+    return &amended, nil
 }
 
-func (me *MatchingEngine) processCancelOrder(orderID string) {
+// processCancelOrder removes an order from the book and reports the engine's verdict. The in-memory
+// order is authoritative, so the result includes any fills applied before the cancel was dequeued.
+func (sh *shard) processCancelOrder(tx repository.Tx, orderID string) (*models.Order, error) {
     log.Printf("Processing cancel order: %s", orderID)
     
-    order, err := me.orderRepo.GetByID(orderID)
+    stored, err := tx.Orders().GetByID(orderID)
     if err != nil {
         log.Printf("Error getting order for cancellation: %v", err)
-        return
-    }
-    
-    if order.Status == models.FILLED || order.Status == models.CANCELED {
-        log.Printf("Cannot cancel order %s: already %s", orderID, order.Status)
-        return
+        return nil, err
     }
     
-    orderBook := me.getOrCreateOrderBook(order.Symbol)
+    orderBook := sh.book
     orderBook.mutex.Lock()
     defer orderBook.mutex.Unlock()
     
+    order := orderBook.find(stored)
+    if order == nil {
+        order = orderBook.findStop(stored)
+    }
+    
+    if order == nil {
+        log.Printf("Cannot cancel order %s: already %s", orderID, stored.Status)
+        switch stored.Status {
+        case models.FILLED:
+            return nil, models.ErrOrderAlreadyFilled
+        case models.CANCELED:
+            return nil, models.ErrOrderAlreadyCanceled
+        case models.EXPIRED:
+            return nil, models.ErrOrderAlreadyExpired
+        case models.REJECTED:
+            return nil, models.ErrOrderAlreadyRejected
+        default:
+            return nil, models.ErrOrderNotOnBook
+        }
+    }
+    
     // Remove from order book
-    me.removeFromOrderBook(orderBook, order)
+    sh.save(order)
+    sh.removeFromOrderBook(orderBook, order)
     
     // Update order status
     order.Status = models.CANCELED
-    order.UpdatedAt = time.Now()
+    order.UpdatedAt = sh.now
     
-    if err := me.orderRepo.Update(order); err != nil {
+    if err := sh.updateOrder(tx, order); err != nil {
         log.Printf("Error updating canceled order: %v", err)
-        return
+        return nil, err
     }
     
     log.Printf("Order canceled: %s", orderID)
+    
+    canceled := *order
+    return &canceled, nil
+}
+
+// runExpirySweeper periodically runs an expiry pass on every book holding a GTD or DAY order
+// whose expiry has passed. Halted books expire too; their passes are applied inline.
+func (me *MatchingEngine) runExpirySweeper() {
+    ticker := time.NewTicker(me.expiryInterval)
+    defer ticker.Stop()
+    
+    for now := range ticker.C {
+        for _, sh := range me.sortedShards() {
+            if !sh.hasExpiredOrders(now) {
+                continue
+            }
+            if result := me.submit(&engineCommand{kind: expireCommand, symbol: sh.symbol, now: now}); result.err != nil {
+                log.Printf("Error expiring orders on %s: %v", sh.symbol, result.err)
+            }
+        }
+    }
+}
+
+func (sh *shard) expireBookOrders(tx repository.Tx, now time.Time) error {
+    orderBook := sh.book
+    orderBook.mutex.Lock()
+    defer orderBook.mutex.Unlock()
+    
+    expired := bookExpiredOrders(orderBook, now)
+    if len(expired) == 0 {
+        return nil
+    }
+    
+    for _, order := range expired {
+        sh.save(order)
+        sh.removeFromOrderBook(orderBook, order)
+        order.Status = models.EXPIRED
+        order.UpdatedAt = now
+        
+        if err := sh.updateOrder(tx, order); err != nil {
+            log.Printf("Error updating expired order: %v", err)
+            return err
+        }
+    }
+    
+    log.Printf("Expired %d orders on %s", len(expired), orderBook.Symbol)
+    return nil
+}
+
+func bookExpiredOrders(orderBook *InMemoryOrderBook, now time.Time) []*models.Order {
+    var expired []*models.Order
+    for _, side := range orderBook.sides() {
+        expired = append(expired, side.Expired(now)...)
+    }
+    return expired
+}
+
+// sortedShards returns every shard in symbol order, so passes over all shards visit them in
+// the same order on replay.
+func (me *MatchingEngine) sortedShards() []*shard {
+    me.mutex.RLock()
+    defer me.mutex.RUnlock()
+    
+    shards := make([]*shard, 0, len(me.shards))
+    for _, sh := range me.shards {
+        shards = append(shards, sh)
+    }
+    
+    sort.Slice(shards, func(i, j int) bool {
+        return shards[i].symbol < shards[j].symbol
+    })
+    return shards
+}
+
+func (me *MatchingEngine) getShard(symbol string) *shard {
+    me.mutex.RLock()
+    defer me.mutex.RUnlock()
+    
+    return me.shards[symbol]
 }
 
-func (me *MatchingEngine) getOrCreateOrderBook(symbol string) *InMemoryOrderBook {
+// addShard registers a stopped shard with an empty book for a symbol.
+func (me *MatchingEngine) addShard(symbol string) *shard {
     me.mutex.Lock()
     defer me.mutex.Unlock()
     
-    if orderBook, exists := me.orderBooks[symbol]; exists {
-        return orderBook
+    if sh, exists := me.shards[symbol]; exists {
+        return sh
     }
     
-    orderBook := &InMemoryOrderBook{
-        Symbol: symbol,
-        Bids:   make([]*models.Order, 0),
-        Asks:   make([]*models.Order, 0),
+    sh := newShard(me, symbol)
+    me.shards[symbol] = sh
+    return sh
+}
+
+// oldestShardSequence returns the lowest sequence any shard has applied, where a journal replay
+// has to start so that every shard sees all of its commands.
+func (me *MatchingEngine) oldestShardSequence() uint64 {
+    shards := me.sortedShards()
+    if len(shards) == 0 {
+        return 0
     }
     
-    me.orderBooks[symbol] = orderBook
-    return orderBook
+    oldest := shards[0].sequence
+    for _, sh := range shards[1:] {
+        if sh.sequence < oldest {
+            oldest = sh.sequence
+        }
+    }
+    return oldest
 }
 
-func (me *MatchingEngine) addToOrderBook(order *models.Order) {
-    orderBook := me.getOrCreateOrderBook(order.Symbol)
+func (sh *shard) addToOrderBook(order *models.Order) {
+    orderBook := sh.book
+    orderBook.Sequence++
     
+    side := orderBook.Asks
     if order.Side == models.BUY {
-        // Insert into bids (sorted by price desc, then time asc)
-        insertIndex := 0
-        for i, bid := range orderBook.Bids {
-            if order.Price.GreaterThan(*bid.Price) ||
-                (order.Price.Equal(*bid.Price) && order.CreatedAt.Before(bid.CreatedAt)) {
-                insertIndex = i
-                break
-            }
-            insertIndex = i + 1
-        }
-        orderBook.Bids = append(orderBook.Bids[:insertIndex], append([]*models.Order{order}, orderBook.Bids[insertIndex:]...)...)
-    } else {
-        // Insert into asks (sorted by price asc, then time asc)
-        insertIndex := 0
-        for i, ask := range orderBook.Asks {
-            if order.Price.LessThan(*ask.Price) ||
-                (order.Price.Equal(*ask.Price) && order.CreatedAt.Before(ask.CreatedAt)) {
-                insertIndex = i
-                break
-            }
-            insertIndex = i + 1
-        }
-        orderBook.Asks = append(orderBook.Asks[:insertIndex], append([]*models.Order{order}, orderBook.Asks[insertIndex:]...)...)
+        side = orderBook.Bids
     }
+    side.Insert(order)
+    sh.onUndo(func() {
+        side.Remove(order.ID)
+    })
 }
 
-func (me *MatchingEngine) removeFromOrderBook(orderBook *InMemoryOrderBook, order *models.Order) {
+func (sh *shard) addToStopBook(orderBook *InMemoryOrderBook, order *models.Order) {
+    side := orderBook.SellStops
     if order.Side == models.BUY {
-        for i, bid := range orderBook.Bids {
-            if bid.ID == order.ID {
-                orderBook.Bids = append(orderBook.Bids[:i], orderBook.Bids[i+1:]...)
-                break
-            }
-        }
-    } else {
-        for i, ask := range orderBook.Asks {
-            if ask.ID == order.ID {
-                orderBook.Asks = append(orderBook.Asks[:i], orderBook.Asks[i+1:]...)
-                break
-            }
-        }
+        side = orderBook.BuyStops
     }
+    side.Insert(order)
+    sh.onUndo(func() {
+        side.Remove(order.ID)
+    })
 }
 
-func (me *MatchingEngine) GetOrderBook(symbol string) *models.OrderBook {
-    orders, err := me.orderRepo.GetOpenOrdersBySymbol(symbol)
-    if err != nil {
-        log.Printf("Error getting order book: %v", err)
-        return &models.OrderBook{Symbol: symbol}
+func (sh *shard) removeFromOrderBook(orderBook *InMemoryOrderBook, order *models.Order) {
+    if order.Status == models.UNTRIGGERED {
+        sh.removeFromStopBook(orderBook, order)
+        return
+    }
+    
+    orderBook.Sequence++
+    
+    side := orderBook.Asks
+    if order.Side == models.BUY {
+        side = orderBook.Bids
+    }
+    if _, restore := side.Take(order.ID); restore != nil {
+        sh.onUndo(restore)
+    }
+}
+
+func (sh *shard) removeFromStopBook(orderBook *InMemoryOrderBook, order *models.Order) {
+    side := orderBook.SellStops
+    if order.Side == models.BUY {
+        side = orderBook.BuyStops
+    }
+    if _, restore := side.Take(order.ID); restore != nil {
+        sh.onUndo(restore)
+    }
+}
+
+// GetOrderBook returns a depth snapshot of the live book. It only takes the book's read lock,
+// so it never waits on the database or blocks the shard for longer than the copy.
+func (me *MatchingEngine) GetOrderBook(symbol string, depth int, increment decimal.Decimal) *models.OrderBook {
+    sh := me.getShard(symbol)
+    if sh == nil {
+        return models.NewOrderBook(symbol, 0, nil, nil, depth, increment)
     }
     
-    return models.NewOrderBook(symbol, orders)
-}
\ No newline at end of file
+    orderBook := sh.book
+    orderBook.mutex.RLock()
+    defer orderBook.mutex.RUnlock()
+    
+    return models.NewOrderBook(symbol, orderBook.Sequence, orderBook.Bids.Each, orderBook.Asks.Each, depth, increment)
+}
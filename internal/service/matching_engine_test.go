@@ -0,0 +1,567 @@
+package service
+
+import (
+    "fmt"
+    "order-matching-system/internal/models"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+// storedOrder reads an order back from storage.
+func storedOrder(t *testing.T, orders *OrderService, orderID string) *models.Order {
+    t.Helper()
+    order, err := orders.GetOrder(orderID)
+    if err != nil {
+        t.Fatalf("get order %s: %v", orderID, err)
+    }
+    return order
+}
+
+// TestStopOrdersTrigger walks the last trade price up through a resting buy stop and checks that
+// only a trade at or beyond its stop price releases it, and that a stop placed beyond the last
+// price triggers at once.
+func TestStopOrdersTrigger(t *testing.T) {
+    orders, _, _, _ := newTestEngine(t)
+    
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    place(t, orders, models.SELL, models.LIMIT, "101", "", "1")
+    place(t, orders, models.SELL, models.LIMIT, "102", "", "2")
+    
+    // Nothing has traded yet, so neither stop can trigger
+    buyStop := place(t, orders, models.BUY, models.STOP_LIMIT, "102", "100.5", "1")
+    sellStop := place(t, orders, models.SELL, models.STOP_MARKET, "", "95", "1")
+    for _, stop := range []*models.Order{buyStop, sellStop} {
+        if stop.Status != models.UNTRIGGERED || stop.TriggeredAt != nil {
+            t.Fatalf("new stop is %s, triggered at %v", stop.Status, stop.TriggeredAt)
+        }
+    }
+    
+    // A trade below the buy stop's price leaves it waiting
+    place(t, orders, models.BUY, models.LIMIT, "100", "", "1")
+    if got := storedOrder(t, orders, buyStop.ID); got.Status != models.UNTRIGGERED {
+        t.Fatalf("buy stop is %s after a trade at 100, want untriggered", got.Status)
+    }
+    
+    // A trade through it releases it as a limit order, which takes the next ask
+    place(t, orders, models.BUY, models.LIMIT, "101", "", "1")
+    got := storedOrder(t, orders, buyStop.ID)
+    if got.Status != models.FILLED || got.TriggeredAt == nil {
+        t.Fatalf("buy stop is %s, triggered at %v, want filled once triggered", got.Status, got.TriggeredAt)
+    }
+    trades, err := orders.GetTrades("BTCUSD", 1)
+    if err != nil || len(trades) != 1 || trades[0].BuyOrderID != buyStop.ID || !trades[0].Price.Equal(*decimalPtr("102")) {
+        t.Fatalf("latest trade = %+v, %v, want the stop's fill at 102", trades, err)
+    }
+    if got := storedOrder(t, orders, sellStop.ID); got.Status != models.UNTRIGGERED {
+        t.Fatalf("sell stop is %s, want untriggered", got.Status)
+    }
+    
+    // The last price has already reached a new buy stop at 102, so it executes on arrival
+    late := place(t, orders, models.BUY, models.STOP_MARKET, "", "102", "1")
+    if late.Status != models.FILLED || late.TriggeredAt == nil {
+        t.Fatalf("late stop is %s, triggered at %v, want filled on arrival", late.Status, late.TriggeredAt)
+    }
+}
+
+func TestImmediateTimeInForce(t *testing.T) {
+    orders, engine, _, _ := newTestEngine(t)
+    
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    place(t, orders, models.SELL, models.LIMIT, "101", "", "1")
+    book := bookState(engine)
+    
+    request := func(timeInForce models.TimeInForce, price, quantity string) *models.PlaceOrderRequest {
+        return &models.PlaceOrderRequest{
+            AccountID:   testAccount,
+            Symbol:      "BTCUSD",
+            Side:        models.BUY,
+            Type:        models.LIMIT,
+            Price:       decimalPtr(price),
+            Quantity:    decimal.RequireFromString(quantity),
+            TimeInForce: timeInForce,
+        }
+    }
+    
+    // Fill-or-kill cancels outright when the book cannot fill all of it, trading nothing
+    killed, err := orders.PlaceOrder(request(models.FOK, "101", "3"))
+    if err != nil || killed.Status != models.CANCELED || !killed.RemainingQuantity.Equal(killed.InitialQuantity) {
+        t.Fatalf("fill-or-kill = %+v, %v, want canceled unfilled", killed, err)
+    }
+    if got := bookState(engine); got != book {
+        t.Fatalf("book changed by a killed order:\nbefore %s\nafter  %s", book, got)
+    }
+    
+    // Immediate-or-cancel takes what crosses and cancels the rest instead of resting
+    partial, err := orders.PlaceOrder(request(models.IOC, "100", "3"))
+    if err != nil || partial.Status != models.CANCELED || !partial.RemainingQuantity.Equal(decimal.RequireFromString("2")) {
+        t.Fatalf("immediate-or-cancel = %+v, %v, want 1 filled and the rest canceled", partial, err)
+    }
+    if engine.getShard("BTCUSD").book.Bids.Len() != 0 {
+        t.Fatalf("immediate-or-cancel remainder rests on the book")
+    }
+    
+    // Fill-or-kill that the book can fill trades in full
+    filled, err := orders.PlaceOrder(request(models.FOK, "101", "1"))
+    if err != nil || filled.Status != models.FILLED {
+        t.Fatalf("fill-or-kill = %+v, %v, want filled", filled, err)
+    }
+    if want := "\ntrader BTC 100/0\ntrader USD 100000/0"; ledgerState(engine) != want {
+        t.Fatalf("ledger:%s\nwant:%s", ledgerState(engine), want)
+    }
+}
+
+// TestOrdersExpire runs expiry passes either side of a DAY order's end of day and a GTD order's
+// expiry, and checks that each order expires exactly when it is due and releases its funds.
+func TestOrdersExpire(t *testing.T) {
+    orders, engine, _, _ := newTestEngine(t)
+    
+    dayEnd := endOfDay(time.Now())
+    gtdExpiry := dayEnd.Add(time.Hour)
+    request := func(timeInForce models.TimeInForce, price string, expiresAt *time.Time) *models.Order {
+        order, err := orders.PlaceOrder(&models.PlaceOrderRequest{
+            AccountID:   testAccount,
+            Symbol:      "BTCUSD",
+            Side:        models.BUY,
+            Type:        models.LIMIT,
+            Price:       decimalPtr(price),
+            Quantity:    decimal.RequireFromString("1"),
+            TimeInForce: timeInForce,
+            ExpiresAt:   expiresAt,
+        })
+        if err != nil {
+            t.Fatalf("place %s: %v", timeInForce, err)
+        }
+        return order
+    }
+    gtd := request(models.GTD, "90", &gtdExpiry)
+    day := request(models.DAY, "91", nil)
+    gtc := request(models.GTC, "92", nil)
+    if day.ExpiresAt == nil || !day.ExpiresAt.Equal(dayEnd) {
+        t.Fatalf("DAY order expires at %v, want %v", day.ExpiresAt, dayEnd)
+    }
+    
+    sh := engine.getShard("BTCUSD")
+    for _, pass := range []struct {
+        at   time.Time
+        due  bool                 // Whether the sweeper would run the pass
+        want []models.OrderStatus // GTD, DAY and GTC orders afterwards
+    }{
+        {dayEnd.Add(-time.Nanosecond), false, []models.OrderStatus{models.OPEN, models.OPEN, models.OPEN}},
+        {dayEnd, true, []models.OrderStatus{models.OPEN, models.EXPIRED, models.OPEN}},
+        {gtdExpiry, true, []models.OrderStatus{models.EXPIRED, models.EXPIRED, models.OPEN}},
+    } {
+        if got := sh.hasExpiredOrders(pass.at); got != pass.due {
+            t.Fatalf("at %v: hasExpiredOrders = %v, want %v", pass.at, got, pass.due)
+        }
+        if result := engine.submit(&engineCommand{kind: expireCommand, symbol: "BTCUSD", now: pass.at}); result.err != nil {
+            t.Fatalf("expire at %v: %v", pass.at, result.err)
+        }
+        
+        for i, order := range []*models.Order{gtd, day, gtc} {
+            if got := storedOrder(t, orders, order.ID).Status; got != pass.want[i] {
+                t.Errorf("at %v: %s order is %s, want %s", pass.at, order.TimeInForce, got, pass.want[i])
+            }
+        }
+    }
+    
+    if want := "\ntrader BTC 100/0\ntrader USD 99908/92"; ledgerState(engine) != want {
+        t.Fatalf("ledger:%s\nwant:%s", ledgerState(engine), want)
+    }
+}
+
+func TestPostOnly(t *testing.T) {
+    orders, engine, _, _ := newTestEngine(t)
+    
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    place(t, orders, models.BUY, models.LIMIT, "99", "", "1")
+    
+    postOnly := func(side models.OrderSide, price string, action models.PostOnlyAction) (*models.Order, error) {
+        return orders.PlaceOrder(&models.PlaceOrderRequest{
+            AccountID:      testAccount,
+            Symbol:         "BTCUSD",
+            Side:           side,
+            Type:           models.LIMIT,
+            Price:          decimalPtr(price),
+            Quantity:       decimal.RequireFromString("1"),
+            PostOnly:       true,
+            PostOnlyAction: action,
+        })
+    }
+    
+    // Rejecting is the default, and the rejected order is kept with its reason
+    if _, err := postOnly(models.BUY, "100", ""); err != models.ErrPostOnlyWouldCross {
+        t.Fatalf("got %v, want ErrPostOnlyWouldCross", err)
+    }
+    page, err := orders.ListOrders(&models.OrderQuery{Statuses: []models.OrderStatus{models.REJECTED}})
+    if err != nil || len(page.Orders) != 1 || page.Orders[0].RejectReason != models.ErrPostOnlyWouldCross.Message {
+        t.Fatalf("rejected orders = %+v, %v", page, err)
+    }
+    
+    // Repricing moves a crossing order one tick behind the touch, whichever side it is on
+    for _, test := range []struct {
+        side        models.OrderSide
+        price, want string
+    }{
+        {models.BUY, "100.5", "99.99"},
+        {models.SELL, "99", "100"},  // Behind the repriced bid
+        {models.SELL, "105", "105"}, // Does not cross, so keeps its price
+    } {
+        order, err := postOnly(test.side, test.price, models.POST_ONLY_REPRICE)
+        if err != nil || order.Status != models.OPEN || !order.Price.Equal(*decimalPtr(test.want)) {
+            t.Fatalf("post-only %s at %s = %+v, %v, want open at %s", test.side, test.price, order, err, test.want)
+        }
+    }
+    
+    if trades, _ := orders.GetTrades("BTCUSD", 10); len(trades) != 0 {
+        t.Fatalf("post-only orders traded: %+v", trades)
+    }
+    // The rejected buy's funds were released; the repriced buy locks its new price
+    if want := "\ntrader BTC 97/3\ntrader USD 99801.01/198.99"; ledgerState(engine) != want {
+        t.Fatalf("ledger:%s\nwant:%s", ledgerState(engine), want)
+    }
+}
+
+// TestIcebergRefillLosesPriority checks that an iceberg shows only its slice and that a refilled
+// slice queues behind the orders already at its price.
+func TestIcebergRefillLosesPriority(t *testing.T) {
+    orders, _, _, _ := newTestEngine(t)
+    
+    iceberg, err := orders.PlaceOrder(&models.PlaceOrderRequest{
+        AccountID:       testAccount,
+        Symbol:          "BTCUSD",
+        Side:            models.SELL,
+        Type:            models.LIMIT,
+        Price:           decimalPtr("100"),
+        Quantity:        decimal.RequireFromString("3"),
+        DisplayQuantity: decimalPtr("1"),
+    })
+    if err != nil {
+        t.Fatalf("place iceberg: %v", err)
+    }
+    plain := place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    
+    book := orders.GetOrderBook("BTCUSD", 0, decimal.Zero)
+    if len(book.Asks) != 1 || !book.Asks[0].Quantity.Equal(decimal.RequireFromString("2")) || book.Asks[0].Orders != 2 {
+        t.Fatalf("asks = %+v, want the slice and the plain order at 100", book.Asks)
+    }
+    
+    // The first buy empties the slice; the refill goes behind the plain order
+    for i, want := range []string{iceberg.ID, plain.ID, iceberg.ID} {
+        place(t, orders, models.BUY, models.LIMIT, "100", "", "1")
+        trades, err := orders.GetTrades("BTCUSD", 1)
+        if err != nil || len(trades) != 1 || trades[0].SellOrderID != want {
+            t.Fatalf("buy %d traded with %+v, %v, want %s", i, trades, err, want)
+        }
+    }
+    
+    got := storedOrder(t, orders, iceberg.ID)
+    if got.Status != models.PARTIAL || !got.RemainingQuantity.Equal(decimal.RequireFromString("1")) || !got.VisibleQuantity.Equal(decimal.RequireFromString("1")) {
+        t.Fatalf("iceberg = %+v, want its last slice showing", got)
+    }
+}
+
+func TestAmendPriority(t *testing.T) {
+    orders, engine, _, _ := newTestEngine(t)
+    
+    a := place(t, orders, models.BUY, models.LIMIT, "99", "", "2")
+    b := place(t, orders, models.BUY, models.LIMIT, "99", "", "1")
+    c := place(t, orders, models.BUY, models.LIMIT, "99", "", "1")
+    names := map[string]string{a.ID: "a", b.ID: "b", c.ID: "c"}
+    // The bids as "price:queue" per level
+    queue := func() string {
+        var levels []string
+        var last string
+        for _, order := range engine.getShard("BTCUSD").book.Bids.Orders() {
+            if price := order.Price.String(); price != last {
+                levels = append(levels, price+":")
+                last = price
+            }
+            levels[len(levels)-1] += names[order.ID]
+        }
+        return strings.Join(levels, " ")
+    }
+    amend := func(order *models.Order, price, quantity string) (*models.Order, error) {
+        req := &models.AmendOrderRequest{}
+        if price != "" {
+            req.Price = decimalPtr(price)
+        }
+        if quantity != "" {
+            req.Quantity = decimalPtr(quantity)
+        }
+        return orders.AmendOrder(order.ID, req)
+    }
+    
+    for _, step := range []struct {
+        order           *models.Order
+        price, quantity string
+        want            string
+    }{
+        {a, "", "1", "99:abc"},      // Reducing keeps the order's place
+        {b, "", "2", "99:acb"},      // Increasing sends it to the back
+        {c, "99", "", "99:acb"},     // Its own price is no change
+        {a, "98", "", "99:cb 98:a"}, // A new price moves it to that level...
+        {a, "99", "", "99:cba"},     // ...and coming back joins at the back
+    } {
+        if _, err := amend(step.order, step.price, step.quantity); err != nil {
+            t.Fatalf("amend %s to %q %q: %v", names[step.order.ID], step.price, step.quantity, err)
+        }
+        if got := queue(); got != step.want {
+            t.Fatalf("after amending %s to %q %q the queue is %s, want %s", names[step.order.ID], step.price, step.quantity, got, step.want)
+        }
+    }
+    
+    // A price that crosses the spread trades at once
+    place(t, orders, models.SELL, models.LIMIT, "101", "", "1")
+    crossed, err := amend(c, "101", "")
+    if err != nil || crossed.Status != models.FILLED {
+        t.Fatalf("crossing amend = %+v, %v, want filled", crossed, err)
+    }
+    if _, err := amend(c, "", "2"); err != models.ErrOrderAlreadyFilled {
+        t.Fatalf("got %v, want ErrOrderAlreadyFilled", err)
+    }
+    
+    // The quantity cannot drop to what has already filled
+    place(t, orders, models.SELL, models.LIMIT, "99", "", "1")
+    if got := storedOrder(t, orders, b.ID); got.Status != models.PARTIAL {
+        t.Fatalf("b is %s, want partially filled", got.Status)
+    }
+    if _, err := amend(b, "", "1"); err != models.ErrInvalidAmendQuantity {
+        t.Fatalf("got %v, want ErrInvalidAmendQuantity", err)
+    }
+}
+
+// TestConcurrentOrders places crossing orders from many goroutines and checks that, applied one
+// at a time by the shard, they leave a book that is not crossed, fills that agree with the trades
+// and no funds created or lost.
+func TestConcurrentOrders(t *testing.T) {
+    orders, engine, store, _ := newTestEngine(t)
+    
+    var wg sync.WaitGroup
+    errs := make(chan error, 200)
+    for worker := 0; worker < 8; worker++ {
+        wg.Add(1)
+        go func(worker int) {
+            defer wg.Done()
+            for i := 0; i < 25; i++ {
+                side := models.BUY
+                if (worker+i)%2 == 0 {
+                    side = models.SELL
+                }
+                _, err := orders.PlaceOrder(&models.PlaceOrderRequest{
+                    AccountID: testAccount,
+                    Symbol:    "BTCUSD",
+                    Side:      side,
+                    Type:      models.LIMIT,
+                    Price:     decimalPtr(fmt.Sprint(99 + (worker*i)%3)),
+                    Quantity:  decimal.RequireFromString("1"),
+                })
+                if err != nil {
+                    errs <- err
+                }
+            }
+        }(worker)
+    }
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        t.Fatalf("place: %v", err)
+    }
+    
+    book := engine.getShard("BTCUSD").book
+    if bid, ask := book.Bids.Best(), book.Asks.Best(); bid != nil && ask != nil && !bid.Price.LessThan(*ask.Price) {
+        t.Fatalf("book is crossed: bid %v, ask %v", bid.Price, ask.Price)
+    }
+    
+    page, err := orders.ListOrders(&models.OrderQuery{Limit: models.MaxOrderPageSize})
+    if err != nil || len(page.Orders) != 200 {
+        t.Fatalf("listed %d orders, %v", len(page.Orders), err)
+    }
+    filled := map[models.OrderSide]decimal.Decimal{}
+    for _, order := range page.Orders {
+        filled[order.Side] = filled[order.Side].Add(order.InitialQuantity.Sub(order.RemainingQuantity))
+    }
+    trades, err := store.Trades.GetSince(time.Time{})
+    if err != nil {
+        t.Fatalf("trades: %v", err)
+    }
+    traded := decimal.Zero
+    for _, trade := range trades {
+        traded = traded.Add(trade.Quantity)
+    }
+    if !filled[models.BUY].Equal(traded) || !filled[models.SELL].Equal(traded) {
+        t.Fatalf("filled %v bought and %v sold, traded %v", filled[models.BUY], filled[models.SELL], traded)
+    }
+    
+    // The account trades with itself, so its totals cannot change
+    engine.ledger.mutex.Lock()
+    defer engine.ledger.mutex.Unlock()
+    for _, balance := range engine.ledger.balances {
+        total := balance.Available.Add(balance.Locked)
+        if want := map[string]string{"BTC": "100", "USD": "100000"}[balance.Asset]; !total.Equal(decimal.RequireFromString(want)) {
+            t.Errorf("%s total is %v, want %s", balance.Asset, total, want)
+        }
+    }
+}
+
+func TestCancelResults(t *testing.T) {
+    orders, engine, _, _ := newTestEngine(t)
+    
+    open := place(t, orders, models.BUY, models.LIMIT, "90", "", "2")
+    partial := place(t, orders, models.BUY, models.LIMIT, "99", "", "2")
+    place(t, orders, models.SELL, models.LIMIT, "99", "", "1")
+    stop := place(t, orders, models.SELL, models.STOP_MARKET, "", "50", "1")
+    
+    for _, test := range []struct {
+        name             string
+        order            *models.Order
+        filled, canceled string
+    }{
+        {"open", open, "0", "2"},
+        {"partially filled", partial, "1", "1"},
+        {"untriggered stop", stop, "0", "1"},
+    } {
+        result, err := orders.CancelOrder(test.order.ID)
+        if err != nil || result.Order.Status != models.CANCELED ||
+            !result.FilledQuantity.Equal(decimal.RequireFromString(test.filled)) || !result.CanceledQuantity.Equal(decimal.RequireFromString(test.canceled)) {
+            t.Fatalf("cancel %s = %+v, %v, want %s filled and %s canceled", test.name, result, err, test.filled, test.canceled)
+        }
+    }
+    
+    filled := place(t, orders, models.SELL, models.LIMIT, "1", "", "1")
+    place(t, orders, models.BUY, models.LIMIT, "1", "", "1")
+    
+    expiresAt := time.Now().Add(time.Hour)
+    expired, err := orders.PlaceOrder(&models.PlaceOrderRequest{
+        AccountID:   testAccount,
+        Symbol:      "BTCUSD",
+        Side:        models.BUY,
+        Type:        models.LIMIT,
+        Price:       decimalPtr("80"),
+        Quantity:    decimal.RequireFromString("1"),
+        TimeInForce: models.GTD,
+        ExpiresAt:   &expiresAt,
+    })
+    if err != nil {
+        t.Fatalf("place GTD: %v", err)
+    }
+    if result := engine.submit(&engineCommand{kind: expireCommand, symbol: "BTCUSD", now: expiresAt}); result.err != nil {
+        t.Fatalf("expire: %v", result.err)
+    }
+    
+    postOnly := &models.PlaceOrderRequest{
+        AccountID: testAccount,
+        Symbol:    "BTCUSD",
+        Side:      models.SELL,
+        Type:      models.LIMIT,
+        Price:     decimalPtr("1"),
+        Quantity:  decimal.RequireFromString("1"),
+        PostOnly:  true,
+    }
+    place(t, orders, models.BUY, models.LIMIT, "1", "", "1")
+    if _, err := orders.PlaceOrder(postOnly); err != models.ErrPostOnlyWouldCross {
+        t.Fatalf("got %v, want ErrPostOnlyWouldCross", err)
+    }
+    page, err := orders.ListOrders(&models.OrderQuery{Statuses: []models.OrderStatus{models.REJECTED}})
+    if err != nil || len(page.Orders) != 1 {
+        t.Fatalf("rejected orders = %+v, %v", page, err)
+    }
+    
+    // Orders that are no longer on the book report why
+    for _, test := range []struct {
+        name    string
+        orderID string
+        want    error
+    }{
+        {"filled", filled.ID, models.ErrOrderAlreadyFilled},
+        {"canceled", open.ID, models.ErrOrderAlreadyCanceled},
+        {"expired", expired.ID, models.ErrOrderAlreadyExpired},
+        {"rejected", page.Orders[0].ID, models.ErrOrderAlreadyRejected},
+        {"unknown", "no-such-order", models.ErrOrderNotFound},
+    } {
+        if _, err := orders.CancelOrder(test.orderID); err != test.want {
+            t.Errorf("cancel %s order: got %v, want %v", test.name, err, test.want)
+        }
+    }
+}
+
+func TestOrderBookDepth(t *testing.T) {
+    orders, _, store, _ := newTestEngine(t)
+    
+    place(t, orders, models.BUY, models.LIMIT, "99.99", "", "1")
+    place(t, orders, models.BUY, models.LIMIT, "99.5", "", "2")
+    place(t, orders, models.BUY, models.LIMIT, "99.01", "", "1")
+    _, err := orders.PlaceOrder(&models.PlaceOrderRequest{
+        AccountID:       testAccount,
+        Symbol:          "BTCUSD",
+        Side:            models.BUY,
+        Type:            models.LIMIT,
+        Price:           decimalPtr("98"),
+        Quantity:        decimal.RequireFromString("3"),
+        DisplayQuantity: decimalPtr("1"),
+    })
+    if err != nil {
+        t.Fatalf("place iceberg: %v", err)
+    }
+    place(t, orders, models.SELL, models.LIMIT, "100.01", "", "1")
+    place(t, orders, models.SELL, models.LIMIT, "100.5", "", "1")
+    place(t, orders, models.SELL, models.LIMIT, "101", "", "2")
+    
+    levels := func(levels []models.PriceLevel) string {
+        var described []string
+        for _, level := range levels {
+            described = append(described, fmt.Sprintf("%v:%v/%d", level.Price, level.Quantity, level.Orders))
+        }
+        return strings.Join(described, " ")
+    }
+    
+    for _, test := range []struct {
+        depth      int
+        increment  string
+        bids, asks string // price:quantity/orders per level
+    }{
+        {0, "0", "99.99:1/1 99.5:2/1 99.01:1/1 98:1/1", "100.01:1/1 100.5:1/1 101:2/1"},
+        {2, "0", "99.99:1/1 99.5:2/1", "100.01:1/1 100.5:1/1"},
+        // Bids round down and asks round up, so buckets never cross
+        {0, "1", "99:4/3 98:1/1", "101:4/3"},
+        {1, "0.5", "99.5:3/2", "100.5:2/2"},
+    } {
+        book := orders.GetOrderBook("BTCUSD", test.depth, decimal.RequireFromString(test.increment))
+        if got := levels(book.Bids); got != test.bids {
+            t.Errorf("depth %d by %s: bids %s, want %s", test.depth, test.increment, got, test.bids)
+        }
+        if got := levels(book.Asks); got != test.asks {
+            t.Errorf("depth %d by %s: asks %s, want %s", test.depth, test.increment, got, test.asks)
+        }
+    }
+    
+    // Every change to the visible book moves its sequence
+    before := orders.GetOrderBook("BTCUSD", 0, decimal.Zero).Sequence
+    place(t, orders, models.SELL, models.LIMIT, "99.99", "", "1")
+    if after := orders.GetOrderBook("BTCUSD", 0, decimal.Zero).Sequence; after <= before {
+        t.Fatalf("sequence %d after a trade, was %d", after, before)
+    }
+    
+    // A restart from storage carries the sequence on rather than counting the resting orders again
+    before = orders.GetOrderBook("BTCUSD", 0, decimal.Zero).Sequence
+    restarted := NewMatchingEngine(store, nil)
+    if err := restarted.Recover(); err != nil {
+        t.Fatalf("recover: %v", err)
+    }
+    restarted.Start()
+    orders = NewOrderService(store.Orders, store.Trades, store.OrderEvents, restarted)
+    if after := orders.GetOrderBook("BTCUSD", 0, decimal.Zero).Sequence; after != before {
+        t.Fatalf("sequence %d after a restart, was %d", after, before)
+    }
+    place(t, orders, models.BUY, models.LIMIT, "90", "", "1")
+    if after := orders.GetOrderBook("BTCUSD", 0, decimal.Zero).Sequence; after <= before {
+        t.Fatalf("sequence %d after an order following a restart, was %d", after, before)
+    }
+    
+    if book := orders.GetOrderBook("ETHUSD", 0, decimal.Zero); len(book.Bids) != 0 || len(book.Asks) != 0 {
+        t.Fatalf("unknown symbol has levels %+v", book)
+    }
+}
@@ -0,0 +1,390 @@
+package service
+
+import (
+    "container/heap"
+    "container/list"
+    "math/rand"
+    "order-matching-system/internal/models"
+    "sort"
+    "sync"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+type InMemoryOrderBook struct {
+    Symbol    string
+    Bids      *bookSide // Price (desc) then priority time (asc)
+    Asks      *bookSide // Price (asc) then priority time (asc)
+    BuyStops  *bookSide // Untriggered buy stops, stop price (asc) then time (asc)
+    SellStops *bookSide // Untriggered sell stops, stop price (desc) then time (asc)
+    LastPrice *decimal.Decimal // Price of the most recent trade, drives stop triggers
+    Sequence  uint64           // Bumped on every change to the visible book
+    mutex     sync.RWMutex
+}
+
+func newInMemoryOrderBook(symbol string) *InMemoryOrderBook {
+    return &InMemoryOrderBook{
+        Symbol:    symbol,
+        Bids:      newBookSide(true, limitPrice),
+        Asks:      newBookSide(false, limitPrice),
+        BuyStops:  newBookSide(false, stopPrice),
+        SellStops: newBookSide(true, stopPrice),
+    }
+}
+
+// oppositeSide returns the resting orders an incoming order on the given side trades against.
+func (ob *InMemoryOrderBook) oppositeSide(side models.OrderSide) *bookSide {
+    if side == models.BUY {
+        return ob.Asks
+    }
+    return ob.Bids
+}
+
+// find returns the resting order with the same ID as the given order, or nil if it is not on the book.
+func (ob *InMemoryOrderBook) find(order *models.Order) *models.Order {
+    if order.Side == models.SELL {
+        return ob.Asks.Get(order.ID)
+    }
+    return ob.Bids.Get(order.ID)
+}
+
+// findStop returns the untriggered stop with the same ID as the given order, or nil if there is none.
+func (ob *InMemoryOrderBook) findStop(order *models.Order) *models.Order {
+    if order.Side == models.SELL {
+        return ob.SellStops.Get(order.ID)
+    }
+    return ob.BuyStops.Get(order.ID)
+}
+
+// sides returns every side of the book: resting bids and asks, then untriggered stops.
+func (ob *InMemoryOrderBook) sides() []*bookSide {
+    return []*bookSide{ob.Bids, ob.Asks, ob.BuyStops, ob.SellStops}
+}
+
+func limitPrice(order *models.Order) decimal.Decimal {
+    return *order.Price
+}
+
+func stopPrice(order *models.Order) decimal.Decimal {
+    return *order.StopPrice
+}
+
+// bookSide is one side of a book: price levels kept in a skip list ordered best price first, each
+// holding its orders in a FIFO queue, plus an index from order ID to queue position. Inserting
+// looks its level up in the skip list, O(log levels), as does removing the last order of a level,
+// which deletes the level. Removing any other order by ID and reading the best order are O(1).
+// Orders with an expiry time are also kept in a min-heap by that time, which inserting and
+// removing them update in O(log orders).
+type bookSide struct {
+    levels   *levelList
+    index    map[string]*bookEntry
+    key      func(*models.Order) decimal.Decimal
+    expiries *expiryHeap
+}
+
+// priceLevel is the queue of orders resting at one price, oldest priority first.
+type priceLevel struct {
+    price  decimal.Decimal
+    orders *list.List // of *models.Order
+}
+
+// bookEntry remembers the level an order was queued at, since callers may change the order's
+// price before removing it.
+type bookEntry struct {
+    level   *priceLevel
+    element *list.Element
+}
+
+func newBookSide(descending bool, key func(*models.Order) decimal.Decimal) *bookSide {
+    return &bookSide{
+        levels:   newLevelList(descending),
+        index:    make(map[string]*bookEntry),
+        key:      key,
+        expiries: &expiryHeap{position: make(map[string]int)},
+    }
+}
+
+func (s *bookSide) Len() int {
+    return len(s.index)
+}
+
+// Get returns the order with the given ID, or nil if it is not on this side.
+func (s *bookSide) Get(id string) *models.Order {
+    if entry, exists := s.index[id]; exists {
+        return entry.element.Value.(*models.Order)
+    }
+    return nil
+}
+
+// Best returns the order at the front of the best price level, or nil if the side is empty.
+func (s *bookSide) Best() *models.Order {
+    level := s.levels.first()
+    if level == nil {
+        return nil
+    }
+    return level.orders.Front().Value.(*models.Order)
+}
+
+// Insert queues an order at its price behind every order at that price with an earlier or equal
+// priority time. New orders carry the latest time, so the search from the back stops at once.
+func (s *bookSide) Insert(order *models.Order) {
+    level := s.levels.getOrInsert(s.key(order))
+    
+    mark := level.orders.Back()
+    for mark != nil && mark.Value.(*models.Order).PriorityAt.After(order.PriorityAt) {
+        mark = mark.Prev()
+    }
+    
+    var element *list.Element
+    if mark == nil {
+        element = level.orders.PushFront(order)
+    } else {
+        element = level.orders.InsertAfter(order, mark)
+    }
+    s.index[order.ID] = &bookEntry{level: level, element: element}
+    s.expiries.add(order)
+}
+
+// Remove takes the order with the given ID off this side and returns it, or nil if it was not there.
+func (s *bookSide) Remove(id string) *models.Order {
+    entry, exists := s.index[id]
+    if !exists {
+        return nil
+    }
+    
+    delete(s.index, id)
+    s.expiries.remove(id)
+    entry.level.orders.Remove(entry.element)
+    if entry.level.orders.Len() == 0 {
+        s.levels.remove(entry.level.price)
+    }
+    return entry.element.Value.(*models.Order)
+}
+
+// Take removes the order with the given ID like Remove and also returns a function that puts it
+// back in the same place in its queue, or nil if the order was not there. Undoing works as long
+// as every later change to the side has been undone first.
+func (s *bookSide) Take(id string) (*models.Order, func()) {
+    entry, exists := s.index[id]
+    if !exists {
+        return nil, nil
+    }
+    
+    // The order behind it may itself be taken and put back in a new element before this is undone
+    price := entry.level.price
+    var next *models.Order
+    if element := entry.element.Next(); element != nil {
+        next = element.Value.(*models.Order)
+    }
+    order := s.Remove(id)
+    
+    return order, func() {
+        level := s.levels.getOrInsert(price)
+        var element *list.Element
+        if next != nil {
+            element = level.orders.InsertBefore(order, s.index[next.ID].element)
+        } else {
+            element = level.orders.PushBack(order)
+        }
+        s.index[id] = &bookEntry{level: level, element: element}
+        s.expiries.add(order)
+    }
+}
+
+// Each calls fn for every order in priority order until fn returns false.
+func (s *bookSide) Each(fn func(*models.Order) bool) {
+    for node := s.levels.head.next[0]; node != nil; node = node.next[0] {
+        for element := node.level.orders.Front(); element != nil; element = element.Next() {
+            if !fn(element.Value.(*models.Order)) {
+                return
+            }
+        }
+    }
+}
+
+// HasExpired reports whether any order on this side has expired by now.
+func (s *bookSide) HasExpired(now time.Time) bool {
+    return len(s.expiries.orders) > 0 && s.expiries.orders[0].Expired(now)
+}
+
+// Expired returns the orders on this side that have expired by now, soonest expiry first. It
+// visits only the part of the heap that is due, and does not change the side.
+func (s *bookSide) Expired(now time.Time) []*models.Order {
+    var expired []*models.Order
+    var visit func(i int)
+    visit = func(i int) {
+        if i >= len(s.expiries.orders) || !s.expiries.orders[i].Expired(now) {
+            return
+        }
+        expired = append(expired, s.expiries.orders[i])
+        visit(2*i + 1)
+        visit(2*i + 2)
+    }
+    visit(0)
+    
+    // Heap order depends on the history of the side; replays need the same order every time
+    sort.Slice(expired, func(i, j int) bool {
+        if !expired[i].ExpiresAt.Equal(*expired[j].ExpiresAt) {
+            return expired[i].ExpiresAt.Before(*expired[j].ExpiresAt)
+        }
+        return expired[i].ID < expired[j].ID
+    })
+    return expired
+}
+
+// Orders returns every order in priority order.
+func (s *bookSide) Orders() []*models.Order {
+    orders := make([]*models.Order, 0, s.Len())
+    s.Each(func(order *models.Order) bool {
+        orders = append(orders, order)
+        return true
+    })
+    return orders
+}
+
+const (
+    maxLevelHeight = 24 // Enough for 4^24 price levels
+    levelBranching = 4
+)
+
+// levelList is a skip list of price levels ordered best price first.
+type levelList struct {
+    head       *levelNode
+    height     int
+    descending bool
+    random     *rand.Rand // Seeded, so a book's shape is reproducible
+    update     [maxLevelHeight]*levelNode
+}
+
+type levelNode struct {
+    level *priceLevel
+    next  []*levelNode
+}
+
+func newLevelList(descending bool) *levelList {
+    return &levelList{
+        head:       &levelNode{next: make([]*levelNode, maxLevelHeight)},
+        height:     1,
+        descending: descending,
+        random:     rand.New(rand.NewSource(1)),
+    }
+}
+
+// before reports whether price a sorts ahead of price b.
+func (l *levelList) before(a, b decimal.Decimal) bool {
+    if l.descending {
+        return a.GreaterThan(b)
+    }
+    return a.LessThan(b)
+}
+
+func (l *levelList) first() *priceLevel {
+    if node := l.head.next[0]; node != nil {
+        return node.level
+    }
+    return nil
+}
+
+// seek fills l.update with the last node before price on every height and returns the node at
+// price, if there is one.
+func (l *levelList) seek(price decimal.Decimal) *levelNode {
+    node := l.head
+    for height := l.height - 1; height >= 0; height-- {
+        for node.next[height] != nil && l.before(node.next[height].level.price, price) {
+            node = node.next[height]
+        }
+        l.update[height] = node
+    }
+    
+    if next := node.next[0]; next != nil && next.level.price.Equal(price) {
+        return next
+    }
+    return nil
+}
+
+func (l *levelList) getOrInsert(price decimal.Decimal) *priceLevel {
+    if node := l.seek(price); node != nil {
+        return node.level
+    }
+    
+    height := 1
+    for height < maxLevelHeight && l.random.Intn(levelBranching) == 0 {
+        height++
+    }
+    for ; l.height < height; l.height++ {
+        l.update[l.height] = l.head
+    }
+    
+    node := &levelNode{
+        level: &priceLevel{price: price, orders: list.New()},
+        next:  make([]*levelNode, height),
+    }
+    for i := 0; i < height; i++ {
+        node.next[i] = l.update[i].next[i]
+        l.update[i].next[i] = node
+    }
+    return node.level
+}
+
+func (l *levelList) remove(price decimal.Decimal) {
+    node := l.seek(price)
+    if node == nil {
+        return
+    }
+    
+    for i := range node.next {
+        l.update[i].next[i] = node.next[i]
+    }
+    for l.height > 1 && l.head.next[l.height-1] == nil {
+        l.height--
+    }
+}
+
+// expiryHeap is a min-heap of orders by expiry time, with each order's position in it so that an
+// order leaving the book can be taken out at once instead of lingering until it is due.
+type expiryHeap struct {
+    orders   []*models.Order
+    position map[string]int
+}
+
+func (h *expiryHeap) add(order *models.Order) {
+    if order.ExpiresAt != nil {
+        heap.Push(h, order)
+    }
+}
+
+func (h *expiryHeap) remove(id string) {
+    if i, exists := h.position[id]; exists {
+        heap.Remove(h, i)
+    }
+}
+
+func (h *expiryHeap) Len() int {
+    return len(h.orders)
+}
+
+func (h *expiryHeap) Less(i, j int) bool {
+    return h.orders[i].ExpiresAt.Before(*h.orders[j].ExpiresAt)
+}
+
+func (h *expiryHeap) Swap(i, j int) {
+    h.orders[i], h.orders[j] = h.orders[j], h.orders[i]
+    h.position[h.orders[i].ID] = i
+    h.position[h.orders[j].ID] = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+    order := x.(*models.Order)
+    h.position[order.ID] = len(h.orders)
+    h.orders = append(h.orders, order)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+    last := len(h.orders) - 1
+    order := h.orders[last]
+    h.orders[last] = nil
+    h.orders = h.orders[:last]
+    delete(h.position, order.ID)
+    return order
+}
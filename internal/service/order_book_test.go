@@ -0,0 +1,277 @@
+package service
+
+import (
+    "fmt"
+    "math/rand"
+    "order-matching-system/internal/models"
+    "sort"
+    "testing"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+// sliceSide is the flat sorted slice the book used before price levels, kept as the baseline the
+// benchmarks compare against. It holds bids: price descending, then priority time ascending.
+type sliceSide struct {
+    orders []*models.Order
+}
+
+func (s *sliceSide) Insert(order *models.Order) {
+    insertIndex := 0
+    for i, bid := range s.orders {
+        if order.Price.GreaterThan(*bid.Price) ||
+            (order.Price.Equal(*bid.Price) && order.PriorityAt.Before(bid.PriorityAt)) {
+            insertIndex = i
+            break
+        }
+        insertIndex = i + 1
+    }
+    s.orders = append(s.orders[:insertIndex], append([]*models.Order{order}, s.orders[insertIndex:]...)...)
+}
+
+func (s *sliceSide) Remove(id string) *models.Order {
+    for i, bid := range s.orders {
+        if bid.ID == id {
+            s.orders = append(s.orders[:i], s.orders[i+1:]...)
+            return bid
+        }
+    }
+    return nil
+}
+
+func (s *sliceSide) Best() *models.Order {
+    if len(s.orders) == 0 {
+        return nil
+    }
+    return s.orders[0]
+}
+
+type benchSide interface {
+    Insert(order *models.Order)
+    Remove(id string) *models.Order
+    Best() *models.Order
+}
+
+var benchStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// benchOrders returns n bids spread over 1000 price levels, in arrival order.
+func benchOrders(random *rand.Rand, n int, offset int) []*models.Order {
+    orders := make([]*models.Order, n)
+    for i := range orders {
+        price := decimal.New(int64(40000+random.Intn(1000)), 0)
+        orders[i] = &models.Order{
+            ID:         fmt.Sprintf("order-%d", offset+i),
+            Side:       models.BUY,
+            Price:      &price,
+            PriorityAt: benchStart.Add(time.Duration(offset+i) * time.Microsecond),
+        }
+    }
+    return orders
+}
+
+// fillSlice builds a sliceSide directly in sorted order; inserting a million orders one at a time
+// would take longer than the benchmark itself.
+func fillSlice(orders []*models.Order) *sliceSide {
+    tree := newBookSide(true, limitPrice)
+    for _, order := range orders {
+        tree.Insert(order)
+    }
+    return &sliceSide{orders: tree.Orders()}
+}
+
+func fillTree(orders []*models.Order) *bookSide {
+    tree := newBookSide(true, limitPrice)
+    for _, order := range orders {
+        tree.Insert(order)
+    }
+    return tree
+}
+
+var benchSizes = []int{10_000, 100_000, 1_000_000}
+
+// benchmarkSides runs op against both implementations at every book size. op is handed a book
+// of n resting orders; it keeps the size near n by restoring the book in untimed batches.
+func benchmarkSides(b *testing.B, op func(b *testing.B, side benchSide, resting []*models.Order)) {
+    for _, n := range benchSizes {
+        resting := benchOrders(rand.New(rand.NewSource(1)), n, 0)
+        
+        b.Run(fmt.Sprintf("slice/%d", n), func(b *testing.B) {
+            op(b, fillSlice(resting), resting)
+        })
+        b.Run(fmt.Sprintf("tree/%d", n), func(b *testing.B) {
+            op(b, fillTree(resting), resting)
+        })
+    }
+}
+
+func batchSize(n int) int {
+    return n/10 + 1
+}
+
+func BenchmarkBookInsert(b *testing.B) {
+    benchmarkSides(b, func(b *testing.B, side benchSide, resting []*models.Order) {
+        incoming := benchOrders(rand.New(rand.NewSource(2)), batchSize(len(resting)), len(resting))
+        
+        b.ResetTimer()
+        for i := 0; i < b.N; i++ {
+            j := i % len(incoming)
+            if i > 0 && j == 0 {
+                b.StopTimer()
+                for _, order := range incoming {
+                    side.Remove(order.ID)
+                }
+                b.StartTimer()
+            }
+            side.Insert(incoming[j])
+        }
+    })
+}
+
+func BenchmarkBookCancel(b *testing.B) {
+    benchmarkSides(b, func(b *testing.B, side benchSide, resting []*models.Order) {
+        random := rand.New(rand.NewSource(3))
+        canceled := make([]*models.Order, 0, batchSize(len(resting)))
+        
+        b.ResetTimer()
+        for i := 0; i < b.N; i++ {
+            if len(canceled) == cap(canceled) {
+                b.StopTimer()
+                for _, order := range canceled {
+                    side.Insert(order)
+                }
+                canceled = canceled[:0]
+                b.StartTimer()
+            }
+            
+            if order := side.Remove(resting[random.Intn(len(resting))].ID); order != nil {
+                canceled = append(canceled, order)
+            }
+        }
+    })
+}
+
+// BenchmarkBookMatch takes the best order off the book, as a fill against an aggressive order does.
+func BenchmarkBookMatch(b *testing.B) {
+    benchmarkSides(b, func(b *testing.B, side benchSide, resting []*models.Order) {
+        filled := make([]*models.Order, 0, batchSize(len(resting)))
+        
+        b.ResetTimer()
+        for i := 0; i < b.N; i++ {
+            if len(filled) == cap(filled) {
+                b.StopTimer()
+                for _, order := range filled {
+                    side.Insert(order)
+                }
+                filled = filled[:0]
+                b.StartTimer()
+            }
+            
+            filled = append(filled, side.Remove(side.Best().ID))
+        }
+    })
+}
+
+// TestBookSideMatchesSlice runs the same random inserts and removals through both implementations
+// and checks they keep the same priority order.
+func TestBookSideMatchesSlice(t *testing.T) {
+    random := rand.New(rand.NewSource(4))
+    tree := newBookSide(true, limitPrice)
+    slice := &sliceSide{}
+    var live []*models.Order
+    
+    for i := 0; i < 5000; i++ {
+        if len(live) > 0 && random.Intn(3) == 0 {
+            j := random.Intn(len(live))
+            id := live[j].ID
+            live = append(live[:j], live[j+1:]...)
+            
+            if tree.Remove(id) == nil || slice.Remove(id) == nil {
+                t.Fatalf("order %s missing", id)
+            }
+            continue
+        }
+        
+        price := decimal.New(int64(100+random.Intn(20)), 0)
+        order := &models.Order{
+            ID:         fmt.Sprintf("order-%d", i),
+            Side:       models.BUY,
+            Price:      &price,
+            // Some orders arrive with an older priority, as when loading from storage
+            PriorityAt: benchStart.Add(time.Duration(random.Intn(i+1)) * time.Second),
+        }
+        tree.Insert(order)
+        slice.Insert(order)
+        live = append(live, order)
+    }
+    
+    got := tree.Orders()
+    if len(got) != len(slice.orders) || tree.Len() != len(slice.orders) {
+        t.Fatalf("tree has %d orders, slice has %d", len(got), len(slice.orders))
+    }
+    for i := range got {
+        if got[i].ID != slice.orders[i].ID {
+            t.Fatalf("position %d: tree has %s, slice has %s", i, got[i].ID, slice.orders[i].ID)
+        }
+    }
+}
+
+// TestBookSideExpiries checks the expiry heap against a scan of the side through random inserts,
+// removals and removals that are undone.
+func TestBookSideExpiries(t *testing.T) {
+    random := rand.New(rand.NewSource(5))
+    side := newBookSide(true, limitPrice)
+    var live []*models.Order
+    
+    for i := 0; i < 2000; i++ {
+        if len(live) > 0 && random.Intn(3) == 0 {
+            j := random.Intn(len(live))
+            order, restore := side.Take(live[j].ID)
+            if order == nil {
+                t.Fatalf("order %s missing", live[j].ID)
+            }
+            if random.Intn(2) == 0 {
+                restore()
+            } else {
+                live = append(live[:j], live[j+1:]...)
+            }
+            continue
+        }
+        
+        price := decimal.New(int64(100+random.Intn(20)), 0)
+        order := &models.Order{ID: fmt.Sprintf("order-%d", i), Side: models.BUY, Price: &price, PriorityAt: benchStart}
+        if random.Intn(2) == 0 {
+            expiresAt := benchStart.Add(time.Duration(random.Intn(100)) * time.Second)
+            order.ExpiresAt = &expiresAt
+        }
+        side.Insert(order)
+        live = append(live, order)
+    }
+    
+    for _, seconds := range []int{-1, 0, 10, 50, 99} {
+        now := benchStart.Add(time.Duration(seconds) * time.Second)
+        var want []string
+        for _, order := range side.Orders() {
+            if order.Expired(now) {
+                want = append(want, order.ID)
+            }
+        }
+        
+        var got []string
+        expired := side.Expired(now)
+        for i, order := range expired {
+            got = append(got, order.ID)
+            if i > 0 && order.ExpiresAt.Before(*expired[i-1].ExpiresAt) {
+                t.Fatalf("at %ds: %s expires before %s", seconds, order.ID, expired[i-1].ID)
+            }
+        }
+        sort.Strings(got)
+        sort.Strings(want)
+        if fmt.Sprint(got) != fmt.Sprint(want) {
+            t.Fatalf("at %ds: expired %v, want %v", seconds, got, want)
+        }
+        if side.HasExpired(now) != (len(want) > 0) {
+            t.Fatalf("at %ds: HasExpired = %v with %d expired", seconds, side.HasExpired(now), len(want))
+        }
+    }
+}
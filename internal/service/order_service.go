@@ -1,23 +1,30 @@
 package service
 
 import (
+    "crypto/sha256"
+    "encoding/hex"
     "order-matching-system/internal/models"
     "order-matching-system/internal/repository"
+    "strconv"
+    "strings"
     "time"
     
     "github.com/google/uuid"
+    "github.com/shopspring/decimal"
 )
 
 type OrderService struct {
-    orderRepo      *repository.OrderRepository
-    tradeRepo      *repository.TradeRepository
+    orderRepo      repository.OrderStore
+    tradeRepo      repository.TradeStore
+    eventRepo      repository.OrderEventStore
     matchingEngine *MatchingEngine
 }
 
-func NewOrderService(orderRepo *repository.OrderRepository, tradeRepo *repository.TradeRepository, matchingEngine *MatchingEngine) *OrderService {
+func NewOrderService(orderRepo repository.OrderStore, tradeRepo repository.TradeStore, eventRepo repository.OrderEventStore, matchingEngine *MatchingEngine) *OrderService {
     return &OrderService{
         orderRepo:      orderRepo,
         tradeRepo:      tradeRepo,
+        eventRepo:      eventRepo,
         matchingEngine: matchingEngine,
     }
 }
@@ -27,60 +34,245 @@ func (s *OrderService) PlaceOrder(req *models.PlaceOrderRequest) (*models.Order,
         return nil, err
     }
     
+    // A retry of a request whose response was lost gets the order it placed
+    if req.ClientOrderID != "" {
+        existing, err := s.orderRepo.GetByClientOrderID(req.AccountID, req.ClientOrderID)
+        if err == nil {
+            return resubmitted(existing, req)
+        }
+        if err != models.ErrOrderNotFound {
+            return nil, err
+        }
+    }
+    
+    instrument, err := s.matchingEngine.GetInstrument(req.Symbol)
+    if err != nil {
+        return nil, models.ErrUnknownSymbol
+    }
+    
+    if instrument.Status != models.ACTIVE {
+        return nil, models.ErrSymbolNotActive
+    }
+    
+    if err := instrument.ValidateOrder(req); err != nil {
+        return nil, err
+    }
+    
+    order := newOrder(req)
+    order.ID = uuid.New().String()
+    if order.ClientOrderID != "" {
+        order.RequestHash = placementHash(order)
+    }
+    
+    if err := s.matchingEngine.PlaceOrder(order); err != nil {
+        // The engine saw the client order ID first, on a request that was not done yet when this one arrived
+        if err == models.ErrDuplicateClientOrderID {
+            if existing, lookupErr := s.orderRepo.GetByClientOrderID(req.AccountID, req.ClientOrderID); lookupErr == nil {
+                return resubmitted(existing, req)
+            }
+        }
+        return nil, err
+    }
+
+     updatedOrder, err := s.orderRepo.GetByID(order.ID)
+    if err != nil {
+        return nil, err
+    }
+    return updatedOrder, nil
+}
+
+// newOrder builds the order a request asks for, with the defaults the request leaves out applied.
+func newOrder(req *models.PlaceOrderRequest) *models.Order {
+    // Storage keeps microseconds, so a listing cursor taken from the order before it is written
+    // matches the stored row
+    now := time.Now().Truncate(time.Microsecond)
     order := &models.Order{
-        ID:                uuid.New().String(),
+        AccountID:         req.AccountID,
+        ClientOrderID:     req.ClientOrderID,
         Symbol:            req.Symbol,
         Side:              req.Side,
         Type:              req.Type,
         Price:             req.Price,
+        StopPrice:         req.StopPrice,
         InitialQuantity:   req.Quantity,
         RemainingQuantity: req.Quantity,
+        DisplayQuantity:   req.DisplayQuantity,
         Status:            models.OPEN,
-        CreatedAt:         time.Now(),
-        UpdatedAt:         time.Now(),
+        TimeInForce:       req.TimeInForce,
+        ExpiresAt:         req.ExpiresAt,
+        PostOnly:          req.PostOnly,
+        PostOnlyAction:    req.PostOnlyAction,
+        SelfTradePrevention: req.SelfTradePrevention,
+        CreatedAt:         now,
+        UpdatedAt:         now,
     }
+    order.PriorityAt = order.CreatedAt
     
-   
-    if err := s.orderRepo.Create(order); err != nil {
-        return nil, err
+    switch {
+    case order.Type.IsMarket():
+        order.TimeInForce = models.IOC
+    case order.TimeInForce == "":
+        order.TimeInForce = models.GTC
+    case order.TimeInForce == models.DAY:
+        expiresAt := endOfDay(order.CreatedAt)
+        order.ExpiresAt = &expiresAt
+    }
+    
+    if order.PostOnly && order.PostOnlyAction == "" {
+        order.PostOnlyAction = models.POST_ONLY_REJECT
     }
     
+    if order.IsIceberg() {
+        order.Replenish()
+    }
     
-   s.matchingEngine.PlaceOrder(order); 
+    if order.Type.IsStop() {
+        order.Status = models.UNTRIGGERED
+    }
+    return order
+}
 
-     updatedOrder, err := s.orderRepo.GetByID(order.ID)
-    if err != nil {
+// resubmitted answers a request reusing a client order ID: the order it placed if the request is
+// the same, or a conflict.
+func resubmitted(existing *models.Order, req *models.PlaceOrderRequest) (*models.Order, error) {
+    // Orders stored before request hashes were kept are compared with their current state
+    placed := existing.RequestHash
+    if placed == "" {
+        placed = placementHash(existing)
+    }
+    
+    if placementHash(newOrder(req)) != placed {
+        return nil, models.ErrClientOrderIDConflict
+    }
+    return existing, nil
+}
+
+// placementHash digests the fields of a new order that its request chose. It is kept on the
+// order, since an amend, a post-only reprice or a self-trade decrement changes the order's price
+// or quantity afterwards and a retry of the request must still match.
+func placementHash(order *models.Order) string {
+    // DAY orders expire at the end of the day they were placed, which the request does not name
+    var expiresAt string
+    if order.ExpiresAt != nil && order.TimeInForce != models.DAY {
+        expiresAt = order.ExpiresAt.UTC().Format(time.RFC3339Nano)
+    }
+    
+    fields := []string{
+        order.AccountID,
+        order.ClientOrderID,
+        order.Symbol,
+        string(order.Side),
+        string(order.Type),
+        decimalField(order.Price),
+        decimalField(order.StopPrice),
+        order.InitialQuantity.String(),
+        decimalField(order.DisplayQuantity),
+        string(order.TimeInForce),
+        expiresAt,
+        strconv.FormatBool(order.PostOnly),
+        string(order.PostOnlyAction),
+        string(order.SelfTradePrevention),
+    }
+    sum := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+    return hex.EncodeToString(sum[:])
+}
+
+func decimalField(value *decimal.Decimal) string {
+    if value == nil {
+        return ""
+    }
+    return value.String()
+}
+
+// endOfDay returns the close of the UTC trading day that t falls in.
+func endOfDay(t time.Time) time.Time {
+    year, month, day := t.UTC().Date()
+    return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *OrderService) AmendOrder(orderID string, req *models.AmendOrderRequest) (*models.Order, error) {
+    if err := req.Validate(); err != nil {
         return nil, err
     }
-    return updatedOrder, nil
+    
+    return s.matchingEngine.AmendOrder(orderID, req)
 }
 
-func (s *OrderService) CancelOrder(orderID string) error {
-    order, err := s.orderRepo.GetByID(orderID)
+// CancelOrder waits for the matching engine to cancel the order and returns its final state.
+func (s *OrderService) CancelOrder(orderID string) (*models.CancelOrderResult, error) {
+    order, err := s.matchingEngine.CancelOrder(orderID)
     if err != nil {
-        return err
+        return nil, err
     }
     
-    if order.Status == models.FILLED {
-        return models.ErrOrderAlreadyFilled
+    return &models.CancelOrderResult{
+        Order:            order,
+        FilledQuantity:   order.InitialQuantity.Sub(order.RemainingQuantity),
+        CanceledQuantity: order.RemainingQuantity,
+    }, nil
+}
+
+func (s *OrderService) GetOrder(orderID string) (*models.Order, error) {
+    return s.orderRepo.GetByID(orderID)
+}
+
+// ListOrders returns a page of the orders the query matches. The next page continues from the
+// returned cursor, so orders created meanwhile are not skipped or repeated.
+func (s *OrderService) ListOrders(query *models.OrderQuery) (*models.OrderPage, error) {
+    if err := query.Validate(); err != nil {
+        return nil, err
     }
     
-    if order.Status == models.CANCELED {
-        return models.ErrOrderAlreadyCanceled
+    limit := query.Limit
+    if limit == 0 {
+        limit = models.DefaultOrderPageSize
     }
     
-  
-    s.matchingEngine.CancelOrder(orderID)
+    // Read one order past the page to learn whether another page follows
+    probe := *query
+    probe.Limit = limit + 1
+    orders, err := s.orderRepo.List(&probe)
+    if err != nil {
+        return nil, err
+    }
     
-    return nil
+    page := &models.OrderPage{Orders: append([]models.Order{}, orders...)}
+    if len(page.Orders) > limit {
+        page.Orders = page.Orders[:limit]
+        page.NextCursor = models.CursorOf(&page.Orders[limit-1]).String()
+    }
+    return page, nil
 }
 
-func (s *OrderService) GetOrder(orderID string) (*models.Order, error) {
-    return s.orderRepo.GetByID(orderID)
+// GetOrderByClientID finds an order by the client order ID its account gave it.
+func (s *OrderService) GetOrderByClientID(accountID, clientOrderID string) (*models.Order, error) {
+    return s.orderRepo.GetByClientOrderID(accountID, clientOrderID)
+}
+
+// CancelOrderByClientID cancels an order named by its account's client order ID.
+func (s *OrderService) CancelOrderByClientID(accountID, clientOrderID string) (*models.CancelOrderResult, error) {
+    order, err := s.orderRepo.GetByClientOrderID(accountID, clientOrderID)
+    if err != nil {
+        return nil, err
+    }
+    return s.CancelOrder(order.ID)
+}
+
+// GetOrderEvents returns an order's history besides its trades, oldest first.
+func (s *OrderService) GetOrderEvents(orderID string) ([]models.OrderEvent, error) {
+    if _, err := s.orderRepo.GetByID(orderID); err != nil {
+        return nil, err
+    }
+    
+    events, err := s.eventRepo.GetByOrder(orderID)
+    if err != nil {
+        return nil, err
+    }
+    return append([]models.OrderEvent{}, events...), nil
 }
 
-func (s *OrderService) GetOrderBook(symbol string) *models.OrderBook {
-    return s.matchingEngine.GetOrderBook(symbol)
+func (s *OrderService) GetOrderBook(symbol string, depth int, increment decimal.Decimal) *models.OrderBook {
+    return s.matchingEngine.GetOrderBook(symbol, depth, increment)
 }
 
 func (s *OrderService) GetTrades(symbol string, limit int) ([]models.Trade, error) {
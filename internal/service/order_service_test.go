@@ -0,0 +1,85 @@
+package service
+
+import (
+    "order-matching-system/internal/models"
+    "testing"
+    
+    "github.com/shopspring/decimal"
+)
+
+// TestTradingRules checks that orders and amends off the symbol's tick and lot sizes, outside its
+// quantity bounds or below its minimum notional are turned away before they lock any funds.
+func TestTradingRules(t *testing.T) {
+    orders, engine, _, _ := newTestEngine(t)
+    
+    _, err := engine.UpdateInstrument("BTCUSD", &models.UpdateInstrumentRequest{
+        TickSize:    decimalPtr("0.5"),
+        LotSize:     decimalPtr("0.01"),
+        MinQuantity: decimalPtr("0.02"),
+        MaxQuantity: decimalPtr("10"),
+        MinNotional: decimalPtr("5"),
+    })
+    if err != nil {
+        t.Fatalf("update instrument: %v", err)
+    }
+    
+    request := func(orderType models.OrderType, price, stopPrice, quantity string) *models.PlaceOrderRequest {
+        req := &models.PlaceOrderRequest{
+            AccountID: testAccount,
+            Symbol:    "BTCUSD",
+            Side:      models.BUY,
+            Type:      orderType,
+            Quantity:  decimal.RequireFromString(quantity),
+        }
+        if price != "" {
+            req.Price = decimalPtr(price)
+        }
+        if stopPrice != "" {
+            req.StopPrice = decimalPtr(stopPrice)
+        }
+        return req
+    }
+    iceberg := request(models.LIMIT, "100", "", "1")
+    iceberg.DisplayQuantity = decimalPtr("0.005")
+    
+    for _, test := range []struct {
+        name string
+        req  *models.PlaceOrderRequest
+        want error
+    }{
+        {"price off tick", request(models.LIMIT, "100.25", "", "1"), models.ErrPriceNotOnTick},
+        {"stop price off tick", request(models.STOP_LIMIT, "100", "99.75", "1"), models.ErrPriceNotOnTick},
+        {"quantity off lot", request(models.LIMIT, "100", "", "0.015"), models.ErrQuantityNotOnLot},
+        {"display quantity off lot", iceberg, models.ErrQuantityNotOnLot},
+        {"quantity below minimum", request(models.LIMIT, "100", "", "0.01"), models.ErrQuantityTooSmall},
+        {"quantity above maximum", request(models.LIMIT, "100", "", "10.01"), models.ErrQuantityTooLarge},
+        {"notional below minimum", request(models.LIMIT, "100", "", "0.04"), models.ErrNotionalTooSmall},
+        {"stop notional below minimum", request(models.STOP_MARKET, "", "100", "0.04"), models.ErrNotionalTooSmall},
+    } {
+        if _, err := orders.PlaceOrder(test.req); err != test.want {
+            t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+        }
+    }
+    
+    order, err := orders.PlaceOrder(request(models.LIMIT, "100.5", "", "0.05"))
+    if err != nil {
+        t.Fatalf("place an order within the rules: %v", err)
+    }
+    for _, test := range []struct {
+        name string
+        req  *models.AmendOrderRequest
+        want error
+    }{
+        {"price off tick", &models.AmendOrderRequest{Price: decimalPtr("100.25")}, models.ErrPriceNotOnTick},
+        {"quantity off lot", &models.AmendOrderRequest{Quantity: decimalPtr("0.055")}, models.ErrQuantityNotOnLot},
+        {"notional below minimum", &models.AmendOrderRequest{Quantity: decimalPtr("0.04")}, models.ErrNotionalTooSmall},
+    } {
+        if _, err := orders.AmendOrder(order.ID, test.req); err != test.want {
+            t.Errorf("amend %s: got %v, want %v", test.name, err, test.want)
+        }
+    }
+    
+    if want := "\ntrader BTC 100/0\ntrader USD 99994.975/5.025"; ledgerState(engine) != want {
+        t.Fatalf("ledger:%s\nwant:%s", ledgerState(engine), want)
+    }
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+)
+
+type PersistenceService struct {
+    writeBehind *repository.WriteBehind // nil when commands are committed synchronously
+}
+
+func NewPersistenceService(writeBehind *repository.WriteBehind) *PersistenceService {
+    return &PersistenceService{
+        writeBehind: writeBehind,
+    }
+}
+
+// GetStats reports how far storage is behind the engine in write-behind mode.
+func (s *PersistenceService) GetStats() (*repository.WriteBehindStats, error) {
+    if s.writeBehind == nil {
+        return nil, models.ErrWriteBehindDisabled
+    }
+    
+    stats := s.writeBehind.Stats()
+    return &stats, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+    "log"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    
+    "github.com/shopspring/decimal"
+)
+
+// selfTrade reports whether an incoming order's self-trade prevention stops it trading against a
+// resting order: both belong to the same account and the incoming order has a mode set.
+func selfTrade(order *models.Order, restingOrder *models.Order) bool {
+    return order.SelfTradePrevention != "" && order.AccountID != "" && order.AccountID == restingOrder.AccountID
+}
+
+// preventSelfTrade applies the incoming order's self-trade prevention mode where it meets a resting
+// order of its own account, instead of trading, and records an event against each of the two
+// orders. It returns the incoming order's remaining quantity and whether the incoming order was
+// cancelled.
+//
+// Decrement-and-cancel takes the smaller order's remaining quantity off both orders: the smaller
+// one is cancelled and the larger one stays live with that much less quantity. A resting order
+// that is decremented keeps its place in the queue.
+func (sh *shard) preventSelfTrade(tx repository.Tx, order *models.Order, restingOrder *models.Order, remainingQuantity decimal.Decimal, orderBook *InMemoryOrderBook) (decimal.Decimal, bool, error) {
+    incoming, incomingQuantity := models.EVENT_UNCHANGED, decimal.Zero
+    resting, restingQuantity := models.EVENT_UNCHANGED, decimal.Zero
+    
+    switch order.SelfTradePrevention {
+    case models.STP_CANCEL_NEWEST:
+        incoming, incomingQuantity = models.EVENT_CANCELED, remainingQuantity
+    case models.STP_CANCEL_OLDEST:
+        resting, restingQuantity = models.EVENT_CANCELED, restingOrder.RemainingQuantity
+    case models.STP_CANCEL_BOTH:
+        incoming, incomingQuantity = models.EVENT_CANCELED, remainingQuantity
+        resting, restingQuantity = models.EVENT_CANCELED, restingOrder.RemainingQuantity
+    case models.STP_DECREMENT_AND_CANCEL:
+        quantity := decimal.Min(remainingQuantity, restingOrder.RemainingQuantity)
+        incoming, incomingQuantity = models.EVENT_DECREMENTED, quantity
+        resting, restingQuantity = models.EVENT_DECREMENTED, quantity
+        if remainingQuantity.Equal(quantity) {
+            incoming = models.EVENT_CANCELED
+        }
+        if restingOrder.RemainingQuantity.Equal(quantity) {
+            resting = models.EVENT_CANCELED
+        }
+    }
+    
+    if resting != models.EVENT_UNCHANGED {
+        sh.save(restingOrder)
+        if resting == models.EVENT_CANCELED {
+            sh.removeFromOrderBook(orderBook, restingOrder)
+            restingOrder.Status = models.CANCELED
+        } else {
+            restingOrder.Decrement(restingQuantity)
+            orderBook.Sequence++
+        }
+        restingOrder.UpdatedAt = sh.now
+        
+        if err := sh.updateOrder(tx, restingOrder); err != nil {
+            log.Printf("Error updating self-trade resting order: %v", err)
+            return remainingQuantity, false, err
+        }
+    }
+    
+    // The caller stores the incoming order once matching ends
+    if incoming == models.EVENT_DECREMENTED {
+        order.InitialQuantity = order.InitialQuantity.Sub(incomingQuantity)
+        remainingQuantity = remainingQuantity.Sub(incomingQuantity)
+    }
+    
+    mode := order.SelfTradePrevention
+    if err := sh.recordEvent(tx, order, restingOrder, mode, incoming, incomingQuantity); err != nil {
+        return remainingQuantity, false, err
+    }
+    if err := sh.recordEvent(tx, restingOrder, order, mode, resting, restingQuantity); err != nil {
+        return remainingQuantity, false, err
+    }
+    
+    log.Printf("Self-trade prevented: %s against %s (%s)", order.ID, restingOrder.ID, mode)
+    return remainingQuantity, incoming == models.EVENT_CANCELED, nil
+}
+
+// recordEvent stores what a self-trade prevention mode did to one of the two orders.
+func (sh *shard) recordEvent(tx repository.Tx, order *models.Order, counterOrder *models.Order, mode models.SelfTradePrevention, action models.OrderEventAction, quantity decimal.Decimal) error {
+    event := &models.OrderEvent{
+        ID:                  sh.nextEventID(),
+        OrderID:             order.ID,
+        Type:                models.SELF_TRADE_PREVENTED,
+        CounterOrderID:      counterOrder.ID,
+        SelfTradePrevention: mode,
+        Action:              action,
+        Quantity:            quantity,
+        CreatedAt:           sh.now,
+    }
+    if err := tx.OrderEvents().Create(event); err != nil {
+        log.Printf("Error saving order event: %v", err)
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+    "fmt"
+    "order-matching-system/internal/models"
+    "strings"
+    "testing"
+    
+    "github.com/shopspring/decimal"
+)
+
+// TestSelfTradePrevention sends a buy against a resting sell of the same account under each mode
+// and checks what is left of both orders, the events recorded against them and the funds they lock.
+func TestSelfTradePrevention(t *testing.T) {
+    tests := []struct {
+        mode              models.SelfTradePrevention
+        quantity          string
+        incoming, resting string // Status and initial/remaining quantity of each order afterwards
+        events            string // Action and quantity of the incoming order's event, then the resting order's
+        ledger            string
+    }{
+        {models.STP_CANCEL_NEWEST, "1", "canceled 1/1", "open 2/2", "canceled 1 unchanged 0", "\ntrader BTC 98/2\ntrader USD 100000/0"},
+        {models.STP_CANCEL_OLDEST, "1", "open 1/1", "canceled 2/2", "unchanged 0 canceled 2", "\ntrader BTC 100/0\ntrader USD 99900/100"},
+        {models.STP_CANCEL_BOTH, "1", "canceled 1/1", "canceled 2/2", "canceled 1 canceled 2", "\ntrader BTC 100/0\ntrader USD 100000/0"},
+        {models.STP_DECREMENT_AND_CANCEL, "3", "open 1/1", "canceled 2/2", "decremented 2 canceled 2", "\ntrader BTC 100/0\ntrader USD 99900/100"},
+        {models.STP_DECREMENT_AND_CANCEL, "1", "canceled 1/1", "open 1/1", "canceled 1 decremented 1", "\ntrader BTC 99/1\ntrader USD 100000/0"},
+    }
+    
+    for _, test := range tests {
+        t.Run(fmt.Sprintf("%s %s", test.mode, test.quantity), func(t *testing.T) {
+            orders, engine, _, _ := newTestEngine(t)
+            
+            sell := place(t, orders, models.SELL, models.LIMIT, "100", "", "2")
+            buy, err := orders.PlaceOrder(&models.PlaceOrderRequest{
+                AccountID:           testAccount,
+                Symbol:              "BTCUSD",
+                Side:                models.BUY,
+                Type:                models.LIMIT,
+                Price:               decimalPtr("100"),
+                Quantity:            decimal.RequireFromString(test.quantity),
+                SelfTradePrevention: test.mode,
+            })
+            if err != nil {
+                t.Fatalf("place buy: %v", err)
+            }
+            
+            var states, events []string
+            for _, orderID := range []string{buy.ID, sell.ID} {
+                order, err := orders.GetOrder(orderID)
+                if err != nil {
+                    t.Fatalf("get order: %v", err)
+                }
+                states = append(states, fmt.Sprintf("%s %v/%v", order.Status, order.InitialQuantity, order.RemainingQuantity))
+                
+                history, err := orders.GetOrderEvents(orderID)
+                if err != nil || len(history) != 1 || history[0].Type != models.SELF_TRADE_PREVENTED || history[0].SelfTradePrevention != test.mode {
+                    t.Fatalf("events = %+v, %v", history, err)
+                }
+                events = append(events, fmt.Sprintf("%s %v", history[0].Action, history[0].Quantity))
+            }
+            if states[0] != test.incoming || states[1] != test.resting {
+                t.Errorf("orders = %q, want %q, %q", states, test.incoming, test.resting)
+            }
+            if got := strings.Join(events, " "); got != test.events {
+                t.Errorf("events = %s, want %s", got, test.events)
+            }
+            
+            if trades, _ := orders.GetTrades("BTCUSD", 10); len(trades) != 0 {
+                t.Errorf("trades = %+v", trades)
+            }
+            if got := ledgerState(engine); got != test.ledger {
+                t.Errorf("ledger:%s\nwant:%s", got, test.ledger)
+            }
+        })
+    }
+}
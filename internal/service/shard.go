@@ -0,0 +1,296 @@
+package service
+
+import (
+    "fmt"
+    "log"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "order-matching-system/internal/snapshot"
+    "time"
+    
+    "github.com/google/uuid"
+)
+
+// shard owns one symbol's book. While the symbol is trading, its commands are applied one at a
+// time by the shard's own goroutine, so symbols match in parallel without sharing a lock. While
+// it is stopped, the router applies its commands inline instead.
+type shard struct {
+    engine     *MatchingEngine
+    symbol     string
+    book       *InMemoryOrderBook
+    unitOfWork repository.UnitOfWork // Replaced by a scratch store while catching up from a snapshot
+    
+    // Guarded by the engine's routeMutex
+    commands chan *engineCommand // nil while the shard is stopped
+    done     chan struct{}       // Closed when the goroutine has applied its last command
+    
+    // Owned by whoever applies the shard's commands
+    sequence uint64          // Journal sequence of the last command applied to this symbol
+    now      time.Time       // Timestamp of the command being applied, the only clock processing reads
+    trades   []*models.Trade // Trades executed by the command being applied
+    events   int             // Order events recorded by the command being applied
+    hold     *fundsHold      // Funds the router locked for the command, until the command uses them
+    balances map[balanceKey]*models.Balance // Balance changes the command writes to storage
+    undo     []func()        // Reverts the command's in-memory changes, newest last; nil outside apply
+}
+
+func newShard(engine *MatchingEngine, symbol string) *shard {
+    return &shard{
+        engine:     engine,
+        symbol:     symbol,
+        book:       newInMemoryOrderBook(symbol),
+        unitOfWork: engine.unitOfWork,
+    }
+}
+
+func (sh *shard) running() bool {
+    return sh.commands != nil
+}
+
+func (sh *shard) start() {
+    if sh.running() {
+        return
+    }
+    
+    sh.commands = make(chan *engineCommand, 1000)
+    sh.done = make(chan struct{})
+    go sh.run(sh.commands, sh.done)
+    
+    log.Printf("Started shard %s", sh.symbol)
+}
+
+// stop lets the goroutine apply the commands already queued and waits for it to exit.
+func (sh *shard) stop() {
+    if !sh.running() {
+        return
+    }
+    
+    close(sh.commands)
+    <-sh.done
+    sh.commands = nil
+    
+    log.Printf("Stopped shard %s", sh.symbol)
+}
+
+func (sh *shard) run(commands <-chan *engineCommand, done chan<- struct{}) {
+    defer close(done)
+    
+    for cmd := range commands {
+        sh.dispatch(cmd)
+    }
+}
+
+// deliver queues a command for the shard's goroutine, or applies it on the caller while the shard is stopped.
+func (sh *shard) deliver(cmd *engineCommand) {
+    if sh.running() {
+        sh.commands <- cmd
+        return
+    }
+    sh.dispatch(cmd)
+}
+
+func (sh *shard) dispatch(cmd *engineCommand) {
+    var result commandResult
+    if cmd.kind == snapshotCommand {
+        result.book, result.instrument = sh.captureBook()
+    } else {
+        result = sh.apply(cmd)
+    }
+    
+    if cmd.reply != nil {
+        cmd.reply <- result
+    }
+}
+
+// apply executes a command in one storage transaction that also advances the symbol's watermark,
+// so storage always holds the effect of a prefix of the symbol's journaled commands. Rejections
+// are committed with the rest of the command. If storage fails, the transaction is rolled back
+// and so is every change the command made to the book, its orders and the registry.
+func (sh *shard) apply(cmd *engineCommand) commandResult {
+    sequence, lastPrice, version := sh.sequence, sh.book.LastPrice, sh.book.Sequence
+    sh.undo = []func(){func() {
+        sh.sequence, sh.book.LastPrice, sh.book.Sequence = sequence, lastPrice, version
+    }}
+    defer func() {
+        sh.undo = nil
+        sh.engine.releaseClientOrderID(cmd)
+    }()
+    
+    sh.sequence = cmd.sequence
+    sh.now = cmd.now
+    sh.trades = nil
+    sh.events = 0
+    sh.hold = cmd.hold
+    sh.balances = make(map[balanceKey]*models.Balance)
+    
+    // A command that is rolled back returns its funds, since the caller is told it had no effect
+    if hold := cmd.hold; hold != nil {
+        sh.onUndo(func() {
+            sh.engine.releaseHold(hold)
+        })
+    }
+    
+    tx, err := sh.unitOfWork.Begin()
+    if err != nil {
+        log.Printf("Error starting transaction: %v", err)
+        return commandResult{err: err}
+    }
+    defer tx.Rollback()
+    
+    result := sh.execute(tx, cmd)
+    sh.returnHold()
+    if result.err != nil && !isRejection(result.err) {
+        sh.rollback(cmd)
+        return result
+    }
+    
+    if err := sh.writeBalances(tx); err != nil {
+        log.Printf("Error writing balances: %v", err)
+        sh.rollback(cmd)
+        return commandResult{err: err}
+    }
+    
+    if err := tx.Watermark().Set(sh.symbol, repository.Watermark{Sequence: cmd.sequence, BookSequence: sh.book.Sequence}); err != nil {
+        log.Printf("Error advancing watermark: %v", err)
+        sh.rollback(cmd)
+        return commandResult{err: err}
+    }
+    
+    if err := tx.Commit(); err != nil {
+        log.Printf("Error committing transaction: %v", err)
+        sh.rollback(cmd)
+        return commandResult{err: err}
+    }
+    
+    sh.recordVolumes()
+    result.trades = sh.trades
+    return result
+}
+
+// recordVolumes counts the committed command's trades toward their accounts' fee tiers.
+func (sh *shard) recordVolumes() {
+    if len(sh.trades) == 0 {
+        return
+    }
+    
+    instrument, err := sh.engine.GetInstrument(sh.symbol)
+    if err != nil {
+        return
+    }
+    sh.engine.fees.record(sh.trades, instrument.QuoteAsset)
+}
+
+// onUndo records how to revert an in-memory change made by the command being applied. Changes
+// made outside apply, such as loading the book at startup, are not recorded.
+func (sh *shard) onUndo(fn func()) {
+    if sh.undo != nil {
+        sh.undo = append(sh.undo, fn)
+    }
+}
+
+// save records an order's current state before the command changes it. Order methods replace
+// pointer fields rather than writing through them, so a shallow copy is enough.
+func (sh *shard) save(order *models.Order) {
+    saved := *order
+    sh.onUndo(func() {
+        *order = saved
+    })
+}
+
+// updateOrder stores an order's new state, first releasing any funds it no longer needs.
+func (sh *shard) updateOrder(tx repository.Tx, order *models.Order) error {
+    if err := sh.releaseExcess(order); err != nil {
+        return err
+    }
+    return tx.Orders().Update(order)
+}
+
+// rollback reverts the command's in-memory changes in reverse order, leaving the book, its orders
+// and the registry as they were before the command.
+func (sh *shard) rollback(cmd *engineCommand) {
+    sh.book.mutex.Lock()
+    defer sh.book.mutex.Unlock()
+    
+    for i := len(sh.undo) - 1; i >= 0; i-- {
+        sh.undo[i]()
+    }
+    sh.trades = nil
+    
+    log.Printf("Rolled back command %d on %s", cmd.sequence, sh.symbol)
+}
+
+func (sh *shard) execute(tx repository.Tx, cmd *engineCommand) commandResult {
+    var result commandResult
+    
+    switch cmd.kind {
+    case placeCommand:
+        result.err = sh.processPlaceOrder(tx, cmd.order)
+        result.order = cmd.order
+    case cancelCommand:
+        result.order, result.err = sh.processCancelOrder(tx, cmd.orderID)
+    case amendCommand:
+        result.order, result.err = sh.processAmendOrder(tx, cmd.orderID, cmd.amend, cmd.reserve)
+    case expireCommand:
+        result.err = sh.expireBookOrders(tx, cmd.now)
+    case createInstrumentCommand:
+        result.instrument, result.err = sh.processCreateInstrument(tx, cmd.instrument)
+    case updateInstrumentCommand:
+        result.instrument, result.err = sh.processUpdateInstrument(tx, cmd.instrumentUpdate)
+    }
+    
+    return result
+}
+
+// nextTradeID derives the ID of the next trade of the command being applied from its sequence
+// number and the trades it has executed so far.
+func (sh *shard) nextTradeID() string {
+    name := fmt.Sprintf("%d/%d", sh.sequence, len(sh.trades))
+    return uuid.NewSHA1(tradeIDSpace, []byte(name)).String()
+}
+
+// nextEventID derives the ID of the next order event of the command being applied, like nextTradeID.
+func (sh *shard) nextEventID() string {
+    name := fmt.Sprintf("%d/%d", sh.sequence, sh.events)
+    sh.events++
+    return uuid.NewSHA1(eventIDSpace, []byte(name)).String()
+}
+
+// hasExpiredOrders reports whether any resting order has expired by now, so that idle sweeps
+// are not journaled. It only reads the book, so the sweeper can call it from its own goroutine.
+func (sh *shard) hasExpiredOrders(now time.Time) bool {
+    sh.book.mutex.RLock()
+    defer sh.book.mutex.RUnlock()
+    
+    for _, side := range sh.book.sides() {
+        if side.HasExpired(now) {
+            return true
+        }
+    }
+    return false
+}
+
+// captureBook copies the book and the symbol's instrument between two of the shard's commands.
+func (sh *shard) captureBook() (*snapshot.Book, *models.Instrument) {
+    sh.book.mutex.RLock()
+    defer sh.book.mutex.RUnlock()
+    
+    book := &snapshot.Book{
+        Symbol:          sh.symbol,
+        Sequence:        sh.book.Sequence,
+        JournalSequence: sh.sequence,
+        Bids:            snapshotOrders(sh.book.Bids.Orders()),
+        Asks:            snapshotOrders(sh.book.Asks.Orders()),
+        BuyStops:        snapshotOrders(sh.book.BuyStops.Orders()),
+        SellStops:       snapshotOrders(sh.book.SellStops.Orders()),
+    }
+    if sh.book.LastPrice != nil {
+        lastPrice := *sh.book.LastPrice
+        book.LastPrice = &lastPrice
+    }
+    
+    instrument, err := sh.engine.GetInstrument(sh.symbol)
+    if err != nil {
+        return book, nil
+    }
+    return book, instrument
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "reflect"
+    "sort"
+    "strings"
+    "sync"
+    "testing"
+    
+    "github.com/shopspring/decimal"
+)
+
+// failingUnitOfWork makes every commit fail while fail is set.
+type failingUnitOfWork struct {
+    repository.UnitOfWork
+    fail bool
+}
+
+func (u *failingUnitOfWork) Begin() (repository.Tx, error) {
+    tx, err := u.UnitOfWork.Begin()
+    if err != nil {
+        return nil, err
+    }
+    return &failingTx{Tx: tx, unitOfWork: u}, nil
+}
+
+type failingTx struct {
+    repository.Tx
+    unitOfWork *failingUnitOfWork
+}
+
+func (t *failingTx) Commit() error {
+    if t.unitOfWork.fail {
+        t.Tx.Rollback()
+        return errors.New("commit failed")
+    }
+    return t.Tx.Commit()
+}
+
+const testAccount = "trader"
+
+func newTestEngine(t *testing.T) (*OrderService, *MatchingEngine, *repository.Store, *failingUnitOfWork) {
+    store := newTestStore(t)
+    unitOfWork := &failingUnitOfWork{UnitOfWork: store.UnitOfWork}
+    store.UnitOfWork = unitOfWork
+    
+    engine := NewMatchingEngine(store, nil)
+    if err := engine.Recover(); err != nil {
+        t.Fatalf("recover: %v", err)
+    }
+    engine.Start()
+    
+    // Funds every order the tests place
+    if err := engine.CreateAccount(&models.Account{ID: testAccount, CreatedAt: benchStart}); err != nil {
+        t.Fatalf("create account: %v", err)
+    }
+    for asset, amount := range map[string]string{"BTC": "100", "USD": "100000"} {
+        if err := engine.Deposit(testAccount, asset, decimal.RequireFromString(amount)); err != nil {
+            t.Fatalf("deposit %s: %v", asset, err)
+        }
+    }
+    
+    return NewOrderService(store.Orders, store.Trades, store.OrderEvents, engine), engine, store, unitOfWork
+}
+
+// newTestStore returns an in-memory store listing BTCUSD.
+func newTestStore(t *testing.T) *repository.Store {
+    store := repository.NewMemoryStore()
+    err := store.Instruments.Create(&models.Instrument{
+        Symbol:            "BTCUSD",
+        BaseAsset:         "BTC",
+        QuoteAsset:        "USD",
+        Status:            models.ACTIVE,
+        PricePrecision:    2,
+        QuantityPrecision: 4,
+        TickSize:          decimal.RequireFromString("0.01"),
+        LotSize:           decimal.RequireFromString("0.0001"),
+        MinQuantity:       decimal.RequireFromString("0.0001"),
+        CreatedAt:         benchStart,
+        UpdatedAt:         benchStart,
+    })
+    if err != nil {
+        t.Fatalf("create instrument: %v", err)
+    }
+    return store
+}
+
+func place(t *testing.T, orders *OrderService, side models.OrderSide, orderType models.OrderType, price, stopPrice, quantity string) *models.Order {
+    req := &models.PlaceOrderRequest{
+        AccountID: testAccount,
+        Symbol:    "BTCUSD",
+        Side:      side,
+        Type:      orderType,
+        Quantity:  decimal.RequireFromString(quantity),
+    }
+    if price != "" {
+        value := decimal.RequireFromString(price)
+        req.Price = &value
+    }
+    if stopPrice != "" {
+        value := decimal.RequireFromString(stopPrice)
+        req.StopPrice = &value
+    }
+    
+    order, err := orders.PlaceOrder(req)
+    if err != nil {
+        t.Fatalf("place %s %s %s: %v", side, quantity, price, err)
+    }
+    return order
+}
+
+// bookState describes every order on every side of the book, in queue order.
+func bookState(engine *MatchingEngine) string {
+    sh := engine.getShard("BTCUSD")
+    sh.book.mutex.RLock()
+    defer sh.book.mutex.RUnlock()
+    
+    state := fmt.Sprintf("last=%v version=%d", sh.book.LastPrice, sh.book.Sequence)
+    for _, side := range sh.book.sides() {
+        state += "\n"
+        side.Each(func(order *models.Order) bool {
+            state += fmt.Sprintf("[%s %s %v/%v %v %v] ", order.ID[:8], order.Status, order.RemainingQuantity, order.VisibleQuantity, order.Price, order.PriorityAt.UnixNano())
+            return true
+        })
+    }
+    return state
+}
+
+// ledgerState describes every balance in the ledger, in account and asset order.
+func ledgerState(engine *MatchingEngine) string {
+    engine.ledger.mutex.Lock()
+    defer engine.ledger.mutex.Unlock()
+    
+    var lines []string
+    for _, balance := range engine.ledger.balances {
+        lines = append(lines, fmt.Sprintf("\n%s %s %v/%v", balance.AccountID, balance.Asset, balance.Available, balance.Locked))
+    }
+    sort.Strings(lines)
+    return strings.Join(lines, "")
+}
+
+// TestFailedCommitRollsBackBook sends an order that sweeps several levels, refills an iceberg
+// and triggers a stop, makes its commit fail, and checks that nothing it did is left behind.
+func TestFailedCommitRollsBackBook(t *testing.T) {
+    orders, engine, store, unitOfWork := newTestEngine(t)
+    
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    iceberg := &models.PlaceOrderRequest{
+        AccountID:       testAccount,
+        Symbol:          "BTCUSD",
+        Side:            models.SELL,
+        Type:            models.LIMIT,
+        Quantity:        decimal.RequireFromString("3"),
+        DisplayQuantity: decimalPtr("1"),
+        Price:           decimalPtr("100"),
+    }
+    if _, err := orders.PlaceOrder(iceberg); err != nil {
+        t.Fatalf("place iceberg: %v", err)
+    }
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    place(t, orders, models.SELL, models.LIMIT, "101", "", "2")
+    place(t, orders, models.BUY, models.LIMIT, "99", "", "1")
+    place(t, orders, models.BUY, models.STOP_MARKET, "", "100.5", "1")
+    
+    before := bookState(engine) + ledgerState(engine)
+    
+    unitOfWork.fail = true
+    _, err := orders.PlaceOrder(&models.PlaceOrderRequest{
+        AccountID: testAccount,
+        Symbol:    "BTCUSD",
+        Side:      models.BUY,
+        Type:      models.LIMIT,
+        Price:     decimalPtr("101"),
+        Quantity:  decimal.RequireFromString("6"),
+    })
+    if err != models.ErrNotApplied {
+        t.Fatalf("got %v, want ErrNotApplied", err)
+    }
+    
+    if after := bookState(engine) + ledgerState(engine); after != before {
+        t.Fatalf("book changed by a failed command:\nbefore %s\nafter  %s", before, after)
+    }
+    if trades, _ := store.Trades.GetBySymbol("BTCUSD", 100); len(trades) != 0 {
+        t.Fatalf("failed command stored %d trades", len(trades))
+    }
+    
+    // The same order goes through once storage recovers
+    unitOfWork.fail = false
+    taker := place(t, orders, models.BUY, models.LIMIT, "101", "", "6")
+    if taker.Status != models.FILLED {
+        t.Fatalf("taker is %s, want filled", taker.Status)
+    }
+    if trades, _ := store.Trades.GetBySymbol("BTCUSD", 100); len(trades) < 7 {
+        t.Fatalf("got %d trades, want the sweep and the triggered stop", len(trades))
+    }
+}
+
+// TestFailedCommitRollsBackInstrument checks that a halt which fails to commit leaves the symbol
+// trading.
+func TestFailedCommitRollsBackInstrument(t *testing.T) {
+    orders, engine, _, unitOfWork := newTestEngine(t)
+    before, _ := engine.GetInstrument("BTCUSD")
+    
+    unitOfWork.fail = true
+    halted := models.HALTED
+    if _, err := engine.UpdateInstrument("BTCUSD", &models.UpdateInstrumentRequest{Status: &halted}); err != models.ErrNotApplied {
+        t.Fatalf("got %v, want ErrNotApplied", err)
+    }
+    unitOfWork.fail = false
+    
+    after, _ := engine.GetInstrument("BTCUSD")
+    if !reflect.DeepEqual(before, after) {
+        t.Fatalf("instrument changed by a failed update: %+v", after)
+    }
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+}
+
+func decimalPtr(value string) *decimal.Decimal {
+    result := decimal.RequireFromString(value)
+    return &result
+}
+
+// TestLatestTradeAfterSweep sweeps several levels in one command and checks that its last fill is
+// the latest trade, both when listing trades and when a restart without a snapshot restores the
+// last price from storage.
+func TestLatestTradeAfterSweep(t *testing.T) {
+    orders, _, store, _ := newTestEngine(t)
+    
+    for _, price := range []string{"100", "101", "102", "103", "104"} {
+        place(t, orders, models.SELL, models.LIMIT, price, "", "1")
+    }
+    place(t, orders, models.BUY, models.LIMIT, "104", "", "5")
+    
+    trades, err := orders.GetTrades("BTCUSD", 5)
+    if err != nil || len(trades) != 5 {
+        t.Fatalf("trades = %+v, %v", trades, err)
+    }
+    for i, trade := range trades {
+        if want := decimal.NewFromInt(int64(104 - i)); !trade.Price.Equal(want) || trade.FillIndex != 4-i {
+            t.Fatalf("trade %d at %v fill %d, want %v fill %d", i, trade.Price, trade.FillIndex, want, 4-i)
+        }
+    }
+    
+    restarted := NewMatchingEngine(store, nil)
+    if err := restarted.Recover(); err != nil {
+        t.Fatalf("recover: %v", err)
+    }
+    if lastPrice := restarted.getShard("BTCUSD").book.LastPrice; lastPrice == nil || !lastPrice.Equal(decimal.NewFromInt(104)) {
+        t.Fatalf("last price after restart = %v, want 104", lastPrice)
+    }
+}
+
+// TestShardsRunInParallel trades BTCUSD and ETHUSD from many goroutines while ETHUSD is halted and
+// resumed over and over, so that each halt stops a shard with orders still queued. Every order must
+// be answered, and each book must end up consistent with its trades and its watermark. Run it with
+// -race to check that the two shards share nothing unguarded.
+func TestShardsRunInParallel(t *testing.T) {
+    orders, engine, store, _ := newTestEngine(t)
+    instruments := NewInstrumentService(engine)
+    
+    _, err := instruments.CreateInstrument(&models.CreateInstrumentRequest{
+        Symbol:            "ETHUSD",
+        BaseAsset:         "ETH",
+        QuoteAsset:        "USD",
+        PricePrecision:    2,
+        QuantityPrecision: 4,
+    })
+    if err != nil {
+        t.Fatalf("create instrument: %v", err)
+    }
+    if err := engine.Deposit(testAccount, "ETH", decimal.RequireFromString("100")); err != nil {
+        t.Fatalf("deposit ETH: %v", err)
+    }
+    
+    symbols := []string{"BTCUSD", "ETHUSD"}
+    var wg sync.WaitGroup
+    var mutex sync.Mutex
+    placed := map[string]int{}
+    errs := make(chan error, 400)
+    for worker := 0; worker < 8; worker++ {
+        wg.Add(1)
+        go func(worker int) {
+            defer wg.Done()
+            symbol := symbols[worker%2]
+            for i := 0; i < 25; i++ {
+                side := models.BUY
+                if (worker/2+i)%2 == 0 {
+                    side = models.SELL
+                }
+                _, err := orders.PlaceOrder(&models.PlaceOrderRequest{
+                    AccountID: testAccount,
+                    Symbol:    symbol,
+                    Side:      side,
+                    Type:      models.LIMIT,
+                    Price:     decimalPtr(fmt.Sprint(99 + (worker*i)%3)),
+                    Quantity:  decimal.RequireFromString("1"),
+                })
+                if err == models.ErrSymbolNotActive && symbol == "ETHUSD" {
+                    continue
+                }
+                if err != nil {
+                    errs <- fmt.Errorf("place on %s: %w", symbol, err)
+                    continue
+                }
+                mutex.Lock()
+                placed[symbol]++
+                mutex.Unlock()
+            }
+        }(worker)
+    }
+    
+    wg.Add(1)
+    go func() {
+        defer wg.Done()
+        for i := 0; i < 10; i++ {
+            for _, status := range []models.InstrumentStatus{models.HALTED, models.ACTIVE} {
+                status := status
+                if _, err := engine.UpdateInstrument("ETHUSD", &models.UpdateInstrumentRequest{Status: &status}); err != nil {
+                    errs <- fmt.Errorf("set ETHUSD %s: %w", status, err)
+                }
+            }
+        }
+    }()
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        t.Fatalf("%v", err)
+    }
+    
+    watermarks, err := store.Watermark.Get()
+    if err != nil {
+        t.Fatalf("get watermarks: %v", err)
+    }
+    for _, symbol := range symbols {
+        sh := engine.getShard(symbol)
+        if !sh.running() {
+            t.Fatalf("%s shard is not running after resuming", symbol)
+        }
+        if watermark := watermarks[symbol].Sequence; watermark != sh.sequence {
+            t.Fatalf("%s watermark %d, shard applied up to %d", symbol, watermark, sh.sequence)
+        }
+        
+        book := sh.book
+        if bid, ask := book.Bids.Best(), book.Asks.Best(); bid != nil && ask != nil && !bid.Price.LessThan(*ask.Price) {
+            t.Fatalf("%s book is crossed: bid %v, ask %v", symbol, bid.Price, ask.Price)
+        }
+        
+        // Orders that reached the shard during a halt are stored as rejected
+        page, err := orders.ListOrders(&models.OrderQuery{Symbol: symbol, Limit: models.MaxOrderPageSize})
+        if err != nil {
+            t.Fatalf("list %s orders: %v", symbol, err)
+        }
+        accepted := 0
+        filled := map[models.OrderSide]decimal.Decimal{}
+        for _, order := range page.Orders {
+            if order.Status != models.REJECTED {
+                accepted++
+            }
+            filled[order.Side] = filled[order.Side].Add(order.InitialQuantity.Sub(order.RemainingQuantity))
+        }
+        if accepted != placed[symbol] {
+            t.Fatalf("listed %d accepted %s orders, placed %d", accepted, symbol, placed[symbol])
+        }
+        trades, err := store.Trades.GetBySymbol(symbol, 1000)
+        if err != nil {
+            t.Fatalf("%s trades: %v", symbol, err)
+        }
+        traded := decimal.Zero
+        for _, trade := range trades {
+            traded = traded.Add(trade.Quantity)
+        }
+        if !filled[models.BUY].Equal(traded) || !filled[models.SELL].Equal(traded) {
+            t.Fatalf("%s filled %v bought and %v sold, traded %v", symbol, filled[models.BUY], filled[models.SELL], traded)
+        }
+    }
+    if placed["BTCUSD"] != 100 {
+        t.Fatalf("placed %d BTCUSD orders, want all 100", placed["BTCUSD"])
+    }
+    
+    // The account trades with itself, so its totals cannot change
+    want := "\ntrader BTC 100\ntrader ETH 100\ntrader USD 100000"
+    engine.ledger.mutex.Lock()
+    var lines []string
+    for _, balance := range engine.ledger.balances {
+        lines = append(lines, fmt.Sprintf("\n%s %s %v", balance.AccountID, balance.Asset, balance.Available.Add(balance.Locked)))
+    }
+    engine.ledger.mutex.Unlock()
+    sort.Strings(lines)
+    if got := strings.Join(lines, ""); got != want {
+        t.Fatalf("totals:%s\nwant:%s", got, want)
+    }
+}
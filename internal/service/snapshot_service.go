@@ -0,0 +1,20 @@
+package service
+
+import (
+    "order-matching-system/internal/snapshot"
+)
+
+type SnapshotService struct {
+    matchingEngine *MatchingEngine
+}
+
+func NewSnapshotService(matchingEngine *MatchingEngine) *SnapshotService {
+    return &SnapshotService{
+        matchingEngine: matchingEngine,
+    }
+}
+
+// CreateSnapshot writes a snapshot of every order book now rather than waiting for the next interval.
+func (s *SnapshotService) CreateSnapshot() (*snapshot.Info, error) {
+    return s.matchingEngine.TakeSnapshot()
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "order-matching-system/internal/journal"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/repository"
+    "order-matching-system/internal/snapshot"
+    "time"
+)
+
+// errCaughtUp stops a journal read once the replay has reached the watermark.
+var errCaughtUp = errors.New("caught up")
+
+// EnableSnapshots makes the engine restore its books from the newest usable snapshot in
+// snapshots and, if interval is positive, write a new one that often. It must be called before
+// Recover.
+func (me *MatchingEngine) EnableSnapshots(snapshots *snapshot.Store, interval time.Duration) {
+    me.snapshots = snapshots
+    me.snapshotInterval = interval
+}
+
+// TakeSnapshot captures every book and writes them to the snapshot store. Each shard copies its
+// own book between two of its commands; compression and the write happen on the caller.
+func (me *MatchingEngine) TakeSnapshot() (*snapshot.Info, error) {
+    if me.snapshots == nil {
+        return nil, models.ErrSnapshotsDisabled
+    }
+    
+    return me.snapshots.Save(me.captureSnapshot())
+}
+
+func (me *MatchingEngine) runSnapshotter() {
+    ticker := time.NewTicker(me.snapshotInterval)
+    defer ticker.Stop()
+    
+    for range ticker.C {
+        info, err := me.TakeSnapshot()
+        if err != nil {
+            log.Printf("Error writing snapshot: %v", err)
+            continue
+        }
+        log.Printf("Wrote snapshot %d (%d orders, %d bytes)", info.Sequence, info.Orders, info.Size)
+    }
+}
+
+// captureSnapshot asks every shard for a copy of its book and instrument. Shards do not stop for
+// each other, so the books reflect different points in the journal and each records its own.
+func (me *MatchingEngine) captureSnapshot() *snapshot.Snapshot {
+    me.routeMutex.Lock()
+    result := &snapshot.Snapshot{
+        Sequence:  me.sequence,
+        CreatedAt: time.Now().UTC(),
+    }
+    
+    var replies []chan commandResult
+    for _, sh := range me.sortedShards() {
+        cmd := &engineCommand{kind: snapshotCommand, reply: make(chan commandResult, 1)}
+        sh.deliver(cmd)
+        replies = append(replies, cmd.reply)
+    }
+    me.routeMutex.Unlock()
+    
+    for _, reply := range replies {
+        captured := <-reply
+        result.Books = append(result.Books, *captured.book)
+        if captured.instrument != nil {
+            result.Instruments = append(result.Instruments, *captured.instrument)
+        }
+    }
+    return result
+}
+
+func snapshotOrders(orders []*models.Order) []snapshot.Order {
+    result := make([]snapshot.Order, len(orders))
+    for i, order := range orders {
+        result[i] = snapshot.NewOrder(order)
+    }
+    return result
+}
+
+// restoreOrders queues orders saved in book order, so each one lands at the back of its level.
+func restoreOrders(side *bookSide, orders []snapshot.Order) {
+    for i := range orders {
+        side.Insert(orders[i].Restore())
+    }
+}
+
+// restoreSnapshot loads the newest snapshot storage has caught up with and replays the journal
+// from each book to its symbol's watermark. It reports false, leaving the engine empty, when
+// there is no usable snapshot, in which case the books must be loaded from storage instead.
+func (me *MatchingEngine) restoreSnapshot(watermarks map[string]uint64) bool {
+    if me.snapshots == nil {
+        return false
+    }
+    
+    latest, err := me.snapshots.LoadLatest(func(snap *snapshot.Snapshot) error {
+        for i := range snap.Books {
+            book := &snap.Books[i]
+            if applied := snap.AppliedSequence(book); applied > watermarks[book.Symbol] {
+                return fmt.Errorf("%s is at sequence %d, storage only at %d", book.Symbol, applied, watermarks[book.Symbol])
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        log.Printf("Error loading snapshot: %v", err)
+        return false
+    }
+    if latest == nil {
+        return false
+    }
+    
+    me.loadSnapshot(latest)
+    
+    if err := me.catchUp(latest, watermarks); err != nil {
+        log.Printf("Discarding snapshot %d: %v", latest.Sequence, err)
+        me.resetBooks()
+        return false
+    }
+    
+    log.Printf("Restored snapshot %d with %d books", latest.Sequence, len(latest.Books))
+    return true
+}
+
+func (me *MatchingEngine) loadSnapshot(snap *snapshot.Snapshot) {
+    for i := range snap.Instruments {
+        instrument := snap.Instruments[i]
+        me.setInstrument(&instrument)
+        me.addShard(instrument.Symbol).sequence = snap.Sequence
+    }
+    
+    for i := range snap.Books {
+        book := &snap.Books[i]
+        sh := me.addShard(book.Symbol)
+        restoreOrders(sh.book.Bids, book.Bids)
+        restoreOrders(sh.book.Asks, book.Asks)
+        restoreOrders(sh.book.BuyStops, book.BuyStops)
+        restoreOrders(sh.book.SellStops, book.SellStops)
+        sh.book.LastPrice = book.LastPrice
+        sh.book.Sequence = book.Sequence
+        sh.sequence = snap.AppliedSequence(book)
+    }
+}
+
+func (me *MatchingEngine) resetBooks() {
+    me.mutex.Lock()
+    defer me.mutex.Unlock()
+    
+    me.shards = make(map[string]*shard)
+    me.instruments = make(map[string]*models.Instrument)
+}
+
+// catchUp brings books restored from a snapshot forward to their watermarks. Storage already holds
+// the effect of those commands, so they are replayed against a scratch store seeded with the
+// snapshot's instruments and resting orders and their writes are discarded.
+func (me *MatchingEngine) catchUp(snap *snapshot.Snapshot, watermarks map[string]uint64) error {
+    var target uint64
+    for _, watermark := range watermarks {
+        if watermark > target {
+            target = watermark
+        }
+    }
+    if me.oldestShardSequence() >= target {
+        return nil
+    }
+    
+    if me.journal == nil {
+        return fmt.Errorf("no journal to replay from sequence %d", me.oldestShardSequence())
+    }
+    
+    scratch, err := me.scratchStore(snap)
+    if err != nil {
+        return err
+    }
+    
+    // Shards created during the catch-up, for symbols listed after the snapshot, pick this up too
+    unitOfWork := me.unitOfWork
+    me.unitOfWork = scratch.UnitOfWork
+    for _, sh := range me.sortedShards() {
+        sh.unitOfWork = scratch.UnitOfWork
+    }
+    defer func() {
+        me.unitOfWork = unitOfWork
+        for _, sh := range me.sortedShards() {
+            sh.unitOfWork = unitOfWork
+        }
+    }()
+    
+    last := me.oldestShardSequence()
+    err = me.journal.ReadFrom(last, func(record *journal.Record) error {
+        if record.Sequence > target {
+            return errCaughtUp
+        }
+        
+        if _, _, err := me.replay(record, watermarks); err != nil {
+            return err
+        }
+        last = record.Sequence
+        return nil
+    })
+    if err != nil && !errors.Is(err, errCaughtUp) {
+        return err
+    }
+    
+    if last < target {
+        return fmt.Errorf("journal ends at sequence %d, storage is at %d", last, target)
+    }
+    return nil
+}
+
+func (me *MatchingEngine) scratchStore(snap *snapshot.Snapshot) (*repository.Store, error) {
+    scratch := repository.NewMemoryStore()
+    
+    for i := range snap.Instruments {
+        if err := scratch.Instruments.Create(&snap.Instruments[i]); err != nil {
+            return nil, err
+        }
+    }
+    
+    for _, sh := range me.sortedShards() {
+        for _, side := range sh.book.sides() {
+            for _, order := range side.Orders() {
+                if err := scratch.Orders.Create(order); err != nil {
+                    return nil, err
+                }
+            }
+        }
+    }
+    return scratch, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+    "order-matching-system/internal/journal"
+    "order-matching-system/internal/models"
+    "order-matching-system/internal/snapshot"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// TestSnapshotCatchUpMatchesReplay takes two snapshots between orders, trades, amends and cancels,
+// and checks that restoring either one and catching up on the journal tail builds the same book
+// as replaying the whole journal. A corrupt newest snapshot falls back to the older one, and with
+// none usable the engine is told to load from storage.
+func TestSnapshotCatchUpMatchesReplay(t *testing.T) {
+    dir := t.TempDir()
+    store := newTestStore(t)
+    path := filepath.Join(dir, "journal.log")
+    snapshots, err := snapshot.NewStore(filepath.Join(dir, "snapshots"), 3)
+    if err != nil {
+        t.Fatalf("snapshot store: %v", err)
+    }
+    
+    commandJournal, err := journal.Open(path)
+    if err != nil {
+        t.Fatalf("open journal: %v", err)
+    }
+    engine := NewMatchingEngine(store, commandJournal)
+    engine.EnableSnapshots(snapshots, 0)
+    if err := engine.Recover(); err != nil {
+        t.Fatalf("recover: %v", err)
+    }
+    engine.Start()
+    orders := NewOrderService(store.Orders, store.Trades, store.OrderEvents, engine)
+    fund(t, engine)
+    
+    takeSnapshot := func() *snapshot.Info {
+        info, err := engine.TakeSnapshot()
+        if err != nil {
+            t.Fatalf("take snapshot: %v", err)
+        }
+        return info
+    }
+    
+    place(t, orders, models.SELL, models.LIMIT, "100", "", "1")
+    resting := place(t, orders, models.SELL, models.LIMIT, "102", "", "2")
+    place(t, orders, models.BUY, models.LIMIT, "98", "", "1")
+    older := takeSnapshot()
+    
+    place(t, orders, models.BUY, models.STOP_LIMIT, "101", "100.5", "1")
+    place(t, orders, models.BUY, models.LIMIT, "100", "", "0.5")
+    if _, err := orders.AmendOrder(resting.ID, &models.AmendOrderRequest{Price: decimalPtr("101")}); err != nil {
+        t.Fatalf("amend: %v", err)
+    }
+    newer := takeSnapshot()
+    
+    canceled := place(t, orders, models.BUY, models.LIMIT, "97", "", "1")
+    if _, err := orders.CancelOrder(canceled.ID); err != nil {
+        t.Fatalf("cancel: %v", err)
+    }
+    place(t, orders, models.BUY, models.LIMIT, "100", "", "1")
+    place(t, orders, models.SELL, models.LIMIT, "103", "", "1")
+    commandJournal.Close()
+    
+    replayed := NewMatchingEngine(newTestStore(t), nil)
+    if err := replayed.Recover(); err != nil {
+        t.Fatalf("recover: %v", err)
+    }
+    if err := replayed.Replay(path, func(*models.Trade) error { return nil }); err != nil {
+        t.Fatalf("replay: %v", err)
+    }
+    want := bookState(replayed)
+    if live := bookState(engine); live != want {
+        t.Fatalf("replayed book:\n%s\nlive book:\n%s", want, live)
+    }
+    
+    stored, err := store.Watermark.Get()
+    if err != nil {
+        t.Fatalf("get watermarks: %v", err)
+    }
+    watermarks := make(map[string]uint64)
+    for symbol, watermark := range stored {
+        watermarks[symbol] = watermark.Sequence
+    }
+    restore := func() (*MatchingEngine, bool) {
+        commandJournal, err := journal.Open(path)
+        if err != nil {
+            t.Fatalf("open journal: %v", err)
+        }
+        t.Cleanup(func() { commandJournal.Close() })
+        
+        restored := NewMatchingEngine(store, commandJournal)
+        restored.EnableSnapshots(snapshots, 0)
+        return restored, restored.restoreSnapshot(watermarks)
+    }
+    
+    for _, step := range []struct {
+        name    string
+        corrupt *snapshot.Info
+    }{
+        {"newest snapshot", nil},
+        {"older snapshot", newer},
+    } {
+        if step.corrupt != nil {
+            data, err := os.ReadFile(step.corrupt.Path)
+            if err != nil {
+                t.Fatalf("read snapshot: %v", err)
+            }
+            data[len(data)-1] ^= 0xff
+            if err := os.WriteFile(step.corrupt.Path, data, 0o644); err != nil {
+                t.Fatalf("write snapshot: %v", err)
+            }
+        }
+        
+        restored, ok := restore()
+        if !ok {
+            t.Fatalf("%s: no snapshot restored", step.name)
+        }
+        if got := bookState(restored); got != want {
+            t.Fatalf("%s: caught-up book:\n%s\nreplayed book:\n%s", step.name, got, want)
+        }
+    }
+    
+    if err := os.Remove(older.Path); err != nil {
+        t.Fatalf("remove snapshot: %v", err)
+    }
+    if _, ok := restore(); ok {
+        t.Fatalf("restored a snapshot when none was usable")
+    }
+}
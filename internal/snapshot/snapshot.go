@@ -0,0 +1,281 @@
+package snapshot
+
+import (
+    "bytes"
+    "compress/gzip"
+    "crypto/sha256"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "order-matching-system/internal/models"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+// magic starts every snapshot file and names its format version.
+const magic = "OMSSNAP1"
+
+const (
+    filePrefix = "snapshot-"
+    fileSuffix = ".json.gz"
+)
+
+var ErrChecksum = errors.New("snapshot checksum mismatch")
+
+// Snapshot is the state of every order book and the symbol registry. Books are captured by their
+// own shards, so each one records the journal sequence it reflects; Sequence is the last sequence
+// accepted when the capture began.
+type Snapshot struct {
+    Sequence    uint64              `json:"sequence"`
+    CreatedAt   time.Time           `json:"created_at"`
+    Instruments []models.Instrument `json:"instruments"`
+    Books       []Book              `json:"books"`
+}
+
+// Book holds one symbol's resting orders in book order, so loading it needs no sorting.
+type Book struct {
+    Symbol          string           `json:"symbol"`
+    Sequence        uint64           `json:"sequence"`
+    JournalSequence uint64           `json:"journal_sequence,omitempty"` // Last command applied to the book
+    LastPrice       *decimal.Decimal `json:"last_price,omitempty"`
+    Bids            []Order          `json:"bids"`
+    Asks            []Order          `json:"asks"`
+    BuyStops        []Order          `json:"buy_stops"`
+    SellStops       []Order          `json:"sell_stops"`
+}
+
+// AppliedSequence returns the journal sequence the book reflects. Snapshots written before books
+// were captured separately reflect the snapshot's own sequence.
+func (s *Snapshot) AppliedSequence(book *Book) uint64 {
+    if book.JournalSequence == 0 {
+        return s.Sequence
+    }
+    return book.JournalSequence
+}
+
+// Order is a resting order together with its queue priority and request hash, which the API form
+// omits.
+type Order struct {
+    models.Order
+    PriorityAt  time.Time `json:"priority_at"`
+    RequestHash string    `json:"request_hash,omitempty"`
+}
+
+func NewOrder(order *models.Order) Order {
+    return Order{Order: *order, PriorityAt: order.PriorityAt, RequestHash: order.RequestHash}
+}
+
+// Restore returns the order with its queue priority and request hash set.
+func (o *Order) Restore() *models.Order {
+    order := o.Order
+    order.PriorityAt = o.PriorityAt
+    order.RequestHash = o.RequestHash
+    return &order
+}
+
+// Info describes a snapshot file.
+type Info struct {
+    Sequence  uint64    `json:"sequence"`
+    CreatedAt time.Time `json:"created_at"`
+    Path      string    `json:"path"`
+    Size      int64     `json:"size"`
+    Orders    int       `json:"orders"`
+}
+
+// Store keeps snapshots in a directory, one gzip-compressed JSON file per sequence number behind
+// a header of the format magic and the SHA-256 of the compressed payload. Only the newest files
+// are kept, so a damaged latest snapshot can fall back to an earlier one.
+type Store struct {
+    dir    string
+    retain int
+}
+
+func NewStore(dir string, retain int) (*Store, error) {
+    if retain < 1 {
+        retain = 1
+    }
+    
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+    }
+    
+    return &Store{dir: dir, retain: retain}, nil
+}
+
+// Save writes a snapshot atomically and removes snapshots beyond the retention count.
+func (s *Store) Save(snapshot *Snapshot) (*Info, error) {
+    var payload bytes.Buffer
+    writer := gzip.NewWriter(&payload)
+    if err := json.NewEncoder(writer).Encode(snapshot); err != nil {
+        return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+    }
+    if err := writer.Close(); err != nil {
+        return nil, fmt.Errorf("failed to compress snapshot: %w", err)
+    }
+    
+    checksum := sha256.Sum256(payload.Bytes())
+    path := filepath.Join(s.dir, fileName(snapshot.Sequence))
+    
+    temp, err := os.CreateTemp(s.dir, ".snapshot-*")
+    if err != nil {
+        return nil, fmt.Errorf("failed to create snapshot file: %w", err)
+    }
+    defer os.Remove(temp.Name())
+    
+    for _, part := range [][]byte{[]byte(magic), checksum[:], payload.Bytes()} {
+        if _, err := temp.Write(part); err != nil {
+            temp.Close()
+            return nil, fmt.Errorf("failed to write snapshot: %w", err)
+        }
+    }
+    
+    if err := temp.Sync(); err != nil {
+        temp.Close()
+        return nil, fmt.Errorf("failed to sync snapshot: %w", err)
+    }
+    if err := temp.Close(); err != nil {
+        return nil, err
+    }
+    
+    if err := os.Rename(temp.Name(), path); err != nil {
+        return nil, fmt.Errorf("failed to install snapshot: %w", err)
+    }
+    syncDir(s.dir)
+    
+    s.prune()
+    
+    orders := 0
+    for _, book := range snapshot.Books {
+        orders += len(book.Bids) + len(book.Asks) + len(book.BuyStops) + len(book.SellStops)
+    }
+    
+    return &Info{
+        Sequence:  snapshot.Sequence,
+        CreatedAt: snapshot.CreatedAt,
+        Path:      path,
+        Size:      int64(len(magic) + len(checksum) + payload.Len()),
+        Orders:    orders,
+    }, nil
+}
+
+// LoadLatest returns the newest snapshot whose checksum verifies and that accept does not refuse,
+// skipping damaged files. It returns nil when there is no usable snapshot.
+func (s *Store) LoadLatest(accept func(*Snapshot) error) (*Snapshot, error) {
+    sequences, err := s.sequences()
+    if err != nil {
+        return nil, err
+    }
+    
+    for i := len(sequences) - 1; i >= 0; i-- {
+        path := filepath.Join(s.dir, fileName(sequences[i]))
+        snapshot, err := Load(path)
+        if err == nil {
+            err = accept(snapshot)
+        }
+        if err != nil {
+            log.Printf("Skipping snapshot %s: %v", path, err)
+            continue
+        }
+        return snapshot, nil
+    }
+    return nil, nil
+}
+
+// Load reads and verifies one snapshot file.
+func Load(path string) (*Snapshot, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    
+    headerSize := len(magic) + sha256.Size
+    if len(data) < headerSize || string(data[:len(magic)]) != magic {
+        return nil, fmt.Errorf("%w: not a snapshot file", ErrChecksum)
+    }
+    
+    payload := data[headerSize:]
+    checksum := sha256.Sum256(payload)
+    if !bytes.Equal(checksum[:], data[len(magic):headerSize]) {
+        return nil, ErrChecksum
+    }
+    
+    reader, err := gzip.NewReader(bytes.NewReader(payload))
+    if err != nil {
+        return nil, fmt.Errorf("failed to decompress snapshot: %w", err)
+    }
+    defer reader.Close()
+    
+    var snapshot Snapshot
+    if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+        return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+    }
+    
+    // Drain to the end so gzip verifies its own trailer as well
+    if _, err := io.Copy(io.Discard, reader); err != nil {
+        return nil, fmt.Errorf("failed to decompress snapshot: %w", err)
+    }
+    return &snapshot, nil
+}
+
+// sequences lists the sequence numbers of the snapshot files in the directory, oldest first.
+func (s *Store) sequences() ([]uint64, error) {
+    entries, err := os.ReadDir(s.dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to list snapshots: %w", err)
+    }
+    
+    var sequences []uint64
+    for _, entry := range entries {
+        name := entry.Name()
+        if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+            continue
+        }
+        
+        sequence, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix), 10, 64)
+        if err != nil {
+            continue
+        }
+        sequences = append(sequences, sequence)
+    }
+    
+    sort.Slice(sequences, func(i, j int) bool {
+        return sequences[i] < sequences[j]
+    })
+    return sequences, nil
+}
+
+func (s *Store) prune() {
+    sequences, err := s.sequences()
+    if err != nil {
+        log.Printf("Error pruning snapshots: %v", err)
+        return
+    }
+    
+    for len(sequences) > s.retain {
+        path := filepath.Join(s.dir, fileName(sequences[0]))
+        if err := os.Remove(path); err != nil {
+            log.Printf("Error removing snapshot %s: %v", path, err)
+        }
+        sequences = sequences[1:]
+    }
+}
+
+// fileName zero-pads the sequence so that files also sort by name.
+func fileName(sequence uint64) string {
+    return fmt.Sprintf("%s%020d%s", filePrefix, sequence, fileSuffix)
+}
+
+func syncDir(dir string) {
+    if d, err := os.Open(dir); err == nil {
+        d.Sync()
+        d.Close()
+    }
+}
@@ -0,0 +1,161 @@
+package snapshot
+
+import (
+    "errors"
+    "order-matching-system/internal/models"
+    "os"
+    "testing"
+    "time"
+    
+    "github.com/shopspring/decimal"
+)
+
+func testSnapshot(sequence uint64) *Snapshot {
+    price := decimal.RequireFromString("100")
+    order := &models.Order{
+        ID:                "order",
+        Symbol:            "BTCUSD",
+        Side:              models.BUY,
+        Type:              models.LIMIT,
+        Price:             &price,
+        InitialQuantity:   decimal.RequireFromString("2"),
+        RemainingQuantity: decimal.RequireFromString("1.5"),
+        Status:            models.PARTIAL,
+        CreatedAt:         time.Unix(1700000000, 0).UTC(),
+        PriorityAt:        time.Unix(1700000001, 5000).UTC(),
+        RequestHash:       "hash",
+    }
+    
+    return &Snapshot{
+        Sequence:  sequence,
+        CreatedAt: time.Unix(int64(sequence), 0).UTC(),
+        Books: []Book{{
+            Symbol:          "BTCUSD",
+            Sequence:        sequence * 10,
+            JournalSequence: sequence,
+            LastPrice:       &price,
+            Bids:            []Order{NewOrder(order)},
+        }},
+    }
+}
+
+func saveAll(t *testing.T, store *Store, sequences ...uint64) []*Info {
+    var infos []*Info
+    for _, sequence := range sequences {
+        info, err := store.Save(testSnapshot(sequence))
+        if err != nil {
+            t.Fatalf("save %d: %v", sequence, err)
+        }
+        infos = append(infos, info)
+    }
+    return infos
+}
+
+func loadLatest(t *testing.T, store *Store, accept func(*Snapshot) error) uint64 {
+    if accept == nil {
+        accept = func(*Snapshot) error { return nil }
+    }
+    
+    snapshot, err := store.LoadLatest(accept)
+    if err != nil {
+        t.Fatalf("load latest: %v", err)
+    }
+    if snapshot == nil {
+        return 0
+    }
+    return snapshot.Sequence
+}
+
+func TestSaveAndLoad(t *testing.T) {
+    store, err := NewStore(t.TempDir(), 2)
+    if err != nil {
+        t.Fatalf("new store: %v", err)
+    }
+    infos := saveAll(t, store, 3, 7, 12)
+    
+    // Only the newest two are kept
+    if _, err := os.Stat(infos[0].Path); !os.IsNotExist(err) {
+        t.Fatalf("oldest snapshot was not pruned: %v", err)
+    }
+    if infos[2].Orders != 1 {
+        t.Fatalf("info reports %d orders, want 1", infos[2].Orders)
+    }
+    
+    snapshot, err := Load(infos[2].Path)
+    if err != nil {
+        t.Fatalf("load: %v", err)
+    }
+    book := &snapshot.Books[0]
+    if snapshot.Sequence != 12 || snapshot.AppliedSequence(book) != 12 || book.Sequence != 120 {
+        t.Fatalf("loaded snapshot %d, book at %d, version %d", snapshot.Sequence, snapshot.AppliedSequence(book), book.Sequence)
+    }
+    
+    // Queue priority and the request hash survive, although the API form of an order omits them
+    restored := book.Bids[0].Restore()
+    want := testSnapshot(12).Books[0].Bids[0].Restore()
+    if !restored.PriorityAt.Equal(want.PriorityAt) || restored.RequestHash != want.RequestHash || !restored.RemainingQuantity.Equal(want.RemainingQuantity) {
+        t.Fatalf("restored order %+v, want %+v", restored, want)
+    }
+    
+    // Books written before each one recorded its own sequence reflect the snapshot's
+    book.JournalSequence = 0
+    if got := snapshot.AppliedSequence(book); got != 12 {
+        t.Fatalf("applied sequence of an old book %d, want 12", got)
+    }
+}
+
+// TestLoadLatestFallsBack damages the newest snapshots in different ways and checks that each is
+// skipped in favour of the one before it.
+func TestLoadLatestFallsBack(t *testing.T) {
+    store, err := NewStore(t.TempDir(), 4)
+    if err != nil {
+        t.Fatalf("new store: %v", err)
+    }
+    infos := saveAll(t, store, 1, 2, 3, 4)
+    
+    if got := loadLatest(t, store, nil); got != 4 {
+        t.Fatalf("latest %d, want 4", got)
+    }
+    
+    // A flipped byte in the payload fails the checksum
+    data, err := os.ReadFile(infos[3].Path)
+    if err != nil {
+        t.Fatalf("read: %v", err)
+    }
+    data[len(data)-10] ^= 0xff
+    if err := os.WriteFile(infos[3].Path, data, 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    if _, err := Load(infos[3].Path); !errors.Is(err, ErrChecksum) {
+        t.Fatalf("load corrupt snapshot: got %v, want ErrChecksum", err)
+    }
+    if got := loadLatest(t, store, nil); got != 3 {
+        t.Fatalf("latest after corrupting 4: %d, want 3", got)
+    }
+    
+    // A file cut short, as by a crash without the atomic rename, is not a snapshot at all
+    if err := os.WriteFile(infos[2].Path, []byte(magic), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    if got := loadLatest(t, store, nil); got != 2 {
+        t.Fatalf("latest after truncating 3: %d, want 2", got)
+    }
+    
+    // A snapshot the caller refuses, such as one ahead of storage, is passed over too
+    refuse := func(snapshot *Snapshot) error {
+        if snapshot.Sequence > 1 {
+            return errors.New("ahead of storage")
+        }
+        return nil
+    }
+    if got := loadLatest(t, store, refuse); got != 1 {
+        t.Fatalf("latest accepted: %d, want 1", got)
+    }
+    
+    if err := os.Remove(infos[0].Path); err != nil {
+        t.Fatalf("remove: %v", err)
+    }
+    if got := loadLatest(t, store, refuse); got != 0 {
+        t.Fatalf("latest with none usable: %d, want none", got)
+    }
+}